@@ -111,5 +111,6 @@ func SwapsToArbitrage(swaps []*models.SwapCH) *models.ArbitrageCH {
 		TraceIDs:        common.Map(swaps, func(swap *models.SwapCH) string { return swap.TraceID }),
 		Dexes:           common.Map(swaps, func(swap *models.SwapCH) string { return swap.Dex }),
 		Senders:         common.Map(swaps, func(swap *models.SwapCH) string { return swap.Sender }),
+		CatchTime:       swaps[len(swaps)-1].CatchTime,
 	}
 }
@@ -1,3 +1,7 @@
+//go:build live
+
+// The tests fetch traces and jettons from tonapi and liteservers: go test -tags live ./arbitrage
+
 package arbitrage
 
 import (
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/tonkeeper/tonapi-go"
+	"log"
+	"regexp"
+	"sort"
+	"time"
+	"tondexer/arbitrage"
+	"tondexer/common"
+	"tondexer/core"
+	"tondexer/jettons"
+	"tondexer/mev"
+	"tondexer/models"
+	"tondexer/persistence"
+	"tondexer/pipeline"
+	"tondexer/registry"
+	"tondexer/spool"
+	"tondexer/traces"
+)
+
+const transactionsPageSize = 100
+
+type Config struct {
+	ConsoleToken string `yaml:"console_token" env:"CONSOLE_TOKEN" env-default:""`
+	DbHost       string `yaml:"db_host" env:"DB_HOST" env-default:"localhost"`
+	DbPort       uint   `yaml:"db_port" env:"DB_PORT" env-default:"9000"`
+	DbUser       string `yaml:"db_user" env:"DB_USER" env-default:"default"`
+	DbPassword   string `yaml:"db_password" env:"DB_PASSWORD" env-default:""`
+	DbName       string `yaml:"db_name" env:"DB_NAME" env-default:"default"`
+	SpoolDir     string `yaml:"spool_dir" env:"SPOOL_DIR" env-default:".spool"`
+	// TraceArchiveDir of the listener, backfilled traces are archived for replays as well
+	TraceArchiveDir string `yaml:"trace_archive_dir" env:"TRACE_ARCHIVE_DIR" env-default:""`
+}
+
+// BackfillRange bounds are inclusive, zero values mean unbounded
+type BackfillRange struct {
+	FromTime time.Time
+	ToTime   time.Time
+	FromLt   uint64
+	ToLt     uint64
+}
+
+func (r *BackfillRange) isOlder(transaction *tonapi.Transaction) bool {
+	return (r.FromLt != 0 && uint64(transaction.Lt) < r.FromLt) ||
+		(!r.FromTime.IsZero() && time.Unix(transaction.Utime, 0).Before(r.FromTime))
+}
+
+func (r *BackfillRange) isNewer(transaction *tonapi.Transaction) bool {
+	return (r.ToLt != 0 && uint64(transaction.Lt) > r.ToLt) ||
+		(!r.ToTime.IsZero() && time.Unix(transaction.Utime, 0).After(r.ToTime))
+}
+
+func (r *BackfillRange) jobName() string {
+	return fmt.Sprintf("range_%v_%v_%v_%v", r.FromLt, r.ToLt, unixOrZero(r.FromTime), unixOrZero(r.ToTime))
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// AccountTransactions pages transactions of an account from the newest one, core.TonConsoleApi in production
+type AccountTransactions interface {
+	AccountTransactions(account string, beforeLt uint64, limit int32) ([]tonapi.Transaction, error)
+}
+
+// Traces resolves a transaction into its whole trace, core.TonConsoleApi in production
+type Traces interface {
+	GetTraceByHash(hash string) (*tonapi.Trace, error)
+}
+
+type Backfiller struct {
+	Transactions AccountTransactions
+	Traces       Traces
+	Store        persistence.Store
+	// SaveCheckpoint and StoredHashes are clickhouse reads and writes the Store doesn't cover
+	SaveCheckpoint func(checkpoint *models.BackfillCheckpoint) error
+	StoredHashes   func(from time.Time, to time.Time) ([]string, error)
+	TokenCaches    *jettons.TokenCaches
+	Range          BackfillRange
+	Job            string
+	// Archive is nil unless trace_archive_dir is set
+	Archive *traces.Archive
+
+	seenTransactions *core.EvictableSet[string]
+	savedHashes      *core.EvictableSet[string]
+}
+
+func newBackfiller(transactions AccountTransactions, traceSource Traces, store persistence.Store, tokenCaches *jettons.TokenCaches, backfillRange BackfillRange, job string) *Backfiller {
+	return &Backfiller{
+		Transactions:     transactions,
+		Traces:           traceSource,
+		Store:            store,
+		TokenCaches:      tokenCaches,
+		Range:            backfillRange,
+		Job:              job,
+		seenTransactions: core.NewEvictableSet[string](30 * time.Minute),
+		savedHashes:      core.NewEvictableSet[string](30 * time.Minute),
+	}
+}
+
+func (b *Backfiller) writeCheckpoint(account string, lt uint64, done bool) {
+	checkpoint := &models.BackfillCheckpoint{
+		Job:     b.Job,
+		Account: account,
+		Lt:      lt,
+		Done:    done,
+		Time:    time.Now(),
+	}
+	if e := b.SaveCheckpoint(checkpoint); e != nil {
+		log.Printf("Warning: Unable to save checkpoint for %v: %v \n", account, e)
+	}
+}
+
+// markStoredHashes adds hashes of swaps already stored in clickhouse around the time of the given swaps
+func (b *Backfiller) markStoredHashes(swaps []*models.SwapCH) error {
+	if len(swaps) == 0 {
+		return nil
+	}
+	from, to := swaps[0].Time, swaps[0].Time
+	for _, swap := range swaps {
+		if swap.Time.Before(from) {
+			from = swap.Time
+		}
+		if swap.Time.After(to) {
+			to = swap.Time
+		}
+	}
+	stored, e := b.StoredHashes(from, to)
+	if e != nil {
+		return e
+	}
+	for _, hash := range stored {
+		b.savedHashes.Add(hash)
+	}
+	return nil
+}
+
+func (b *Backfiller) backfillAccount(account string, checkpoint *models.BackfillCheckpoint) error {
+	if checkpoint != nil && checkpoint.Done {
+		log.Printf("Account %v is already backfilled \n", account)
+		return nil
+	}
+
+	var beforeLt uint64
+	if b.Range.ToLt != 0 {
+		beforeLt = b.Range.ToLt + 1
+	}
+	if checkpoint != nil {
+		log.Printf("Resuming %v from lt %v \n", account, checkpoint.Lt)
+		beforeLt = checkpoint.Lt
+	}
+
+	processedChModels := core.NewEvictableSet[*models.SwapCH](15 * time.Minute)
+	sandwichCandidates := core.NewEvictableSet[*models.SwapCH](15 * time.Minute)
+	for {
+		transactions, e := b.Transactions.AccountTransactions(account, beforeLt, transactionsPageSize)
+		if e != nil {
+			return e
+		}
+		if len(transactions) == 0 {
+			b.writeCheckpoint(account, beforeLt, true)
+			return nil
+		}
+
+		finished := false
+		var swaps []*models.SwapCH
+		for _, transaction := range transactions {
+			if b.Range.isOlder(&transaction) {
+				finished = true
+				break
+			}
+			if b.Range.isNewer(&transaction) || b.seenTransactions.Exists(transaction.Hash) {
+				continue
+			}
+			trace, e := b.Traces.GetTraceByHash(transaction.Hash)
+			if e != nil {
+				// Not moving the checkpoint, the page will be processed again on restart
+				return e
+			}
+			if b.Archive != nil {
+				if e := b.Archive.Write(trace); e != nil {
+					log.Printf("Warning: Unable to archive trace %v: %v \n", trace.Transaction.Hash, e)
+				}
+			}
+			for _, traceTransaction := range core.GetAllTransactionsFromTrace(trace) {
+				b.seenTransactions.Add(traceTransaction.Hash)
+			}
+			swaps = append(swaps, pipeline.ExtractSwapsFromTrace(trace, b.TokenCaches)...)
+		}
+
+		if e := b.markStoredHashes(swaps); e != nil {
+			return e
+		}
+		newSwaps := pipeline.FilterNewSwaps(swaps, b.savedHashes)
+		if len(newSwaps) > 0 {
+			if e := b.Store.SaveSwaps(newSwaps); e != nil {
+				return e
+			}
+			if e := b.Store.SaveTrades(pipeline.BuildTrades(newSwaps)); e != nil {
+				return e
+			}
+		}
+
+		for _, model := range newSwaps {
+			processedChModels.Add(model)
+			sandwichCandidates.Add(model)
+		}
+		arbitrages := arbitrage.FindArbitragesAndDeleteThemFromSetGeneric(processedChModels)
+		if len(arbitrages) > 0 {
+			if e := b.Store.SaveArbitrages(arbitrages); e != nil {
+				log.Printf("Warning: Unable to save arbitrages %v\n", e)
+			}
+		}
+		processedChModels.Evict()
+		sandwiches := mev.FindSandwichesAndDeleteThemFromSet(sandwichCandidates, mev.DefaultLtWindow)
+		if len(sandwiches) > 0 {
+			if e := b.Store.SaveMevEvents(sandwiches); e != nil {
+				log.Printf("Warning: Unable to save mev events %v\n", e)
+			}
+		}
+		sandwichCandidates.Evict()
+		b.seenTransactions.Evict()
+		b.savedHashes.Evict()
+
+		beforeLt = uint64(transactions[len(transactions)-1].Lt)
+		log.Printf("%v: %v new swaps, %v arbitrages, %v sandwiches before lt %v \n", account, len(newSwaps), len(arbitrages), len(sandwiches), beforeLt)
+		b.writeCheckpoint(account, beforeLt, finished)
+		if finished {
+			return nil
+		}
+	}
+}
+
+func main() {
+	configPath := flag.String("config", "", "path to the config file")
+	job := flag.String("job", "", "checkpoint name, derived from the range if empty")
+	from := flag.String("from", "", "start of the range, unix seconds or RFC3339")
+	to := flag.String("to", "", "end of the range, unix seconds or RFC3339")
+	fromLt := flag.Uint64("from-lt", 0, "start of the range, logical time")
+	toLt := flag.Uint64("to-lt", 0, "end of the range, logical time")
+	flag.Parse()
+
+	var cfg Config
+	if err := cleanenv.ReadConfig(*configPath, &cfg); err != nil {
+		panic(err)
+	}
+
+	fromTime, e := core.ParseTime(*from)
+	if e != nil {
+		panic(e)
+	}
+	toTime, e := core.ParseTime(*to)
+	if e != nil {
+		panic(e)
+	}
+	backfillRange := BackfillRange{FromTime: fromTime, ToTime: toTime, FromLt: *fromLt, ToLt: *toLt}
+	if backfillRange.FromTime.IsZero() && backfillRange.FromLt == 0 {
+		panic(errors.New("either -from or -from-lt must be set"))
+	}
+
+	jobName := *job
+	if jobName == "" {
+		jobName = backfillRange.jobName()
+	}
+	if !regexp.MustCompile(`^[A-Za-z0-9_\-]+$`).MatchString(jobName) {
+		panic(fmt.Errorf("invalid job name %v", jobName))
+	}
+
+	dbConfig := core.DbConfig{
+		DbHost:     cfg.DbHost,
+		DbPort:     cfg.DbPort,
+		DbUser:     cfg.DbUser,
+		DbPassword: cfg.DbPassword,
+		DbName:     cfg.DbName,
+	}
+
+	freeConsoleClient, _ := tonapi.New() // free one for the rates
+	freeConsoleApi := core.TonConsoleApi{Client: freeConsoleClient}
+	deadLetter, e := spool.New(cfg.SpoolDir)
+	if e != nil {
+		panic(e)
+	}
+	store := persistence.NewClickhouseStore(&dbConfig)
+	tokenCaches, e := jettons.InitTokenCaches(store, &freeConsoleApi, deadLetter)
+	if e != nil {
+		panic(e)
+	}
+
+	var archive *traces.Archive
+	if cfg.TraceArchiveDir != "" {
+		if archive, e = traces.NewArchive(cfg.TraceArchiveDir); e != nil {
+			panic(e)
+		}
+	}
+
+	client, _ := tonapi.New(tonapi.WithToken(cfg.ConsoleToken))
+	consoleApi := &core.TonConsoleApi{Client: client}
+	backfiller := newBackfiller(consoleApi, consoleApi, store, tokenCaches, backfillRange, jobName)
+	backfiller.Archive = archive
+	backfiller.SaveCheckpoint = func(checkpoint *models.BackfillCheckpoint) error {
+		return persistence.WriteBackfillCheckpoints(&dbConfig, []*models.BackfillCheckpoint{checkpoint})
+	}
+	backfiller.StoredHashes = func(from time.Time, to time.Time) ([]string, error) {
+		stored, e := persistence.ReadArrayFromClickhouse[persistence.SwapHash](&dbConfig, persistence.SwapHashesSqlQuery(&dbConfig, from, to))
+		return common.Map(stored, func(hash persistence.SwapHash) string { return hash.Hash }), e
+	}
+
+	checkpoints, e := persistence.ReadBackfillCheckpoints(&dbConfig, jobName)
+	if e != nil {
+		panic(e)
+	}
+	checkpointByAccount := map[string]*models.BackfillCheckpoint{}
+	for i := range checkpoints {
+		checkpointByAccount[checkpoints[i].Account] = &checkpoints[i]
+	}
+
+	dexRegistry, e := registry.LoadRegistry(store, nil)
+	if e != nil {
+		panic(e)
+	}
+	accounts := dexRegistry.Accounts()
+	sort.Strings(accounts)
+	log.Printf("Backfill job %v for %v accounts \n", jobName, len(accounts))
+	for _, account := range accounts {
+		if e := backfiller.backfillAccount(account, checkpointByAccount[account]); e != nil {
+			tokenCaches.Close()
+			log.Fatalf("Backfill of %v failed, rerun to continue from the checkpoint: %v \n", account, e)
+		}
+	}
+	tokenCaches.Close()
+	log.Printf("Backfill job %v is finished \n", jobName)
+}
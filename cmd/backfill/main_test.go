@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tonkeeper/tonapi-go"
+	"testing"
+	"time"
+	"tondexer/jettons"
+	"tondexer/models"
+	"tondexer/persistence"
+)
+
+// fakeAccount serves transactions with lts from newest down to 1, every lt at a second of unix time equal to it
+type fakeAccount struct {
+	newestLt uint64
+	pageSize int
+	// requestedBefore lists beforeLt of every page request
+	requestedBefore []uint64
+}
+
+func (account *fakeAccount) AccountTransactions(_ string, beforeLt uint64, limit int32) ([]tonapi.Transaction, error) {
+	account.requestedBefore = append(account.requestedBefore, beforeLt)
+	var transactions []tonapi.Transaction
+	for lt := account.newestLt; lt >= 1 && len(transactions) < min(int(limit), account.pageSize); lt-- {
+		if beforeLt == 0 || lt < beforeLt {
+			transactions = append(transactions, tonapi.Transaction{Hash: fmt.Sprint("tx", lt), Lt: int64(lt), Utime: int64(lt)})
+		}
+	}
+	return transactions, nil
+}
+
+// wallet is an account no dex extractor recognizes, traces of the fake have no swaps
+const wallet = "0:0000000000000000000000000000000000000000000000000000000000000000"
+
+// fakeTraces returns traces of a single transaction, failing is the hash whose trace is unavailable
+type fakeTraces struct {
+	fetched []string
+	failing string
+}
+
+func (traces *fakeTraces) GetTraceByHash(hash string) (*tonapi.Trace, error) {
+	if hash == traces.failing {
+		return nil, errors.New("tonapi is down")
+	}
+	traces.fetched = append(traces.fetched, hash)
+	return &tonapi.Trace{Transaction: tonapi.Transaction{Hash: hash, Account: tonapi.AccountAddress{Address: wallet}}}, nil
+}
+
+func newTestBackfiller(account *fakeAccount, traces *fakeTraces, backfillRange BackfillRange, checkpoints *[]models.BackfillCheckpoint) *Backfiller {
+	backfiller := newBackfiller(account, traces, persistence.NewMemoryStore(), &jettons.TokenCaches{}, backfillRange, "test")
+	backfiller.SaveCheckpoint = func(checkpoint *models.BackfillCheckpoint) error {
+		*checkpoints = append(*checkpoints, *checkpoint)
+		return nil
+	}
+	backfiller.StoredHashes = func(time.Time, time.Time) ([]string, error) { return nil, nil }
+	return backfiller
+}
+
+func TestBackfillRangeBounds(t *testing.T) {
+	transaction := &tonapi.Transaction{Lt: 10, Utime: 100}
+	for _, test := range []struct {
+		backfillRange BackfillRange
+		older, newer  bool
+	}{
+		{BackfillRange{}, false, false},
+		{BackfillRange{FromLt: 10, ToLt: 10}, false, false},
+		{BackfillRange{FromLt: 11}, true, false},
+		{BackfillRange{ToLt: 9}, false, true},
+		{BackfillRange{FromTime: time.Unix(100, 0), ToTime: time.Unix(100, 0)}, false, false},
+		{BackfillRange{FromTime: time.Unix(101, 0)}, true, false},
+		{BackfillRange{ToTime: time.Unix(99, 0)}, false, true},
+	} {
+		assert.Equal(t, test.older, test.backfillRange.isOlder(transaction), test.backfillRange)
+		assert.Equal(t, test.newer, test.backfillRange.isNewer(transaction), test.backfillRange)
+	}
+}
+
+func TestBackfillAccountStopsAtRangeStart(t *testing.T) {
+	account := &fakeAccount{newestLt: 10, pageSize: 3}
+	traces := &fakeTraces{}
+	var checkpoints []models.BackfillCheckpoint
+	backfiller := newTestBackfiller(account, traces, BackfillRange{FromLt: 3, ToTime: time.Unix(7, 0)}, &checkpoints)
+
+	assert.Nil(t, backfiller.backfillAccount("account", nil))
+	assert.Equal(t, []string{"tx7", "tx6", "tx5", "tx4", "tx3"}, traces.fetched)
+	assert.Equal(t, []uint64{0, 8, 5}, account.requestedBefore)
+	assert.Equal(t, 3, len(checkpoints))
+	assert.Equal(t, []uint64{8, 5, 2}, []uint64{checkpoints[0].Lt, checkpoints[1].Lt, checkpoints[2].Lt})
+	assert.False(t, checkpoints[1].Done)
+	assert.True(t, checkpoints[2].Done)
+}
+
+func TestBackfillAccountResumesFromCheckpoint(t *testing.T) {
+	account := &fakeAccount{newestLt: 10, pageSize: 100}
+	traces := &fakeTraces{}
+	var checkpoints []models.BackfillCheckpoint
+	backfiller := newTestBackfiller(account, traces, BackfillRange{FromLt: 2, ToLt: 9}, &checkpoints)
+
+	assert.Nil(t, backfiller.backfillAccount("account", &models.BackfillCheckpoint{Lt: 5}))
+	assert.Equal(t, uint64(5), account.requestedBefore[0])
+	assert.Equal(t, []string{"tx4", "tx3", "tx2"}, traces.fetched)
+	assert.True(t, checkpoints[len(checkpoints)-1].Done)
+
+	traces.fetched = nil
+	assert.Nil(t, backfiller.backfillAccount("account", &models.BackfillCheckpoint{Lt: 5, Done: true}))
+	assert.Empty(t, traces.fetched)
+}
+
+func TestBackfillAccountKeepsCheckpointWhenTraceFails(t *testing.T) {
+	account := &fakeAccount{newestLt: 10, pageSize: 3}
+	traces := &fakeTraces{failing: "tx6"}
+	var checkpoints []models.BackfillCheckpoint
+	backfiller := newTestBackfiller(account, traces, BackfillRange{FromLt: 1}, &checkpoints)
+
+	assert.NotNil(t, backfiller.backfillAccount("account", nil))
+	assert.Equal(t, 1, len(checkpoints))
+	assert.Equal(t, uint64(8), checkpoints[0].Lt)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/tonkeeper/tonapi-go"
+	"log"
+	"os"
+	"path/filepath"
+	"tondexer/fixtures"
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: fixtures [-dir <package>] [-token <console token>] record <trace hash>...\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	dir := flag.String("dir", ".", "package directory, traces are saved into its "+fixtures.TracesDir)
+	token := flag.String("token", os.Getenv("CONSOLE_TOKEN"), "tonapi token, the free tier is used if empty")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 2 || flag.Arg(0) != "record" {
+		usage()
+		os.Exit(2)
+	}
+
+	var options []tonapi.ClientOption
+	if *token != "" {
+		options = append(options, tonapi.WithToken(*token))
+	}
+	client, e := tonapi.New(options...)
+	if e != nil {
+		panic(e)
+	}
+
+	tracesDir := filepath.Join(*dir, fixtures.TracesDir)
+	for _, id := range flag.Args()[1:] {
+		trace, e := fixtures.Fetch(client, id)
+		if e != nil {
+			log.Fatalf("Unable to fetch trace %v: %v \n", id, e)
+		}
+		if e := fixtures.WriteTrace(tracesDir, id, trace); e != nil {
+			log.Fatalf("Unable to record trace %v: %v \n", id, e)
+		}
+		log.Printf("Recorded %v into %v \n", id, tracesDir)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ilyakaznacheev/cleanenv"
+	"log"
+	"os"
+	"tondexer/core"
+	"tondexer/migrations"
+)
+
+type Config struct {
+	DbHost     string `yaml:"db_host" env:"DB_HOST" env-default:"localhost"`
+	DbPort     uint   `yaml:"db_port" env:"DB_PORT" env-default:"9000"`
+	DbUser     string `yaml:"db_user" env:"DB_USER" env-default:"default"`
+	DbPassword string `yaml:"db_password" env:"DB_PASSWORD" env-default:""`
+	DbName     string `yaml:"db_name" env:"DB_NAME" env-default:"default"`
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: migrate -config <path> [-steps n] up|down|status\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	configPath := flag.String("config", "", "path to the config file, environment variables are used if empty")
+	steps := flag.Int("steps", 1, "number of migrations to revert with down")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	var cfg Config
+	var err error
+	if *configPath == "" {
+		err = cleanenv.ReadEnv(&cfg)
+	} else {
+		err = cleanenv.ReadConfig(*configPath, &cfg)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	dbConfig := core.DbConfig{
+		DbHost:     cfg.DbHost,
+		DbPort:     cfg.DbPort,
+		DbUser:     cfg.DbUser,
+		DbPassword: cfg.DbPassword,
+		DbName:     cfg.DbName,
+	}
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, e := migrations.Up(&dbConfig)
+		for _, migration := range applied {
+			log.Printf("Applied %v_%v \n", migration.Version, migration.Name)
+		}
+		if e != nil {
+			panic(e)
+		}
+		log.Printf("Schema is up to date, %v migrations applied \n", len(applied))
+	case "down":
+		reverted, e := migrations.Down(&dbConfig, *steps)
+		for _, migration := range reverted {
+			log.Printf("Reverted %v_%v \n", migration.Version, migration.Name)
+		}
+		if e != nil {
+			panic(e)
+		}
+	case "status":
+		statuses, e := migrations.Status(&dbConfig)
+		if e != nil {
+			panic(e)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied at " + status.Time.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%v\t%v\n", status.Migration.Version, status.Migration.Name, state)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
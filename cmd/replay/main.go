@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/tonkeeper/tonapi-go"
+	"log"
+	"sort"
+	"time"
+	"tondexer/core"
+	"tondexer/jettons"
+	"tondexer/models"
+	"tondexer/persistence"
+	"tondexer/pipeline"
+	"tondexer/replay"
+	"tondexer/spool"
+	"tondexer/traces"
+)
+
+type Config struct {
+	DbHost          string `yaml:"db_host" env:"DB_HOST" env-default:"localhost"`
+	DbPort          uint   `yaml:"db_port" env:"DB_PORT" env-default:"9000"`
+	DbUser          string `yaml:"db_user" env:"DB_USER" env-default:"default"`
+	DbPassword      string `yaml:"db_password" env:"DB_PASSWORD" env-default:""`
+	DbName          string `yaml:"db_name" env:"DB_NAME" env-default:"default"`
+	SpoolDir        string `yaml:"spool_dir" env:"SPOOL_DIR" env-default:".spool"`
+	TraceArchiveDir string `yaml:"trace_archive_dir" env:"TRACE_ARCHIVE_DIR" env-default:""`
+}
+
+// rewriteSpoolKind keeps rows of a rewrite from before the stored ones are deleted until they're written
+const rewriteSpoolKind = "rewrite"
+
+// Rewrite is what replaces stored swaps and trades of the traces, Candles cover both the stored and the new swaps
+type Rewrite struct {
+	TraceIDs []string                  `json:"trace_ids"`
+	Swaps    []*models.SwapCH          `json:"swaps"`
+	Candles  persistence.CandleBuckets `json:"candles"`
+}
+
+type Replayer struct {
+	DbConfig    *core.DbConfig
+	TokenCaches *jettons.TokenCaches
+	Spool       *spool.Spool
+	Rewrite     bool
+	Summary     replay.Summary
+	Traces      int
+}
+
+// replayBatch extracts swaps of the traces again, reports differences from the stored rows and rewrites swaps and trades of the traces which differ
+func (r *Replayer) replayBatch(batch []*tonapi.Trace) error {
+	var ids []string
+	var replayed []*models.SwapCH
+	for _, trace := range batch {
+		ids = append(ids, trace.Transaction.Hash)
+		replayed = append(replayed, pipeline.ExtractSwapsFromTrace(trace, r.TokenCaches)...)
+	}
+	stored, e := persistence.ReadTraceSwaps(r.DbConfig, ids)
+	if e != nil {
+		return e
+	}
+
+	changes := replay.DiffSwaps(stored, replayed)
+	r.Summary.Add(changes)
+	r.Traces += len(batch)
+	affected := map[string]bool{}
+	for _, change := range changes {
+		log.Println(change)
+		if change.Replayed != nil {
+			affected[change.Replayed.TraceID] = true
+		} else {
+			affected[change.Stored.TraceID] = true
+		}
+	}
+	if !r.Rewrite || len(affected) == 0 {
+		return nil
+	}
+
+	var affectedIDs []string
+	for id := range affected {
+		affectedIDs = append(affectedIDs, id)
+	}
+	sort.Strings(affectedIDs)
+	var rewritten []*models.SwapCH
+	for _, swap := range replayed {
+		if affected[swap.TraceID] {
+			rewritten = append(rewritten, swap)
+		}
+	}
+	replay.KeepStoredPricing(stored, rewritten)
+	var replaced []*models.SwapCH
+	for _, swap := range stored {
+		if affected[swap.TraceID] {
+			replaced = append(replaced, swap)
+		}
+	}
+	candles := persistence.SwapCandleBuckets(append(replaced, rewritten...))
+	// deleting and writing the rows isn't atomic, the spooled rewrite is applied again by the next run if it fails in between
+	if e := spool.Write(r.Spool, rewriteSpoolKind, []*Rewrite{{TraceIDs: affectedIDs, Swaps: rewritten, Candles: candles}}); e != nil {
+		return e
+	}
+	return r.applyRewrites()
+}
+
+// applyRewrites replaces rows of the spooled rewrites oldest first, a rewrite is deleted from the spool once it's written
+func (r *Replayer) applyRewrites() error {
+	return spool.Replay(r.Spool, rewriteSpoolKind, func(rewrites []*Rewrite) error {
+		for _, rewrite := range rewrites {
+			if e := persistence.ReplaceTraceSwaps(r.DbConfig, rewrite.TraceIDs, rewrite.Swaps); e != nil {
+				return e
+			}
+			if e := persistence.ReplaceTraceTrades(r.DbConfig, rewrite.TraceIDs, pipeline.BuildTrades(rewrite.Swaps)); e != nil {
+				return e
+			}
+			if e := persistence.RebuildCandles(r.DbConfig, rewrite.Candles); e != nil {
+				return e
+			}
+			log.Printf("Rewrote %v swaps of %v traces \n", len(rewrite.Swaps), len(rewrite.TraceIDs))
+		}
+		return nil
+	})
+}
+
+func main() {
+	configPath := flag.String("config", "", "path to the config file")
+	archiveDir := flag.String("archive", "", "trace archive directory, trace_archive_dir of the config if empty")
+	from := flag.String("from", "", "replay traces since, unix seconds or RFC3339")
+	to := flag.String("to", "", "replay traces until, unix seconds or RFC3339")
+	rewrite := flag.Bool("rewrite", false, "replace stored swaps of the traces which differ")
+	batchSize := flag.Int("batch", 100, "traces compared per query")
+	flag.Parse()
+
+	var cfg Config
+	if err := cleanenv.ReadConfig(*configPath, &cfg); err != nil {
+		panic(err)
+	}
+	if *archiveDir == "" {
+		*archiveDir = cfg.TraceArchiveDir
+	}
+	if *archiveDir == "" {
+		panic(errors.New("either -archive or trace_archive_dir must be set"))
+	}
+	fromTime, e := core.ParseTime(*from)
+	if e != nil {
+		panic(e)
+	}
+	toTime, e := core.ParseTime(*to)
+	if e != nil {
+		panic(e)
+	}
+
+	archive, e := traces.NewArchive(*archiveDir)
+	if e != nil {
+		panic(e)
+	}
+
+	dbConfig := core.DbConfig{
+		DbHost:     cfg.DbHost,
+		DbPort:     cfg.DbPort,
+		DbUser:     cfg.DbUser,
+		DbPassword: cfg.DbPassword,
+		DbName:     cfg.DbName,
+	}
+
+	freeConsoleClient, _ := tonapi.New() // free one for the rates
+	freeConsoleApi := core.TonConsoleApi{Client: freeConsoleClient}
+	deadLetter, e := spool.New(cfg.SpoolDir)
+	if e != nil {
+		panic(e)
+	}
+	tokenCaches, e := jettons.InitTokenCaches(persistence.NewClickhouseStore(&dbConfig), &freeConsoleApi, deadLetter)
+	if e != nil {
+		panic(e)
+	}
+
+	replayer := &Replayer{
+		DbConfig:    &dbConfig,
+		TokenCaches: tokenCaches,
+		Spool:       deadLetter,
+		Rewrite:     *rewrite,
+		Summary:     replay.Summary{},
+	}
+	// finishes rewrites a previous run failed in the middle of
+	if e := replayer.applyRewrites(); e != nil {
+		panic(e)
+	}
+	var batch []*tonapi.Trace
+	e = archive.Each(func(trace *tonapi.Trace) error {
+		traceTime := time.Unix(trace.Transaction.Utime, 0)
+		if (!fromTime.IsZero() && traceTime.Before(fromTime)) || (!toTime.IsZero() && traceTime.After(toTime)) {
+			return nil
+		}
+		batch = append(batch, trace)
+		if len(batch) < *batchSize {
+			return nil
+		}
+		defer func() { batch = nil }()
+		return replayer.replayBatch(batch)
+	})
+	if e == nil && len(batch) > 0 {
+		e = replayer.replayBatch(batch)
+	}
+	// saves jettons and wallets discovered while replaying
+	tokenCaches.Close()
+	if e != nil {
+		log.Fatalf("Replay failed after %v traces: %v \n", replayer.Traces, e)
+	}
+
+	log.Printf("Replayed %v traces \n", replayer.Traces)
+	for dex, kinds := range replayer.Summary {
+		log.Printf("%v: %v added, %v removed, %v changed \n", dex, kinds[replay.Added], kinds[replay.Removed], kinds[replay.Changed])
+	}
+}
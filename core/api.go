@@ -81,3 +81,24 @@ func (api *TonConsoleApi) jettonRateToUsdByMasterInternal(master string) (float6
 
 	return 0, errors.New("no usd rate for master " + master)
 }
+
+// AccountTransactions returns up to limit transactions of the account with lt strictly less than beforeLt,
+// newest first. Zero beforeLt means starting from the latest transaction.
+func (api *TonConsoleApi) AccountTransactions(account string, beforeLt uint64, limit int32) ([]tonapi.Transaction, error) {
+	backoff := retry.WithMaxRetries(4, retry.NewExponential(1*time.Second))
+	params := tonapi.GetBlockchainAccountTransactionsParams{
+		AccountID: account,
+		Limit:     tonapi.NewOptInt32(limit),
+		SortOrder: tonapi.NewOptGetBlockchainAccountTransactionsSortOrder(tonapi.GetBlockchainAccountTransactionsSortOrderDesc),
+	}
+	if beforeLt != 0 {
+		params.BeforeLt = tonapi.NewOptInt64(int64(beforeLt))
+	}
+	return retry.DoValue(context.Background(), backoff, func(ctx context.Context) ([]tonapi.Transaction, error) {
+		transactions, err := api.GetBlockchainAccountTransactions(context.Background(), params)
+		if err != nil {
+			return nil, retry.RetryableError(err)
+		}
+		return transactions.Transactions, nil
+	})
+}
@@ -1,3 +1,7 @@
+//go:build live
+
+// The tests call tonapi, run them with network access: go test -tags live ./core
+
 package core
 
 import (
@@ -31,6 +31,16 @@ func (waitingList *WaitingList[T]) Evict() []T {
 	return evicted
 }
 
+// EvictAll returns every entity regardless of its age
+func (waitingList *WaitingList[T]) EvictAll() []T {
+	var evicted []T
+	for _, pair := range waitingList.Entities {
+		evicted = append(evicted, pair.First)
+	}
+	waitingList.Entities = nil
+	return evicted
+}
+
 type EvictableSet[T comparable] struct {
 	mp                map[T]time.Time
 	ExpirationSeconds time.Duration
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"github.com/tonkeeper/tonapi-go"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+)
+
+// MessageBody parses raw body of the message skipping the op code and returns the op code separately
+func MessageBody(message *tonapi.Message) (uint64, *cell.Slice, error) {
+	if !message.RawBody.IsSet() {
+		return 0, nil, errors.New("message without raw body")
+	}
+	boc, e := hex.DecodeString(message.RawBody.Value)
+	if e != nil {
+		return 0, nil, e
+	}
+	cl, e := cell.FromBOC(boc)
+	if e != nil {
+		return 0, nil, e
+	}
+	slice := cl.BeginParse()
+	opCode, e := slice.LoadUInt(32)
+	if e != nil {
+		return 0, nil, e
+	}
+	return opCode, slice, nil
+}
+
+func InMessageBody(trace *tonapi.Trace) (uint64, *cell.Slice, error) {
+	if !trace.Transaction.InMsg.IsSet() {
+		return 0, nil, errors.New("transaction without in message")
+	}
+	return MessageBody(&trace.Transaction.InMsg.Value)
+}
+
+func InMessageOpCode(trace *tonapi.Trace) string {
+	if trace.Transaction.InMsg.IsSet() && trace.Transaction.InMsg.Value.OpCode.IsSet() {
+		return trace.Transaction.InMsg.Value.OpCode.Value
+	}
+	return ""
+}
+
+func InMessageSource(trace *tonapi.Trace) *address.Address {
+	if !trace.Transaction.InMsg.IsSet() || !trace.Transaction.InMsg.Value.Source.IsSet() {
+		return nil
+	}
+	source, e := address.ParseRawAddr(trace.Transaction.InMsg.Value.Source.Value.Address)
+	if e != nil {
+		return nil
+	}
+	return source
+}
+
+// JettonInternalTransferAmount parses amount of jetton internal_transfer message
+func JettonInternalTransferAmount(trace *tonapi.Trace) (*big.Int, error) {
+	opCode, body, e := InMessageBody(trace)
+	if e != nil {
+		return nil, e
+	}
+	if opCode != JettonInternalTransferCode {
+		return nil, errors.New("not a jetton internal transfer")
+	}
+	if _, e := body.LoadUInt(64); e != nil { // query id
+		return nil, e
+	}
+	return body.LoadBigCoins()
+}
+
+// JettonBurnNotificationAmount parses amount of burnt jettons from burn_notification message
+func JettonBurnNotificationAmount(trace *tonapi.Trace) (*big.Int, error) {
+	opCode, body, e := InMessageBody(trace)
+	if e != nil {
+		return nil, e
+	}
+	if opCode != JettonBurnNotificationCode {
+		return nil, errors.New("not a jetton burn notification")
+	}
+	if _, e := body.LoadUInt(64); e != nil { // query id
+		return nil, e
+	}
+	return body.LoadBigCoins()
+}
+
+// MintedJettonAmount sums jetton internal transfers sent by the minter in the subtree, zero if there are none
+func MintedJettonAmount(trace *tonapi.Trace, minter *address.Address) *big.Int {
+	total := big.NewInt(0)
+	if minter == nil {
+		return total
+	}
+	var traverse func(t *tonapi.Trace)
+	traverse = func(t *tonapi.Trace) {
+		if InMessageOpCode(t) == JettonInternalTransferOpCode {
+			if source := InMessageSource(t); source != nil && source.Equals(minter) {
+				if amount, e := JettonInternalTransferAmount(t); e == nil {
+					total.Add(total, amount)
+				}
+			}
+		}
+		for i := range t.Children {
+			traverse(&t.Children[i])
+		}
+	}
+	traverse(trace)
+	return total
+}
@@ -0,0 +1,17 @@
+package core
+
+import (
+	"strconv"
+	"time"
+)
+
+// ParseTime accepts either unix seconds or RFC3339, empty string is zero time
+func ParseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if seconds, e := strconv.ParseInt(s, 10, 64); e == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,20 @@
+package core
+
+import "github.com/tonkeeper/tonapi-go"
+
+// GetAllTransactionsFromTrace lists transactions of the trace and all of its children
+func GetAllTransactionsFromTrace(trace *tonapi.Trace) []tonapi.Transaction {
+	var transactions []tonapi.Transaction
+	var traverse func(t *tonapi.Trace)
+
+	traverse = func(t *tonapi.Trace) {
+		transactions = append(transactions, t.Transaction)
+
+		for _, child := range t.Children {
+			traverse(&child)
+		}
+	}
+
+	traverse(trace)
+	return transactions
+}
@@ -1,3 +1,8 @@
 package core
 
 const JettonNotifyOpCode = "0x7362d09c"
+const JettonInternalTransferOpCode = "0x178d4519"
+const JettonBurnNotificationOpCode = "0x7bdd97de"
+
+const JettonInternalTransferCode = 0x178d4519
+const JettonBurnNotificationCode = 0x7bdd97de
@@ -0,0 +1,32 @@
+package dedust
+
+import (
+	"testing"
+	"tondexer/fixtures"
+	"tondexer/fixtures/fixturestest"
+)
+
+// goldenTraces are the traces the tests of the package read
+var goldenTraces = []string{
+	"0b4bf597f1a07d0a97ab8cc7c1e961c2013ba974c4708487eab2afc7ba3e0b76",
+	"3b0411ae3fe1fae4ec0cef2f4ce7ced1864d5f93481259d9a12fac235625b030",
+	"528f4366cc050178566b635a39ab7810c31d95f82aaeeb60b0c0595d2300202f",
+	"5ac93cd223409580fcdd4c0899e9a88f3acf831cb1fffbb742ca0ec6103a0cbb",
+	"5b4a7d422346a9fd28b574623f1ec54502ca346f7cc3d31175377e6029bc0bc5",
+	"6932e9372c48b340f0bccc4cb2a06c12a0093e7241c9bbfebcee753205bbd6a7",
+	"87bcc2cfbfc23228d7c27d179bea253c9acfa5899183f05e857a5ec4f63e204c",
+	"b59046a551499a63ba541dc17c22f628f8e36bc1b997f70aff6da8f5aab02058",
+	"c63df8e487c3f516847f56c596913b4ed4267a15ca7a5b12557827c05fbbb8f6",
+	"dd4500f05ae7c10d96bd446e1eada3aa5eae08e74dbf6b165d32ba498e63b46d",
+	"e4a2ea99a1bf8b8812edf9487d70ce9e9c77a1b72345e3abff071624eebf801d",
+	"fad824432d05c95ccd0e0926d5a16d39356a24366c48bd5e81e7b9ce12a9ad37",
+}
+
+func TestGoldenSwaps(t *testing.T) {
+	for _, id := range fixturestest.Traces(t, fixtures.TracesDir, goldenTraces...) {
+		t.Run(id, func(t *testing.T) {
+			trace := fixturestest.Trace(t, id)
+			fixturestest.Golden(t, id, ExtractDedustSwapsFromRootTrace(trace))
+		})
+	}
+}
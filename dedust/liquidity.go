@@ -0,0 +1,208 @@
+package dedust
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/tonkeeper/tonapi-go"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"log"
+	"math/big"
+	"time"
+	"tondexer/common"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+const dedustDepositLiquidityAllOpCode = "0xb56b9598"
+
+// ExtractDedustLiquidityFromRootTrace finds deposits completed by the liquidity deposit contract
+// and withdrawals started by burning LP tokens of the pool
+func ExtractDedustLiquidityFromRootTrace(root *tonapi.Trace) []*models.LiquidityInfo {
+	var infos []*models.LiquidityInfo
+	var traverse func(trace *tonapi.Trace)
+	traverse = func(trace *tonapi.Trace) {
+		if common.Contains(trace.Interfaces, "dedust_pool") {
+			var info *models.LiquidityInfo
+			var e error
+			switch core.InMessageOpCode(trace) {
+			case dedustDepositLiquidityAllOpCode:
+				info, e = depositInfoFromPoolTrace(trace, root)
+			case core.JettonBurnNotificationOpCode:
+				info, e = withdrawalInfoFromPoolTrace(trace, root)
+			}
+			if e != nil {
+				log.Printf("Error extracting dedust liquidity from %v: %v \n", trace.Transaction.Hash, e)
+			} else if info != nil {
+				infos = append(infos, info)
+			}
+		}
+		for i := range trace.Children {
+			traverse(&trace.Children[i])
+		}
+	}
+	traverse(root)
+	return infos
+}
+
+func poolTraceFailed(poolTrace *tonapi.Trace) bool {
+	return poolTrace.Transaction.ComputePhase.Set &&
+		poolTrace.Transaction.ComputePhase.Value.ExitCode.IsSet() &&
+		poolTrace.Transaction.ComputePhase.Value.ExitCode.Value != 0
+}
+
+// LoadAsset returns jetton master of the asset, nil for native TON
+func LoadAsset(slice *cell.Slice) (*address.Address, error) {
+	tag, e := slice.LoadUInt(4)
+	if e != nil {
+		return nil, e
+	}
+	switch tag {
+	case 0:
+		return nil, nil
+	case 1:
+		workchain, e := slice.LoadInt(8)
+		if e != nil {
+			return nil, e
+		}
+		data, e := slice.LoadSlice(256)
+		if e != nil {
+			return nil, e
+		}
+		return address.NewAddress(0, byte(workchain), data), nil
+	default:
+		return nil, errors.New("unsupported asset type")
+	}
+}
+
+func depositInfoFromPoolTrace(poolTrace *tonapi.Trace, root *tonapi.Trace) (*models.LiquidityInfo, error) {
+	if poolTraceFailed(poolTrace) {
+		return nil, nil
+	}
+	_, body, e := core.InMessageBody(poolTrace)
+	if e != nil {
+		return nil, e
+	}
+	if _, e := body.LoadUInt(64); e != nil { // query id
+		return nil, e
+	}
+	if _, e := body.LoadRef(); e != nil { // proof
+		return nil, e
+	}
+	owner, e := body.LoadAddr()
+	if e != nil {
+		return nil, e
+	}
+	if _, e := body.LoadBigCoins(); e != nil { // min lp amount
+		return nil, e
+	}
+	assetsSlice, e := body.LoadRef()
+	if e != nil {
+		return nil, e
+	}
+
+	var assets []*models.LiquidityAsset
+	for i := 0; i < 2; i++ {
+		master, e := LoadAsset(assetsSlice)
+		if e != nil {
+			return nil, e
+		}
+		amount, e := assetsSlice.LoadBigCoins()
+		if e != nil {
+			return nil, e
+		}
+		assets = append(assets, &models.LiquidityAsset{Master: master, Amount: amount})
+	}
+
+	poolAddress, e := address.ParseRawAddr(poolTrace.Transaction.Account.Address)
+	if e != nil {
+		return nil, e
+	}
+
+	return &models.LiquidityInfo{
+		TraceID:     root.Transaction.Hash,
+		Dex:         models.DeDust,
+		Type:        models.LiquidityProvide,
+		Hashes:      []string{poolTrace.Transaction.Hash},
+		Lt:          uint64(poolTrace.Transaction.Lt),
+		Time:        time.UnixMilli(poolTrace.Transaction.Utime * 1000),
+		CatchTime:   time.Now(),
+		PoolAddress: poolAddress,
+		Provider:    owner,
+		Assets:      assets,
+		LpAmount:    core.MintedJettonAmount(poolTrace, poolAddress),
+	}, nil
+}
+
+func withdrawalInfoFromPoolTrace(poolTrace *tonapi.Trace, root *tonapi.Trace) (*models.LiquidityInfo, error) {
+	if poolTraceFailed(poolTrace) {
+		return nil, nil
+	}
+	_, body, e := core.InMessageBody(poolTrace)
+	if e != nil {
+		return nil, e
+	}
+	if _, e := body.LoadUInt(64); e != nil { // query id
+		return nil, e
+	}
+	lpAmount, e := body.LoadBigCoins()
+	if e != nil {
+		return nil, e
+	}
+	owner, e := body.LoadAddr()
+	if e != nil {
+		return nil, e
+	}
+
+	hashes := []string{poolTrace.Transaction.Hash}
+	var assets []*models.LiquidityAsset
+	for i := range poolTrace.Children {
+		vaultTrace := &poolTrace.Children[i]
+		if !poolChildIsVaultAndHasProperCode(*vaultTrace) {
+			continue
+		}
+		var outVaultJson OutVaultJsonBody
+		if err := json.Unmarshal(vaultTrace.Transaction.InMsg.Value.DecodedBody, &outVaultJson); err != nil {
+			return nil, err
+		}
+		amount, p := new(big.Int).SetString(outVaultJson.Amount, 10)
+		if !p {
+			return nil, errors.New("invalid payout amount")
+		}
+
+		asset := &models.LiquidityAsset{Amount: amount}
+		for _, walletTrace := range vaultTrace.Children {
+			if core.InMessageOpCode(&walletTrace) == jettonTransferOpCode {
+				// otherwise it's TON
+				asset.Wallet, e = address.ParseRawAddr(walletTrace.Transaction.Account.Address)
+				if e != nil {
+					return nil, e
+				}
+			}
+		}
+		hashes = append(hashes, vaultTrace.Transaction.Hash)
+		assets = append(assets, asset)
+	}
+	if len(assets) == 0 {
+		return nil, errors.New("no payouts for dedust withdrawal")
+	}
+
+	poolAddress, e := address.ParseRawAddr(poolTrace.Transaction.Account.Address)
+	if e != nil {
+		return nil, e
+	}
+
+	return &models.LiquidityInfo{
+		TraceID:     root.Transaction.Hash,
+		Dex:         models.DeDust,
+		Type:        models.LiquidityWithdraw,
+		Hashes:      hashes,
+		Lt:          uint64(poolTrace.Transaction.Lt),
+		Time:        time.UnixMilli(poolTrace.Transaction.Utime * 1000),
+		CatchTime:   time.Now(),
+		PoolAddress: poolAddress,
+		Provider:    owner,
+		Assets:      assets,
+		LpAmount:    lpAmount,
+	}, nil
+}
@@ -0,0 +1,80 @@
+package dedust
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tonkeeper/tonapi-go"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"testing"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+func TestExtractDedustWithdrawalFromTrace(t *testing.T) {
+	pool := address.MustParseAddr("EQA-X_yo3fzzbDbJ_0bzFWKqtRuZFIRa1sJsveZJ1YpViO3r")
+	owner := address.MustParseAddr("EQCM3B12QK1e4yZSf8GtBRT0aLMNyEsBc_DhVfRRtOEffLez")
+	vaultWallet := address.MustParseAddr("EQDa4VOnTYlLvDJ0gZjNYm5PXfSmmtL6Vs6A_CZEtXCNICq_")
+
+	burnBody := cell.BeginCell().
+		MustStoreUInt(core.JettonBurnNotificationCode, 32).
+		MustStoreUInt(1, 64).
+		MustStoreBigCoins(big.NewInt(1000)).
+		MustStoreAddr(owner).
+		EndCell()
+
+	payout := func(amount string, children ...tonapi.Trace) tonapi.Trace {
+		return tonapi.Trace{
+			Transaction: tonapi.Transaction{
+				Hash: "payout" + amount,
+				InMsg: tonapi.NewOptMessage(tonapi.Message{
+					OpCode:      tonapi.NewOptString(dedustPayoutFromPoolOpCode),
+					DecodedBody: []byte(`{"amount":"` + amount + `"}`),
+				}),
+			},
+			Interfaces: []string{"dedust_vault"},
+			Children:   children,
+		}
+	}
+	walletTrace := tonapi.Trace{Transaction: tonapi.Transaction{
+		Account: tonapi.AccountAddress{Address: rawAddress(vaultWallet)},
+		InMsg:   tonapi.NewOptMessage(tonapi.Message{OpCode: tonapi.NewOptString(jettonTransferOpCode)}),
+	}}
+
+	root := tonapi.Trace{
+		Transaction: tonapi.Transaction{
+			Hash:    "pool",
+			Lt:      10,
+			Utime:   1700000000,
+			Account: tonapi.AccountAddress{Address: rawAddress(pool)},
+			InMsg: tonapi.NewOptMessage(tonapi.Message{
+				OpCode:  tonapi.NewOptString(core.JettonBurnNotificationOpCode),
+				RawBody: tonapi.NewOptString(hex.EncodeToString(burnBody.ToBOC())),
+			}),
+		},
+		Interfaces: []string{"dedust_pool"},
+		Children:   []tonapi.Trace{payout("500"), payout("700", walletTrace)},
+	}
+
+	infos := ExtractDedustLiquidityFromRootTrace(&root)
+
+	assert.Equal(t, 1, len(infos))
+	info := infos[0]
+	assert.Equal(t, models.LiquidityWithdraw, info.Type)
+	assert.Equal(t, models.DeDust, info.Dex)
+	assert.True(t, pool.Equals(info.PoolAddress))
+	assert.True(t, owner.Equals(info.Provider))
+	assert.Equal(t, big.NewInt(1000), info.LpAmount)
+	assert.Equal(t, []string{"pool", "payout500", "payout700"}, info.Hashes)
+	assert.Equal(t, 2, len(info.Assets))
+	assert.Nil(t, info.Assets[0].Wallet)
+	assert.Equal(t, big.NewInt(500), info.Assets[0].Amount)
+	assert.True(t, vaultWallet.Equals(info.Assets[1].Wallet))
+	assert.Equal(t, big.NewInt(700), info.Assets[1].Amount)
+}
+
+func rawAddress(addr *address.Address) string {
+	return fmt.Sprintf("%v:%x", addr.Workchain(), addr.Data())
+}
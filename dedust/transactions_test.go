@@ -1,18 +1,15 @@
 package dedust
 
 import (
-	"context"
 	"github.com/stretchr/testify/assert"
-	"github.com/tonkeeper/tonapi-go"
 	"github.com/xssnick/tonutils-go/address"
 	"math/big"
 	"testing"
+	"tondexer/fixtures/fixturestest"
 )
 
 func TestFindDedustSwapTokenForTonTrace(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "5b4a7d422346a9fd28b574623f1ec54502ca346f7cc3d31175377e6029bc0bc5"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "5b4a7d422346a9fd28b574623f1ec54502ca346f7cc3d31175377e6029bc0bc5")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -31,9 +28,7 @@ func TestFindDedustSwapTokenForTonTrace(t *testing.T) {
 }
 
 func TestFindDedustSwapTonForTokenTrace(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "e4a2ea99a1bf8b8812edf9487d70ce9e9c77a1b72345e3abff071624eebf801d"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "e4a2ea99a1bf8b8812edf9487d70ce9e9c77a1b72345e3abff071624eebf801d")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -52,9 +47,7 @@ func TestFindDedustSwapTonForTokenTrace(t *testing.T) {
 }
 
 func TestFindDedustSwapTokenForToken(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "87bcc2cfbfc23228d7c27d179bea253c9acfa5899183f05e857a5ec4f63e204c"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "87bcc2cfbfc23228d7c27d179bea253c9acfa5899183f05e857a5ec4f63e204c")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -75,9 +68,7 @@ func TestFindDedustSwapTokenForToken(t *testing.T) {
 }
 
 func TestFindSwapTracesForThreeCycle(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "528f4366cc050178566b635a39ab7810c31d95f82aaeeb60b0c0595d2300202f"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "528f4366cc050178566b635a39ab7810c31d95f82aaeeb60b0c0595d2300202f")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -102,9 +93,7 @@ func TestFindSwapTracesForThreeCycle(t *testing.T) {
 //---------------------------- NEW
 
 func TestDedustSwapInfoForOneSwap(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "87bcc2cfbfc23228d7c27d179bea253c9acfa5899183f05e857a5ec4f63e204c"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "87bcc2cfbfc23228d7c27d179bea253c9acfa5899183f05e857a5ec4f63e204c")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -130,9 +119,7 @@ func TestDedustSwapInfoForOneSwap(t *testing.T) {
 }
 
 func TestDedustSwapInfoForThreeSwap(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "fad824432d05c95ccd0e0926d5a16d39356a24366c48bd5e81e7b9ce12a9ad37"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "fad824432d05c95ccd0e0926d5a16d39356a24366c48bd5e81e7b9ce12a9ad37")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -172,9 +159,7 @@ func TestDedustSwapInfoForThreeSwap(t *testing.T) {
 }
 
 func TestSeveralSwapsWithFailed(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "6932e9372c48b340f0bccc4cb2a06c12a0093e7241c9bbfebcee753205bbd6a7"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "6932e9372c48b340f0bccc4cb2a06c12a0093e7241c9bbfebcee753205bbd6a7")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -202,9 +187,7 @@ func TestSeveralSwapsWithFailed(t *testing.T) {
 //---------------------------- OLD
 
 func TestNotificationParsingFromTonToTokenSwap(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "3b0411ae3fe1fae4ec0cef2f4ce7ced1864d5f93481259d9a12fac235625b030"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "3b0411ae3fe1fae4ec0cef2f4ce7ced1864d5f93481259d9a12fac235625b030")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -227,9 +210,7 @@ func TestNotificationParsingFromTonToTokenSwap(t *testing.T) {
 }
 
 func TestNotificationParsingToTokenFromTonSwap(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "b59046a551499a63ba541dc17c22f628f8e36bc1b997f70aff6da8f5aab02058"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "b59046a551499a63ba541dc17c22f628f8e36bc1b997f70aff6da8f5aab02058")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -252,9 +233,7 @@ func TestNotificationParsingToTokenFromTonSwap(t *testing.T) {
 }
 
 func TestTraceIdIsSetForSwapInfo(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "87bcc2cfbfc23228d7c27d179bea253c9acfa5899183f05e857a5ec4f63e204c"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "87bcc2cfbfc23228d7c27d179bea253c9acfa5899183f05e857a5ec4f63e204c")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -264,9 +243,7 @@ func TestTraceIdIsSetForSwapInfo(t *testing.T) {
 }
 
 func TestPaymentParsingForTokenForTokenSwap(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "c63df8e487c3f516847f56c596913b4ed4267a15ca7a5b12557827c05fbbb8f6"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "c63df8e487c3f516847f56c596913b4ed4267a15ca7a5b12557827c05fbbb8f6")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -289,9 +266,7 @@ func TestPaymentParsingForTokenForTokenSwap(t *testing.T) {
 }
 
 func TestPaymentParsingForTokenForTonSwap(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "5b4a7d422346a9fd28b574623f1ec54502ca346f7cc3d31175377e6029bc0bc5"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "5b4a7d422346a9fd28b574623f1ec54502ca346f7cc3d31175377e6029bc0bc5")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -314,9 +289,7 @@ func TestPaymentParsingForTokenForTonSwap(t *testing.T) {
 }
 
 func TestPaymentParsingForTonForTokenSwap(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "3b0411ae3fe1fae4ec0cef2f4ce7ced1864d5f93481259d9a12fac235625b030"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "3b0411ae3fe1fae4ec0cef2f4ce7ced1864d5f93481259d9a12fac235625b030")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -339,9 +312,7 @@ func TestPaymentParsingForTonForTokenSwap(t *testing.T) {
 }
 
 func TestParseBigLimitAndAmount(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "0b4bf597f1a07d0a97ab8cc7c1e961c2013ba974c4708487eab2afc7ba3e0b76"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "0b4bf597f1a07d0a97ab8cc7c1e961c2013ba974c4708487eab2afc7ba3e0b76")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -366,9 +337,7 @@ func TestParseBigLimitAndAmount(t *testing.T) {
 }
 
 func TestWhenInitialAccountHasTwoOutgoingMessages(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "5ac93cd223409580fcdd4c0899e9a88f3acf831cb1fffbb742ca0ec6103a0cbb"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "5ac93cd223409580fcdd4c0899e9a88f3acf831cb1fffbb742ca0ec6103a0cbb")
 
 	swapTraces := findSwapTraces(trace)
 	assert.Equal(t, 1, len(swapTraces))
@@ -391,9 +360,7 @@ func TestWhenInitialAccountHasTwoOutgoingMessages(t *testing.T) {
 }
 
 func TestRootTrace(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "dd4500f05ae7c10d96bd446e1eada3aa5eae08e74dbf6b165d32ba498e63b46d"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "dd4500f05ae7c10d96bd446e1eada3aa5eae08e74dbf6b165d32ba498e63b46d")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -401,9 +368,7 @@ func TestRootTrace(t *testing.T) {
 }
 
 func TestTraceIFForSwapInfo(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "0b4bf597f1a07d0a97ab8cc7c1e961c2013ba974c4708487eab2afc7ba3e0b76"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "0b4bf597f1a07d0a97ab8cc7c1e961c2013ba974c4708487eab2afc7ba3e0b76")
 
 	swapInfos := ExtractDedustSwapsFromRootTrace(trace)
 
@@ -0,0 +1,49 @@
+package feed
+
+import (
+	"slices"
+	"tondexer/models"
+	"tondexer/persistence"
+)
+
+const (
+	SwapEvent      = "swap"
+	ArbitrageEvent = "arbitrage"
+)
+
+// Event is a newly persisted swap or arbitrage, only the field of its type is set
+type Event struct {
+	Type      string                           `json:"type"`
+	Swap      *persistence.EnrichedSwapCH      `json:"swap,omitempty"`
+	Arbitrage *persistence.EnrichedArbitrageCH `json:"arbitrage,omitempty"`
+}
+
+// Filter is chosen by a subscriber, zero values match everything
+type Filter struct {
+	Type   string
+	Dex    models.Dex
+	Jetton string
+	Pool   string
+	MinUsd float64
+}
+
+func (f *Filter) Matches(event *Event) bool {
+	if f.Type != "" && f.Type != event.Type {
+		return false
+	}
+	switch event.Type {
+	case SwapEvent:
+		swap := event.Swap
+		return (f.Dex == "" || slices.Contains(f.Dex.Names(), swap.Dex)) &&
+			(f.Jetton == "" || swap.JettonInMaster == f.Jetton || swap.JettonOut == f.Jetton) &&
+			(f.Pool == "" || swap.PoolAddress == f.Pool) &&
+			(swap.InUsd+swap.OutUsd)/2 >= f.MinUsd
+	case ArbitrageEvent:
+		arbitrage := event.Arbitrage
+		return (f.Dex == "" || slices.ContainsFunc(arbitrage.Dexes, func(dex string) bool { return slices.Contains(f.Dex.Names(), dex) })) &&
+			(f.Jetton == "" || slices.Contains(arbitrage.JettonsPath, f.Jetton)) &&
+			(f.Pool == "" || slices.Contains(arbitrage.PoolsPath, f.Pool)) &&
+			arbitrage.AmountInUSD >= f.MinUsd
+	}
+	return false
+}
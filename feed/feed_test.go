@@ -0,0 +1,85 @@
+package feed
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"tondexer/models"
+	"tondexer/persistence"
+)
+
+func TestFilterMatches(t *testing.T) {
+	swap := &Event{Type: SwapEvent, Swap: &persistence.EnrichedSwapCH{
+		Dex: models.DeDust, JettonInMaster: "ton", JettonOut: "usdt", PoolAddress: "pool", InUsd: 10, OutUsd: 12,
+	}}
+	arbitrage := &Event{Type: ArbitrageEvent, Arbitrage: &persistence.EnrichedArbitrageCH{
+		Dexes: []string{models.StonfiV2, models.DeDust}, JettonsPath: []string{"ton", "usdt", "ton"}, PoolsPath: []string{"pool", "other"}, AmountInUSD: 5,
+	}}
+
+	assert.True(t, (&Filter{}).Matches(swap))
+	assert.True(t, (&Filter{Dex: models.Dex("dedust"), Jetton: "usdt", Pool: "pool", MinUsd: 11}).Matches(swap))
+	assert.False(t, (&Filter{Dex: models.Dex("stonfi")}).Matches(swap))
+	assert.False(t, (&Filter{MinUsd: 12}).Matches(swap))
+	assert.False(t, (&Filter{Type: ArbitrageEvent}).Matches(swap))
+
+	assert.True(t, (&Filter{Dex: models.Dex("stonfi"), Jetton: "usdt", Pool: "other", MinUsd: 5}).Matches(arbitrage))
+	assert.False(t, (&Filter{Dex: models.Dex("tonco")}).Matches(arbitrage))
+	assert.False(t, (&Filter{Pool: "missing"}).Matches(arbitrage))
+}
+
+func TestHubFansOutAndDropsForSlowSubscribers(t *testing.T) {
+	hub := NewHub()
+	all := hub.Subscribe(Filter{})
+	arbitrages := hub.Subscribe(Filter{Type: ArbitrageEvent})
+
+	for i := 0; i < subscriptionBuffer+10; i++ {
+		hub.Publish(&Event{Type: SwapEvent, Swap: &persistence.EnrichedSwapCH{}})
+	}
+	assert.Equal(t, subscriptionBuffer, len(all.Events))
+	assert.Equal(t, uint64(10), all.dropped)
+	assert.Equal(t, 0, len(arbitrages.Events))
+
+	hub.Unsubscribe(all)
+	hub.Unsubscribe(all)
+	assert.Equal(t, 1, hub.Subscribers())
+	count := 0
+	for range all.Events {
+		count++
+	}
+	assert.Equal(t, subscriptionBuffer, count)
+}
+
+func TestSeenForgetsRowsOutsideTheWindow(t *testing.T) {
+	s := newSeen()
+	start := time.Unix(1000, 0)
+	assert.True(t, s.add("a", start))
+	assert.False(t, s.add("a", start.Add(time.Second)))
+
+	s.prune(start)
+	assert.False(t, s.add("a", start.Add(time.Second)))
+	s.prune(start.Add(time.Second))
+	assert.True(t, s.add("a", start.Add(2*time.Second)))
+}
+
+func TestPagesReadPastTheLimit(t *testing.T) {
+	start := time.Unix(1000, 0)
+	var rows []persistence.EnrichedSwapCH
+	for i := 0; i < persistence.FeedLimit+10; i++ {
+		rows = append(rows, persistence.EnrichedSwapCH{Lt: uint64(i), CatchTime: start.Add(time.Duration(i) * time.Millisecond)})
+	}
+	read := func(since time.Time) ([]persistence.EnrichedSwapCH, error) {
+		var page []persistence.EnrichedSwapCH
+		for _, row := range rows {
+			if !row.CatchTime.Before(since) && len(page) < persistence.FeedLimit {
+				page = append(page, row)
+			}
+		}
+		return page, nil
+	}
+
+	published := map[uint64]bool{}
+	e := pages(start, read, func(swap *persistence.EnrichedSwapCH) { published[swap.Lt] = true },
+		func(swap *persistence.EnrichedSwapCH) time.Time { return swap.CatchTime })
+	assert.Nil(t, e)
+	assert.Equal(t, len(rows), len(published))
+}
@@ -0,0 +1,67 @@
+package feed
+
+import (
+	"log"
+	"sync"
+)
+
+// subscriptionBuffer is how many events a subscriber may lag behind before new ones are dropped for it
+const subscriptionBuffer = 256
+
+type Subscription struct {
+	Events  chan *Event
+	filter  Filter
+	dropped uint64
+}
+
+// Hub fans events out to subscribers, a slow subscriber loses events instead of blocking the others
+type Hub struct {
+	mutex       sync.Mutex
+	subscribers map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: map[*Subscription]struct{}{}}
+}
+
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	subscription := &Subscription{Events: make(chan *Event, subscriptionBuffer), filter: filter}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.subscribers[subscription] = struct{}{}
+	return subscription
+}
+
+// Unsubscribe closes the events channel of the subscription
+func (h *Hub) Unsubscribe(subscription *Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, exists := h.subscribers[subscription]; exists {
+		delete(h.subscribers, subscription)
+		close(subscription.Events)
+		if subscription.dropped > 0 {
+			log.Printf("Feed subscriber dropped %v events \n", subscription.dropped)
+		}
+	}
+}
+
+func (h *Hub) Publish(event *Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for subscription := range h.subscribers {
+		if !subscription.filter.Matches(event) {
+			continue
+		}
+		select {
+		case subscription.Events <- event:
+		default:
+			subscription.dropped++
+		}
+	}
+}
+
+func (h *Hub) Subscribers() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.subscribers)
+}
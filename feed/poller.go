@@ -0,0 +1,116 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"tondexer/persistence"
+)
+
+type PollerOptions struct {
+	Interval time.Duration
+	// Lookback covers rows written late, by buffered writers or after retries
+	Lookback time.Duration
+}
+
+var DefaultPollerOptions = PollerOptions{
+	Interval: 2 * time.Second,
+	Lookback: time.Minute,
+}
+
+// seen remembers published rows until they fall out of the lookback window
+type seen struct {
+	keys map[string]time.Time
+}
+
+func newSeen() *seen {
+	return &seen{keys: map[string]time.Time{}}
+}
+
+// add returns false for rows which were already published
+func (s *seen) add(key string, now time.Time) bool {
+	if _, exists := s.keys[key]; exists {
+		return false
+	}
+	s.keys[key] = now
+	return true
+}
+
+// prune forgets rows seen before the start of the window, queries of the window can't return them anymore
+func (s *seen) prune(since time.Time) {
+	for key, seenAt := range s.keys {
+		if seenAt.Before(since) {
+			delete(s.keys, key)
+		}
+	}
+}
+
+// Poll publishes swaps and arbitrages which appear in the store, listener and web run separately and share only the database.
+// Rows present at start are not published
+func Poll(ctx context.Context, store persistence.Store, hub *Hub, options PollerOptions) {
+	swaps := newSeen()
+	arbitrages := newSeen()
+	last := time.Now()
+	primed := false
+
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+	for {
+		started := time.Now()
+		since := last.Add(-options.Lookback)
+		e := poll(store, since, func(swap *persistence.EnrichedSwapCH) {
+			if swaps.add(fmt.Sprint(swap.PoolAddress, ":", swap.Lt), started) && primed {
+				hub.Publish(&Event{Type: SwapEvent, Swap: swap})
+			}
+		}, func(arbitrage *persistence.EnrichedArbitrageCH) {
+			if arbitrages.add(fmt.Sprint(arbitrage.Key), started) && primed {
+				hub.Publish(&Event{Type: ArbitrageEvent, Arbitrage: arbitrage})
+			}
+		})
+		if e != nil {
+			log.Printf("Feed poll failed: %v \n", e)
+		} else {
+			swaps.prune(since)
+			arbitrages.prune(since)
+			last = started
+			primed = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll pages each query by catch_time until it returns less than the limit, rows on the page boundary come twice and are deduplicated by seen
+func poll(store persistence.Store, since time.Time, onSwap func(*persistence.EnrichedSwapCH), onArbitrage func(*persistence.EnrichedArbitrageCH)) error {
+	e := pages(since, store.SwapsCaughtSince, onSwap, func(swap *persistence.EnrichedSwapCH) time.Time { return swap.CatchTime })
+	if e != nil {
+		return e
+	}
+	return pages(since, store.ArbitragesSince, onArbitrage, func(arbitrage *persistence.EnrichedArbitrageCH) time.Time { return arbitrage.CatchTime })
+}
+
+func pages[T any](since time.Time, read func(time.Time) ([]T, error), on func(*T), catchTime func(*T) time.Time) error {
+	for {
+		rows, e := read(since)
+		if e != nil {
+			return e
+		}
+		for i := range rows {
+			on(&rows[i])
+		}
+		if len(rows) < persistence.FeedLimit {
+			return nil
+		}
+		newest := catchTime(&rows[len(rows)-1])
+		if !newest.After(since) {
+			log.Printf("Feed poll skips rows: more than %v caught at %v \n", persistence.FeedLimit, since)
+			return nil
+		}
+		since = newest
+	}
+}
@@ -0,0 +1,115 @@
+// Package fixtures records traces of tonapi and loads them, helpers of tests reading them are in fixturestest
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/tonkeeper/tonapi-go"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Dirs are relative to the package under test, go test runs in its directory
+const (
+	TracesDir = "testdata/traces"
+	GoldenDir = "testdata/golden"
+)
+
+// volatileFields are the wall clock of the extraction, they differ on every run
+var volatileFields = map[string]bool{
+	"CatchTime":      true,
+	"EventCatchTime": true,
+}
+
+func tracePath(dir string, id string) string {
+	return filepath.Join(dir, id+".json")
+}
+
+// Fetch loads the trace from tonapi, id is the hash of any transaction of the trace
+func Fetch(client *tonapi.Client, id string) (*tonapi.Trace, error) {
+	return client.GetTrace(context.Background(), tonapi.GetTraceParams{TraceID: id})
+}
+
+// WriteTrace stores the trace indented under the id it was requested by, so reviews show what the fixture contains
+func WriteTrace(dir string, id string, trace *tonapi.Trace) error {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return errors.New("invalid trace id " + id)
+	}
+	data, e := trace.MarshalJSON()
+	if e != nil {
+		return e
+	}
+	var indented bytes.Buffer
+	if e := json.Indent(&indented, data, "", "  "); e != nil {
+		return e
+	}
+	if e := os.MkdirAll(dir, 0755); e != nil {
+		return e
+	}
+	return os.WriteFile(tracePath(dir, id), append(indented.Bytes(), '\n'), 0644)
+}
+
+func ReadTrace(dir string, id string) (*tonapi.Trace, error) {
+	data, e := os.ReadFile(tracePath(dir, id))
+	if e != nil {
+		return nil, e
+	}
+	var trace tonapi.Trace
+	if e := trace.UnmarshalJSON(data); e != nil {
+		return nil, e
+	}
+	return &trace, nil
+}
+
+// Recorded lists ids of all traces recorded into the directory, TracesDir of another package is ../<package>/testdata/traces
+func Recorded(dir string) ([]string, error) {
+	files, e := filepath.Glob(filepath.Join(dir, "*.json"))
+	if e != nil {
+		return nil, e
+	}
+	var ids []string
+	for _, file := range files {
+		ids = append(ids, strings.TrimSuffix(filepath.Base(file), ".json"))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// GoldenJson is the indented json of the value without the wall clock fields, as it is kept in GoldenDir
+func GoldenJson(value any) ([]byte, error) {
+	data, e := json.Marshal(value)
+	if e != nil {
+		return nil, e
+	}
+	var generic any
+	if e := json.Unmarshal(data, &generic); e != nil {
+		return nil, e
+	}
+	data, e = json.MarshalIndent(withoutVolatileFields(generic), "", "  ")
+	if e != nil {
+		return nil, e
+	}
+	return append(data, '\n'), nil
+}
+
+func withoutVolatileFields(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, field := range typed {
+			if volatileFields[key] {
+				delete(typed, key)
+			} else {
+				typed[key] = withoutVolatileFields(field)
+			}
+		}
+	case []any:
+		for i := range typed {
+			typed[i] = withoutVolatileFields(typed[i])
+		}
+	}
+	return value
+}
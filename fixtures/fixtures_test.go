@@ -0,0 +1,37 @@
+package fixtures
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tonkeeper/tonapi-go"
+	"os"
+	"testing"
+	"time"
+)
+
+type extracted struct {
+	Hash           string
+	EventCatchTime time.Time
+	Nested         []map[string]any
+}
+
+func TestTraceRoundTrip(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "fixtures")
+	defer os.RemoveAll(dir)
+
+	trace := &tonapi.Trace{Transaction: tonapi.Transaction{Hash: "root", Lt: 7}}
+	assert.Nil(t, WriteTrace(dir, "child", trace))
+	assert.NotNil(t, WriteTrace(dir, "../child", trace))
+
+	read, e := ReadTrace(dir, "child")
+	assert.Nil(t, e)
+	assert.Equal(t, int64(7), read.Transaction.Lt)
+	recorded, e := Recorded(dir)
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"child"}, recorded)
+}
+
+func TestGoldenJsonIgnoresCatchTime(t *testing.T) {
+	data, e := GoldenJson(extracted{Hash: "a", EventCatchTime: time.Now(), Nested: []map[string]any{{"CatchTime": 1, "Lt": 2}}})
+	assert.Nil(t, e)
+	assert.JSONEq(t, `{"Hash": "a", "Nested": [{"Lt": 2}]}`, string(data))
+}
@@ -0,0 +1,86 @@
+// Package fixturestest reads recorded traces and golden files in tests, -update records the missing ones
+package fixturestest
+
+import (
+	"errors"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/tonkeeper/tonapi-go"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"tondexer/fixtures"
+)
+
+// update regenerates golden files and records traces which are fetched from tonapi: go test ./stonfi -update
+var update = flag.Bool("update", false, "rewrite golden files and record missing traces")
+
+// Trace returns the recorded trace, a missing one fails the test unless -update fetches it from tonapi and records it
+func Trace(t *testing.T, id string) *tonapi.Trace {
+	t.Helper()
+	trace, e := fixtures.ReadTrace(fixtures.TracesDir, id)
+	if e == nil {
+		return trace
+	}
+	if !errors.Is(e, os.ErrNotExist) {
+		t.Fatalf("broken fixture %v: %v", id, e)
+	}
+	if !*update {
+		t.Fatalf("trace %v is not recorded, run the test with -update to record it", id)
+	}
+
+	client, _ := tonapi.New()
+	trace, e = fixtures.Fetch(client, id)
+	if e != nil {
+		t.Fatalf("unable to record trace %v: %v", id, e)
+	}
+	if e := fixtures.WriteTrace(fixtures.TracesDir, id, trace); e != nil {
+		t.Fatalf("unable to record trace %v: %v", id, e)
+	}
+	return trace
+}
+
+// Traces lists the ids followed by every other trace recorded into dir, a golden test without traces fails instead of
+// passing with nothing compared
+func Traces(t *testing.T, dir string, ids ...string) []string {
+	t.Helper()
+	recorded, e := fixtures.Recorded(dir)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, id := range recorded {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		t.Fatalf("no traces recorded in %v", dir)
+	}
+	return ids
+}
+
+// Golden compares json of the value with testdata/golden/<name>.json, -update writes the file instead
+func Golden(t *testing.T, name string, actual any) {
+	t.Helper()
+	data, e := fixtures.GoldenJson(actual)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	path := filepath.Join(fixtures.GoldenDir, name+".json")
+	if *update {
+		if e := os.MkdirAll(filepath.Dir(path), 0755); e != nil {
+			t.Fatal(e)
+		}
+		if e := os.WriteFile(path, data, 0644); e != nil {
+			t.Fatal(e)
+		}
+		return
+	}
+	expected, e := os.ReadFile(path)
+	if e != nil {
+		t.Fatalf("golden file %v is missing, run the test with -update to create it: %v", path, e)
+	}
+	assert.JSONEq(t, string(expected), string(data), path)
+}
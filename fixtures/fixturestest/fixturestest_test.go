@@ -0,0 +1,39 @@
+package fixturestest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tonkeeper/tonapi-go"
+	"os"
+	"testing"
+	"time"
+	"tondexer/fixtures"
+)
+
+func TestTracesListsRecordedAfterListed(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "fixtures")
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, fixtures.WriteTrace(dir, "child", &tonapi.Trace{}))
+	assert.Nil(t, fixtures.WriteTrace(dir, "other", &tonapi.Trace{}))
+	assert.Equal(t, []string{"listed", "child", "other"}, Traces(t, dir, "listed", "child"))
+}
+
+func TestGoldenIgnoresCatchTime(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "golden")
+	defer os.RemoveAll(dir)
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+	assert.Nil(t, os.Chdir(dir))
+
+	value := map[string]any{"Hash": "a", "CatchTime": time.Now()}
+	*update = true
+	Golden(t, "swaps", value)
+	*update = false
+
+	data, e := os.ReadFile(fixtures.GoldenDir + "/swaps.json")
+	assert.Nil(t, e)
+	assert.JSONEq(t, `{"Hash": "a"}`, string(data))
+
+	value["CatchTime"] = time.Now().Add(time.Hour)
+	Golden(t, "swaps", value)
+}
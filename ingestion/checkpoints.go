@@ -0,0 +1,80 @@
+package ingestion
+
+import (
+	"sync"
+	"time"
+	"tondexer/core"
+	"tondexer/models"
+	"tondexer/persistence"
+)
+
+// CheckpointTracker keeps the last processed lt of every account and persists the changed ones
+type CheckpointTracker struct {
+	mu      sync.Mutex
+	lts     map[string]uint64
+	changed map[string]bool
+}
+
+func NewCheckpointTracker(checkpoints []models.IngestionCheckpoint) *CheckpointTracker {
+	tracker := &CheckpointTracker{
+		lts:     map[string]uint64{},
+		changed: map[string]bool{},
+	}
+	for _, checkpoint := range checkpoints {
+		tracker.lts[checkpoint.Account] = checkpoint.Lt
+	}
+	return tracker
+}
+
+func LoadCheckpointTracker(config *core.DbConfig) (*CheckpointTracker, error) {
+	checkpoints, e := persistence.ReadIngestionCheckpoints(config)
+	if e != nil {
+		return nil, e
+	}
+	return NewCheckpointTracker(checkpoints), nil
+}
+
+func (tracker *CheckpointTracker) Checkpoint(account string) (uint64, bool) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	lt, exists := tracker.lts[account]
+	return lt, exists
+}
+
+func (tracker *CheckpointTracker) Observe(account string, lt uint64) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if lt > tracker.lts[account] {
+		tracker.lts[account] = lt
+		tracker.changed[account] = true
+	}
+}
+
+func (tracker *CheckpointTracker) Flush(config *core.DbConfig) error {
+	tracker.mu.Lock()
+	var checkpoints []*models.IngestionCheckpoint
+	for account := range tracker.changed {
+		checkpoints = append(checkpoints, &models.IngestionCheckpoint{
+			Account: account,
+			Lt:      tracker.lts[account],
+			Time:    time.Now(),
+		})
+	}
+	tracker.changed = map[string]bool{}
+	tracker.mu.Unlock()
+
+	if len(checkpoints) == 0 {
+		return nil
+	}
+	if e := persistence.WriteIngestionCheckpoints(config, checkpoints); e != nil {
+		tracker.mu.Lock()
+		for _, checkpoint := range checkpoints {
+			tracker.changed[checkpoint.Account] = true
+		}
+		tracker.mu.Unlock()
+		return e
+	}
+	return nil
+}
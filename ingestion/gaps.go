@@ -0,0 +1,98 @@
+package ingestion
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"tondexer/core"
+	"tondexer/models"
+	"tondexer/persistence"
+	"tondexer/traces"
+)
+
+const gapPageSize = 100
+
+// GapFiller finds transactions missed between the checkpoint and the first streamed transaction of every account
+type GapFiller struct {
+	Store           persistence.Store
+	ConsoleApi      *core.TonConsoleApi
+	Checkpoints     *CheckpointTracker
+	MaxTransactions int
+
+	mu      sync.Mutex
+	started map[string]bool
+}
+
+func NewGapFiller(store persistence.Store, consoleApi *core.TonConsoleApi, checkpoints *CheckpointTracker, maxTransactions int) *GapFiller {
+	return &GapFiller{
+		Store:           store,
+		ConsoleApi:      consoleApi,
+		Checkpoints:     checkpoints,
+		MaxTransactions: maxTransactions,
+		started:         map[string]bool{},
+	}
+}
+
+// GapBefore returns the checkpoint lt if the event is the first streamed one of its account and there is a gap before it
+func (filler *GapFiller) GapBefore(event traces.TransactionEvent) (uint64, bool) {
+	filler.mu.Lock()
+	defer filler.mu.Unlock()
+
+	if filler.started[event.Account] {
+		return 0, false
+	}
+	filler.started[event.Account] = true
+
+	checkpoint, exists := filler.Checkpoints.Checkpoint(event.Account)
+	if !exists || checkpoint+1 >= event.Lt {
+		return 0, false
+	}
+	return checkpoint, true
+}
+
+// Fill returns transactions of the account with fromLt < lt < toLt. The part which is not possible to fetch is recorded as a gap
+func (filler *GapFiller) Fill(account string, fromLt uint64, toLt uint64) []traces.TransactionEvent {
+	var events []traces.TransactionEvent
+	beforeLt := toLt
+	for {
+		if len(events) >= filler.MaxTransactions {
+			filler.RecordGap(account, fromLt, beforeLt, fmt.Sprintf("gap is larger than %v transactions", filler.MaxTransactions))
+			return events
+		}
+		transactions, e := filler.ConsoleApi.AccountTransactions(account, beforeLt, gapPageSize)
+		if e != nil {
+			filler.RecordGap(account, fromLt, beforeLt, e.Error())
+			return events
+		}
+		for _, transaction := range transactions {
+			if uint64(transaction.Lt) <= fromLt {
+				log.Printf("Filled gap of %v transactions for %v \n", len(events), account)
+				return events
+			}
+			events = append(events, traces.TransactionEvent{
+				Account: account,
+				Lt:      uint64(transaction.Lt),
+				Hash:    transaction.Hash,
+			})
+		}
+		if len(transactions) < gapPageSize {
+			return events
+		}
+		beforeLt = uint64(transactions[len(transactions)-1].Lt)
+	}
+}
+
+func (filler *GapFiller) RecordGap(account string, fromLt uint64, toLt uint64, reason string) {
+	log.Printf("Warning: gap for %v between lt %v and %v: %v \n", account, fromLt, toLt, reason)
+	gap := &models.IngestionGap{
+		Account: account,
+		FromLt:  fromLt,
+		ToLt:    toLt,
+		Reason:  reason,
+		Time:    time.Now(),
+	}
+	if e := filler.Store.SaveIngestionGaps([]*models.IngestionGap{gap}); e != nil {
+		log.Printf("Warning: Unable to save ingestion gap %v\n", e)
+	}
+}
@@ -0,0 +1,42 @@
+package ingestion
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"tondexer/models"
+	"tondexer/traces"
+)
+
+func TestGapBeforeFirstTransactionOnly(t *testing.T) {
+	tracker := NewCheckpointTracker([]models.IngestionCheckpoint{{Account: "A", Lt: 100}})
+	filler := NewGapFiller(nil, nil, tracker, 10)
+
+	checkpoint, exists := filler.GapBefore(traces.TransactionEvent{Account: "A", Lt: 150, Hash: "1"})
+	assert.True(t, exists)
+	assert.Equal(t, uint64(100), checkpoint)
+
+	_, exists = filler.GapBefore(traces.TransactionEvent{Account: "A", Lt: 200, Hash: "2"})
+	assert.False(t, exists)
+}
+
+func TestNoGapWithoutCheckpointOrForNextTransaction(t *testing.T) {
+	tracker := NewCheckpointTracker([]models.IngestionCheckpoint{{Account: "A", Lt: 100}})
+	filler := NewGapFiller(nil, nil, tracker, 10)
+
+	_, exists := filler.GapBefore(traces.TransactionEvent{Account: "B", Lt: 150, Hash: "1"})
+	assert.False(t, exists)
+
+	_, exists = filler.GapBefore(traces.TransactionEvent{Account: "A", Lt: 101, Hash: "2"})
+	assert.False(t, exists)
+}
+
+func TestCheckpointTrackerKeepsMaxLt(t *testing.T) {
+	tracker := NewCheckpointTracker(nil)
+	tracker.Observe("A", 10)
+	tracker.Observe("A", 5)
+
+	lt, exists := tracker.Checkpoint("A")
+	assert.True(t, exists)
+	assert.Equal(t, uint64(10), lt)
+	assert.True(t, tracker.changed["A"])
+}
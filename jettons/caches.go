@@ -2,57 +2,58 @@ package jettons
 
 import (
 	"context"
-	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/eko/gocache/lib/v4/cache"
 	gocache_store "github.com/eko/gocache/store/go_cache/v4"
 	gocache "github.com/patrickmn/go-cache"
 	"log"
 	"time"
 	"tondexer/core"
+	"tondexer/metrics"
 	"tondexer/models"
 	"tondexer/persistence"
+	"tondexer/spool"
 )
 
+const (
+	jettonInfoCacheName   = "jetton_info"
+	usdRateCacheName      = "usd_rate"
+	walletJettonCacheName = "wallet_jetton"
+)
+
+// cachedGet counts lookups, misses are counted by the load function
+func cachedGet(name string, cacheManager *cache.LoadableCache[any], key string) (any, error) {
+	metrics.CacheRequests.WithLabelValues(name).Inc()
+	return cacheManager.Get(context.Background(), key)
+}
+
+// newCacheWriter buffers entries loaded into a cache, failed batches are dead lettered as kind
+func newCacheWriter[T any](kind string, insert func([]*T) error, writerOptions persistence.WriterOptions) *persistence.Writer[T] {
+	writer := persistence.NewBatchWriter(kind, insert, writerOptions)
+	if e := writer.ReplayDeadLetters(); e != nil {
+		log.Printf("Warning: Unable to replay dead letters of %v: %v \n", kind, e)
+	}
+	return writer
+}
+
+// initCache persists loaded entries with writer unless it's nil
 func initCache[T any](
-	config *core.DbConfig,
-	table string,
+	name string,
+	writer *persistence.Writer[T],
 	cacheFunction func(key any) (*T, error),
-	batchAppendFunc func(batch driver.Batch, model *T) error,
 	initCacheFunction func(cacheManager *cache.LoadableCache[any]) error) (*cache.LoadableCache[any], error) {
 
 	gocacheClient := gocache.New(gocache.NoExpiration, gocache.NoExpiration)
 	gocacheStore := gocache_store.NewGoCache(gocacheClient)
 
-	chChannel := make(chan *T)
-	ticker := time.NewTicker(10 * time.Second)
-
 	loadFunction := func(ctx context.Context, key any) (any, error) {
+		metrics.CacheMisses.WithLabelValues(name).Inc()
 		result, err := cacheFunction(key)
-		if result != nil {
-			go func() { chChannel <- result }()
+		if result != nil && writer != nil {
+			writer.Write(result)
 		}
 		return result, err
 	}
 
-	var toPersist []*T
-
-	if table != "" {
-		go func() {
-			for {
-				select {
-				case model := <-chChannel:
-					toPersist = append(toPersist, model)
-				case <-ticker.C:
-					if len(toPersist) > 0 {
-						if e := persistence.WriteToClickhouse(config, toPersist, table, batchAppendFunc); e == nil {
-							toPersist = []*T{}
-						}
-					}
-				}
-			}
-		}()
-	}
-
 	// any because go-cache is supporting only any
 	cacheManager := cache.NewLoadable[any](loadFunction, gocacheStore)
 
@@ -63,23 +64,15 @@ func initCache[T any](
 	return cacheManager, nil
 }
 
-func InitJettonInfoCache(config *core.DbConfig, api *core.TonConsoleApi) (*cache.LoadableCache[any], error) {
-	return initCache[models.ChainTokenInfo](
-		config,
-		"clickhouse_jetton",
+func InitJettonInfoCache(store persistence.Store, api *core.TonConsoleApi, writer *persistence.Writer[models.ChainTokenInfo]) (*cache.LoadableCache[any], error) {
+	return initCache(
+		jettonInfoCacheName,
+		writer,
 		func(key any) (*models.ChainTokenInfo, error) {
 			return api.JettonInfoByMaster(key.(string))
 		},
-		func(batch driver.Batch, model *models.ChainTokenInfo) error {
-			return batch.Append(
-				model.Name,
-				model.Symbol,
-				model.JettonAddress,
-				model.Decimals,
-			)
-		},
 		func(cacheManager *cache.LoadableCache[any]) error {
-			chJettons, e := persistence.ReadClickhouseJettons(config)
+			chJettons, e := store.Jettons()
 			if e != nil {
 				return e
 			}
@@ -98,10 +91,11 @@ func InitJettonInfoCache(config *core.DbConfig, api *core.TonConsoleApi) (*cache
 		})
 }
 
-func InitUsdRateCache(config *core.DbConfig, consoleApi *core.TonConsoleApi) (*cache.LoadableCache[any], error) {
+// InitUsdRateCache refreshes the rates hourly and saves them with ratesWriter
+func InitUsdRateCache(store persistence.Store, consoleApi *core.TonConsoleApi, ratesWriter *persistence.Writer[models.JettonRate]) (*cache.LoadableCache[any], error) {
 	cacheManager, err := initCache[float64](
-		config,
-		"",
+		usdRateCacheName,
+		nil,
 		func(key any) (*float64, error) {
 			rate, e := consoleApi.JettonRateToUsdByMaster(key.(string))
 			if e != nil {
@@ -109,10 +103,9 @@ func InitUsdRateCache(config *core.DbConfig, consoleApi *core.TonConsoleApi) (*c
 			}
 			return &rate, nil
 		},
-		func(batch driver.Batch, model *float64) error { return nil },
 		func(cacheManager *cache.LoadableCache[any]) error {
 			go func() {
-				walletToMasters, e := persistence.ReadWalletMasters(config)
+				walletToMasters, e := store.WalletMasters()
 				if e != nil {
 					return
 				}
@@ -137,64 +130,41 @@ func InitUsdRateCache(config *core.DbConfig, consoleApi *core.TonConsoleApi) (*c
 	go func() {
 		time.Sleep(20 * time.Minute)
 		for range ticker.C {
-			recalculateUsdRates(config, consoleApi, cacheManager)
+			recalculateUsdRates(store, consoleApi, cacheManager, ratesWriter)
 		}
 	}()
 
 	return cacheManager, err
 }
 
-func recalculateUsdRates(config *core.DbConfig, consoleApi *core.TonConsoleApi, cacheManager *cache.LoadableCache[any]) {
-	jettons, e := persistence.ReadClickhouseJettons(config)
+func recalculateUsdRates(store persistence.Store, consoleApi *core.TonConsoleApi, cacheManager *cache.LoadableCache[any], ratesWriter *persistence.Writer[models.JettonRate]) {
+	jettons, e := store.Jettons()
 	if e != nil {
 		log.Printf("Unable to read jetton from CH: %v \n", e)
 	}
 	log.Printf("Loaded %v jettons for rates updates \n", len(jettons))
 
-	writeBatch := func(jettonRates []*models.JettonRate) error {
-		return persistence.WriteToClickhouse(config, jettonRates, "jetton_rates", func(batch driver.Batch, m *models.JettonRate) error {
-			return batch.Append(
-				m.Time,
-				m.Name,
-				m.Symbol,
-				m.Master,
-				m.Decimals,
-				m.Rate,
-			)
-		})
-	}
-
-	var jettonRates []*models.JettonRate
-	for i, jetton := range jettons {
+	for _, jetton := range jettons {
 		if rate, e := consoleApi.JettonRateToUsdByMaster(jetton.Master); e == nil {
 			if e := cacheManager.Set(context.Background(), jetton.Master, &rate); e != nil {
 				log.Printf("Unable to set jetton cache entry %v \n", jetton.Symbol)
 			}
-			jettonRate := &models.JettonRate{
+			ratesWriter.Write(&models.JettonRate{
 				Time:     time.Now(),
 				Name:     jetton.Name,
 				Symbol:   jetton.Symbol,
 				Master:   jetton.Master,
 				Decimals: jetton.Decimals,
 				Rate:     rate,
-			}
-			jettonRates = append(jettonRates, jettonRate)
-		}
-		if i%40 == 0 {
-			if e := writeBatch(jettonRates); e == nil {
-				jettonRates = []*models.JettonRate{}
-			} else {
-				log.Printf("Unable to write jetton rates %v \n", e)
-			}
+			})
 		}
 	}
-	writeBatch(jettonRates)
 }
 
-func InitWalletJettonCache(config *core.DbConfig) (*cache.LoadableCache[any], error) {
-	return initCache[models.WalletJetton](
-		config,
-		"wallet_to_master",
+func InitWalletJettonCache(store persistence.Store, writer *persistence.Writer[models.WalletJetton]) (*cache.LoadableCache[any], error) {
+	return initCache(
+		walletJettonCacheName,
+		writer,
 		func(key any) (*models.WalletJetton, error) {
 			tonApi, e := GetTonApi()
 			if e != nil {
@@ -208,14 +178,8 @@ func InitWalletJettonCache(config *core.DbConfig) (*cache.LoadableCache[any], er
 				Wallet: key.(string),
 				Master: master.String()}, nil
 		},
-		func(batch driver.Batch, model *models.WalletJetton) error {
-			return batch.Append(
-				model.Wallet,
-				model.Master,
-			)
-		},
 		func(cacheManager *cache.LoadableCache[any]) error {
-			walletToMasters, e := persistence.ReadWalletMasters(config)
+			walletToMasters, e := store.WalletMasters()
 			if e != nil {
 				return e
 			}
@@ -227,3 +191,74 @@ func InitWalletJettonCache(config *core.DbConfig) (*cache.LoadableCache[any], er
 		},
 	)
 }
+
+type TokenCaches struct {
+	WalletToMaster func(wallet string) *models.ChainTokenInfo
+	Master         func(master string) *models.ChainTokenInfo
+	UsdRate        func(master string) *float64
+
+	writersClose []func()
+}
+
+// Close saves the jettons, wallets and rates still buffered, the caches must not be used afterwards
+func (caches *TokenCaches) Close() {
+	for _, closeWriter := range caches.writersClose {
+		closeWriter()
+	}
+}
+
+// InitTokenCaches persists newly loaded jettons through buffered writers, batches which fail all retries go to deadLetter
+func InitTokenCaches(store persistence.Store, consoleApi *core.TonConsoleApi, deadLetter *spool.Spool) (*TokenCaches, error) {
+	writerOptions := persistence.DefaultWriterOptions
+	writerOptions.FlushInterval = 10 * time.Second
+	writerOptions.DeadLetter = deadLetter
+
+	jettonWriter := newCacheWriter("clickhouse_jetton", store.SaveJettons, writerOptions)
+	walletWriter := newCacheWriter("wallet_to_master", store.SaveWalletMasters, writerOptions)
+	ratesWriter := newCacheWriter("jetton_rates", store.SaveRates, writerOptions)
+	caches := &TokenCaches{writersClose: []func(){jettonWriter.Close, walletWriter.Close, ratesWriter.Close}}
+
+	jettonInfoCache, e := InitJettonInfoCache(store, consoleApi, jettonWriter)
+	if e != nil {
+		caches.Close()
+		return nil, e
+	}
+
+	walletMasterCache, e := InitWalletJettonCache(store, walletWriter)
+	if e != nil {
+		caches.Close()
+		return nil, e
+	}
+
+	usdRateCache, e := InitUsdRateCache(store, consoleApi, ratesWriter)
+	if e != nil {
+		caches.Close()
+		return nil, e
+	}
+
+	masterFunc := func(master string) *models.ChainTokenInfo {
+		info, e := cachedGet(jettonInfoCacheName, jettonInfoCache, master)
+		if e != nil {
+			log.Printf("Unable to get jetton info for %v \n", master)
+			return nil
+		}
+		return info.(*models.ChainTokenInfo)
+	}
+
+	caches.WalletToMaster = func(wallet string) *models.ChainTokenInfo {
+		master, e := cachedGet(walletJettonCacheName, walletMasterCache, wallet)
+		if e != nil {
+			return nil
+		}
+		return masterFunc(master.(*models.WalletJetton).Master)
+	}
+	caches.Master = masterFunc
+	caches.UsdRate = func(master string) *float64 {
+		rate, e := cachedGet(usdRateCacheName, usdRateCache, master)
+		if e != nil {
+			return nil
+		}
+		return rate.(*float64)
+	}
+	return caches, nil
+}
@@ -1,13 +1,17 @@
+//go:build live
+
+// The tests resolve jettons through tonapi and liteservers: go test -tags live ./listener
+
 package main
 
 import (
-	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/tonkeeper/tonapi-go"
 	"math/big"
 	"testing"
 	"tondexer/core"
 	"tondexer/dedust"
+	"tondexer/fixtures/fixturestest"
 	"tondexer/jettons"
 	"tondexer/models"
 )
@@ -35,9 +39,7 @@ func rateCache(s string) *float64 {
 
 func TestSingleSwap(t *testing.T) {
 	//03090d15f57f01a13b32b24cacc87ee82c34bafa5dc2302948449afbcda4cb8e
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "03090d15f57f01a13b32b24cacc87ee82c34bafa5dc2302948449afbcda4cb8e"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "03090d15f57f01a13b32b24cacc87ee82c34bafa5dc2302948449afbcda4cb8e")
 
 	swapInfos := dedust.ExtractDedustSwapsFromRootTrace(trace)
 	assert.Equal(t, 1, len(swapInfos))
@@ -69,9 +71,7 @@ func TestSingleSwap(t *testing.T) {
 }
 
 func TestThreeCycleSwap(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "b3e9443a3d2c4a41863c71943ca3ba6b7e56beeb130918b195248299ff325fa2"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "b3e9443a3d2c4a41863c71943ca3ba6b7e56beeb130918b195248299ff325fa2")
 
 	swapInfos := dedust.ExtractDedustSwapsFromRootTrace(trace)
 	assert.Equal(t, 1, len(swapInfos))
@@ -135,9 +135,7 @@ func TestThreeCycleSwap(t *testing.T) {
 }
 
 func TestThreeCycleWithOneFailed(t *testing.T) {
-	client, _ := tonapi.New()
-	params := tonapi.GetTraceParams{TraceID: "d5c23c14919b0542928a1fe5e63d71110c82a5f7084e08442b2ba2589d89cc49"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "d5c23c14919b0542928a1fe5e63d71110c82a5f7084e08442b2ba2589d89cc49")
 
 	swapInfos := dedust.ExtractDedustSwapsFromRootTrace(trace)
 	assert.Equal(t, 1, len(swapInfos))
@@ -2,65 +2,142 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/tonkeeper/tonapi-go"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 	"tondexer/arbitrage"
 	"tondexer/common"
 	"tondexer/core"
-	"tondexer/dedust"
+	"tondexer/ingestion"
 	"tondexer/jettons"
+	"tondexer/metrics"
+	"tondexer/mev"
+	"tondexer/migrations"
 	"tondexer/models"
 	"tondexer/persistence"
-	"tondexer/stonfi"
-	"tondexer/stonfiv2"
+	"tondexer/pipeline"
+	"tondexer/pools"
+	"tondexer/pricing"
+	"tondexer/registry"
+	"tondexer/spool"
+	"tondexer/traces"
 )
 
 type Config struct {
-	ConsoleToken      string   `yaml:"console_token" env:"CONSOLE_TOKEN" env-default:""`
-	DbHost            string   `yaml:"db_host" env:"DB_HOST" env-default:"localhost"`
-	DbPort            uint     `yaml:"db_port" env:"DB_PORT" env-default:"9000"`
-	DbUser            string   `yaml:"db_user" env:"DB_USER" env-default:"default"`
-	DbPassword        string   `yaml:"db_password" env:"DB_PASSWORD" env-default:""`
-	DbName            string   `yaml:"db_name" env:"DB_NAME" env-default:"default"`
-	StonfiV1Addresses []string `yaml:"stonfiv1_addresses" env:"STONFIV1_ADDRESSES" env-default:""`
-	StonfiV2Addresses []string `yaml:"stonfiv2_addresses" env:"STONFIV2_ADDRESSES" env-default:""`
-	DedustAddresses   []string `yaml:"dedust_addresses" env:"DEDUST_ADDRESSES" env-default:""`
+	ConsoleToken           string        `yaml:"console_token" env:"CONSOLE_TOKEN" env-default:""`
+	DbHost                 string        `yaml:"db_host" env:"DB_HOST" env-default:"localhost"`
+	DbPort                 uint          `yaml:"db_port" env:"DB_PORT" env-default:"9000"`
+	DbUser                 string        `yaml:"db_user" env:"DB_USER" env-default:"default"`
+	DbPassword             string        `yaml:"db_password" env:"DB_PASSWORD" env-default:""`
+	DbName                 string        `yaml:"db_name" env:"DB_NAME" env-default:"default"`
+	StonfiV1Addresses      []string      `yaml:"stonfiv1_addresses" env:"STONFIV1_ADDRESSES" env-default:""`
+	StonfiV2Addresses      []string      `yaml:"stonfiv2_addresses" env:"STONFIV2_ADDRESSES" env-default:""`
+	DedustAddresses        []string      `yaml:"dedust_addresses" env:"DEDUST_ADDRESSES" env-default:""`
+	ToncoAddresses         []string      `yaml:"tonco_addresses" env:"TONCO_ADDRESSES" env-default:""`
+	RegistryReloadInterval time.Duration `yaml:"registry_reload_interval" env:"REGISTRY_RELOAD_INTERVAL" env-default:"5m"`
+	MetricsAddress         string        `yaml:"metrics_address" env:"METRICS_ADDRESS" env-default:":9100"`
+	SpoolDir               string        `yaml:"spool_dir" env:"SPOOL_DIR" env-default:".spool"`
+	ShutdownTimeout        time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"60s"`
+	TraceSource            string        `yaml:"trace_source" env:"TRACE_SOURCE" env-default:"tonapi"`
+	TracesDir              string        `yaml:"traces_dir" env:"TRACES_DIR" env-default:""`
+	RecordTracesDir        string        `yaml:"record_traces_dir" env:"RECORD_TRACES_DIR" env-default:""`
+	TraceArchiveDir        string        `yaml:"trace_archive_dir" env:"TRACE_ARCHIVE_DIR" env-default:""`
+	GapMaxTransactions     int           `yaml:"gap_max_transactions" env:"GAP_MAX_TRANSACTIONS" env-default:"2000"`
+	PriceWindow            time.Duration `yaml:"price_window" env:"PRICE_WINDOW" env-default:"24h"`
+	MevLtWindow            uint64        `yaml:"mev_lt_window" env:"MEV_LT_WINDOW" env-default:"10000000"`
+	// PoolSnapshotInterval of zero disables pool snapshots, TONCO pools are not snapshotted
+	PoolSnapshotInterval time.Duration `yaml:"pool_snapshot_interval" env:"POOL_SNAPSHOT_INTERVAL" env-default:"1h"`
+	// PoolSnapshotActiveWithin skips pools without swaps in the period
+	PoolSnapshotActiveWithin time.Duration `yaml:"pool_snapshot_active_within" env:"POOL_SNAPSHOT_ACTIVE_WITHIN" env-default:"168h"`
+	PoolSnapshotWorkers      int           `yaml:"pool_snapshot_workers" env:"POOL_SNAPSHOT_WORKERS" env-default:"8"`
+	// Store is clickhouse or memory. The memory one keeps no checkpoints, liquidity events or pool snapshots, so it only suits running the pipeline locally
+	Store string `yaml:"store" env:"STORE" env-default:"clickhouse"`
 }
 
-func subscribeToAccounts(streamingApi *tonapi.StreamingAPI, accounts []string, incomingTransactionsChannel chan string) {
-	for {
-		e := streamingApi.WebsocketHandleRequests(context.Background(), func(ws tonapi.Websocket) error {
-			ws.SetTransactionHandler(func(data tonapi.TransactionEventData) {
-				//log.Printf("New tx with hash: %v lt: %v \n", data.TxHash, data.Lt)
-				go func() {
-					incomingTransactionsChannel <- data.TxHash
-				}()
-			})
-			if err := ws.SubscribeToTransactions(accounts, nil); err != nil {
-				return err
-			}
+// abandonTimeout bounds spooling rows of a writer after the drain timed out, an insert in flight may hold them
+const abandonTimeout = 10 * time.Second
 
-			return nil
-		})
-		if e != nil {
-			log.Printf("Streaming failed! for accounts %v: %v \n", accounts, e)
+// extractedBatch is what one batch of transactions yields, Processed holds the highest processed lt of every account
+type extractedBatch struct {
+	Swaps           []*models.SwapCH
+	LiquidityEvents []*models.LiquidityEventCH
+	Processed       map[string]uint64
+}
+
+// afterAll returns a callback which calls done on its n-th call
+func afterAll(n int32, done func()) func() {
+	var remaining atomic.Int32
+	remaining.Store(n)
+	return func() {
+		if remaining.Add(-1) == 0 {
+			done()
 		}
-		time.Sleep(1 * time.Second)
 	}
 }
 
-func swapInfoWithDex(infos []*models.SwapInfo, dex string) []core.Pair[*models.SwapInfo, string] {
-	return common.Map(infos, func(swapInfo *models.SwapInfo) core.Pair[*models.SwapInfo, string] {
-		return core.Pair[*models.SwapInfo, string]{
-			First:  swapInfo,
-			Second: dex,
-		}
+// replaySpool saves batches that failed to persist before the restart
+func replaySpool(swapWriter *persistence.Writer[models.SwapCH], tradeWriter *persistence.Writer[models.TradeCH], liquidityWriter *persistence.Writer[models.LiquidityEventCH], arbitrageWriter *persistence.Writer[models.ArbitrageCH], mevWriter *persistence.Writer[models.MevEventCH]) {
+	if e := swapWriter.ReplayDeadLetters(); e != nil {
+		log.Printf("Warning: Unable to replay spooled swaps %v\n", e)
+	}
+	if e := tradeWriter.ReplayDeadLetters(); e != nil {
+		log.Printf("Warning: Unable to replay spooled trades %v\n", e)
+	}
+	if e := arbitrageWriter.ReplayDeadLetters(); e != nil {
+		log.Printf("Warning: Unable to replay spooled arbitrages %v\n", e)
+	}
+	if e := mevWriter.ReplayDeadLetters(); e != nil {
+		log.Printf("Warning: Unable to replay spooled mev events %v\n", e)
+	}
+	if e := liquidityWriter.ReplayDeadLetters(); e != nil {
+		log.Printf("Warning: Unable to replay spooled liquidity events %v\n", e)
+	}
+}
+
+func configuredAccounts(cfg *Config) []*models.DexAccount {
+	return registry.ConfiguredAccounts(map[string][]string{
+		models.StonfiV1: cfg.StonfiV1Addresses,
+		models.StonfiV2: cfg.StonfiV2Addresses,
+		models.DeDust:   cfg.DedustAddresses,
+		models.TONCO:    cfg.ToncoAddresses,
 	})
 }
 
+func traceSourceFromConfig(cfg *Config) (traces.TraceSource, error) {
+	var source traces.TraceSource
+	switch cfg.TraceSource {
+	case "tonapi":
+		source = traces.NewTonapiTraceSource(cfg.ConsoleToken)
+	case "files":
+		fileSource, e := traces.NewFileTraceSource(cfg.TracesDir)
+		if e != nil {
+			return nil, e
+		}
+		source = fileSource
+	default:
+		return nil, fmt.Errorf("unknown trace source %v", cfg.TraceSource)
+	}
+	if cfg.RecordTracesDir != "" {
+		source = &traces.RecordingTraceSource{TraceSource: source, Dir: cfg.RecordTracesDir}
+	}
+	if cfg.TraceArchiveDir != "" {
+		archive, e := traces.NewArchive(cfg.TraceArchiveDir)
+		if e != nil {
+			return nil, e
+		}
+		source = &traces.ArchivingTraceSource{TraceSource: source, Archive: archive}
+	}
+	return source, nil
+}
+
 func main() {
 	var cfg Config
 
@@ -70,6 +147,10 @@ func main() {
 	if err := cleanenv.ReadConfig(os.Args[1], &cfg); err != nil {
 		panic(err)
 	}
+	if cfg.MetricsAddress != "" {
+		metrics.Serve(cfg.MetricsAddress)
+	}
+
 	dbConfig := core.DbConfig{
 		DbHost:     cfg.DbHost,
 		DbPort:     cfg.DbPort,
@@ -77,182 +158,325 @@ func main() {
 		DbPassword: cfg.DbPassword,
 		DbName:     cfg.DbName,
 	}
+	store, e := persistence.OpenStore(cfg.Store, &dbConfig)
+	if e != nil {
+		panic(e)
+	}
+	withClickhouse := cfg.Store == persistence.StoreClickhouse
+	if withClickhouse {
+		if e := migrations.CheckSchema(&dbConfig); e != nil {
+			panic(e)
+		}
+	}
 
-	jettonInfoCache, e := jettons.InitJettonInfoCache(&dbConfig, &freeConsoleApi)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	writeAheadSpool, e := spool.New(cfg.SpoolDir)
 	if e != nil {
 		panic(e)
 	}
+	writerOptions := persistence.DefaultWriterOptions
+	writerOptions.DeadLetter = writeAheadSpool
+	swapWriter := persistence.NewBatchWriter("swaps", store.SaveSwaps, writerOptions)
+	tradeWriter := persistence.NewBatchWriter("trades", store.SaveTrades, writerOptions)
+	arbitrageWriter := persistence.NewBatchWriter("arbitrages", store.SaveArbitrages, writerOptions)
+	mevWriter := persistence.NewBatchWriter("mev_events", store.SaveMevEvents, writerOptions)
+	liquidityWriter := persistence.NewBatchWriter("liquidity_events", func(events []*models.LiquidityEventCH) error {
+		if !withClickhouse {
+			return nil
+		}
+		return persistence.WriteLiquidityEventsToClickhouse(&dbConfig, events)
+	}, writerOptions)
+	replaySpool(swapWriter, tradeWriter, liquidityWriter, arbitrageWriter, mevWriter)
 
-	walletMasterCache, e := jettons.InitWalletJettonCache(&dbConfig)
+	tokenCaches, e := jettons.InitTokenCaches(store, &freeConsoleApi, writeAheadSpool)
 	if e != nil {
 		panic(e)
 	}
+	priceEngine := pricing.NewEngine(cfg.PriceWindow, tokenCaches.UsdRate)
+	if withClickhouse {
+		if e := priceEngine.Warmup(&dbConfig); e != nil {
+			log.Printf("Warning: Unable to warm up price engine %v\n", e)
+		}
+	}
+	// liquidity events and pool snapshots are priced by the engine as well
+	tokenCaches.UsdRate = priceEngine.UsdRate
 
-	usdRateCache, err := jettons.InitUsdRateCache(&dbConfig, &freeConsoleApi)
-	if err != nil {
+	traceSource, e := traceSourceFromConfig(&cfg)
+	if e != nil {
 		panic(e)
 	}
 
-	streamingApi := tonapi.NewStreamingAPI(tonapi.WithStreamingToken(cfg.ConsoleToken))
+	checkpoints := ingestion.NewCheckpointTracker(nil)
+	if withClickhouse {
+		if checkpoints, e = ingestion.LoadCheckpointTracker(&dbConfig); e != nil {
+			panic(e)
+		}
+	}
+	client, _ := tonapi.New(tonapi.WithToken(cfg.ConsoleToken))
+	gapFiller := ingestion.NewGapFiller(store, &core.TonConsoleApi{Client: client}, checkpoints, cfg.GapMaxTransactions)
 
-	stonfiV1Accounts := []string{stonfi.StonfiRouter}
+	if cfg.PoolSnapshotInterval > 0 && withClickhouse {
+		tonApi, e := jettons.GetTonApi()
+		if e != nil {
+			panic(e)
+		}
+		collector := &pools.SnapshotCollector{
+			DbConfig:     &dbConfig,
+			TonApi:       tonApi,
+			TokenCaches:  tokenCaches,
+			ActiveWithin: cfg.PoolSnapshotActiveWithin,
+			Workers:      cfg.PoolSnapshotWorkers,
+		}
+		go collector.Run(ctx, cfg.PoolSnapshotInterval)
+	}
 
-	client, _ := tonapi.New(tonapi.WithToken(cfg.ConsoleToken))
-	consoleApi := &core.TonConsoleApi{Client: client}
-	incomingTransactionsChannel := make(chan string)
+	dexRegistry := registry.NewRegistry()
+	dexRegistry.Add(registry.DefaultAccounts()...)
+	dexRegistry.Add(configuredAccounts(&cfg)...)
+	if e := dexRegistry.Reload(store); e != nil {
+		panic(e)
+	}
+
+	incomingTransactionsChannel := make(chan traces.TransactionEvent)
 
-	allSubscribers := append(stonfiV1Accounts, append(stonfiv2.Routers, dedust.VaultAddresses...)...)
-	log.Printf("Subscribing to %v addresses... \n", len(allSubscribers))
-	for _, chunk := range common.ChunkArray(allSubscribers, 10) {
-		go subscribeToAccounts(streamingApi, chunk, incomingTransactionsChannel)
+	chunksNumber := 0
+	subscribe := func(accounts []string) {
+		log.Printf("Subscribing to %v addresses... \n", len(accounts))
+		for _, chunk := range common.ChunkArray(accounts, 10) {
+			chunkTransactions := make(chan traces.TransactionEvent)
+			chunkReceived := metrics.TransactionsReceived.WithLabelValues(strconv.Itoa(chunksNumber))
+			chunksNumber++
+			go func() {
+				for {
+					select {
+					case transaction := <-chunkTransactions:
+						chunkReceived.Inc()
+						select {
+						case incomingTransactionsChannel <- transaction:
+						case <-ctx.Done():
+							return
+						}
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			go traceSource.SubscribeToAccounts(ctx, chunk, chunkTransactions)
+		}
 	}
+	addedAccounts := dexRegistry.Subscribe()
+	subscribe(dexRegistry.Accounts())
+	go func() {
+		for {
+			select {
+			case accounts := <-addedAccounts:
+				subscribe(accounts)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(cfg.RegistryReloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			var reloaded Config
+			if e := cleanenv.ReadConfig(os.Args[1], &reloaded); e != nil {
+				log.Printf("Warning: Unable to reload config %v\n", e)
+			} else {
+				dexRegistry.Add(configuredAccounts(&reloaded)...)
+			}
+			if e := dexRegistry.Reload(store); e != nil {
+				log.Printf("Warning: Unable to reload registry %v\n", e)
+			}
+		}
+	}()
 
-	readyTransactionsChannel := make(chan []string)
+	// Stages are closed one after another on shutdown, so everything already received is processed and saved.
+	// Transactions which didn't make it before the timeout are recovered by the gap filling on the next start.
+	var stages sync.WaitGroup
+	stages.Add(4)
 
-	transactionsWaitingList := &core.WaitingList[string]{
+	readyTransactionsChannel := make(chan []traces.TransactionEvent)
+
+	transactionsWaitingList := &core.WaitingList[traces.TransactionEvent]{
 		ExpirationSeconds: 70 * time.Second,
 	}
 	transactionsTicker := time.NewTicker(10 * time.Second)
 	go func() {
+		defer stages.Done()
+		defer close(readyTransactionsChannel)
+		defer transactionsTicker.Stop()
 		for {
 			select {
-			case transactionHash := <-incomingTransactionsChannel:
-				transactionsWaitingList.Add(transactionHash)
+			case transaction := <-incomingTransactionsChannel:
+				if checkpoint, exists := gapFiller.GapBefore(transaction); exists {
+					go func() {
+						for _, missed := range gapFiller.Fill(transaction.Account, checkpoint, transaction.Lt) {
+							select {
+							case incomingTransactionsChannel <- missed:
+							case <-ctx.Done():
+								return
+							}
+						}
+					}()
+				}
+				transactionsWaitingList.Add(transaction)
 			case <-transactionsTicker.C:
 				evicted := transactionsWaitingList.Evict()
-				go func() { readyTransactionsChannel <- evicted }()
+				readyTransactionsChannel <- evicted
 				log.Printf("%v transaction hashes was sent for processing\n", len(evicted))
+			case <-ctx.Done():
+				pending := transactionsWaitingList.EvictAll()
+				log.Printf("Draining %v pending transactions \n", len(pending))
+				readyTransactionsChannel <- pending
+				return
 			}
 		}
 	}()
 
-	walletToMasterJettonCacheFunc := func(wallet string) *models.ChainTokenInfo {
-		master, e := walletMasterCache.Get(context.Background(), wallet)
-		if e != nil {
-			return nil
-		}
-
-		info, e := jettonInfoCache.Get(context.Background(), master.(*models.WalletJetton).Master)
-		if e != nil {
-			log.Printf("Unable to get jetton info for %v \n", master.(*models.WalletJetton).Master)
-			return nil
-		}
-		return info.(*models.ChainTokenInfo)
-	}
-
-	masterJettonCacheFunc := func(master string) *models.ChainTokenInfo {
-		info, e := jettonInfoCache.Get(context.Background(), master)
-		if e != nil {
-			log.Printf("Unable to get jetton info for %v \n", master)
-			return nil
-		}
-		return info.(*models.ChainTokenInfo)
-	}
-
-	usdRateCacheFunction := func(wallet string) *float64 {
-		rate, e := usdRateCache.Get(context.Background(), wallet)
-		if e != nil {
-			return nil
-		}
-		return rate.(*float64)
-	}
-	swapChChannel := make(chan []*models.SwapCH)
+	extractedBatchChannel := make(chan extractedBatch)
 	swapChArbitrageDetectorChannel := make(chan []*models.SwapCH)
 
 	alreadySeenHashes := core.NewEvictableSet[string](3 * time.Minute)
 
 	savedToChTransactionsHashes := core.NewEvictableSet[string](15 * time.Minute)
+	savedLiquidityHashes := core.NewEvictableSet[string](15 * time.Minute)
 	go func() {
-		for transactionHashes := range readyTransactionsChannel {
+		defer stages.Done()
+		defer close(extractedBatchChannel)
+		defer close(swapChArbitrageDetectorChannel)
+		for transactions := range readyTransactionsChannel {
 			var modelsCh []*models.SwapCH
-			for _, transactionHash := range transactionHashes {
-				if alreadySeenHashes.Exists(transactionHash) {
+			var liquidityCh []*models.LiquidityEventCH
+			processed := map[string]uint64{}
+			for _, transaction := range transactions {
+				if alreadySeenHashes.Exists(transaction.Hash) {
+					processed[transaction.Account] = max(processed[transaction.Account], transaction.Lt)
 					continue
 				}
-				trace, e := consoleApi.GetTraceByHash(transactionHash)
+				trace, e := traceSource.TraceByHash(transaction.Hash)
 				if e != nil {
 					log.Printf("Unable to get trace %v \n", e)
+					metrics.TracesFailed.Inc()
+					gapFiller.RecordGap(transaction.Account, transaction.Lt-1, transaction.Lt+1, e.Error())
 					continue
 				}
-				for _, transaction := range stonfi.GetAllTransactionsFromTrace(trace) {
-					alreadySeenHashes.Add(transaction.Hash)
-				}
-
-				stonfiV1Swaps := swapInfoWithDex(stonfi.ExtractStonfiSwapsFromRootTrace(trace), models.StonfiV1)
-				stonfiV2Swaps := swapInfoWithDex(stonfiv2.ExtractStonfiV2SwapsFromRootTrace(trace), models.StonfiV2)
-
-				dedustSwaps := dedust.ExtractDedustSwapsFromRootTrace(trace)
-
-				modelsCh = append(modelsCh, common.Map(stonfiV1Swaps, func(pair core.Pair[*models.SwapInfo, string]) *models.SwapCH {
-					return models.ToChSwap(pair.First, pair.Second, walletToMasterJettonCacheFunc, usdRateCacheFunction)
-				})...)
-
-				modelsCh = append(modelsCh, common.Map(stonfiV2Swaps, func(pair core.Pair[*models.SwapInfo, string]) *models.SwapCH {
-					return models.ToChSwap(pair.First, pair.Second, walletToMasterJettonCacheFunc, usdRateCacheFunction)
-				})...)
-
-				for _, dedustSwap := range dedustSwaps {
-					modelsCh = append(modelsCh, models.DedustSwapInfoToChSwap(dedustSwap, walletToMasterJettonCacheFunc, masterJettonCacheFunc, usdRateCacheFunction)...)
+				metrics.TracesFetched.Inc()
+				processed[transaction.Account] = max(processed[transaction.Account], transaction.Lt)
+				dexRegistry.Discover(store, trace)
+				for _, traceTransaction := range core.GetAllTransactionsFromTrace(trace) {
+					alreadySeenHashes.Add(traceTransaction.Hash)
 				}
 
+				modelsCh = append(modelsCh, pipeline.ExtractSwapsFromTrace(trace, tokenCaches)...)
+				liquidityCh = append(liquidityCh, pipeline.ExtractLiquidityEventsFromTrace(trace, tokenCaches)...)
 			}
-			notNullModels := common.Filter(modelsCh, func(ch *models.SwapCH) bool {
-				return ch != nil
-			})
-			newModels := common.Filter(notNullModels, func(ch *models.SwapCH) bool {
-				contains := false
-				for _, hash := range ch.Hashes {
-					if savedToChTransactionsHashes.Exists(hash) {
-						contains = true
-					}
-				}
-				return !contains
-			})
+			newModels := pipeline.FilterNewSwaps(modelsCh, savedToChTransactionsHashes)
+			priceEngine.Observe(newModels)
+			priceEngine.ApplyPrices(newModels)
+			newLiquidityEvents := pipeline.FilterNewLiquidityEvents(liquidityCh, savedLiquidityHashes)
 			for _, swap := range newModels {
-				for _, hash := range swap.Hashes {
-					savedToChTransactionsHashes.Add(hash)
-				}
+				metrics.SwapsExtracted.WithLabelValues(swap.Dex).Inc()
+				metrics.SwapLagSeconds.WithLabelValues(swap.Dex).Observe(swap.CatchTime.Sub(swap.Time).Seconds())
+			}
+			for _, event := range newLiquidityEvents {
+				metrics.LiquidityEventsExtracted.WithLabelValues(event.Dex).Inc()
 			}
 
-			go func() {
-				swapChChannel <- newModels
-			}()
-			go func() {
-				swapChArbitrageDetectorChannel <- newModels
-			}()
+			extractedBatchChannel <- extractedBatch{Swaps: newModels, LiquidityEvents: newLiquidityEvents, Processed: processed}
+			swapChArbitrageDetectorChannel <- newModels
 
+			// saves the checkpoints of batches the writers have confirmed by now
+			if withClickhouse {
+				if e := checkpoints.Flush(&dbConfig); e != nil {
+					log.Printf("Warning: Unable to save checkpoints %v\n", e)
+				}
+			}
 			alreadySeenHashes.Evict()
 			savedToChTransactionsHashes.Evict()
+			savedLiquidityHashes.Evict()
 		}
 	}()
 
 	go func() {
-		for {
-			select {
-			case chModels := <-swapChChannel:
-				if chModels != nil {
-					e := persistence.SaveSwapsToClickhouse(&dbConfig, chModels)
-					if e != nil {
-						log.Printf("Warning: Unable to save models %v\n", e)
-					}
+		defer stages.Done()
+		for batch := range extractedBatchChannel {
+			// checkpoints move only once the rows of the batch are saved, so a crash before that refetches the batch
+			saved := afterAll(3, func() {
+				for account, lt := range batch.Processed {
+					checkpoints.Observe(account, lt)
 				}
-			}
+			})
+			swapWriter.WriteThen(saved, batch.Swaps...)
+			tradeWriter.WriteThen(saved, pipeline.BuildTrades(batch.Swaps)...)
+			liquidityWriter.WriteThen(saved, batch.LiquidityEvents...)
 		}
 	}()
 
-	processedChModels := core.NewEvictableSet[*models.SwapCH](15 * time.Minute)
-	for {
-		chModels := <-swapChArbitrageDetectorChannel
-		for _, model := range chModels {
-			processedChModels.Add(model)
+	go func() {
+		defer stages.Done()
+		processedChModels := core.NewEvictableSet[*models.SwapCH](15 * time.Minute)
+		sandwichCandidates := core.NewEvictableSet[*models.SwapCH](15 * time.Minute)
+		for chModels := range swapChArbitrageDetectorChannel {
+			for _, model := range chModels {
+				processedChModels.Add(model)
+				sandwichCandidates.Add(model)
+			}
+
+			arbitrages := arbitrage.FindArbitragesAndDeleteThemFromSetGeneric(processedChModels)
+			metrics.ArbitragesDetected.Add(float64(len(arbitrages)))
+			arbitrageWriter.Write(arbitrages...)
+			processedChModels.Evict()
+
+			sandwiches := mev.FindSandwichesAndDeleteThemFromSet(sandwichCandidates, cfg.MevLtWindow)
+			for _, sandwich := range sandwiches {
+				metrics.SandwichesDetected.WithLabelValues(sandwich.Dex).Inc()
+			}
+			mevWriter.Write(sandwiches...)
+			sandwichCandidates.Evict()
 		}
+	}()
 
-		arbitrages := arbitrage.FindArbitragesAndDeleteThemFromSetGeneric(processedChModels)
-		if len(arbitrages) > 0 {
-			if e := persistence.WriteArbitragesToClickhouse(&dbConfig, arbitrages); e != nil {
-				log.Printf("Warning: Unable to save arbitrages %v\n", e)
+	<-ctx.Done()
+	log.Printf("Shutting down, draining the pipeline... \n")
+	drained := make(chan struct{})
+	go func() {
+		stages.Wait()
+		swapWriter.Close()
+		tradeWriter.Close()
+		liquidityWriter.Close()
+		arbitrageWriter.Close()
+		mevWriter.Close()
+		tokenCaches.Close()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		if withClickhouse {
+			if e := checkpoints.Flush(&dbConfig); e != nil {
+				log.Printf("Warning: Unable to save checkpoints %v\n", e)
+			}
+		}
+		log.Printf("Listener stopped \n")
+	case <-time.After(cfg.ShutdownTimeout):
+		// rows the writers still hold go to the spool and are saved on the next start, rows the stages hold are
+		// fetched again since their checkpoints haven't moved
+		log.Printf("Warning: Drain timed out after %v, spooling buffered rows \n", cfg.ShutdownTimeout)
+		for _, abandon := range []func(time.Duration) bool{swapWriter.Abandon, tradeWriter.Abandon, liquidityWriter.Abandon, arbitrageWriter.Abandon, mevWriter.Abandon} {
+			abandon(abandonTimeout)
+		}
+		if withClickhouse {
+			if e := checkpoints.Flush(&dbConfig); e != nil {
+				log.Printf("Warning: Unable to save checkpoints %v\n", e)
 			}
 		}
-		processedChModels.Evict()
 	}
 }
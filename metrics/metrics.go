@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log"
+	"net/http"
+)
+
+const namespace = "tondexer"
+
+var (
+	TransactionsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_received_total",
+		Help:      "Transactions received from the streaming api per subscription chunk",
+	}, []string{"chunk"})
+
+	TracesFetched = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "traces_fetched_total",
+		Help:      "Traces fetched successfully",
+	})
+
+	TracesFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "traces_failed_total",
+		Help:      "Trace fetches that failed after retries",
+	})
+
+	SwapsExtracted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "swaps_extracted_total",
+		Help:      "New swaps extracted from traces per dex",
+	}, []string{"dex"})
+
+	LiquidityEventsExtracted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "liquidity_events_extracted_total",
+		Help:      "New liquidity events extracted from traces per dex",
+	}, []string{"dex"})
+
+	ArbitragesDetected = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "arbitrages_detected_total",
+		Help:      "Arbitrages found among processed swaps",
+	})
+
+	SandwichesDetected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sandwiches_detected_total",
+		Help:      "Victim swaps of sandwich attacks found among processed swaps per dex",
+	}, []string{"dex"})
+
+	ClickhouseBatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "clickhouse_batch_size",
+		Help:      "Number of rows in clickhouse insert batches",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"table"})
+
+	ClickhouseWriteSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "clickhouse_write_seconds",
+		Help:      "Latency of clickhouse batch inserts",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"table", "status"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups, hits are requests minus misses",
+	}, []string{"cache"})
+
+	CacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_misses_total",
+		Help:      "Cache lookups loading the value from the api or the chain",
+	}, []string{"cache"})
+
+	SwapLagSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "swap_lag_seconds",
+		Help:      "Delay between the swap transaction and the moment it was caught",
+		Buckets:   []float64{1, 5, 10, 20, 30, 60, 120, 300, 600, 1800, 3600},
+	}, []string{"dex"})
+)
+
+// Serve exposes /metrics on the address in background
+func Serve(address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if e := http.ListenAndServe(address, mux); e != nil {
+			log.Printf("Warning: Metrics server stopped: %v \n", e)
+		}
+	}()
+}
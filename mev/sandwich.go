@@ -0,0 +1,153 @@
+package mev
+
+import (
+	"cmp"
+	"math"
+	"math/big"
+	"slices"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+// DefaultLtWindow is about ten blocks between the front and the back swap, shard blocks start at multiples of a million lt
+const DefaultLtWindow = 10000000
+
+func toJettons(amount *big.Int, decimals uint64) float64 {
+	if amount == nil {
+		return 0
+	}
+	value, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), big.NewFloat(math.Pow10(int(decimals)))).Float64()
+	return value
+}
+
+// rate is how much of the jetton out a swap got for one jetton in
+func rate(swap *models.SwapCH) float64 {
+	in := toJettons(swap.AmountIn, swap.JettonInDecimals)
+	if in == 0 {
+		return 0
+	}
+	return toJettons(swap.AmountOut, swap.JettonOutDecimals) / in
+}
+
+func sameDirection(a *models.SwapCH, b *models.SwapCH) bool {
+	return a.JettonIn == b.JettonIn && a.JettonOut == b.JettonOut
+}
+
+func reverse(a *models.SwapCH, b *models.SwapCH) bool {
+	return a.JettonIn == b.JettonOut && a.JettonOut == b.JettonIn
+}
+
+func isVictim(front *models.SwapCH, swap *models.SwapCH) bool {
+	return sameDirection(front, swap) && swap.Sender != front.Sender && swap.TraceID != front.TraceID && rate(swap) < rate(front)
+}
+
+// profitUsd is what the attacker earned on the amount both bought in the front swap and sold in the back one
+func profitUsd(front *models.SwapCH, back *models.SwapCH) float64 {
+	bought := toJettons(front.AmountOut, front.JettonOutDecimals)
+	sold := toJettons(back.AmountIn, back.JettonInDecimals)
+	if bought == 0 || sold == 0 {
+		return 0
+	}
+	buyPrice := toJettons(front.AmountIn, front.JettonInDecimals) / bought
+	sellPrice := toJettons(back.AmountOut, back.JettonOutDecimals) / sold
+	usdRate := front.JettonInUsdRate
+	if usdRate == 0 {
+		usdRate = back.JettonOutUsdRate
+	}
+	return min(bought, sold) * (sellPrice - buyPrice) * usdRate
+}
+
+func newSandwich(front *models.SwapCH, victim *models.SwapCH, back *models.SwapCH, profitUsd float64) *models.MevEventCH {
+	frontRate := rate(front)
+	victimOut := toJettons(victim.AmountOut, victim.JettonOutDecimals)
+	expectedOut := toJettons(victim.AmountIn, victim.JettonInDecimals) * frontRate
+	return &models.MevEventCH{
+		Kind:            models.MevSandwich,
+		Time:            victim.Time,
+		Dex:             victim.Dex,
+		PoolAddress:     victim.PoolAddress,
+		Attacker:        front.Sender,
+		Victim:          victim.Sender,
+		FrontrunTraceID: front.TraceID,
+		VictimTraceID:   victim.TraceID,
+		BackrunTraceID:  back.TraceID,
+		FrontrunLt:      front.Lt,
+		VictimLt:        victim.Lt,
+		BackrunLt:       back.Lt,
+		JettonIn:        victim.JettonIn,
+		JettonOut:       victim.JettonOut,
+		VictimVolumeUsd: toJettons(victim.AmountIn, victim.JettonInDecimals) * victim.JettonInUsdRate,
+		ProfitUsd:       profitUsd,
+		VictimSlippage:  1 - rate(victim)/frontRate,
+		VictimLossUsd:   (expectedOut - victimOut) * victim.JettonOutUsdRate,
+		CatchTime:       victim.CatchTime,
+	}
+}
+
+// findPoolSandwiches looks for the first reverse swap of the front sender within the window and takes the swaps in the same
+// direction between them as victims. Victims must get a worse rate than the front swap and the attacker must earn
+func findPoolSandwiches(swaps []*models.SwapCH, ltWindow uint64) ([]*models.MevEventCH, []*models.SwapCH) {
+	var events []*models.MevEventCH
+	var participated []*models.SwapCH
+	used := map[*models.SwapCH]bool{}
+	for i, front := range swaps {
+		if used[front] {
+			continue
+		}
+		for k := i + 1; k < len(swaps) && swaps[k].Lt-front.Lt <= ltWindow; k++ {
+			back := swaps[k]
+			if used[back] || back.Sender != front.Sender || back.TraceID == front.TraceID || !reverse(front, back) {
+				continue
+			}
+			var victims []*models.SwapCH
+			for _, swap := range swaps[i+1 : k] {
+				if !used[swap] && isVictim(front, swap) {
+					victims = append(victims, swap)
+				}
+			}
+			profit := profitUsd(front, back)
+			if len(victims) > 0 && profit > 0 {
+				for _, victim := range victims {
+					events = append(events, newSandwich(front, victim, back, profit/float64(len(victims))))
+					used[victim] = true
+				}
+				used[front], used[back] = true, true
+				participated = append(append(participated, front, back), victims...)
+			}
+			break
+		}
+	}
+	return events, participated
+}
+
+// FindSandwiches returns sandwich attacks among the swaps and the swaps which took part in them
+func FindSandwiches(swaps []*models.SwapCH, ltWindow uint64) ([]*models.MevEventCH, []*models.SwapCH) {
+	byPool := map[string][]*models.SwapCH{}
+	var pools []string
+	for _, swap := range swaps {
+		key := swap.Dex + ":" + swap.PoolAddress
+		if _, exists := byPool[key]; !exists {
+			pools = append(pools, key)
+		}
+		byPool[key] = append(byPool[key], swap)
+	}
+
+	var events []*models.MevEventCH
+	var participated []*models.SwapCH
+	for _, pool := range pools {
+		ordered := slices.SortedStableFunc(slices.Values(byPool[pool]), func(a, b *models.SwapCH) int { return cmp.Compare(a.Lt, b.Lt) })
+		poolEvents, poolSwaps := findPoolSandwiches(ordered, ltWindow)
+		events = append(events, poolEvents...)
+		participated = append(participated, poolSwaps...)
+	}
+	return events, participated
+}
+
+// FindSandwichesAndDeleteThemFromSet keeps swaps of unfinished attacks in the set, the back swap may come with a later batch
+func FindSandwichesAndDeleteThemFromSet(swapSet *core.EvictableSet[*models.SwapCH], ltWindow uint64) []*models.MevEventCH {
+	events, participated := FindSandwiches(swapSet.Elements(), ltWindow)
+	for _, swap := range participated {
+		swapSet.Remove(swap)
+	}
+	return events
+}
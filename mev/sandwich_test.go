@@ -0,0 +1,61 @@
+package mev
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+// poolSwap trades whole units of ton and usdt with no decimals, ton costs 5 usd
+func poolSwap(sender string, lt uint64, jettonIn string, amountIn int64, jettonOut string, amountOut int64) *models.SwapCH {
+	rates := map[string]float64{"ton": 5, "usdt": 1}
+	return &models.SwapCH{
+		Dex: models.DeDust, PoolAddress: "pool", Sender: sender, TraceID: sender + string(rune('a'+lt)), Lt: lt, Time: time.Unix(int64(lt), 0),
+		JettonIn: jettonIn, AmountIn: big.NewInt(amountIn), JettonInUsdRate: rates[jettonIn],
+		JettonOut: jettonOut, AmountOut: big.NewInt(amountOut), JettonOutUsdRate: rates[jettonOut],
+	}
+}
+
+func TestFindSandwiches(t *testing.T) {
+	front := poolSwap("bot", 1, "usdt", 100, "ton", 20)
+	victim := poolSwap("alice", 2, "usdt", 100, "ton", 16)
+	bystander := poolSwap("bob", 3, "ton", 1, "usdt", 5)
+	back := poolSwap("bot", 4, "ton", 20, "usdt", 110)
+	outOfWindow := poolSwap("carol", 20, "usdt", 100, "ton", 10)
+
+	events, participated := FindSandwiches([]*models.SwapCH{back, bystander, victim, outOfWindow, front}, 10)
+
+	assert.Equal(t, 1, len(events))
+	event := events[0]
+	assert.Equal(t, "bot", event.Attacker)
+	assert.Equal(t, "alice", event.Victim)
+	assert.Equal(t, []uint64{1, 2, 4}, []uint64{event.FrontrunLt, event.VictimLt, event.BackrunLt})
+	assert.InDelta(t, 10, event.ProfitUsd, 1e-9)
+	assert.InDelta(t, 0.2, event.VictimSlippage, 1e-9)
+	assert.InDelta(t, 20, event.VictimLossUsd, 1e-9)
+	assert.InDelta(t, 100, event.VictimVolumeUsd, 1e-9)
+	assert.ElementsMatch(t, []*models.SwapCH{front, victim, back}, participated)
+}
+
+func TestFindSandwichesSkipsLosingRoundTrips(t *testing.T) {
+	front := poolSwap("bot", 1, "usdt", 100, "ton", 20)
+	victim := poolSwap("alice", 2, "usdt", 100, "ton", 16)
+	back := poolSwap("bot", 3, "ton", 20, "usdt", 90)
+
+	events, _ := FindSandwiches([]*models.SwapCH{front, victim, back}, DefaultLtWindow)
+	assert.Empty(t, events)
+}
+
+func TestFindSandwichesWaitsForTheBackSwap(t *testing.T) {
+	set := core.NewEvictableSet[*models.SwapCH](time.Minute)
+	set.Add(poolSwap("bot", 1, "usdt", 100, "ton", 20))
+	set.Add(poolSwap("alice", 2, "usdt", 100, "ton", 16))
+	assert.Empty(t, FindSandwichesAndDeleteThemFromSet(set, DefaultLtWindow))
+
+	set.Add(poolSwap("bot", 4, "ton", 20, "usdt", 110))
+	assert.Equal(t, 1, len(FindSandwichesAndDeleteThemFromSet(set, DefaultLtWindow)))
+	assert.Empty(t, set.Elements())
+}
@@ -0,0 +1,253 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"tondexer/core"
+	"tondexer/persistence"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// databasePlaceholder is replaced by the configured database name in every statement
+const databasePlaceholder = "{db}"
+
+// appliedAtPlaceholder is the time the migration is applied, the same in every statement of it. It splits rows between a
+// materialized view and the backfill of the table behind it
+const appliedAtPlaceholder = "{applied_at}"
+
+var fileNameRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version uint32
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration *Migration
+	Applied   bool
+	Time      time.Time
+}
+
+type tablesCount struct {
+	Count uint64 `ch:"count"`
+}
+
+type appliedMigration struct {
+	Version uint32    `ch:"version"`
+	Applied bool      `ch:"applied"`
+	Time    time.Time `ch:"time"`
+}
+
+// Load returns embedded migrations ordered by version, every one of them must have both up and down files
+func Load() ([]*Migration, error) {
+	return load(files)
+}
+
+func load(fileSystem fs.FS) ([]*Migration, error) {
+	entries, e := fs.ReadDir(fileSystem, "sql")
+	if e != nil {
+		return nil, e
+	}
+	byVersion := map[uint32]*Migration{}
+	for _, entry := range entries {
+		match := fileNameRegexp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %v", entry.Name())
+		}
+		version, e := strconv.ParseUint(match[1], 10, 32)
+		if e != nil {
+			return nil, e
+		}
+		content, e := fs.ReadFile(fileSystem, "sql/"+entry.Name())
+		if e != nil {
+			return nil, e
+		}
+		migration, exists := byVersion[uint32(version)]
+		if !exists {
+			migration = &Migration{Version: uint32(version), Name: match[2]}
+			byVersion[uint32(version)] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %v has different names %v and %v", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var migrations []*Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %v_%v must have both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, migration := range migrations {
+		if migration.Version != uint32(i+1) {
+			return nil, fmt.Errorf("migration %v is missing", i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// Statements splits the sql into statements, the native protocol accepts only one per query
+func Statements(sql string, config *core.DbConfig) []string {
+	return statements(sql, config, time.Now())
+}
+
+func statements(sql string, config *core.DbConfig, appliedAt time.Time) []string {
+	placeholders := strings.NewReplacer(
+		databasePlaceholder, config.DbName,
+		appliedAtPlaceholder, fmt.Sprint("toDateTime(", appliedAt.Unix(), ")"))
+	var statements []string
+	for _, statement := range strings.Split(sql, ";\n") {
+		statement = strings.TrimSuffix(strings.TrimSpace(statement), ";")
+		if statement != "" {
+			statements = append(statements, placeholders.Replace(statement))
+		}
+	}
+	return statements
+}
+
+func ensureMigrationsTable(config *core.DbConfig) error {
+	return persistence.ExecClickhouse(config, fmt.Sprint(`
+CREATE TABLE IF NOT EXISTS `, config.DbName, `.schema_migrations (
+    version UInt32,
+    applied Bool,
+    time DateTime64(3)
+) ENGINE = MergeTree
+ORDER BY (version, time)`))
+}
+
+func migrationsTableExists(config *core.DbConfig) (bool, error) {
+	tables, e := persistence.ReadSingleRow[tablesCount](config, persistence.NewQuery(config).
+		Sql("SELECT count() AS count FROM system.tables").
+		Where("database = ? AND name = 'schema_migrations'", config.DbName).
+		Build())
+	if e != nil {
+		return false, e
+	}
+	return tables.Count > 0, nil
+}
+
+func record(config *core.DbConfig, version uint32, applied bool) error {
+	return persistence.ExecClickhouse(config, fmt.Sprint(
+		"INSERT INTO ", config.DbName, ".schema_migrations VALUES (", version, ", ", applied, ", now64(3))"))
+}
+
+// Status lists all known migrations and whether they are applied, it doesn't modify the database
+func Status(config *core.DbConfig) ([]*MigrationStatus, error) {
+	migrations, e := Load()
+	if e != nil {
+		return nil, e
+	}
+	var statuses []*MigrationStatus
+	for _, migration := range migrations {
+		statuses = append(statuses, &MigrationStatus{Migration: migration})
+	}
+	if exists, e := migrationsTableExists(config); e != nil || !exists {
+		return statuses, e
+	}
+	applied, e := persistence.ReadArrayFromClickhouse[appliedMigration](config, persistence.NewQuery(config).Sql(`
+SELECT
+    version,
+    argMax(applied, time) AS applied,
+    max(time) AS time`).
+		From("schema_migrations").
+		Sql(`
+GROUP BY version`).
+		Build())
+	if e != nil {
+		return nil, e
+	}
+	appliedByVersion := map[uint32]appliedMigration{}
+	for _, migration := range applied {
+		appliedByVersion[migration.Version] = migration
+	}
+
+	for _, status := range statuses {
+		if applied, exists := appliedByVersion[status.Migration.Version]; exists && applied.Applied {
+			status.Applied = true
+			status.Time = applied.Time
+		}
+	}
+	return statuses, nil
+}
+
+// Up applies all pending migrations in order and returns the applied ones
+func Up(config *core.DbConfig) ([]*Migration, error) {
+	if e := ensureMigrationsTable(config); e != nil {
+		return nil, e
+	}
+	statuses, e := Status(config)
+	if e != nil {
+		return nil, e
+	}
+	var applied []*Migration
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+		if e := persistence.ExecClickhouse(config, Statements(status.Migration.Up, config)...); e != nil {
+			return applied, fmt.Errorf("migration %v_%v failed: %w", status.Migration.Version, status.Migration.Name, e)
+		}
+		if e := record(config, status.Migration.Version, true); e != nil {
+			return applied, e
+		}
+		applied = append(applied, status.Migration)
+	}
+	return applied, nil
+}
+
+// Down reverts the given number of the latest applied migrations
+func Down(config *core.DbConfig, steps int) ([]*Migration, error) {
+	statuses, e := Status(config)
+	if e != nil {
+		return nil, e
+	}
+	var reverted []*Migration
+	for i := len(statuses) - 1; i >= 0 && len(reverted) < steps; i-- {
+		if !statuses[i].Applied {
+			continue
+		}
+		migration := statuses[i].Migration
+		if e := persistence.ExecClickhouse(config, Statements(migration.Down, config)...); e != nil {
+			return reverted, fmt.Errorf("migration %v_%v revert failed: %w", migration.Version, migration.Name, e)
+		}
+		if e := record(config, migration.Version, false); e != nil {
+			return reverted, e
+		}
+		reverted = append(reverted, migration)
+	}
+	return reverted, nil
+}
+
+// CheckSchema fails unless every known migration is applied
+func CheckSchema(config *core.DbConfig) error {
+	statuses, e := Status(config)
+	if e != nil {
+		return e
+	}
+	var pending []string
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, fmt.Sprintf("%v_%v", status.Migration.Version, status.Migration.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is out of date, pending migrations %v, run `migrate up`", strings.Join(pending, ", "))
+	}
+	return nil
+}
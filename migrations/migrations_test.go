@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+	"tondexer/core"
+)
+
+func TestEmbeddedMigrationsAreComplete(t *testing.T) {
+	migrations, e := Load()
+	assert.Nil(t, e)
+	assert.NotEmpty(t, migrations)
+
+	config := &core.DbConfig{DbName: "tondexer"}
+	for i, migration := range migrations {
+		assert.Equal(t, uint32(i+1), migration.Version)
+		for _, statement := range append(Statements(migration.Up, config), Statements(migration.Down, config)...) {
+			assert.NotContains(t, statement, databasePlaceholder)
+			assert.NotContains(t, statement, appliedAtPlaceholder)
+			assert.False(t, strings.HasSuffix(statement, ";"))
+		}
+	}
+}
+
+func TestStatementsSplitsAndSubstitutesDatabase(t *testing.T) {
+	statements := Statements("DROP TABLE {db}.a;\n\nDROP TABLE {db}.b;\n", &core.DbConfig{DbName: "test"})
+	assert.Equal(t, []string{"DROP TABLE test.a", "DROP TABLE test.b"}, statements)
+}
+
+func TestStatementsShareTheApplyTime(t *testing.T) {
+	sql := "CREATE VIEW {db}.v AS SELECT * FROM {db}.t WHERE time >= {applied_at};\nINSERT INTO {db}.c SELECT * FROM {db}.t WHERE time < {applied_at};\n"
+	result := statements(sql, &core.DbConfig{DbName: "test"}, time.Unix(1700000000, 0))
+	assert.Equal(t, []string{
+		"CREATE VIEW test.v AS SELECT * FROM test.t WHERE time >= toDateTime(1700000000)",
+		"INSERT INTO test.c SELECT * FROM test.t WHERE time < toDateTime(1700000000)",
+	}, result)
+}
+
+func TestLoadRejectsIncompleteMigrations(t *testing.T) {
+	_, e := load(fstest.MapFS{
+		"sql/0001_first.up.sql":   {Data: []byte("SELECT 1")},
+		"sql/0001_first.down.sql": {Data: []byte("SELECT 1")},
+		"sql/0002_second.up.sql":  {Data: []byte("SELECT 1")},
+	})
+	assert.ErrorContains(t, e, "2_second")
+
+	_, e = load(fstest.MapFS{
+		"sql/0001_first.up.sql":   {Data: []byte("SELECT 1")},
+		"sql/0001_first.down.sql": {Data: []byte("SELECT 1")},
+		"sql/0003_third.up.sql":   {Data: []byte("SELECT 1")},
+		"sql/0003_third.down.sql": {Data: []byte("SELECT 1")},
+	})
+	assert.ErrorContains(t, e, "missing")
+}
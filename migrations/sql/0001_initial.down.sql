@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS {db}.jetton_rates;
+DROP TABLE IF EXISTS {db}.wallet_to_master;
+DROP TABLE IF EXISTS {db}.clickhouse_jetton;
+DROP TABLE IF EXISTS {db}.arbitrages;
+DROP TABLE IF EXISTS {db}.swaps;
@@ -0,0 +1,76 @@
+CREATE TABLE IF NOT EXISTS {db}.swaps (
+    dex LowCardinality(String),
+    hashes Array(String),
+    lt UInt64,
+    time DateTime,
+    jetton_in String,
+    amount_in UInt256,
+    jetton_in_symbol String,
+    jetton_in_name String,
+    jetton_in_usd_rate Float64,
+    jetton_in_decimals UInt64,
+    jetton_out String,
+    amount_out UInt256,
+    jetton_out_symbol String,
+    jetton_out_name String,
+    jetton_out_usd_rate Float64,
+    jetton_out_decimals UInt64,
+    min_amount_out UInt256,
+    pool_address String,
+    sender String,
+    referral_address String,
+    referral_amount UInt256,
+    catch_time DateTime,
+    trace_id String
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(time)
+ORDER BY (time, pool_address);
+
+CREATE TABLE IF NOT EXISTS {db}.arbitrages (
+    sender String,
+    time DateTime,
+    amount_in UInt256,
+    amount_out UInt256,
+    jetton String,
+    jetton_name String,
+    jetton_symbol String,
+    jetton_usd_rate Float64,
+    jetton_decimals UInt64,
+    amounts_path Array(UInt256),
+    jettons_path Array(String),
+    jetton_names Array(String),
+    jetton_symbols Array(String),
+    jetton_usd_rates Array(Float64),
+    jettons_decimals Array(UInt64),
+    pools_path Array(String),
+    trace_ids Array(String),
+    dexes Array(String),
+    senders Array(String)
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(time)
+ORDER BY (time, sender);
+
+CREATE TABLE IF NOT EXISTS {db}.clickhouse_jetton (
+    name String,
+    symbol String,
+    master String,
+    decimals UInt64
+) ENGINE = ReplacingMergeTree
+ORDER BY master;
+
+CREATE TABLE IF NOT EXISTS {db}.wallet_to_master (
+    wallet String,
+    master String
+) ENGINE = ReplacingMergeTree
+ORDER BY wallet;
+
+CREATE TABLE IF NOT EXISTS {db}.jetton_rates (
+    time DateTime,
+    name String,
+    symbol String,
+    master String,
+    decimals UInt64,
+    rate Float64
+) ENGINE = MergeTree
+ORDER BY (master, time)
+TTL time + INTERVAL 1 YEAR;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS {db}.ingestion_gaps;
+DROP TABLE IF EXISTS {db}.ingestion_checkpoints;
+DROP TABLE IF EXISTS {db}.backfill_checkpoints;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS {db}.backfill_checkpoints (
+    job String,
+    account String,
+    lt UInt64,
+    done Bool,
+    time DateTime
+) ENGINE = MergeTree
+ORDER BY (job, account, time);
+
+CREATE TABLE IF NOT EXISTS {db}.ingestion_checkpoints (
+    account String,
+    lt UInt64,
+    time DateTime
+) ENGINE = ReplacingMergeTree(lt)
+ORDER BY account;
+
+-- gaps are diagnostics only, the filled ones are not interesting after a while
+CREATE TABLE IF NOT EXISTS {db}.ingestion_gaps (
+    account String,
+    from_lt UInt64,
+    to_lt UInt64,
+    reason String,
+    time DateTime
+) ENGINE = MergeTree
+ORDER BY (time, account)
+TTL time + INTERVAL 90 DAY;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS {db}.liquidity_events (
+    dex LowCardinality(String),
+    type LowCardinality(String),
+    hashes Array(String),
+    lt UInt64,
+    time DateTime,
+    pool_address String,
+    provider String,
+    jetton0 String,
+    amount0 UInt256,
+    jetton0_symbol String,
+    jetton0_name String,
+    jetton0_usd_rate Float64,
+    jetton0_decimals UInt64,
+    jetton1 String,
+    amount1 UInt256,
+    jetton1_symbol String,
+    jetton1_name String,
+    jetton1_usd_rate Float64,
+    jetton1_decimals UInt64,
+    lp_amount UInt256,
+    catch_time DateTime,
+    trace_id String
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(time)
+ORDER BY (time, pool_address);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS {db}.pool_snapshots (
+    time DateTime,
+    dex LowCardinality(String),
+    pool_address String,
+    jetton0 String,
+    reserve0 UInt256,
+    jetton0_symbol String,
+    jetton0_name String,
+    jetton0_usd_rate Float64,
+    jetton0_decimals UInt64,
+    jetton1 String,
+    reserve1 UInt256,
+    jetton1_symbol String,
+    jetton1_name String,
+    jetton1_usd_rate Float64,
+    jetton1_decimals UInt64,
+    lp_supply UInt256,
+    tvl_usd Float64
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(time)
+ORDER BY (pool_address, time);
@@ -0,0 +1,3 @@
+ALTER TABLE {db}.swaps DROP COLUMN IF EXISTS jetton_out_price_source;
+
+ALTER TABLE {db}.swaps DROP COLUMN IF EXISTS jetton_in_price_source;
@@ -0,0 +1,3 @@
+ALTER TABLE {db}.swaps ADD COLUMN IF NOT EXISTS jetton_in_price_source LowCardinality(String) DEFAULT '';
+
+ALTER TABLE {db}.swaps ADD COLUMN IF NOT EXISTS jetton_out_price_source LowCardinality(String) DEFAULT '';
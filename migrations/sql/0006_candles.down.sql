@@ -0,0 +1,3 @@
+DROP VIEW IF EXISTS {db}.candles_1m_mv;
+
+DROP TABLE IF EXISTS {db}.candles_1m;
@@ -0,0 +1,97 @@
+CREATE TABLE IF NOT EXISTS {db}.candles_1m (
+    pool_address String,
+    time DateTime,
+    jetton0 String,
+    jetton1 String,
+    open AggregateFunction(argMin, Float64, UInt64),
+    high SimpleAggregateFunction(max, Float64),
+    low SimpleAggregateFunction(min, Float64),
+    close AggregateFunction(argMax, Float64, UInt64),
+    open_usd AggregateFunction(argMin, Float64, UInt64),
+    high_usd SimpleAggregateFunction(max, Float64),
+    low_usd SimpleAggregateFunction(min, Float64),
+    close_usd AggregateFunction(argMax, Float64, UInt64),
+    volume0 SimpleAggregateFunction(sum, Float64),
+    volume1 SimpleAggregateFunction(sum, Float64),
+    volume_usd SimpleAggregateFunction(sum, Float64),
+    count SimpleAggregateFunction(sum, UInt64)
+) ENGINE = AggregatingMergeTree
+ORDER BY (pool_address, jetton0, jetton1, time);
+
+-- the view is created before the backfill and both split swaps by the time the migration is applied, so every swap is
+-- counted once. catch_time is used instead of time, swaps of old traces saved later by backfills still get candles
+CREATE MATERIALIZED VIEW IF NOT EXISTS {db}.candles_1m_mv TO {db}.candles_1m AS
+SELECT
+    pool_address,
+    toStartOfMinute(time) AS time,
+    jetton0,
+    jetton1,
+    argMinState(price, lt) AS open,
+    max(price) AS high,
+    min(price) AS low,
+    argMaxState(price, lt) AS close,
+    argMinState(price_usd, lt) AS open_usd,
+    max(price_usd) AS high_usd,
+    min(price_usd) AS low_usd,
+    argMaxState(price_usd, lt) AS close_usd,
+    sum(amount0) AS volume0,
+    sum(amount1) AS volume1,
+    sum(volume_usd) AS volume_usd,
+    toUInt64(count()) AS count
+FROM (
+SELECT
+    pool_address,
+    time,
+    lt,
+    least(jetton_in, jetton_out) AS jetton0,
+    greatest(jetton_in, jetton_out) AS jetton1,
+    if(jetton_in = jetton0, amount_in / pow(10, jetton_in_decimals), amount_out / pow(10, jetton_out_decimals)) AS amount0,
+    if(jetton_in = jetton0, amount_out / pow(10, jetton_out_decimals), amount_in / pow(10, jetton_in_decimals)) AS amount1,
+    if(jetton_in = jetton0, jetton_out_usd_rate, jetton_in_usd_rate) AS rate1,
+    if(jetton_in = jetton0, jetton_in_usd_rate, jetton_out_usd_rate) AS rate0,
+    amount1 / amount0 AS price,
+    if(rate1 > 0, price * rate1, rate0) AS price_usd,
+    if(rate1 > 0, amount1 * rate1, amount0 * rate0) AS volume_usd
+FROM {db}.swaps
+WHERE jetton_in != '' AND jetton_out != '' AND jetton_in != jetton_out AND amount_in > 0 AND amount_out > 0
+    AND catch_time >= {applied_at}
+)
+GROUP BY pool_address, time, jetton0, jetton1;
+
+INSERT INTO {db}.candles_1m
+SELECT
+    pool_address,
+    toStartOfMinute(time) AS time,
+    jetton0,
+    jetton1,
+    argMinState(price, lt) AS open,
+    max(price) AS high,
+    min(price) AS low,
+    argMaxState(price, lt) AS close,
+    argMinState(price_usd, lt) AS open_usd,
+    max(price_usd) AS high_usd,
+    min(price_usd) AS low_usd,
+    argMaxState(price_usd, lt) AS close_usd,
+    sum(amount0) AS volume0,
+    sum(amount1) AS volume1,
+    sum(volume_usd) AS volume_usd,
+    toUInt64(count()) AS count
+FROM (
+SELECT
+    pool_address,
+    time,
+    lt,
+    least(jetton_in, jetton_out) AS jetton0,
+    greatest(jetton_in, jetton_out) AS jetton1,
+    if(jetton_in = jetton0, amount_in / pow(10, jetton_in_decimals), amount_out / pow(10, jetton_out_decimals)) AS amount0,
+    if(jetton_in = jetton0, amount_out / pow(10, jetton_out_decimals), amount_in / pow(10, jetton_in_decimals)) AS amount1,
+    if(jetton_in = jetton0, jetton_out_usd_rate, jetton_in_usd_rate) AS rate1,
+    if(jetton_in = jetton0, jetton_in_usd_rate, jetton_out_usd_rate) AS rate0,
+    amount1 / amount0 AS price,
+    if(rate1 > 0, price * rate1, rate0) AS price_usd,
+    if(rate1 > 0, amount1 * rate1, amount0 * rate0) AS volume_usd
+FROM {db}.swaps
+WHERE jetton_in != '' AND jetton_out != '' AND jetton_in != jetton_out AND amount_in > 0 AND amount_out > 0
+    AND catch_time < {applied_at}
+)
+GROUP BY pool_address, time, jetton0, jetton1;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS {db}.dex_accounts (
+    dex LowCardinality(String),
+    address String,
+    source LowCardinality(String),
+    time DateTime
+) ENGINE = MergeTree
+ORDER BY (address, time);
@@ -0,0 +1,66 @@
+CREATE TABLE IF NOT EXISTS {db}.trades (
+    dex LowCardinality(String),
+    trace_id String,
+    sender String,
+    lt UInt64,
+    time DateTime,
+    hashes Array(String),
+    jetton_in String,
+    amount_in UInt256,
+    jetton_in_symbol String,
+    jetton_in_name String,
+    jetton_in_usd_rate Float64,
+    jetton_in_decimals UInt64,
+    jetton_in_price_source LowCardinality(String),
+    jetton_out String,
+    amount_out UInt256,
+    jetton_out_symbol String,
+    jetton_out_name String,
+    jetton_out_usd_rate Float64,
+    jetton_out_decimals UInt64,
+    jetton_out_price_source LowCardinality(String),
+    pools_path Array(String),
+    jettons_path Array(String),
+    catch_time DateTime
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(time)
+ORDER BY (time, sender);
+
+INSERT INTO {db}.trades
+SELECT
+    dex,
+    trace_id,
+    tupleElement(route[1], 3) AS sender,
+    tupleElement(route[1], 1) AS lt,
+    tupleElement(route[1], 2) AS time,
+    arrayFlatten(arrayMap(hop -> tupleElement(hop, 4), route)) AS hashes,
+    tupleElement(route[1], 5) AS jetton_in,
+    tupleElement(route[1], 6) AS amount_in,
+    tupleElement(route[1], 7) AS jetton_in_symbol,
+    tupleElement(route[1], 8) AS jetton_in_name,
+    tupleElement(route[1], 9) AS jetton_in_usd_rate,
+    tupleElement(route[1], 10) AS jetton_in_decimals,
+    tupleElement(route[1], 11) AS jetton_in_price_source,
+    tupleElement(route[-1], 12) AS jetton_out,
+    tupleElement(route[-1], 13) AS amount_out,
+    tupleElement(route[-1], 14) AS jetton_out_symbol,
+    tupleElement(route[-1], 15) AS jetton_out_name,
+    tupleElement(route[-1], 16) AS jetton_out_usd_rate,
+    tupleElement(route[-1], 17) AS jetton_out_decimals,
+    tupleElement(route[-1], 18) AS jetton_out_price_source,
+    arrayMap(hop -> tupleElement(hop, 19), route) AS pools_path,
+    arrayConcat([jetton_in], arrayMap(hop -> tupleElement(hop, 12), route)) AS jettons_path,
+    tupleElement(route[1], 20) AS catch_time
+FROM (
+SELECT
+    dex,
+    trace_id,
+    arraySort(hop -> tupleElement(hop, 1), groupArray((lt, time, sender, hashes,
+        jetton_in, amount_in, jetton_in_symbol, jetton_in_name, jetton_in_usd_rate, jetton_in_decimals, jetton_in_price_source,
+        jetton_out, amount_out, jetton_out_symbol, jetton_out_name, jetton_out_usd_rate, jetton_out_decimals, jetton_out_price_source,
+        pool_address, catch_time))) AS hops
+FROM {db}.swaps
+WHERE (SELECT count() FROM {db}.trades) = 0
+GROUP BY dex, trace_id, if(trace_id = '', concat(pool_address, ':', toString(lt), ':', arrayStringConcat(hashes, ',')), '')
+)
+ARRAY JOIN if(arrayAll(i -> tupleElement(hops[i], 12) = tupleElement(hops[i + 1], 5), range(1, length(hops))), [hops], arrayMap(hop -> [hop], hops)) AS route;
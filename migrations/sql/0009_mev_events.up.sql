@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS {db}.mev_events (
+    kind LowCardinality(String),
+    time DateTime,
+    dex LowCardinality(String),
+    pool_address String,
+    attacker String,
+    victim String,
+    frontrun_trace_id String,
+    victim_trace_id String,
+    backrun_trace_id String,
+    frontrun_lt UInt64,
+    victim_lt UInt64,
+    backrun_lt UInt64,
+    jetton_in String,
+    jetton_out String,
+    victim_volume_usd Float64,
+    profit_usd Float64,
+    victim_slippage Float64,
+    victim_loss_usd Float64,
+    catch_time DateTime
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(time)
+ORDER BY (time, attacker);
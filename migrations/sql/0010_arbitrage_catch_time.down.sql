@@ -0,0 +1 @@
+ALTER TABLE {db}.arbitrages DROP COLUMN IF EXISTS catch_time;
@@ -0,0 +1,2 @@
+-- the feed polls arbitrages by the time they were written, rows written before read as caught at the trace time
+ALTER TABLE {db}.arbitrages ADD COLUMN IF NOT EXISTS catch_time DateTime DEFAULT time;
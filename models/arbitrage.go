@@ -28,4 +28,6 @@ type ArbitrageCH struct {
 	TraceIDs  []string `json:"trace_ids"`
 	Dexes     []string `json:"dexes"`
 	Senders   []string `json:"senders"`
+
+	CatchTime time.Time `json:"catch_time"`
 }
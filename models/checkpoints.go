@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type BackfillCheckpoint struct {
+	Job     string    `ch:"job"`
+	Account string    `ch:"account"`
+	Lt      uint64    `ch:"lt"`
+	Done    bool      `ch:"done"`
+	Time    time.Time `ch:"time"`
+}
+
+type IngestionCheckpoint struct {
+	Account string    `ch:"account"`
+	Lt      uint64    `ch:"lt"`
+	Time    time.Time `ch:"time"`
+}
+
+// IngestionGap is a range of account transactions the listener was unable to process, bounds are exclusive
+type IngestionGap struct {
+	Account string    `ch:"account" json:"account"`
+	FromLt  uint64    `ch:"from_lt" json:"from_lt"`
+	ToLt    uint64    `ch:"to_lt" json:"to_lt"`
+	Reason  string    `ch:"reason" json:"reason"`
+	Time    time.Time `ch:"time" json:"time"`
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"log"
+	"math"
 	"math/big"
 )
 
@@ -16,7 +17,7 @@ func DedustSwapInfoToChSwap(info *DedustSwapInfo,
 		var jettonIn *ChainTokenInfo
 		if i == 0 {
 			if info.InWalletAddress == nil { //Then it's TON
-				jettonIn = masterJettonCacheFunc("EQCM3B12QK1e4yZSf8GtBRT0aLMNyEsBc_DhVfRRtOEffLez")
+				jettonIn = masterJettonCacheFunc(TonMaster)
 			} else {
 				jettonIn = walletToMasterCache(info.InWalletAddress.String())
 			}
@@ -27,6 +28,7 @@ func DedustSwapInfoToChSwap(info *DedustSwapInfo,
 		}
 
 		var tokenInUsdRate float64 = 0
+		var tokenInPriceSource = ""
 		var tokenInSymbol = ""
 		var tokenInName = ""
 		var jettonMasterIn = ""
@@ -36,6 +38,7 @@ func DedustSwapInfoToChSwap(info *DedustSwapInfo,
 			rate := rateCache(jettonIn.JettonAddress)
 			if rate != nil {
 				tokenInUsdRate = *rate
+				tokenInPriceSource = PriceSourceTonapi
 			}
 			tokenInSymbol = jettonIn.Symbol
 			tokenInName = jettonIn.Name
@@ -54,7 +57,7 @@ func DedustSwapInfoToChSwap(info *DedustSwapInfo,
 		var jettonOut *ChainTokenInfo
 		if i == len(info.PoolsInfo)-1 {
 			if info.OutWalletAddress == nil { //then it's TON
-				jettonOut = masterJettonCacheFunc("EQCM3B12QK1e4yZSf8GtBRT0aLMNyEsBc_DhVfRRtOEffLez")
+				jettonOut = masterJettonCacheFunc(TonMaster)
 			} else {
 				jettonOut = walletToMasterCache(info.OutWalletAddress.String())
 			}
@@ -63,6 +66,7 @@ func DedustSwapInfoToChSwap(info *DedustSwapInfo,
 		}
 
 		var tokenOutUsdRate float64 = 0
+		var tokenOutPriceSource = ""
 		var tokenOutSymbol = ""
 		var tokenOutName = ""
 		var jettonMasterOut string
@@ -72,6 +76,7 @@ func DedustSwapInfoToChSwap(info *DedustSwapInfo,
 			rate := rateCache(jettonOut.JettonAddress)
 			if rate != nil {
 				tokenOutUsdRate = *rate
+				tokenOutPriceSource = PriceSourceTonapi
 			}
 			tokenOutSymbol = jettonOut.Symbol
 			tokenOutName = jettonOut.Name
@@ -81,29 +86,31 @@ func DedustSwapInfoToChSwap(info *DedustSwapInfo,
 		limit := poolInfo.Limit
 
 		swapChs = append(swapChs, &SwapCH{
-			Dex:               DeDust,
-			Hashes:            []string{poolInfo.Hash},
-			Lt:                poolInfo.Lt,
-			Time:              info.Time,
-			JettonIn:          jettonMasterIn,
-			AmountIn:          amountIn,
-			JettonInSymbol:    tokenInSymbol,
-			JettonInName:      tokenInName,
-			JettonInUsdRate:   tokenInUsdRate,
-			JettonInDecimals:  tokenInDecimals,
-			JettonOut:         jettonMasterOut,
-			AmountOut:         amountOut,
-			JettonOutSymbol:   tokenOutSymbol,
-			JettonOutName:     tokenOutName,
-			JettonOutUsdRate:  tokenOutUsdRate,
-			JettonOutDecimals: tokenOutDecimals,
-			MinAmountOut:      limit,
-			PoolAddress:       poolInfo.Address.String(),
-			Sender:            poolInfo.Sender.String(),
-			ReferralAddress:   "",
-			ReferralAmount:    nil,
-			CatchTime:         info.CatchTime,
-			TraceID:           info.TraceID,
+			Dex:                  DeDust,
+			Hashes:               []string{poolInfo.Hash},
+			Lt:                   poolInfo.Lt,
+			Time:                 info.Time,
+			JettonIn:             jettonMasterIn,
+			AmountIn:             amountIn,
+			JettonInSymbol:       tokenInSymbol,
+			JettonInName:         tokenInName,
+			JettonInUsdRate:      tokenInUsdRate,
+			JettonInDecimals:     tokenInDecimals,
+			JettonOut:            jettonMasterOut,
+			AmountOut:            amountOut,
+			JettonOutSymbol:      tokenOutSymbol,
+			JettonOutName:        tokenOutName,
+			JettonOutUsdRate:     tokenOutUsdRate,
+			JettonOutDecimals:    tokenOutDecimals,
+			MinAmountOut:         limit,
+			PoolAddress:          poolInfo.Address.String(),
+			Sender:               poolInfo.Sender.String(),
+			ReferralAddress:      "",
+			ReferralAmount:       nil,
+			CatchTime:            info.CatchTime,
+			TraceID:              info.TraceID,
+			JettonInPriceSource:  tokenInPriceSource,
+			JettonOutPriceSource: tokenOutPriceSource,
 		})
 	}
 
@@ -164,6 +171,7 @@ func ToChSwap(swap *SwapInfo,
 	tokenInInfo := cache(walletIn)
 
 	var tokenInUsdRate float64 = 0
+	var tokenInPriceSource = ""
 	var tokenInSymbol = ""
 	var tokenInName = ""
 	var jettonMasterIn = ""
@@ -173,6 +181,7 @@ func ToChSwap(swap *SwapInfo,
 		rate := rateCache(tokenInInfo.JettonAddress)
 		if rate != nil {
 			tokenInUsdRate = *rate
+			tokenInPriceSource = PriceSourceTonapi
 		}
 		tokenInSymbol = tokenInInfo.Symbol
 		tokenInName = tokenInInfo.Name
@@ -183,6 +192,7 @@ func ToChSwap(swap *SwapInfo,
 	tokenOutInfo := cache(walletOut)
 
 	var tokenOutUsdRate float64 = 0
+	var tokenOutPriceSource = ""
 	var tokenOutSymbol = ""
 	var tokenOutName = ""
 	var jettonMasterOut string
@@ -192,6 +202,7 @@ func ToChSwap(swap *SwapInfo,
 		rate := rateCache(tokenOutInfo.JettonAddress)
 		if rate != nil {
 			tokenOutUsdRate = *rate
+			tokenOutPriceSource = PriceSourceTonapi
 		}
 		tokenOutSymbol = tokenOutInfo.Symbol
 		tokenOutName = tokenOutInfo.Name
@@ -200,28 +211,179 @@ func ToChSwap(swap *SwapInfo,
 	}
 
 	return &SwapCH{
-		Dex:               dex,
-		Hashes:            hashes,
-		Lt:                swap.Notification.Lt,
-		Time:              swap.Notification.TransactionTime,
-		JettonIn:          jettonMasterIn,
-		AmountIn:          amountIn,
-		JettonInSymbol:    tokenInSymbol,
-		JettonInName:      tokenInName,
-		JettonInUsdRate:   tokenInUsdRate,
-		JettonInDecimals:  tokenInDecimals,
-		JettonOut:         jettonMasterOut,
-		AmountOut:         amountOut,
-		JettonOutSymbol:   tokenOutSymbol,
-		JettonOutName:     tokenOutName,
-		JettonOutUsdRate:  tokenOutUsdRate,
-		JettonOutDecimals: tokenOutDecimals,
-		MinAmountOut:      swap.Notification.MinOut,
-		PoolAddress:       swap.PoolAddress.String(),
-		Sender:            swap.Notification.Sender.String(),
-		ReferralAddress:   referralAddress,
-		ReferralAmount:    referralAmount,
-		CatchTime:         swap.Notification.EventCatchTime,
-		TraceID:           swap.TraceID,
+		Dex:                  dex,
+		Hashes:               hashes,
+		Lt:                   swap.Notification.Lt,
+		Time:                 swap.Notification.TransactionTime,
+		JettonIn:             jettonMasterIn,
+		AmountIn:             amountIn,
+		JettonInSymbol:       tokenInSymbol,
+		JettonInName:         tokenInName,
+		JettonInUsdRate:      tokenInUsdRate,
+		JettonInDecimals:     tokenInDecimals,
+		JettonOut:            jettonMasterOut,
+		AmountOut:            amountOut,
+		JettonOutSymbol:      tokenOutSymbol,
+		JettonOutName:        tokenOutName,
+		JettonOutUsdRate:     tokenOutUsdRate,
+		JettonOutDecimals:    tokenOutDecimals,
+		MinAmountOut:         swap.Notification.MinOut,
+		PoolAddress:          swap.PoolAddress.String(),
+		Sender:               swap.Notification.Sender.String(),
+		ReferralAddress:      referralAddress,
+		ReferralAmount:       referralAmount,
+		CatchTime:            swap.Notification.EventCatchTime,
+		TraceID:              swap.TraceID,
+		JettonInPriceSource:  tokenInPriceSource,
+		JettonOutPriceSource: tokenOutPriceSource,
+	}
+}
+
+type assetFields struct {
+	master   string
+	amount   *big.Int
+	symbol   string
+	name     string
+	usdRate  float64
+	decimals uint64
+}
+
+func resolveAsset(asset *LiquidityAsset,
+	walletToMasterCache func(string) *ChainTokenInfo,
+	masterJettonCacheFunc func(string) *ChainTokenInfo,
+	rateCache func(string) *float64) assetFields {
+
+	fields := assetFields{amount: big.NewInt(0), decimals: 9}
+	if asset == nil {
+		return fields
+	}
+	if asset.Amount != nil {
+		fields.amount = asset.Amount
+	}
+
+	var jetton *ChainTokenInfo
+	if asset.Wallet != nil {
+		jetton = walletToMasterCache(asset.Wallet.String())
+	} else if asset.Master != nil {
+		jetton = masterJettonCacheFunc(asset.Master.String())
+	} else { // Then it's TON
+		jetton = masterJettonCacheFunc(TonMaster)
+	}
+
+	if jetton != nil {
+		rate := rateCache(jetton.JettonAddress)
+		if rate != nil {
+			fields.usdRate = *rate
+		}
+		fields.master = jetton.JettonAddress
+		fields.symbol = jetton.Symbol
+		fields.name = jetton.Name
+		fields.decimals = jetton.Decimals
+	}
+	return fields
+}
+
+// usd converts raw amount of the asset into usd
+func (fields assetFields) usd() float64 {
+	amount, _ := new(big.Float).Quo(new(big.Float).SetInt(fields.amount),
+		new(big.Float).SetFloat64(math.Pow10(int(fields.decimals)))).Float64()
+	return amount * fields.usdRate
+}
+
+func ToChLiquidityEvent(info *LiquidityInfo,
+	walletToMasterCache func(string) *ChainTokenInfo,
+	masterJettonCacheFunc func(string) *ChainTokenInfo,
+	rateCache func(string) *float64) *LiquidityEventCH {
+
+	if len(info.Assets) == 0 || len(info.Assets) > 2 {
+		log.Printf("Liquidity event with %v assets! %v \n", len(info.Assets), info.TraceID)
+		return nil
+	}
+
+	token0 := resolveAsset(info.Assets[0], walletToMasterCache, masterJettonCacheFunc, rateCache)
+	var token1 assetFields
+	if len(info.Assets) == 2 {
+		token1 = resolveAsset(info.Assets[1], walletToMasterCache, masterJettonCacheFunc, rateCache)
+	} else {
+		token1 = resolveAsset(nil, walletToMasterCache, masterJettonCacheFunc, rateCache)
+	}
+
+	lpAmount := info.LpAmount
+	if lpAmount == nil {
+		lpAmount = big.NewInt(0)
+	}
+
+	var provider string
+	if info.Provider != nil {
+		provider = info.Provider.String()
+	}
+	var pool string
+	if info.PoolAddress != nil {
+		pool = info.PoolAddress.String()
+	}
+
+	return &LiquidityEventCH{
+		Dex:             info.Dex,
+		Type:            info.Type,
+		Hashes:          info.Hashes,
+		Lt:              info.Lt,
+		Time:            info.Time,
+		PoolAddress:     pool,
+		Provider:        provider,
+		Jetton0:         token0.master,
+		Amount0:         token0.amount,
+		Jetton0Symbol:   token0.symbol,
+		Jetton0Name:     token0.name,
+		Jetton0UsdRate:  token0.usdRate,
+		Jetton0Decimals: token0.decimals,
+		Jetton1:         token1.master,
+		Amount1:         token1.amount,
+		Jetton1Symbol:   token1.symbol,
+		Jetton1Name:     token1.name,
+		Jetton1UsdRate:  token1.usdRate,
+		Jetton1Decimals: token1.decimals,
+		LpAmount:        lpAmount,
+		CatchTime:       info.CatchTime,
+		TraceID:         info.TraceID,
+	}
+}
+
+func ToChPoolSnapshot(state *PoolState,
+	walletToMasterCache func(string) *ChainTokenInfo,
+	masterJettonCacheFunc func(string) *ChainTokenInfo,
+	rateCache func(string) *float64) *PoolSnapshotCH {
+
+	token0 := resolveAsset(state.Asset0, walletToMasterCache, masterJettonCacheFunc, rateCache)
+	token1 := resolveAsset(state.Asset1, walletToMasterCache, masterJettonCacheFunc, rateCache)
+
+	// constant product pools hold equal value on both sides, so one known rate is enough
+	tvl := token0.usd() + token1.usd()
+	if token0.usdRate == 0 || token1.usdRate == 0 {
+		tvl = 2 * tvl
+	}
+
+	lpSupply := state.LpSupply
+	if lpSupply == nil {
+		lpSupply = big.NewInt(0)
+	}
+
+	return &PoolSnapshotCH{
+		Time:            state.Time,
+		Dex:             state.Dex,
+		PoolAddress:     state.PoolAddress.String(),
+		Jetton0:         token0.master,
+		Reserve0:        token0.amount,
+		Jetton0Symbol:   token0.symbol,
+		Jetton0Name:     token0.name,
+		Jetton0UsdRate:  token0.usdRate,
+		Jetton0Decimals: token0.decimals,
+		Jetton1:         token1.master,
+		Reserve1:        token1.amount,
+		Jetton1Symbol:   token1.symbol,
+		Jetton1Name:     token1.name,
+		Jetton1UsdRate:  token1.usdRate,
+		Jetton1Decimals: token1.decimals,
+		LpSupply:        lpSupply,
+		TvlUsd:          tvl,
 	}
 }
@@ -1,14 +1,11 @@
 package models
 
-import (
-	"fmt"
-)
-
 type Dex string
 
 const (
 	stonfi = "stonfi"
 	dedust = "dedust"
+	tonco  = "tonco"
 	all    = "all"
 )
 
@@ -22,6 +19,8 @@ func ParseDex(s string) (Dex, error) {
 		return stonfi, nil
 	case string(dedust):
 		return dedust, nil
+	case string(tonco):
+		return tonco, nil
 	case string(all):
 		return all, nil
 	default:
@@ -29,20 +28,18 @@ func ParseDex(s string) (Dex, error) {
 	}
 }
 
-func (dex Dex) WhereStatement(field string) string {
+// Names are the values of the dex column which belong to the dex
+func (dex Dex) Names() []string {
 	switch dex {
 	case stonfi:
-		return fmt.Sprint("(", field, " = '", StonfiV1, "'",
-			" OR ", field, " = '", StonfiV2, "'",
-			")")
+		return []string{StonfiV1, StonfiV2}
 	case dedust:
-		return fmt.Sprint("(", field, " = '", DeDust, "'", ")")
+		return []string{DeDust}
+	case tonco:
+		return []string{TONCO}
 	case all:
-		return fmt.Sprint("(", field, " = '", StonfiV1, "'",
-			" OR ", field, " = '", StonfiV2, "'",
-			" OR ", field, " = '", DeDust, "'",
-			")")
+		return []string{StonfiV1, StonfiV2, DeDust, TONCO}
 	default:
-		return ""
+		return []string{}
 	}
 }
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"github.com/xssnick/tonutils-go/address"
+	"math/big"
+	"time"
+)
+
+const LiquidityProvide = "provide"
+const LiquidityWithdraw = "withdraw"
+
+// LiquidityAsset is identified either by jetton wallet of the pool side or by jetton master. Neither means TON
+type LiquidityAsset struct {
+	Wallet *address.Address
+	Master *address.Address
+	Amount *big.Int
+}
+
+type LiquidityInfo struct {
+	TraceID     string
+	Dex         string
+	Type        string
+	Hashes      []string
+	Lt          uint64
+	Time        time.Time
+	CatchTime   time.Time
+	PoolAddress *address.Address
+	Provider    *address.Address
+	Assets      []*LiquidityAsset // one for single sided Ston.fi provisions, two otherwise
+	LpAmount    *big.Int          // zero until LP tokens are minted
+}
+
+type LiquidityEventCH struct {
+	Dex             string    `ch:"dex"`
+	Type            string    `ch:"type"`
+	Hashes          []string  `ch:"hashes"`
+	Lt              uint64    `ch:"lt"`
+	Time            time.Time `ch:"time"`
+	PoolAddress     string    `ch:"pool_address"`
+	Provider        string    `ch:"provider"`
+	Jetton0         string    `ch:"jetton0"`
+	Amount0         *big.Int  `ch:"amount0"`
+	Jetton0Symbol   string    `ch:"jetton0_symbol"`
+	Jetton0Name     string    `ch:"jetton0_name"`
+	Jetton0UsdRate  float64   `ch:"jetton0_usd_rate"`
+	Jetton0Decimals uint64    `ch:"jetton0_decimals"`
+	Jetton1         string    `ch:"jetton1"`
+	Amount1         *big.Int  `ch:"amount1"`
+	Jetton1Symbol   string    `ch:"jetton1_symbol"`
+	Jetton1Name     string    `ch:"jetton1_name"`
+	Jetton1UsdRate  float64   `ch:"jetton1_usd_rate"`
+	Jetton1Decimals uint64    `ch:"jetton1_decimals"`
+	LpAmount        *big.Int  `ch:"lp_amount"`
+	CatchTime       time.Time `ch:"catch_time"`
+	TraceID         string    `ch:"trace_id"`
+}
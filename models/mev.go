@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+const MevSandwich = "sandwich"
+
+// MevEventCH is a victim swap wrapped by the attacker into a swap in the same direction right before it and a reverse one
+// right after it in the same pool. An attack with several victims is a row per victim sharing the profit equally
+type MevEventCH struct {
+	Kind            string    `ch:"kind"`
+	Time            time.Time `ch:"time"`
+	Dex             string    `ch:"dex"`
+	PoolAddress     string    `ch:"pool_address"`
+	Attacker        string    `ch:"attacker"`
+	Victim          string    `ch:"victim"`
+	FrontrunTraceID string    `ch:"frontrun_trace_id"`
+	VictimTraceID   string    `ch:"victim_trace_id"`
+	BackrunTraceID  string    `ch:"backrun_trace_id"`
+	FrontrunLt      uint64    `ch:"frontrun_lt"`
+	VictimLt        uint64    `ch:"victim_lt"`
+	BackrunLt       uint64    `ch:"backrun_lt"`
+	JettonIn        string    `ch:"jetton_in"`
+	JettonOut       string    `ch:"jetton_out"`
+	VictimVolumeUsd float64   `ch:"victim_volume_usd"`
+	ProfitUsd       float64   `ch:"profit_usd"`
+	VictimSlippage  float64   `ch:"victim_slippage"`
+	VictimLossUsd   float64   `ch:"victim_loss_usd"`
+	CatchTime       time.Time `ch:"catch_time"`
+}
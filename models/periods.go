@@ -1,6 +1,10 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 type Period string
 
@@ -46,3 +50,111 @@ func ParsePeriod(s string) (Period, error) {
 		return "", errors.New("invalid period value")
 	}
 }
+
+type Interval string
+
+const (
+	FiveMinutes = "5m"
+	OneHour     = "1h"
+	OneDay      = "1d"
+	OneWeek     = "1w"
+)
+
+type IntervalParams struct {
+	ToStartOf string
+	Step      string
+	// MaxRange keeps the number of buckets of a history reasonable
+	MaxRange time.Duration
+}
+
+var IntervalParamsMap = map[Interval]IntervalParams{
+	FiveMinutes: {
+		ToStartOf: "toStartOfFiveMinutes",
+		Step:      "toIntervalMinute(5)",
+		MaxRange:  3 * 24 * time.Hour,
+	},
+	OneHour: {
+		ToStartOf: "toStartOfHour",
+		Step:      "toIntervalHour(1)",
+		MaxRange:  31 * 24 * time.Hour,
+	},
+	OneDay: {
+		ToStartOf: "toStartOfDay",
+		Step:      "toIntervalDay(1)",
+		MaxRange:  2 * 366 * 24 * time.Hour,
+	},
+	OneWeek: {
+		ToStartOf: "toMonday",
+		Step:      "toIntervalWeek(1)",
+		MaxRange:  10 * 366 * 24 * time.Hour,
+	},
+}
+
+// Window is either the period back from now or the explicit range [From, To), histories are bucketed by the interval
+type Window struct {
+	Period   Period
+	From     time.Time
+	To       time.Time
+	Interval Interval
+}
+
+// NewWindow validates the request, the period is ignored when from is set and the interval is derived if empty
+func NewWindow(period string, from time.Time, to time.Time, interval string) (Window, error) {
+	var window Window
+	var length time.Duration
+	if !from.IsZero() {
+		if to.IsZero() {
+			to = time.Now()
+		}
+		if !from.Before(to) {
+			return Window{}, errors.New("from must be before to")
+		}
+		window.From, window.To = from, to
+		length = to.Sub(from)
+	} else {
+		p, e := ParsePeriod(period)
+		if e != nil {
+			return Window{}, e
+		}
+		window.Period = p
+		length = time.Duration(PeriodParamsMap[p].WindowInDays) * 24 * time.Hour
+	}
+
+	if interval == "" {
+		if window.Period != "" {
+			return window, nil
+		}
+		for _, candidate := range []Interval{OneHour, OneDay, OneWeek} {
+			if length <= IntervalParamsMap[candidate].MaxRange {
+				window.Interval = candidate
+				return window, nil
+			}
+		}
+		return Window{}, errors.New("range is too long")
+	}
+	params, exists := IntervalParamsMap[Interval(interval)]
+	if !exists {
+		return Window{}, errors.New("invalid interval value")
+	}
+	if length > params.MaxRange {
+		return Window{}, fmt.Errorf("range is too long for %v interval, maximum is %v", interval, params.MaxRange)
+	}
+	window.Interval = Interval(interval)
+	return window, nil
+}
+
+// ToStartOf is the function which truncates time to the bucket
+func (w Window) ToStartOf() string {
+	if w.Interval != "" {
+		return IntervalParamsMap[w.Interval].ToStartOf
+	}
+	return PeriodParamsMap[w.Period].ToStartOf
+}
+
+// Step is the interval between buckets
+func (w Window) Step() string {
+	if w.Interval != "" {
+		return IntervalParamsMap[w.Interval].Step
+	}
+	return fmt.Sprint(PeriodParamsMap[w.Period].ToInterval, "(1)")
+}
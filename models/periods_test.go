@@ -0,0 +1,32 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewWindow(t *testing.T) {
+	window, e := NewWindow("week", time.Time{}, time.Time{}, "")
+	assert.Nil(t, e)
+	assert.Equal(t, Window{Period: Week}, window)
+	assert.Equal(t, "toStartOfDay", window.ToStartOf())
+	assert.Equal(t, "toIntervalDay(1)", window.Step())
+
+	to := time.Unix(1700000000, 0)
+	window, e = NewWindow("", to.Add(-90*24*time.Hour), to, "")
+	assert.Nil(t, e)
+	assert.Equal(t, Interval(OneDay), window.Interval)
+
+	window, e = NewWindow("day", to.Add(-6*time.Hour), to, "5m")
+	assert.Nil(t, e)
+	assert.Equal(t, Period(""), window.Period)
+	assert.Equal(t, "toIntervalMinute(5)", window.Step())
+
+	_, e = NewWindow("month", time.Time{}, time.Time{}, "5m")
+	assert.ErrorContains(t, e, "too long")
+	_, e = NewWindow("", to, to.Add(-time.Hour), "")
+	assert.NotNil(t, e)
+	_, e = NewWindow("", time.Time{}, time.Time{}, "")
+	assert.NotNil(t, e)
+}
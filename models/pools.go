@@ -0,0 +1,37 @@
+package models
+
+import (
+	"github.com/xssnick/tonutils-go/address"
+	"math/big"
+	"time"
+)
+
+// PoolState is reserves of the pool read from its get methods, Amount of the assets are the reserves
+type PoolState struct {
+	Time        time.Time
+	Dex         string
+	PoolAddress *address.Address
+	Asset0      *LiquidityAsset
+	Asset1      *LiquidityAsset
+	LpSupply    *big.Int
+}
+
+type PoolSnapshotCH struct {
+	Time            time.Time `ch:"time"`
+	Dex             string    `ch:"dex"`
+	PoolAddress     string    `ch:"pool_address"`
+	Jetton0         string    `ch:"jetton0"`
+	Reserve0        *big.Int  `ch:"reserve0"`
+	Jetton0Symbol   string    `ch:"jetton0_symbol"`
+	Jetton0Name     string    `ch:"jetton0_name"`
+	Jetton0UsdRate  float64   `ch:"jetton0_usd_rate"`
+	Jetton0Decimals uint64    `ch:"jetton0_decimals"`
+	Jetton1         string    `ch:"jetton1"`
+	Reserve1        *big.Int  `ch:"reserve1"`
+	Jetton1Symbol   string    `ch:"jetton1_symbol"`
+	Jetton1Name     string    `ch:"jetton1_name"`
+	Jetton1UsdRate  float64   `ch:"jetton1_usd_rate"`
+	Jetton1Decimals uint64    `ch:"jetton1_decimals"`
+	LpSupply        *big.Int  `ch:"lp_supply"`
+	TvlUsd          float64   `ch:"tvl_usd"`
+}
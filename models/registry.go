@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// DexAccount is a contract the listener subscribes to, Dex is one of the swap dex constants
+type DexAccount struct {
+	Dex     string    `ch:"dex"`
+	Address string    `ch:"address"`
+	Source  string    `ch:"source"`
+	Time    time.Time `ch:"time"`
+}
+
+const (
+	DexAccountSourceDefault    = "default"
+	DexAccountSourceConfig     = "config"
+	DexAccountSourceDiscovered = "discovered"
+)
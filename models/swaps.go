@@ -10,6 +10,7 @@ import (
 const StonfiV1 = "StonfiV1"
 const StonfiV2 = "StonfiV2"
 const DeDust = "DeDust"
+const TONCO = "TONCO"
 
 type SwapInfo struct {
 	TraceID      string //hash of first transaction
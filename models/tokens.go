@@ -1,5 +1,8 @@
 package models
 
+// TonMaster is the master address used for TON, it is the Ston.fi v1 pTON jetton
+const TonMaster = "EQCM3B12QK1e4yZSf8GtBRT0aLMNyEsBc_DhVfRRtOEffLez"
+
 type TonviewerTokenInfo struct {
 	TokenSymbol string
 	TokenName   string
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"math/big"
+	"time"
+)
+
+// TradeCH is what the user swapped in one trace on one dex, a multi-hop route is a single trade of the first jetton in
+// into the last jetton out, the hops stay in the swaps table
+type TradeCH struct {
+	Dex                  string    `ch:"dex"`
+	TraceID              string    `ch:"trace_id"`
+	Sender               string    `ch:"sender"`
+	Lt                   uint64    `ch:"lt"`
+	Time                 time.Time `ch:"time"`
+	Hashes               []string  `ch:"hashes"`
+	JettonIn             string    `ch:"jetton_in"`
+	AmountIn             *big.Int  `ch:"amount_in"`
+	JettonInSymbol       string    `ch:"jetton_in_symbol"`
+	JettonInName         string    `ch:"jetton_in_name"`
+	JettonInUsdRate      float64   `ch:"jetton_in_usd_rate"`
+	JettonInDecimals     uint64    `ch:"jetton_in_decimals"`
+	JettonInPriceSource  string    `ch:"jetton_in_price_source"`
+	JettonOut            string    `ch:"jetton_out"`
+	AmountOut            *big.Int  `ch:"amount_out"`
+	JettonOutSymbol      string    `ch:"jetton_out_symbol"`
+	JettonOutName        string    `ch:"jetton_out_name"`
+	JettonOutUsdRate     float64   `ch:"jetton_out_usd_rate"`
+	JettonOutDecimals    uint64    `ch:"jetton_out_decimals"`
+	JettonOutPriceSource string    `ch:"jetton_out_price_source"`
+	PoolsPath            []string  `ch:"pools_path"`
+	JettonsPath          []string  `ch:"jettons_path"`
+	CatchTime            time.Time `ch:"catch_time"`
+}
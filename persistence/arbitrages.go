@@ -15,27 +15,28 @@ type ArbitrageHistoryEntry struct {
 	Number    uint64    `json:"number" ch:"number"`
 }
 
-func ArbitrageHistorySqlQuery(config *core.DbConfig, period models.Period) string {
-	periodParams := models.PeriodParamsMap[period]
+func ArbitrageHistorySqlQuery(config *core.DbConfig, window models.Window) Query {
 
-	return fmt.Sprint(`
+	return NewQuery(config).Sql(`
 SELECT `,
-		periodParams.ToStartOf, `(time) AS period,
+		window.ToStartOf(), `(time) AS period,
 	sum((`, UsdField("out"), ` - `, UsdField("in"), `) AS usd_diff) AS usd_profit,
 	sum(`, UsdField("in"), `) AS usd_volume,
-	count() AS number
-FROM `, config.DbName, `.arbitrages
-WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
-AND usd_diff > 0
-AND usd_diff < 10000
-AND length(arrayDistinct(senders)) = 1
-GROUP BY period
-ORDER BY period ASC WITH FILL STEP `, periodParams.ToInterval, `(1)`,
-	)
+	count() AS number`).
+		From("arbitrages").
+		TimeWindow("time", window).
+		Where("usd_diff > 0").
+		UsdCap(MaxArbitrageUsd, "usd_diff").
+		Where(singleSenderCondition).
+		Sql(`
+GROUP BY period`).
+		OrderByBuckets(window).
+		Build()
 }
 
 type EnrichedArbitrageCH struct {
 	Time             time.Time  `json:"time" ch:"time"`
+	Key              uint64     `json:"key" ch:"key"`
 	Sender           string     `json:"sender" ch:"sender"`
 	Traces           []string   `json:"traces" ch:"traces"`
 	AmountIn         *big.Int   `json:"amount_in" ch:"amount_in"`
@@ -59,11 +60,16 @@ type EnrichedArbitrageCH struct {
 	AmountsUsdPath   []float64  `json:"amounts_usd_path" ch:"amounts_usd_path"`
 	PoolsPath        []string   `json:"pools_path" ch:"pools_path"`
 	Dexes            []string   `json:"dexes" ch:"dexes"`
+	CatchTime        time.Time  `json:"catch_time" ch:"catch_time"`
 }
 
+// singleSenderCondition keeps arbitrages made by one wallet, chains of swaps of different users are coincidences
+const singleSenderCondition = "length(arrayDistinct(senders)) = 1"
+
 func arbitrageSelectFields() string {
 	return fmt.Sprint(`SELECT
     time,
+    cityHash64(trace_ids) AS key,
     sender,
     trace_ids AS traces,
     amount_in,
@@ -86,29 +92,39 @@ func arbitrageSelectFields() string {
     arrayMap(i -> (toFloat64(amounts_path[i]) / pow(10, jettons_decimals[i])), range(1, length(amounts_path) + 1)) AS amounts_jettons,
     arrayMap(i -> ((amounts_jettons[i]) * (jetton_usd_rates[i])), range(1, length(amounts_path) + 1)) AS amounts_usd_path,
     pools_path,
-    dexes`)
+    dexes,
+    catch_time`)
 }
 
-func LatestArbitragesSqlQuery(config *core.DbConfig, limit uint64) string {
-	return fmt.Sprint(arbitrageSelectFields(), `
-FROM `, config.DbName, `.arbitrages
-WHERE length(arrayDistinct(senders)) = 1
-AND amount_out_usd - amount_in_usd < 10000
-ORDER BY time DESC
-LIMIT `, limit)
+func LatestArbitragesSqlQuery(config *core.DbConfig, filter ListingFilter, cursor *Cursor, limit uint64) Query {
+	q := NewQuery(config).Sql(arbitrageSelectFields()).
+		From("arbitrages").
+		Where(singleSenderCondition).
+		Where("hasAny(?, dexes)", filter.Dex.Names()).
+		UsdCap(MaxArbitrageUsd, "amount_out_usd - amount_in_usd")
+	if len(filter.Senders) > 0 {
+		q.Where("has(?, sender)", filter.Senders)
+	}
+	if filter.Jetton != "" {
+		q.Where("has(jettons_path, ?)", filter.Jetton)
+	}
+	if filter.Pool != "" {
+		q.Where("has(pools_path, ?)", filter.Pool)
+	}
+	return q.page(filter, cursor, "key", "sender", "amount_in_usd", limit).Build()
 }
 
-func TopArbitragesSqlQuery(config *core.DbConfig, period models.Period) string {
-	periodParams := models.PeriodParamsMap[period]
-	return fmt.Sprint(arbitrageSelectFields(), `
-FROM `, config.DbName, `.arbitrages
-    WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
-	AND amount_out_usd - amount_in_usd > 0
-	AND amount_out_usd - amount_in_usd < 10000
-	AND length(arrayDistinct(senders)) = 1
-	ORDER BY amount_out_usd - amount_in_usd desc
-	LIMIT 15
-`)
+func TopArbitragesSqlQuery(config *core.DbConfig, window models.Window) Query {
+	return NewQuery(config).Sql(arbitrageSelectFields()).
+		From("arbitrages").
+		TimeWindow("time", window).
+		Where("amount_out_usd - amount_in_usd > 0").
+		UsdCap(MaxArbitrageUsd, "amount_out_usd - amount_in_usd").
+		Where(singleSenderCondition).
+		Sql(`
+ORDER BY amount_out_usd - amount_in_usd desc`).
+		Limit(15).
+		Build()
 }
 
 type ArbitrageDistribution struct {
@@ -122,9 +138,8 @@ type ArbitrageDistribution struct {
 	Usd_5000      uint64 `ch:"usd_5000" json:"usd_5000"`
 }
 
-func ArbitrageDistributionSqlQuery(config *core.DbConfig, period models.Period) string {
-	periodParams := models.PeriodParamsMap[period]
-	return fmt.Sprint(`
+func ArbitrageDistributionSqlQuery(config *core.DbConfig, window models.Window) Query {
+	return NewQuery(config).Sql(`
 SELECT
     countIf((usd >= 0) AND (usd <= 0.05)) AS usd_5,
     countIf((usd > 0.05) AND (usd <= 0.2)) AS usd_5_20,
@@ -137,11 +152,13 @@ SELECT
 FROM
 (
     SELECT
-        ((amount_out - amount_in) / pow(10, jetton_decimals)) * jetton_usd_rate AS usd
-	FROM `, config.DbName, `.arbitrages
-    WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
-	AND length(arrayDistinct(senders)) = 1
-)`)
+        ((amount_out - amount_in) / pow(10, jetton_decimals)) * jetton_usd_rate AS usd`).
+		From("arbitrages").
+		TimeWindow("time", window).
+		Where(singleSenderCondition).
+		Sql(`
+)`).
+		Build()
 }
 
 type TopArbitrageUser struct {
@@ -151,22 +168,22 @@ type TopArbitrageUser struct {
 	Number    uint64  `ch:"number" json:"number"`
 }
 
-func TopArbitrageUsersSql(config *core.DbConfig, period models.Period) string {
-	periodParams := models.PeriodParamsMap[period]
-	return fmt.Sprint(`
+func TopArbitrageUsersSql(config *core.DbConfig, window models.Window) Query {
+	return NewQuery(config).Sql(`
 SELECT
     sender,
     sum(((amount_out - amount_in) / pow(10, jetton_decimals)) * jetton_usd_rate as usd) AS profit_usd,
     uniq(jetton_symbol) as jettons,
-    count() AS number
-FROM `, config.DbName, `.arbitrages
-WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
-AND length(arrayDistinct(senders)) = 1
-AND usd < 10000
+    count() AS number`).
+		From("arbitrages").
+		TimeWindow("time", window).
+		Where(singleSenderCondition).
+		UsdCap(MaxArbitrageUsd, "usd").
+		Sql(`
 GROUP BY sender
-ORDER BY profit_usd DESC
-LIMIT 10
-`)
+ORDER BY profit_usd DESC`).
+		Limit(10).
+		Build()
 }
 
 type TopArbitrageJetton struct {
@@ -178,24 +195,24 @@ type TopArbitrageJetton struct {
 	Number         uint64  `ch:"number" json:"number"`
 }
 
-func TopArbitrageJettonsSql(config *core.DbConfig, period models.Period) string {
-	periodParams := models.PeriodParamsMap[period]
-	return fmt.Sprint(`
+func TopArbitrageJettonsSql(config *core.DbConfig, window models.Window) Query {
+	return NewQuery(config).Sql(`
 SELECT
     anyHeavy(jetton) as jetton,
     jetton_symbol,
 	anyHeavy(jetton_name) AS jetton_name,
     anyHeavy(jetton_decimals) AS jetton_decimals_tmp,
     sum(((amount_out - amount_in) / pow(10, jetton_decimals)) * jetton_usd_rate AS usd) AS profit_usd,
-    count() AS number
-FROM `, config.DbName, `.arbitrages
-WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
-AND length(arrayDistinct(senders)) = 1
-AND usd > 0
-AND usd < 10000
+    count() AS number`).
+		From("arbitrages").
+		TimeWindow("time", window).
+		Where(singleSenderCondition).
+		Where("usd > 0").
+		UsdCap(MaxArbitrageUsd, "usd").
+		Sql(`
 GROUP BY jetton_symbol
 HAVING number > 1
-ORDER BY profit_usd DESC
-LIMIT 5
-`)
+ORDER BY profit_usd DESC`).
+		Limit(5).
+		Build()
 }
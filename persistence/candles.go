@@ -0,0 +1,212 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+var CandleIntervals = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// MaxCandles is the most candles one request returns
+const MaxCandles = 5000
+
+// Candle prices are amounts of jetton1 per one jetton0, jetton0 is the lexicographically smaller master
+type Candle struct {
+	Time      time.Time `json:"time" ch:"period"`
+	Jetton0   string    `json:"jetton0" ch:"jetton0"`
+	Jetton1   string    `json:"jetton1" ch:"jetton1"`
+	Open      float64   `json:"open" ch:"open"`
+	High      float64   `json:"high" ch:"high"`
+	Low       float64   `json:"low" ch:"low"`
+	Close     float64   `json:"close" ch:"close"`
+	OpenUsd   float64   `json:"open_usd" ch:"open_usd"`
+	HighUsd   float64   `json:"high_usd" ch:"high_usd"`
+	LowUsd    float64   `json:"low_usd" ch:"low_usd"`
+	CloseUsd  float64   `json:"close_usd" ch:"close_usd"`
+	Volume0   float64   `json:"volume0" ch:"volume0"`
+	Volume1   float64   `json:"volume1" ch:"volume1"`
+	VolumeUsd float64   `json:"volume_usd" ch:"volume_usd"`
+	Count     uint64    `json:"count" ch:"count"`
+}
+
+// Invert makes prices denominated in jetton0 and usd prices of jetton1
+func (c *Candle) Invert() {
+	inverse := func(v float64) float64 {
+		if v == 0 {
+			return 0
+		}
+		return 1 / v
+	}
+	// usd price of jetton1 is usd price of jetton0 divided by the amount of jetton1 per jetton0
+	usdOf1 := func(usd float64, price float64) float64 {
+		if price == 0 {
+			return 0
+		}
+		return usd / price
+	}
+	c.OpenUsd, c.CloseUsd = usdOf1(c.OpenUsd, c.Open), usdOf1(c.CloseUsd, c.Close)
+	c.HighUsd, c.LowUsd = usdOf1(c.HighUsd, c.Low), usdOf1(c.LowUsd, c.High)
+	c.Open, c.Close = inverse(c.Open), inverse(c.Close)
+	c.High, c.Low = inverse(c.Low), inverse(c.High)
+	c.Jetton0, c.Jetton1 = c.Jetton1, c.Jetton0
+	c.Volume0, c.Volume1 = c.Volume1, c.Volume0
+}
+
+// ValidateCandleRange rejects ranges with more than MaxCandles candles of the interval instead of cutting them short
+func ValidateCandleRange(interval string, from time.Time, to time.Time) error {
+	step, exists := CandleIntervals[interval]
+	if !exists {
+		return errors.New("invalid interval")
+	}
+	if !from.Before(to) {
+		return errors.New("from must be before to")
+	}
+	if maxRange := MaxCandles * step; to.Sub(from) > maxRange {
+		return fmt.Errorf("range is too long for %v interval, maximum is %v", interval, maxRange)
+	}
+	return nil
+}
+
+// candleStep expects either the pool or both jettons to be set
+func candleStep(pool string, jetton0 string, jetton1 string, interval string, from time.Time, to time.Time) (time.Duration, error) {
+	if e := ValidateCandleRange(interval, from, to); e != nil {
+		return 0, e
+	}
+	if pool == "" && (jetton0 == "" || jetton1 == "") {
+		return 0, errors.New("either pool or jettons must be set")
+	}
+	return CandleIntervals[interval], nil
+}
+
+func CandlesSqlQuery(config *core.DbConfig, pool string, jetton0 string, jetton1 string, interval string, from time.Time, to time.Time) (Query, error) {
+	step, e := candleStep(pool, jetton0, jetton1, interval, from, to)
+	if e != nil {
+		return Query{}, e
+	}
+
+	query := NewQuery(config).Sql(`
+SELECT
+    toStartOfInterval(time, INTERVAL `, uint64(step.Seconds()), ` second) AS period,
+    jetton0,
+    jetton1,
+    argMinMerge(open) AS open,
+    max(high) AS high,
+    min(low) AS low,
+    argMaxMerge(close) AS close,
+    argMinMerge(open_usd) AS open_usd,
+    max(high_usd) AS high_usd,
+    min(low_usd) AS low_usd,
+    argMaxMerge(close_usd) AS close_usd,
+    sum(volume0) AS volume0,
+    sum(volume1) AS volume1,
+    sum(volume_usd) AS volume_usd,
+    sum(count) AS count`).
+		From("candles_1m")
+	if pool != "" {
+		query.Where("pool_address = ?", pool)
+	} else {
+		query.Where("jetton0 = ? AND jetton1 = ?", jetton0, jetton1)
+	}
+	return query.
+		Where("time >= ? AND time < ?", from, to).
+		Sql(`
+GROUP BY period, jetton0, jetton1
+ORDER BY period ASC`).
+		Limit(MaxCandles).
+		Build(), nil
+}
+
+// CandleBuckets are pools and minutes of candles which are rebuilt from the stored swaps, every minute of every pool
+type CandleBuckets struct {
+	Pools   []string `json:"pools"`
+	Minutes []int64  `json:"minutes"`
+}
+
+// SwapCandleBuckets returns the pools and the minutes the swaps were counted in
+func SwapCandleBuckets(swaps []*models.SwapCH) CandleBuckets {
+	var buckets CandleBuckets
+	for _, swap := range swaps {
+		if !slices.Contains(buckets.Pools, swap.PoolAddress) {
+			buckets.Pools = append(buckets.Pools, swap.PoolAddress)
+		}
+		if minute := swap.Time.Truncate(time.Minute).Unix(); !slices.Contains(buckets.Minutes, minute) {
+			buckets.Minutes = append(buckets.Minutes, minute)
+		}
+	}
+	return buckets
+}
+
+// DeleteCandlesSqlQuery waits for the mutation, the candles are inserted again right after it
+func DeleteCandlesSqlQuery(config *core.DbConfig, buckets CandleBuckets) Query {
+	return NewQuery(config).Sql("ALTER TABLE ").Table("candles_1m").Sql(" DELETE").
+		Where("has(?, pool_address)", buckets.Pools).
+		Where("has(?, toUnixTimestamp(time))", buckets.Minutes).
+		Sql(`
+SETTINGS mutations_sync = 1`).
+		Build()
+}
+
+// RebuildCandlesSqlQuery aggregates stored swaps of the buckets like candles_1m_mv of migration 0006_candles does
+func RebuildCandlesSqlQuery(config *core.DbConfig, buckets CandleBuckets) Query {
+	return NewQuery(config).Sql("INSERT INTO ").Table("candles_1m").Sql(`
+SELECT
+    pool_address,
+    toStartOfMinute(time) AS time,
+    jetton0,
+    jetton1,
+    argMinState(price, lt) AS open,
+    max(price) AS high,
+    min(price) AS low,
+    argMaxState(price, lt) AS close,
+    argMinState(price_usd, lt) AS open_usd,
+    max(price_usd) AS high_usd,
+    min(price_usd) AS low_usd,
+    argMaxState(price_usd, lt) AS close_usd,
+    sum(amount0) AS volume0,
+    sum(amount1) AS volume1,
+    sum(volume_usd) AS volume_usd,
+    toUInt64(count()) AS count
+FROM (
+SELECT
+    pool_address,
+    time,
+    lt,
+    least(jetton_in, jetton_out) AS jetton0,
+    greatest(jetton_in, jetton_out) AS jetton1,
+    if(jetton_in = jetton0, amount_in / pow(10, jetton_in_decimals), amount_out / pow(10, jetton_out_decimals)) AS amount0,
+    if(jetton_in = jetton0, amount_out / pow(10, jetton_out_decimals), amount_in / pow(10, jetton_in_decimals)) AS amount1,
+    if(jetton_in = jetton0, jetton_out_usd_rate, jetton_in_usd_rate) AS rate1,
+    if(jetton_in = jetton0, jetton_in_usd_rate, jetton_out_usd_rate) AS rate0,
+    amount1 / amount0 AS price,
+    if(rate1 > 0, price * rate1, rate0) AS price_usd,
+    if(rate1 > 0, amount1 * rate1, amount0 * rate0) AS volume_usd`).
+		From("swaps").
+		Where("jetton_in != '' AND jetton_out != '' AND jetton_in != jetton_out AND amount_in > 0 AND amount_out > 0").
+		Where("has(?, pool_address)", buckets.Pools).
+		Where("has(?, toUnixTimestamp(toStartOfMinute(time)))", buckets.Minutes).
+		Sql(`
+)
+GROUP BY pool_address, time, jetton0, jetton1`).
+		Build()
+}
+
+// RebuildCandles replaces candles of the buckets with the ones of the swaps stored now. The view counts every inserted
+// swap and a deleted swap stays in its candle, so rewriting swaps leaves their candles counted twice until this runs
+func RebuildCandles(config *core.DbConfig, buckets CandleBuckets) error {
+	if len(buckets.Pools) == 0 {
+		return nil
+	}
+	if e := ExecQuery(config, DeleteCandlesSqlQuery(config, buckets)); e != nil {
+		return e
+	}
+	return ExecQuery(config, RebuildCandlesSqlQuery(config, buckets))
+}
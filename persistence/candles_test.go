@@ -0,0 +1,65 @@
+package persistence
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+func TestCandleInvert(t *testing.T) {
+	candle := Candle{
+		Jetton0: "a", Jetton1: "b",
+		Open: 2, High: 4, Low: 1, Close: 0,
+		OpenUsd: 10, HighUsd: 16, LowUsd: 4, CloseUsd: 8,
+		Volume0: 3, Volume1: 6,
+	}
+	candle.Invert()
+
+	assert.Equal(t, "b", candle.Jetton0)
+	assert.Equal(t, "a", candle.Jetton1)
+	assert.Equal(t, 0.5, candle.Open)
+	assert.Equal(t, 1.0, candle.High)
+	assert.Equal(t, 0.25, candle.Low)
+	assert.Equal(t, 0.0, candle.Close)
+	assert.Equal(t, 5.0, candle.OpenUsd)
+	assert.Equal(t, 16.0, candle.HighUsd)
+	assert.Equal(t, 1.0, candle.LowUsd)
+	assert.Equal(t, 0.0, candle.CloseUsd)
+	assert.Equal(t, 6.0, candle.Volume0)
+	assert.Equal(t, 3.0, candle.Volume1)
+}
+
+func TestCandlesSqlQueryValidatesRange(t *testing.T) {
+	config := &core.DbConfig{DbName: "tondexer"}
+	to := time.Unix(1700000000, 0)
+	for _, test := range []struct {
+		interval string
+		from     time.Time
+		valid    bool
+	}{
+		{"1m", to.Add(-MaxCandles * time.Minute), true},
+		{"1m", to.Add(-MaxCandles*time.Minute - time.Second), false},
+		{"1d", to.AddDate(-10, 0, 0), true},
+		{"1h", to, false},
+		{"1h", to.Add(time.Hour), false},
+		{"2h", to.Add(-time.Hour), false},
+	} {
+		_, e := CandlesSqlQuery(config, "pool", "", "", test.interval, test.from, to)
+		assert.Equal(t, test.valid, e == nil, test)
+	}
+
+	_, e := CandlesSqlQuery(config, "", "a", "", "1h", to.Add(-time.Hour), to)
+	assert.NotNil(t, e)
+}
+
+func TestSwapCandleBuckets(t *testing.T) {
+	at := time.Unix(600, 0)
+	buckets := SwapCandleBuckets([]*models.SwapCH{
+		{PoolAddress: "a", Time: at.Add(5 * time.Second)},
+		{PoolAddress: "b", Time: at.Add(59 * time.Second)},
+		{PoolAddress: "a", Time: at.Add(time.Minute)},
+	})
+	assert.Equal(t, CandleBuckets{Pools: []string{"a", "b"}, Minutes: []int64{600, 660}}, buckets)
+}
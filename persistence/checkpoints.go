@@ -0,0 +1,103 @@
+package persistence
+
+import (
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"time"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+func WriteBackfillCheckpoints(config *core.DbConfig, checkpoints []*models.BackfillCheckpoint) error {
+	return WriteToClickhouse(config, checkpoints, "backfill_checkpoints", func(batch driver.Batch, model *models.BackfillCheckpoint) error {
+		return batch.Append(
+			model.Job,
+			model.Account,
+			model.Lt,
+			model.Done,
+			model.Time,
+		)
+	})
+}
+
+// BackfillCheckpointsSqlQuery returns the furthest checkpoint of every account of the job. Pages go from the newest
+// transaction, so the lowest lt is the furthest one. Several pages may be written within a second, the time can't order them
+func BackfillCheckpointsSqlQuery(config *core.DbConfig, job string) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    job,
+    account,
+    min(lt) AS lt,
+    max(done) AS done,
+    max(time) AS time`).
+		From("backfill_checkpoints").
+		Where("job = ?", job).
+		Sql(`
+GROUP BY job, account`).
+		Build()
+}
+
+func ReadBackfillCheckpoints(config *core.DbConfig, job string) ([]models.BackfillCheckpoint, error) {
+	return ReadArrayFromClickhouse[models.BackfillCheckpoint](config, BackfillCheckpointsSqlQuery(config, job))
+}
+
+type SwapHash struct {
+	Hash string `ch:"hash"`
+}
+
+// SwapHashesSqlQuery selects hashes of all transactions of swaps stored in the time range.
+func SwapHashesSqlQuery(config *core.DbConfig, from time.Time, to time.Time) Query {
+	return NewQuery(config).Sql(`
+SELECT DISTINCT arrayJoin(hashes) AS hash`).
+		From("swaps").
+		Where("time >= toDateTime(?) AND time <= toDateTime(?)", from.Unix(), to.Unix()).
+		Build()
+}
+
+func WriteIngestionCheckpoints(config *core.DbConfig, checkpoints []*models.IngestionCheckpoint) error {
+	return WriteToClickhouse(config, checkpoints, "ingestion_checkpoints", func(batch driver.Batch, model *models.IngestionCheckpoint) error {
+		return batch.Append(
+			model.Account,
+			model.Lt,
+			model.Time,
+		)
+	})
+}
+
+func ReadIngestionCheckpoints(config *core.DbConfig) ([]models.IngestionCheckpoint, error) {
+	return ReadArrayFromClickhouse[models.IngestionCheckpoint](config, NewQuery(config).Sql(`
+SELECT
+    account,
+    max(lt) AS lt,
+    max(time) AS time`).
+		From("ingestion_checkpoints").
+		Sql(`
+GROUP BY account`).
+		Build())
+}
+
+func WriteIngestionGaps(config *core.DbConfig, gaps []*models.IngestionGap) error {
+	return WriteToClickhouse(config, gaps, "ingestion_gaps", func(batch driver.Batch, model *models.IngestionGap) error {
+		return batch.Append(
+			model.Account,
+			model.FromLt,
+			model.ToLt,
+			model.Reason,
+			model.Time,
+		)
+	})
+}
+
+func LatestIngestionGapsSqlQuery(config *core.DbConfig, limit uint64) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    account,
+    from_lt,
+    to_lt,
+    reason,
+    time`).
+		From("ingestion_gaps").
+		Sql(`
+ORDER BY time DESC`).
+		Limit(limit).
+		Build()
+}
@@ -6,7 +6,9 @@ import (
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"log"
+	"time"
 	"tondexer/core"
+	"tondexer/metrics"
 	"tondexer/models"
 )
 
@@ -24,76 +26,63 @@ func connection(config *core.DbConfig) (driver.Conn, error) {
 	})
 }
 
-func WriteToClickhouse[T any](config *core.DbConfig, entities []*T, table string, batchFunc func(driver.Batch, *T) error) error {
-	conn, err := connection(config)
+func observeWrite(table string, size int, start time.Time, e error) {
+	status := "ok"
+	if e != nil {
+		status = "error"
+	}
+	metrics.ClickhouseBatchSize.WithLabelValues(table).Observe(float64(size))
+	metrics.ClickhouseWriteSeconds.WithLabelValues(table, status).Observe(time.Since(start).Seconds())
+}
 
+func WriteToClickhouse[T any](config *core.DbConfig, entities []*T, table string, batchFunc func(driver.Batch, *T) error) error {
+	conn, err := sharedConnection(config)
 	if err != nil {
 		log.Printf("Open connection issue: %v \n", err)
 		return err
 	}
 
-	defer func(conn driver.Conn) {
-		err := conn.Close()
-		if err != nil {
-			log.Printf("Close connection issue: %v \n", err)
-		}
-	}(conn)
-
-	batch, err := conn.PrepareBatch(context.Background(), "INSERT INTO "+config.DbName+"."+table)
-	if err != nil {
-		fmt.Printf("Unable to create batch  %v \n", err)
+	if err := insertBatch(conn, config, entities, table, batchFunc); err != nil {
+		log.Printf("Clickhouse insert issue for %v: %v \n", table, err)
 		return err
-	} else {
-		for _, model := range entities {
-			e := batchFunc(batch, model)
-
-			if e != nil {
-				log.Printf("Unable to add batch to %v: %v \n", table, e)
-				return err
-			}
-
-		}
-
-		if len(entities) != 0 {
-			e := batch.Send()
-			if e != nil {
-				log.Printf("Clickhouse insert issue: %v \n", e)
-				return err
-			} else {
-				log.Printf("Batch of %v entities has been written to %v\n", len(entities), table)
-			}
-		}
-		return nil
 	}
+	if len(entities) != 0 {
+		log.Printf("Batch of %v entities has been written to %v\n", len(entities), table)
+	}
+	return nil
 }
 
 func WriteArbitragesToClickhouse(config *core.DbConfig, arbitrages []*models.ArbitrageCH) error {
-	return WriteToClickhouse(config, arbitrages, "arbitrages", func(batch driver.Batch, model *models.ArbitrageCH) error {
-		return batch.Append(
-			model.Sender,
-			model.Time,
-
-			model.AmountIn,
-			model.AmountOut,
-			model.Jetton,
-			model.JettonName,
-			model.JettonSymbol,
-			model.JettonUsdRate,
-			model.JettonDecimals,
-
-			model.AmountsPath,
-			model.JettonsPath,
-			model.JettonNames,
-			model.JettonSymbols,
-			model.JettonUsdRates,
-			model.JettonsDecimals,
-
-			model.PoolsPath,
-			model.TraceIDs,
-			model.Dexes,
-			model.Senders,
-		)
-	})
+	return WriteToClickhouse(config, arbitrages, "arbitrages", AppendArbitrage)
+}
+
+func AppendArbitrage(batch driver.Batch, model *models.ArbitrageCH) error {
+	return batch.Append(
+		model.Sender,
+		model.Time,
+
+		model.AmountIn,
+		model.AmountOut,
+		model.Jetton,
+		model.JettonName,
+		model.JettonSymbol,
+		model.JettonUsdRate,
+		model.JettonDecimals,
+
+		model.AmountsPath,
+		model.JettonsPath,
+		model.JettonNames,
+		model.JettonSymbols,
+		model.JettonUsdRates,
+		model.JettonsDecimals,
+
+		model.PoolsPath,
+		model.TraceIDs,
+		model.Dexes,
+		model.Senders,
+
+		model.CatchTime,
+	)
 }
 
 func ReadClickhouseJettons(config *core.DbConfig) ([]models.ClickhouseJetton, error) {
@@ -139,13 +128,13 @@ type SummaryStats struct {
 	UniqueUsers  uint64 `ch:"unique_users" json:"unique_users"`
 }
 
-func ReadSingleRow[T any](config *core.DbConfig, sql string) (*T, error) {
+func ReadSingleRow[T any](config *core.DbConfig, query Query) (*T, error) {
 	conn, err := connection(config)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
-	row := conn.QueryRow(context.Background(), sql)
+	row := conn.QueryRow(context.Background(), query.Sql, query.Args...)
 
 	var res T
 	err = row.ScanStruct(&res)
@@ -156,78 +145,46 @@ func ReadSingleRow[T any](config *core.DbConfig, sql string) (*T, error) {
 }
 
 func SaveSwapsToClickhouse(config *core.DbConfig, modelsBatch []*models.SwapCH) error {
-	conn, err := connection(config)
-
-	if err != nil {
-		log.Printf("Open connection issue: %v \n", err)
-		return err
-	}
-
-	defer func(conn driver.Conn) {
-		err := conn.Close()
-		if err != nil {
-			log.Printf("Close connection issue: %v \n", err)
-		}
-	}(conn)
-
-	batch, err := conn.PrepareBatch(context.Background(), fmt.Sprintf("INSERT INTO %v.swaps", config.DbName))
-	if err != nil {
-		fmt.Printf("Unable to create batch  %v \n", err)
-		return err
-	} else {
-		for _, model := range modelsBatch {
-			e := batch.Append(
-				model.Dex,
-				model.Hashes,
-				model.Lt,
-				model.Time,
-				model.JettonIn,
-				model.AmountIn,
-				model.JettonInSymbol,
-				model.JettonInName,
-				model.JettonInUsdRate,
-				model.JettonInDecimals,
-				model.JettonOut,
-				model.AmountOut,
-				model.JettonOutSymbol,
-				model.JettonOutName,
-				model.JettonOutUsdRate,
-				model.JettonOutDecimals,
-				model.MinAmountOut,
-				model.PoolAddress,
-				model.Sender,
-				model.ReferralAddress,
-				model.ReferralAmount,
-				model.CatchTime,
-				model.TraceID,
-			)
-
-			if e != nil {
-				log.Printf("Unable to add batch %v \n", e)
-				return err
-			}
-
-		}
+	return WriteToClickhouse(config, modelsBatch, "swaps", AppendSwap)
+}
 
-		if len(modelsBatch) != 0 {
-			e := batch.Send()
-			log.Printf("Batch of %v swaps has been written \n", len(modelsBatch))
-			if e != nil {
-				log.Printf("Clickhouse insert issue: %v \n", e)
-				return err
-			}
-		}
-		return nil
-	}
+func AppendSwap(batch driver.Batch, model *models.SwapCH) error {
+	return batch.Append(
+		model.Dex,
+		model.Hashes,
+		model.Lt,
+		model.Time,
+		model.JettonIn,
+		model.AmountIn,
+		model.JettonInSymbol,
+		model.JettonInName,
+		model.JettonInUsdRate,
+		model.JettonInDecimals,
+		model.JettonOut,
+		model.AmountOut,
+		model.JettonOutSymbol,
+		model.JettonOutName,
+		model.JettonOutUsdRate,
+		model.JettonOutDecimals,
+		model.MinAmountOut,
+		model.PoolAddress,
+		model.Sender,
+		model.ReferralAddress,
+		model.ReferralAmount,
+		model.CatchTime,
+		model.TraceID,
+		model.JettonInPriceSource,
+		model.JettonOutPriceSource,
+	)
 }
 
-func ReadArrayFromClickhouse[T any](config *core.DbConfig, query string) ([]T, error) {
+func ReadArrayFromClickhouse[T any](config *core.DbConfig, query Query) ([]T, error) {
 	conn, err := connection(config)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
-	rows, err := conn.Query(context.Background(), query)
+	rows, err := conn.Query(context.Background(), query.Sql, query.Args...)
 
 	defer func() {
 		if rows != nil {
@@ -249,3 +206,27 @@ func ReadArrayFromClickhouse[T any](config *core.DbConfig, query string) ([]T, e
 
 	return ts, nil
 }
+
+func ExecClickhouse(config *core.DbConfig, statements ...string) error {
+	conn, err := connection(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, statement := range statements {
+		if err := conn.Exec(context.Background(), statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExecQuery runs a statement with bound arguments which returns no rows
+func ExecQuery(config *core.DbConfig, query Query) error {
+	conn, err := connection(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Exec(context.Background(), query.Sql, query.Args...)
+}
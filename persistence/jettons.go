@@ -1,8 +1,8 @@
 package persistence
 
 import (
-	"fmt"
 	"math/big"
+	"time"
 	"tondexer/core"
 	"tondexer/models"
 )
@@ -16,45 +16,236 @@ type JettonVolume struct {
 	JettonUsd      float64  `json:"jetton_usd" ch:"jetton_usd"`
 }
 
-func TopJettonRequest(config *core.DbConfig, period models.Period, dex models.Dex) string {
-	periodParams := models.PeriodParamsMap[period]
+type JettonStats struct {
+	JettonAddress  string  `json:"jetton_address" ch:"jetton_address"`
+	JettonSymbol   string  `json:"jetton_symbol" ch:"jetton_symbol"`
+	JettonName     string  `json:"jetton_name" ch:"jetton_name"`
+	JettonDecimals uint64  `json:"jetton_decimals" ch:"jetton_decimals"`
+	VolumeUsd      float64 `json:"volume_usd" ch:"volume_usd"`
+	BuyUsd         float64 `json:"buy_usd" ch:"buy_usd"`
+	SellUsd        float64 `json:"sell_usd" ch:"sell_usd"`
+	Swaps          uint64  `json:"swaps" ch:"swaps"`
+	Traders        uint64  `json:"traders" ch:"traders"`
+}
 
-	return fmt.Sprint(`
-SELECT
-    any(jetton_address) AS jetton_address,
-    jetton_symbol,
-    any(jetton_name) AS jetton_name,
-    any(jetton_decimals) AS jetton_decimals,
-    sum(amount) AS jetton_amount,
-    sum(jetton_usd_inner) AS jetton_usd
+type JettonPriceEntry struct {
+	Period time.Time `json:"period" ch:"period"`
+	Rate   float64   `json:"rate" ch:"rate"`
+}
+
+type JettonVolumeHistoryEntry struct {
+	Period    time.Time `json:"period" ch:"period"`
+	VolumeUsd float64   `json:"volume_usd" ch:"volume_usd"`
+	Number    uint64    `json:"number" ch:"number"`
+}
+
+type JettonPool struct {
+	PoolAddress string  `json:"pool_address" ch:"pool_address"`
+	Dex         string  `json:"dex" ch:"pool_dex"`
+	VolumeUsd   float64 `json:"volume_usd" ch:"volume_usd"`
+	Swaps       uint64  `json:"swaps" ch:"swaps"`
+}
+
+type JettonTrader struct {
+	Sender    string  `json:"sender" ch:"sender"`
+	VolumeUsd float64 `json:"volume_usd" ch:"volume_usd"`
+	BuyUsd    float64 `json:"buy_usd" ch:"buy_usd"`
+	SellUsd   float64 `json:"sell_usd" ch:"sell_usd"`
+	Swaps     uint64  `json:"swaps" ch:"swaps"`
+}
+
+type JettonDexVolume struct {
+	Dex       string  `json:"dex" ch:"dex"`
+	VolumeUsd float64 `json:"volume_usd" ch:"volume_usd"`
+	Swaps     uint64  `json:"swaps" ch:"swaps"`
+}
+
+type JettonDetails struct {
+	Stats         *JettonStats               `json:"stats"`
+	PriceHistory  []JettonPriceEntry         `json:"price_history"`
+	VolumeHistory []JettonVolumeHistoryEntry `json:"volume_history"`
+	Pools         []JettonPool               `json:"pools"`
+	Traders       []JettonTrader             `json:"traders"`
+	Dexes         []JettonDexVolume          `json:"dexes"`
+}
+
+// fromJettonSides appends a subquery with a row for each jetton side of every swap, the sold jetton and the bought one
+func fromJettonSides(q *QueryBuilder) *QueryBuilder {
+	return q.Sql(`
 FROM
 (
     SELECT
 		time,
     	dex,
+    	sender,
+    	pool_address,
         jetton_in AS jetton_address,
         `, Symbol("jetton_in_symbol"), ` AS jetton_symbol,
         jetton_in_name AS jetton_name,
     	jetton_in_decimals AS jetton_decimals,
         amount_in AS amount,
-        `, UsdInField, ` AS jetton_usd_inner
-    FROM `, config.DbName, `.swaps
+        `, UsdInField, ` AS jetton_usd_inner,
+        'sell' AS side
+    FROM `).Table("swaps").Sql(`
     UNION ALL
     SELECT
 		time,
 		dex,
+		sender,
+		pool_address,
         jetton_out AS jetton_address,
         `, Symbol("jetton_out_symbol"), ` AS jetton_symbol,
         jetton_out_name AS jetton_name,
 		jetton_out_decimals AS jetton_decimals,
         amount_out AS amount,
-        `, UsdOutField, ` AS jetton_usd_inner
-    FROM `, config.DbName, `.swaps
-)
-WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
-AND `, dex.WhereStatement("dex"), ` AND jetton_usd_inner < 1000000
-GROUP BY jetton_symbol
-ORDER BY jetton_usd DESC
-LIMIT 10
-`)
+        `, UsdOutField, ` AS jetton_usd_inner,
+        'buy' AS side
+    FROM `).Table("swaps").Sql(`
+)`)
+}
+
+// TopJettonRequest groups by master, symbols are not unique and scam tokens reuse popular ones
+func TopJettonRequest(config *core.DbConfig, window models.Window, dex models.Dex) Query {
+	return fromJettonSides(NewQuery(config).Sql(`
+SELECT
+    jetton_address,
+    anyHeavy(jetton_symbol) AS jetton_symbol,
+    anyHeavy(jetton_name) AS jetton_name,
+    any(jetton_decimals) AS jetton_decimals,
+    sum(amount) AS jetton_amount,
+    sum(jetton_usd_inner) AS jetton_usd`)).
+		TimeWindow("time", window).
+		Dex("dex", dex).
+		UsdCap(MaxSwapUsd, "jetton_usd_inner").
+		Sql(`
+GROUP BY jetton_address
+ORDER BY jetton_usd DESC`).
+		Limit(10).
+		Build()
+}
+
+// jettonSwaps keeps sides of the given master within the window
+func jettonSwaps(q *QueryBuilder, window models.Window, dex models.Dex, master string) *QueryBuilder {
+	return fromJettonSides(q).
+		TimeWindow("time", window).
+		Dex("dex", dex).
+		Where("jetton_address = ?", master).
+		UsdCap(MaxSwapUsd, "jetton_usd_inner")
+}
+
+func JettonStatsSqlQuery(config *core.DbConfig, window models.Window, dex models.Dex, master string) Query {
+	return jettonSwaps(NewQuery(config).Sql(`
+SELECT
+    any(jetton_address) AS jetton_address,
+    anyHeavy(jetton_symbol) AS jetton_symbol,
+    anyHeavy(jetton_name) AS jetton_name,
+    any(jetton_decimals) AS jetton_decimals,
+    sum(jetton_usd_inner) AS volume_usd,
+    sumIf(jetton_usd_inner, side = 'buy') AS buy_usd,
+    sumIf(jetton_usd_inner, side = 'sell') AS sell_usd,
+    count() AS swaps,
+    uniqExact(sender) AS traders`), window, dex, master).
+		Build()
+}
+
+func JettonPriceHistorySqlQuery(config *core.DbConfig, window models.Window, master string) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    `, window.ToStartOf(), `(time) AS period,
+    avg(rate) AS rate`).
+		From("jetton_rates").
+		TimeWindow("time", window).
+		Where("master = ?", master).
+		Sql(`
+GROUP BY period
+ORDER BY period ASC`).
+		Build()
+}
+
+func JettonVolumeHistorySqlQuery(config *core.DbConfig, window models.Window, dex models.Dex, master string) Query {
+	return jettonSwaps(NewQuery(config).Sql(`
+SELECT
+    `, window.ToStartOf(), `(time) AS period,
+    sum(jetton_usd_inner) AS volume_usd,
+    count() AS number`), window, dex, master).
+		Sql(`
+GROUP BY period`).
+		OrderByBuckets(window).
+		Build()
+}
+
+func JettonTopPoolsSqlQuery(config *core.DbConfig, window models.Window, dex models.Dex, master string) Query {
+	return jettonSwaps(NewQuery(config).Sql(`
+SELECT
+    pool_address,
+    anyHeavy(dex) AS pool_dex,
+    sum(jetton_usd_inner) AS volume_usd,
+    count() AS swaps`), window, dex, master).
+		Sql(`
+GROUP BY pool_address
+ORDER BY volume_usd DESC`).
+		Limit(10).
+		Build()
+}
+
+func JettonTopTradersSqlQuery(config *core.DbConfig, window models.Window, dex models.Dex, master string) Query {
+	return jettonSwaps(NewQuery(config).Sql(`
+SELECT
+    sender,
+    sum(jetton_usd_inner) AS volume_usd,
+    sumIf(jetton_usd_inner, side = 'buy') AS buy_usd,
+    sumIf(jetton_usd_inner, side = 'sell') AS sell_usd,
+    count() AS swaps`), window, dex, master).
+		Sql(`
+GROUP BY sender
+ORDER BY volume_usd DESC`).
+		Limit(10).
+		Build()
+}
+
+func JettonDexSplitSqlQuery(config *core.DbConfig, window models.Window, dex models.Dex, master string) Query {
+	return jettonSwaps(NewQuery(config).Sql(`
+SELECT
+    dex,
+    sum(jetton_usd_inner) AS volume_usd,
+    count() AS swaps`), window, dex, master).
+		Sql(`
+GROUP BY dex
+ORDER BY volume_usd DESC`).
+		Build()
+}
+
+func ReadJettonDetails(config *core.DbConfig, window models.Window, dex models.Dex, master string) (*JettonDetails, error) {
+	stats, e := ReadSingleRow[JettonStats](config, JettonStatsSqlQuery(config, window, dex, master))
+	if e != nil {
+		return nil, e
+	}
+	prices, e := ReadArrayFromClickhouse[JettonPriceEntry](config, JettonPriceHistorySqlQuery(config, window, master))
+	if e != nil {
+		return nil, e
+	}
+	volumes, e := ReadArrayFromClickhouse[JettonVolumeHistoryEntry](config, JettonVolumeHistorySqlQuery(config, window, dex, master))
+	if e != nil {
+		return nil, e
+	}
+	pools, e := ReadArrayFromClickhouse[JettonPool](config, JettonTopPoolsSqlQuery(config, window, dex, master))
+	if e != nil {
+		return nil, e
+	}
+	traders, e := ReadArrayFromClickhouse[JettonTrader](config, JettonTopTradersSqlQuery(config, window, dex, master))
+	if e != nil {
+		return nil, e
+	}
+	dexes, e := ReadArrayFromClickhouse[JettonDexVolume](config, JettonDexSplitSqlQuery(config, window, dex, master))
+	if e != nil {
+		return nil, e
+	}
+	return &JettonDetails{
+		Stats:         stats,
+		PriceHistory:  prices,
+		VolumeHistory: volumes,
+		Pools:         pools,
+		Traders:       traders,
+		Dexes:         dexes,
+	}, nil
 }
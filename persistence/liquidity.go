@@ -0,0 +1,104 @@
+package persistence
+
+import (
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+const UsdLiquidityField = "((amount0 / pow(10, jetton0_decimals)) * jetton0_usd_rate + (amount1 / pow(10, jetton1_decimals)) * jetton1_usd_rate)"
+
+type LiquidityProvider struct {
+	Provider     string  `json:"provider" ch:"provider"`
+	ProvidedUsd  float64 `json:"provided_usd" ch:"provided_usd"`
+	WithdrawnUsd float64 `json:"withdrawn_usd" ch:"withdrawn_usd"`
+	NetUsd       float64 `json:"net_usd" ch:"net_usd"`
+	Pools        uint64  `json:"pools" ch:"pools"`
+	Count        uint64  `json:"count" ch:"count"`
+}
+
+type PoolLiquidityFlow struct {
+	PoolAddress   string  `json:"pool_address" ch:"pool_address"`
+	Dex           string  `json:"dex" ch:"pool_dex"`
+	Jetton0       string  `json:"jetton0" ch:"jetton0"`
+	Jetton0Symbol string  `json:"jetton0_symbol" ch:"jetton0_symbol"`
+	Jetton1       string  `json:"jetton1" ch:"jetton1"`
+	Jetton1Symbol string  `json:"jetton1_symbol" ch:"jetton1_symbol"`
+	ProvidedUsd   float64 `json:"provided_usd" ch:"provided_usd"`
+	WithdrawnUsd  float64 `json:"withdrawn_usd" ch:"withdrawn_usd"`
+	NetUsd        float64 `json:"net_usd" ch:"net_usd"`
+	Providers     uint64  `json:"providers" ch:"providers"`
+}
+
+func WriteLiquidityEventsToClickhouse(config *core.DbConfig, events []*models.LiquidityEventCH) error {
+	return WriteToClickhouse(config, events, "liquidity_events", func(batch driver.Batch, model *models.LiquidityEventCH) error {
+		return batch.Append(
+			model.Dex,
+			model.Type,
+			model.Hashes,
+			model.Lt,
+			model.Time,
+			model.PoolAddress,
+			model.Provider,
+			model.Jetton0,
+			model.Amount0,
+			model.Jetton0Symbol,
+			model.Jetton0Name,
+			model.Jetton0UsdRate,
+			model.Jetton0Decimals,
+			model.Jetton1,
+			model.Amount1,
+			model.Jetton1Symbol,
+			model.Jetton1Name,
+			model.Jetton1UsdRate,
+			model.Jetton1Decimals,
+			model.LpAmount,
+			model.CatchTime,
+			model.TraceID,
+		)
+	})
+}
+
+func TopLiquidityProvidersSql(config *core.DbConfig, window models.Window, dex models.Dex) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    provider,
+    sumIf(`, UsdLiquidityField, `, type = '`, models.LiquidityProvide, `') AS provided_usd,
+    sumIf(`, UsdLiquidityField, `, type = '`, models.LiquidityWithdraw, `') AS withdrawn_usd,
+    provided_usd - withdrawn_usd AS net_usd,
+    uniq(pool_address) AS pools,
+    count() AS count`).
+		From("liquidity_events").
+		TimeWindow("time", window).
+		Dex("dex", dex).
+		UsdCap(MaxLiquidityUsd, UsdLiquidityField).
+		Sql(`
+GROUP BY provider
+ORDER BY provided_usd DESC`).
+		Limit(15).
+		Build()
+}
+
+func PoolLiquidityFlowSql(config *core.DbConfig, window models.Window, dex models.Dex) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    pool_address,
+    anyHeavy(dex) AS pool_dex,
+    anyHeavy(jetton0) AS jetton0,
+    anyHeavy(`, Symbol("jetton0_symbol"), `) AS jetton0_symbol,
+    anyHeavy(jetton1) AS jetton1,
+    anyHeavy(`, Symbol("jetton1_symbol"), `) AS jetton1_symbol,
+    sumIf(`, UsdLiquidityField, `, type = '`, models.LiquidityProvide, `') AS provided_usd,
+    sumIf(`, UsdLiquidityField, `, type = '`, models.LiquidityWithdraw, `') AS withdrawn_usd,
+    provided_usd - withdrawn_usd AS net_usd,
+    uniq(provider) AS providers`).
+		From("liquidity_events").
+		TimeWindow("time", window).
+		Dex("dex", dex).
+		UsdCap(MaxLiquidityUsd, UsdLiquidityField).
+		Sql(`
+GROUP BY pool_address
+ORDER BY abs(net_usd) DESC`).
+		Limit(15).
+		Build()
+}
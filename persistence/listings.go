@@ -0,0 +1,141 @@
+package persistence
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+// Page sizes of swap and arbitrage listings, larger requests are cut to MaxPageSize
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// ListingFilter narrows swap and arbitrage listings, zero values don't filter
+type ListingFilter struct {
+	Senders []string
+	Jetton  string
+	Pool    string
+	Dex     models.Dex
+	MinUsd  float64
+	MaxUsd  float64
+	From    time.Time
+	To      time.Time
+}
+
+// Cursor points at the last row of a page, the next page starts right after it.
+// Key is lt for swaps and a hash of trace ids for arbitrages which have no lt.
+// Lt is per account, so Address, the pool for swaps and the sender for arbitrages, tells apart rows sharing time and key
+type Cursor struct {
+	Time    time.Time
+	Key     uint64
+	Address string
+}
+
+func (c *Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%v_%v_%v", c.Time.Unix(), c.Key, c.Address)))
+}
+
+func ParseCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	decoded, e := base64.RawURLEncoding.DecodeString(s)
+	if e != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	parts := strings.SplitN(string(decoded), "_", 3)
+	if len(parts) != 3 {
+		return nil, errors.New("invalid cursor")
+	}
+	seconds, e := strconv.ParseInt(parts[0], 10, 64)
+	if e != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	key, e := strconv.ParseUint(parts[1], 10, 64)
+	if e != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &Cursor{Time: time.Unix(seconds, 0), Key: key, Address: parts[2]}, nil
+}
+
+// PageSize applies the default and the cap to the requested size
+func PageSize(limit uint64) uint64 {
+	if limit == 0 {
+		return DefaultPageSize
+	}
+	return min(limit, MaxPageSize)
+}
+
+// NextSwapsCursor returns nil when the page is the last one
+func NextSwapsCursor(swaps []EnrichedSwapCH, limit uint64) *Cursor {
+	if len(swaps) == 0 || uint64(len(swaps)) < limit {
+		return nil
+	}
+	last := swaps[len(swaps)-1]
+	return &Cursor{Time: last.Time, Key: last.Lt, Address: last.PoolAddress}
+}
+
+func NextArbitragesCursor(arbitrages []EnrichedArbitrageCH, limit uint64) *Cursor {
+	if len(arbitrages) == 0 || uint64(len(arbitrages)) < limit {
+		return nil
+	}
+	last := arbitrages[len(arbitrages)-1]
+	return &Cursor{Time: last.Time, Key: last.Key, Address: last.Sender}
+}
+
+// page appends filters shared by listings, the time range and the cursor, then orders rows from the newest
+func (q *QueryBuilder) page(filter ListingFilter, cursor *Cursor, key string, address string, usd string, limit uint64) *QueryBuilder {
+	if filter.MinUsd > 0 {
+		q.Where(usd+" >= ?", filter.MinUsd)
+	}
+	if filter.MaxUsd > 0 {
+		q.Where(usd+" <= ?", filter.MaxUsd)
+	}
+	if !filter.From.IsZero() {
+		q.Where("time >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		q.Where("time < ?", filter.To)
+	}
+	if cursor != nil {
+		q.Where(fmt.Sprint("(time, ", key, ", ", address, ") < (?, ?, ?)"), cursor.Time, cursor.Key, cursor.Address)
+	}
+	return q.Sql(fmt.Sprint(`
+ORDER BY time DESC, `, key, ` DESC, `, address, ` DESC`)).
+		Limit(limit)
+}
+
+// FeedLimit bounds a single feed query, polls are seconds apart so it is reached only after outages and the poller pages past it
+const FeedLimit = 5000
+
+// SwapsCaughtSinceSqlQuery returns swaps written by the listener since the time, catch_time is the listener clock
+func SwapsCaughtSinceSqlQuery(config *core.DbConfig, since time.Time) Query {
+	return NewQuery(config).Sql(enrichedSwapSelect).
+		From("swaps").
+		Where("catch_time >= ?", since).
+		UsdCap(MaxSwapUsd, "out_usd", "in_usd").
+		Sql(`
+ORDER BY catch_time ASC, lt ASC`).
+		Limit(FeedLimit).
+		Build()
+}
+
+// ArbitragesSinceSqlQuery returns arbitrages written since the time, catch_time is the listener clock like for swaps
+func ArbitragesSinceSqlQuery(config *core.DbConfig, since time.Time) Query {
+	return NewQuery(config).Sql(arbitrageSelectFields()).
+		From("arbitrages").
+		Where("catch_time >= ?", since).
+		Where(singleSenderCondition).
+		UsdCap(MaxArbitrageUsd, "amount_out_usd - amount_in_usd").
+		Sql(`
+ORDER BY catch_time ASC, time ASC`).
+		Limit(FeedLimit).
+		Build()
+}
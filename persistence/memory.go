@@ -0,0 +1,1230 @@
+package persistence
+
+import (
+	"cmp"
+	"hash/fnv"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"tondexer/models"
+)
+
+// MemoryStore keeps rows in memory and answers reads by scanning them the way the clickhouse queries do.
+// Pool snapshots, liquidity events and ingestion gaps are not written through a Store, their reads are empty
+type MemoryStore struct {
+	mutex      sync.RWMutex
+	swaps      []*models.SwapCH
+	arbitrages []*models.ArbitrageCH
+	trades     []*models.TradeCH
+	mevEvents  []*models.MevEventCH
+	jettons    map[string]models.ClickhouseJetton
+	wallets    map[string]models.WalletJetton
+	rates      []*models.JettonRate
+	gaps       []*models.IngestionGap
+	accounts   map[string]models.DexAccount
+	// Now is the clock of period windows
+	Now func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		jettons:  map[string]models.ClickhouseJetton{},
+		wallets:  map[string]models.WalletJetton{},
+		accounts: map[string]models.DexAccount{},
+		Now:      time.Now,
+	}
+}
+
+func (s *MemoryStore) SaveSwaps(swaps []*models.SwapCH) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.swaps = append(s.swaps, swaps...)
+	return nil
+}
+
+func (s *MemoryStore) SaveArbitrages(arbitrages []*models.ArbitrageCH) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.arbitrages = append(s.arbitrages, arbitrages...)
+	return nil
+}
+
+func (s *MemoryStore) SaveTrades(trades []*models.TradeCH) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.trades = append(s.trades, trades...)
+	return nil
+}
+
+func (s *MemoryStore) SaveMevEvents(events []*models.MevEventCH) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.mevEvents = append(s.mevEvents, events...)
+	return nil
+}
+
+// SaveJettons replaces jettons with the same master like ReplacingMergeTree does
+func (s *MemoryStore) SaveJettons(jettons []*models.ChainTokenInfo) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, jetton := range jettons {
+		s.jettons[jetton.JettonAddress] = models.ClickhouseJetton{
+			Name:     jetton.Name,
+			Symbol:   jetton.Symbol,
+			Master:   jetton.JettonAddress,
+			Decimals: jetton.Decimals,
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) SaveWalletMasters(wallets []*models.WalletJetton) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, wallet := range wallets {
+		s.wallets[wallet.Wallet] = *wallet
+	}
+	return nil
+}
+
+func (s *MemoryStore) SaveRates(rates []*models.JettonRate) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.rates = append(s.rates, rates...)
+	return nil
+}
+
+func (s *MemoryStore) SaveIngestionGaps(gaps []*models.IngestionGap) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.gaps = append(s.gaps, gaps...)
+	return nil
+}
+
+// SaveDexAccounts keeps the first record of an address like the argMin of DexAccountsSqlQuery
+func (s *MemoryStore) SaveDexAccounts(accounts []*models.DexAccount) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, account := range accounts {
+		if stored, exists := s.accounts[account.Address]; !exists || account.Time.Before(stored.Time) {
+			s.accounts[account.Address] = *account
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Jettons() ([]models.ClickhouseJetton, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var jettons []models.ClickhouseJetton
+	for _, jetton := range s.jettons {
+		jettons = append(jettons, jetton)
+	}
+	return jettons, nil
+}
+
+func (s *MemoryStore) WalletMasters() ([]models.WalletJetton, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var wallets []models.WalletJetton
+	for _, wallet := range s.wallets {
+		wallets = append(wallets, wallet)
+	}
+	return wallets, nil
+}
+
+func (s *MemoryStore) DexAccounts() ([]models.DexAccount, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var accounts []models.DexAccount
+	for _, account := range s.accounts {
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+func toJettons(amount *big.Int, decimals uint64) float64 {
+	if amount == nil {
+		return 0
+	}
+	value, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), big.NewFloat(math.Pow10(int(decimals)))).Float64()
+	return value
+}
+
+func usdIn(swap *models.SwapCH) float64 {
+	return toJettons(swap.AmountIn, swap.JettonInDecimals) * swap.JettonInUsdRate
+}
+
+func usdOut(swap *models.SwapCH) float64 {
+	return toJettons(swap.AmountOut, swap.JettonOutDecimals) * swap.JettonOutUsdRate
+}
+
+func usdReferral(swap *models.SwapCH) float64 {
+	return toJettons(swap.ReferralAmount, swap.JettonOutDecimals) * swap.JettonOutUsdRate
+}
+
+func swapUsd(swap *models.SwapCH) float64 {
+	return (usdIn(swap) + usdOut(swap)) / 2
+}
+
+func swapUnderCap(swap *models.SwapCH) bool {
+	return usdIn(swap) < MaxSwapUsd && usdOut(swap) < MaxSwapUsd
+}
+
+func tradeUsdIn(trade *models.TradeCH) float64 {
+	return toJettons(trade.AmountIn, trade.JettonInDecimals) * trade.JettonInUsdRate
+}
+
+func tradeUsdOut(trade *models.TradeCH) float64 {
+	return toJettons(trade.AmountOut, trade.JettonOutDecimals) * trade.JettonOutUsdRate
+}
+
+func tradeUsd(trade *models.TradeCH) float64 {
+	return (tradeUsdIn(trade) + tradeUsdOut(trade)) / 2
+}
+
+func tradeUnderCap(trade *models.TradeCH) bool {
+	return tradeUsdIn(trade) < MaxSwapUsd && tradeUsdOut(trade) < MaxSwapUsd
+}
+
+func tradeJettons(trade *models.TradeCH) []string {
+	return trade.JettonsPath
+}
+
+func tradeSender(trade *models.TradeCH) string {
+	return trade.Sender
+}
+
+func symbol(s string) string {
+	if s == "pTON" {
+		return "TON"
+	}
+	return s
+}
+
+func floor2(value float64) float64 {
+	return math.Floor(value*100) / 100
+}
+
+func toUInt256(value float64) *big.Int {
+	result, _ := big.NewFloat(math.Max(value, 0)).Int(nil)
+	return result
+}
+
+func sumBig(a *big.Int, b *big.Int) *big.Int {
+	if a == nil {
+		a = new(big.Int)
+	}
+	if b == nil {
+		return a
+	}
+	return new(big.Int).Add(a, b)
+}
+
+func enrichSwap(swap *models.SwapCH) EnrichedSwapCH {
+	return EnrichedSwapCH{
+		Time:              swap.Time,
+		Lt:                swap.Lt,
+		Dex:               swap.Dex,
+		Hashes:            swap.Hashes,
+		Sender:            swap.Sender,
+		JettonInMaster:    swap.JettonIn,
+		JettonInSymbol:    symbol(swap.JettonInSymbol),
+		JettonInName:      swap.JettonInName,
+		JettonInUsdRate:   swap.JettonInUsdRate,
+		JettonInDecimals:  swap.JettonInDecimals,
+		AmountIn:          swap.AmountIn,
+		AmountJettonIn:    toJettons(swap.AmountIn, swap.JettonInDecimals),
+		InUsd:             floor2(usdIn(swap)),
+		JettonOut:         swap.JettonOut,
+		JettonOutSymbol:   symbol(swap.JettonOutSymbol),
+		JettonOutName:     swap.JettonOutName,
+		JettonOutUsdRate:  swap.JettonOutUsdRate,
+		JettonOutDecimals: swap.JettonOutDecimals,
+		AmountOut:         swap.AmountOut,
+		AmountJettonOut:   toJettons(swap.AmountOut, swap.JettonOutDecimals),
+		OutUsd:            floor2(usdOut(swap)),
+		MinAmountOut:      swap.MinAmountOut,
+		ReferralAddress:   swap.ReferralAddress,
+		ReferralAmount:    swap.ReferralAmount,
+		ReferralUsd:       floor2(usdReferral(swap)),
+		TraceID:           swap.TraceID,
+		PoolAddress:       swap.PoolAddress,
+		CatchTime:         swap.CatchTime,
+	}
+}
+
+func enrichSwaps(swaps []*models.SwapCH) []EnrichedSwapCH {
+	var enriched []EnrichedSwapCH
+	for _, swap := range swaps {
+		enriched = append(enriched, enrichSwap(swap))
+	}
+	return enriched
+}
+
+func arbitrageInUsd(arbitrage *models.ArbitrageCH) float64 {
+	return toJettons(arbitrage.AmountIn, arbitrage.JettonDecimals) * arbitrage.JettonUsdRate
+}
+
+func arbitrageProfitUsd(arbitrage *models.ArbitrageCH) float64 {
+	return toJettons(arbitrage.AmountOut, arbitrage.JettonDecimals)*arbitrage.JettonUsdRate - arbitrageInUsd(arbitrage)
+}
+
+func singleSender(arbitrage *models.ArbitrageCH) bool {
+	for _, sender := range arbitrage.Senders {
+		if sender != arbitrage.Senders[0] {
+			return false
+		}
+	}
+	return len(arbitrage.Senders) > 0
+}
+
+// arbitrageKey stands for cityHash64(trace_ids), it only has to be stable within the store
+func arbitrageKey(arbitrage *models.ArbitrageCH) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(strings.Join(arbitrage.TraceIDs, ",")))
+	return hash.Sum64()
+}
+
+func enrichArbitrage(arbitrage *models.ArbitrageCH) EnrichedArbitrageCH {
+	enriched := EnrichedArbitrageCH{
+		Time:             arbitrage.Time,
+		Key:              arbitrageKey(arbitrage),
+		Sender:           arbitrage.Sender,
+		Traces:           arbitrage.TraceIDs,
+		AmountIn:         arbitrage.AmountIn,
+		AmountInJettons:  toJettons(arbitrage.AmountIn, arbitrage.JettonDecimals),
+		AmountOut:        arbitrage.AmountOut,
+		AmountOutJettons: toJettons(arbitrage.AmountOut, arbitrage.JettonDecimals),
+		Jetton:           arbitrage.Jetton,
+		JettonSymbol:     symbol(arbitrage.JettonSymbol),
+		JettonName:       arbitrage.JettonName,
+		JettonUsdRate:    arbitrage.JettonUsdRate,
+		JettonDecimals:   arbitrage.JettonDecimals,
+		AmountsPath:      arbitrage.AmountsPath,
+		JettonsPath:      arbitrage.JettonsPath,
+		JettonNames:      arbitrage.JettonNames,
+		JettonUsdRates:   arbitrage.JettonUsdRates,
+		JettonsDecimals:  arbitrage.JettonsDecimals,
+		PoolsPath:        arbitrage.PoolsPath,
+		Dexes:            arbitrage.Dexes,
+		CatchTime:        arbitrage.CatchTime,
+	}
+	enriched.AmountInUSD = enriched.AmountInJettons * arbitrage.JettonUsdRate
+	enriched.AmountOutUSD = enriched.AmountOutJettons * arbitrage.JettonUsdRate
+	for _, jettonSymbol := range arbitrage.JettonSymbols {
+		enriched.JettonSymbols = append(enriched.JettonSymbols, symbol(jettonSymbol))
+	}
+	for i, amount := range arbitrage.AmountsPath {
+		if i >= len(arbitrage.JettonsDecimals) || i >= len(arbitrage.JettonUsdRates) {
+			break
+		}
+		jettons := toJettons(amount, arbitrage.JettonsDecimals[i])
+		enriched.AmountsJettons = append(enriched.AmountsJettons, jettons)
+		enriched.AmountsUsdPath = append(enriched.AmountsUsdPath, jettons*arbitrage.JettonUsdRates[i])
+	}
+	return enriched
+}
+
+func enrichArbitrages(arbitrages []*models.ArbitrageCH) []EnrichedArbitrageCH {
+	var enriched []EnrichedArbitrageCH
+	for _, arbitrage := range arbitrages {
+		enriched = append(enriched, enrichArbitrage(arbitrage))
+	}
+	return enriched
+}
+
+// bucketing mirrors ToStartOf and Step of the window, clickhouse works in UTC
+func bucketing(window models.Window) (func(time.Time) time.Time, time.Duration) {
+	switch window.ToStartOf() {
+	case "toStartOfFiveMinutes":
+		return func(t time.Time) time.Time { return t.UTC().Truncate(5 * time.Minute) }, 5 * time.Minute
+	case "toStartOfHour":
+		return func(t time.Time) time.Time { return t.UTC().Truncate(time.Hour) }, time.Hour
+	case "toMonday":
+		return func(t time.Time) time.Time {
+			day := t.UTC().Truncate(24 * time.Hour)
+			return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		}, 7 * 24 * time.Hour
+	default:
+		return func(t time.Time) time.Time { return t.UTC().Truncate(24 * time.Hour) }, 24 * time.Hour
+	}
+}
+
+// inWindow mirrors TimeWindow, a period starts at the beginning of its first bucket
+func (s *MemoryStore) inWindow(window models.Window) func(time.Time) bool {
+	if window.Period != "" {
+		params := models.PeriodParamsMap[window.Period]
+		truncate, _ := bucketing(models.Window{Period: window.Period})
+		start := truncate(s.Now().AddDate(0, 0, -int(params.WindowInDays)))
+		return func(t time.Time) bool { return !t.Before(start) }
+	}
+	return func(t time.Time) bool { return !t.Before(window.From) && t.Before(window.To) }
+}
+
+// buckets groups rows by bucket and lists buckets with empty ones filled like OrderByBuckets does
+func buckets[T any](window models.Window, rows []T, timeOf func(T) time.Time) ([]time.Time, map[time.Time][]T) {
+	truncate, step := bucketing(window)
+	grouped := map[time.Time][]T{}
+	for _, row := range rows {
+		bucket := truncate(timeOf(row))
+		grouped[bucket] = append(grouped[bucket], row)
+	}
+
+	var first, end time.Time
+	if window.Period == "" {
+		first, end = truncate(window.From), window.To
+	} else {
+		if len(grouped) == 0 {
+			return nil, grouped
+		}
+		for bucket := range grouped {
+			if first.IsZero() || bucket.Before(first) {
+				first = bucket
+			}
+			if bucket.Add(step).After(end) {
+				end = bucket.Add(step)
+			}
+		}
+	}
+	var periods []time.Time
+	for period := first; period.Before(end); period = period.Add(step) {
+		periods = append(periods, period)
+	}
+	return periods, grouped
+}
+
+func top[T any](rows []T, compare func(a, b T) int, limit int) []T {
+	slices.SortStableFunc(rows, compare)
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+func descending[T any](value func(T) float64) func(a, b T) int {
+	return func(a, b T) int { return cmp.Compare(value(b), value(a)) }
+}
+
+// groupBy keeps the order in which keys appear
+func groupBy[T any](rows []T, key func(T) string) ([]string, map[string][]T) {
+	var keys []string
+	groups := map[string][]T{}
+	for _, row := range rows {
+		k := key(row)
+		if _, exists := groups[k]; !exists {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], row)
+	}
+	return keys, groups
+}
+
+func uniq[T any](rows []T, values func(T) []string) uint64 {
+	set := map[string]struct{}{}
+	for _, row := range rows {
+		for _, value := range values(row) {
+			set[value] = struct{}{}
+		}
+	}
+	return uint64(len(set))
+}
+
+func sum[T any](rows []T, value func(T) float64) float64 {
+	total := 0.0
+	for _, row := range rows {
+		total += value(row)
+	}
+	return total
+}
+
+func (s *MemoryStore) selectSwaps(keep func(*models.SwapCH) bool) []*models.SwapCH {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var swaps []*models.SwapCH
+	for _, swap := range s.swaps {
+		if keep(swap) {
+			swaps = append(swaps, swap)
+		}
+	}
+	return swaps
+}
+
+func (s *MemoryStore) selectTrades(keep func(*models.TradeCH) bool) []*models.TradeCH {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var trades []*models.TradeCH
+	for _, trade := range s.trades {
+		if keep(trade) {
+			trades = append(trades, trade)
+		}
+	}
+	return trades
+}
+
+func (s *MemoryStore) selectArbitrages(keep func(*models.ArbitrageCH) bool) []*models.ArbitrageCH {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var arbitrages []*models.ArbitrageCH
+	for _, arbitrage := range s.arbitrages {
+		if singleSender(arbitrage) && keep(arbitrage) {
+			arbitrages = append(arbitrages, arbitrage)
+		}
+	}
+	return arbitrages
+}
+
+// windowSwaps are swaps of the dex within the window under the usd cap, most of the queries start with them
+func (s *MemoryStore) windowSwaps(window models.Window, dex models.Dex) []*models.SwapCH {
+	inWindow := s.inWindow(window)
+	names := dex.Names()
+	return s.selectSwaps(func(swap *models.SwapCH) bool {
+		return inWindow(swap.Time) && slices.Contains(names, swap.Dex) && swapUnderCap(swap)
+	})
+}
+
+// windowTrades are trades of the dex within the window under the usd cap, user and summary stats count them
+func (s *MemoryStore) windowTrades(window models.Window, dex models.Dex) []*models.TradeCH {
+	inWindow := s.inWindow(window)
+	names := dex.Names()
+	return s.selectTrades(func(trade *models.TradeCH) bool {
+		return inWindow(trade.Time) && slices.Contains(names, trade.Dex) && tradeUnderCap(trade)
+	})
+}
+
+func swapTime(swap *models.SwapCH) time.Time {
+	return swap.Time
+}
+
+func tradeTime(trade *models.TradeCH) time.Time {
+	return trade.Time
+}
+
+func newestSwapsFirst(a, b *models.SwapCH) int {
+	if c := b.Time.Compare(a.Time); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(b.Lt, a.Lt); c != 0 {
+		return c
+	}
+	return cmp.Compare(b.PoolAddress, a.PoolAddress)
+}
+
+func (s *MemoryStore) Summary(window models.Window, dex models.Dex) (*SummaryStats, error) {
+	trades := s.windowTrades(window, dex)
+	return &SummaryStats{
+		Volume:       uint64(sum(trades, tradeUsd)),
+		Number:       uint64(len(trades)),
+		UniqueTokens: uniq(trades, tradeJettons),
+		UniqueUsers:  uniq(trades, func(trade *models.TradeCH) []string { return []string{trade.Sender} }),
+	}, nil
+}
+
+func (s *MemoryStore) VolumeHistory(window models.Window, dex models.Dex) ([]VolumeHistoryEntry, error) {
+	periods, grouped := buckets(window, s.windowTrades(window, dex), tradeTime)
+	dexVolume := func(trades []*models.TradeCH, dexes ...string) *big.Int {
+		return toUInt256(sum(trades, func(trade *models.TradeCH) float64 {
+			if slices.Contains(dexes, trade.Dex) {
+				return tradeUsd(trade)
+			}
+			return 0
+		}))
+	}
+	var history []VolumeHistoryEntry
+	for _, period := range periods {
+		trades := grouped[period]
+		history = append(history, VolumeHistoryEntry{
+			Period:          period,
+			StonfiVolumeUsd: dexVolume(trades, models.StonfiV1, models.StonfiV2),
+			DedustVolumeUsd: dexVolume(trades, models.DeDust),
+			ToncoVolumeUsd:  dexVolume(trades, models.TONCO),
+			Number:          uint64(len(trades)),
+		})
+	}
+	return history, nil
+}
+
+func (s *MemoryStore) SwapsDistribution(window models.Window, dex models.Dex) (*SwapDistribution, error) {
+	var distribution SwapDistribution
+	for _, swap := range s.windowSwaps(window, dex) {
+		switch usd := swapUsd(swap); {
+		case usd <= 1:
+			distribution.Usd_1++
+		case usd <= 5:
+			distribution.Usd_1_5++
+		case usd < 15:
+			distribution.Usd_5_15++
+		case usd < 50:
+			distribution.Usd_15_50++
+		case usd < 150:
+			distribution.Usd_50_150++
+		case usd < 500:
+			distribution.Usd_150_500++
+		case usd < 2000:
+			distribution.Usd_500_2000++
+		default:
+			distribution.Usd_2000++
+		}
+	}
+	return &distribution, nil
+}
+
+func (s *MemoryStore) TopSwaps(window models.Window, dex models.Dex, limit uint64) ([]EnrichedSwapCH, error) {
+	var swaps []EnrichedSwapCH
+	for _, swap := range enrichSwaps(s.windowSwaps(window, dex)) {
+		if swap.InUsd != 0 && swap.OutUsd != 0 {
+			swaps = append(swaps, swap)
+		}
+	}
+	return top(swaps, descending(func(swap EnrichedSwapCH) float64 { return swap.InUsd + swap.OutUsd }), int(limit)), nil
+}
+
+// inPage mirrors the filters of QueryBuilder.page
+func inPage(filter ListingFilter, cursor *Cursor, t time.Time, key uint64, address string, usd float64) bool {
+	return (filter.MinUsd == 0 || usd >= filter.MinUsd) &&
+		(filter.MaxUsd == 0 || usd <= filter.MaxUsd) &&
+		(filter.From.IsZero() || !t.Before(filter.From)) &&
+		(filter.To.IsZero() || t.Before(filter.To)) &&
+		(cursor == nil || t.Before(cursor.Time) || (t.Equal(cursor.Time) && (key < cursor.Key || (key == cursor.Key && address < cursor.Address))))
+}
+
+func (s *MemoryStore) LatestSwaps(filter ListingFilter, cursor *Cursor, limit uint64) ([]EnrichedSwapCH, error) {
+	names := filter.Dex.Names()
+	swaps := s.selectSwaps(func(swap *models.SwapCH) bool {
+		enriched := enrichSwap(swap)
+		return slices.Contains(names, swap.Dex) &&
+			enriched.InUsd < MaxSwapUsd && enriched.OutUsd < MaxSwapUsd &&
+			(len(filter.Senders) == 0 || slices.Contains(filter.Senders, swap.Sender)) &&
+			(filter.Jetton == "" || swap.JettonIn == filter.Jetton || swap.JettonOut == filter.Jetton) &&
+			(filter.Pool == "" || swap.PoolAddress == filter.Pool) &&
+			inPage(filter, cursor, swap.Time, swap.Lt, swap.PoolAddress, (enriched.InUsd+enriched.OutUsd)/2)
+	})
+	return enrichSwaps(top(swaps, newestSwapsFirst, int(limit))), nil
+}
+
+func (s *MemoryStore) SwapsCaughtSince(since time.Time) ([]EnrichedSwapCH, error) {
+	swaps := s.selectSwaps(func(swap *models.SwapCH) bool {
+		return !swap.CatchTime.Before(since) && swapUnderCap(swap)
+	})
+	return enrichSwaps(top(swaps, func(a, b *models.SwapCH) int {
+		if c := a.CatchTime.Compare(b.CatchTime); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Lt, b.Lt)
+	}, FeedLimit)), nil
+}
+
+func (s *MemoryStore) TopPools(window models.Window, dex models.Dex) ([]PoolVolume, error) {
+	pools, grouped := groupBy(s.windowSwaps(window, dex), func(swap *models.SwapCH) string { return swap.PoolAddress })
+	var volumes []PoolVolume
+	for _, pool := range pools {
+		swaps := grouped[pool]
+		first := swaps[0]
+		volume := PoolVolume{
+			PoolAddress:       pool,
+			JettonIn:          first.JettonIn,
+			AmountIn:          new(big.Int),
+			AmountInUsd:       sum(swaps, usdIn),
+			JettonInName:      first.JettonInName,
+			JettonInSymbol:    symbol(first.JettonInSymbol),
+			JettonInDecimals:  first.JettonInDecimals,
+			JettonOut:         first.JettonOut,
+			AmountOut:         new(big.Int),
+			AmountOutUsd:      sum(swaps, usdOut),
+			JettonOutName:     first.JettonOutName,
+			JettonOutSymbol:   symbol(first.JettonOutSymbol),
+			JettonOutDecimals: first.JettonOutDecimals,
+			Dex:               first.Dex,
+		}
+		for _, swap := range swaps {
+			volume.AmountIn = sumBig(volume.AmountIn, swap.AmountIn)
+			volume.AmountOut = sumBig(volume.AmountOut, swap.AmountOut)
+		}
+		volume.AmountUsd = (volume.AmountInUsd + volume.AmountOutUsd) / 2
+		volumes = append(volumes, volume)
+	}
+	return top(volumes, descending(func(volume PoolVolume) float64 { return volume.AmountUsd }), 15), nil
+}
+
+// jettonSide mirrors a row of fromJettonSides
+type jettonSide struct {
+	swap     *models.SwapCH
+	jetton   string
+	symbol   string
+	name     string
+	decimals uint64
+	amount   *big.Int
+	usd      float64
+	side     string
+}
+
+// tradeSides are sides of trades, the jettons the user gave and got without the intermediate ones
+func tradeSides(trades []*models.TradeCH) []jettonSide {
+	var sides []jettonSide
+	for _, trade := range trades {
+		sides = append(sides,
+			jettonSide{nil, trade.JettonIn, symbol(trade.JettonInSymbol), trade.JettonInName, trade.JettonInDecimals, trade.AmountIn, tradeUsdIn(trade), "sell"},
+			jettonSide{nil, trade.JettonOut, symbol(trade.JettonOutSymbol), trade.JettonOutName, trade.JettonOutDecimals, trade.AmountOut, tradeUsdOut(trade), "buy"})
+	}
+	return sides
+}
+
+func jettonSides(swaps []*models.SwapCH) []jettonSide {
+	var sides []jettonSide
+	for _, swap := range swaps {
+		sides = append(sides,
+			jettonSide{swap, swap.JettonIn, symbol(swap.JettonInSymbol), swap.JettonInName, swap.JettonInDecimals, swap.AmountIn, usdIn(swap), "sell"},
+			jettonSide{swap, swap.JettonOut, symbol(swap.JettonOutSymbol), swap.JettonOutName, swap.JettonOutDecimals, swap.AmountOut, usdOut(swap), "buy"})
+	}
+	return sides
+}
+
+// windowSides are sides of swaps in the window without the cap of the whole swap, each side is capped on its own
+func (s *MemoryStore) windowSides(window models.Window, dex models.Dex, keep func(jettonSide) bool) []jettonSide {
+	inWindow := s.inWindow(window)
+	names := dex.Names()
+	swaps := s.selectSwaps(func(swap *models.SwapCH) bool {
+		return inWindow(swap.Time) && slices.Contains(names, swap.Dex)
+	})
+	var sides []jettonSide
+	for _, side := range jettonSides(swaps) {
+		if side.usd < MaxSwapUsd && keep(side) {
+			sides = append(sides, side)
+		}
+	}
+	return sides
+}
+
+func sideUsd(side jettonSide) float64 {
+	return side.usd
+}
+
+func sideUsdOf(kind string) func(jettonSide) float64 {
+	return func(side jettonSide) float64 {
+		if side.side == kind {
+			return side.usd
+		}
+		return 0
+	}
+}
+
+func (s *MemoryStore) TopJettons(window models.Window, dex models.Dex) ([]JettonVolume, error) {
+	sides := s.windowSides(window, dex, func(jettonSide) bool { return true })
+	jettons, grouped := groupBy(sides, func(side jettonSide) string { return side.jetton })
+	var volumes []JettonVolume
+	for _, jetton := range jettons {
+		sides := grouped[jetton]
+		volume := JettonVolume{
+			JettonAddress:  jetton,
+			JettonSymbol:   sides[0].symbol,
+			JettonName:     sides[0].name,
+			JettonDecimals: sides[0].decimals,
+			JettonAmount:   new(big.Int),
+			JettonUsd:      sum(sides, sideUsd),
+		}
+		for _, side := range sides {
+			volume.JettonAmount = sumBig(volume.JettonAmount, side.amount)
+		}
+		volumes = append(volumes, volume)
+	}
+	return top(volumes, descending(func(volume JettonVolume) float64 { return volume.JettonUsd }), 10), nil
+}
+
+func userVolumes[T any](rows []T, user func(T) string, usd func(T) float64, tokens func(T) []string) []UserVolume {
+	users, grouped := groupBy(rows, user)
+	var volumes []UserVolume
+	for _, address := range users {
+		rows := grouped[address]
+		volumes = append(volumes, UserVolume{
+			UserAddress: address,
+			AmountUsd:   sum(rows, usd),
+			Tokens:      uniq(rows, tokens),
+			Count:       uint64(len(rows)),
+		})
+	}
+	return top(volumes, descending(func(volume UserVolume) float64 { return volume.AmountUsd }), 15)
+}
+
+func (s *MemoryStore) TopUsers(window models.Window, dex models.Dex) ([]UserVolume, error) {
+	return userVolumes(s.windowTrades(window, dex), tradeSender, tradeUsd, tradeJettons), nil
+}
+
+func (s *MemoryStore) TopReferrers(window models.Window, _ models.Dex) ([]UserVolume, error) {
+	inWindow := s.inWindow(window)
+	swaps := s.selectSwaps(func(swap *models.SwapCH) bool { return inWindow(swap.Time) })
+	return userVolumes(swaps, func(swap *models.SwapCH) string { return swap.ReferralAddress }, usdReferral, func(swap *models.SwapCH) []string {
+		return []string{swap.JettonOut}
+	}), nil
+}
+
+func (s *MemoryStore) TopProfiters(window models.Window) ([]UserVolume, error) {
+	inWindow := s.inWindow(window)
+	trades := s.selectTrades(func(trade *models.TradeCH) bool {
+		return inWindow(trade.Time) && trade.JettonInUsdRate != 0 && trade.JettonOutUsdRate != 0
+	})
+	return userVolumes(trades, tradeSender, func(trade *models.TradeCH) float64 {
+		return tradeUsdOut(trade) - tradeUsdIn(trade)
+	}, tradeJettons), nil
+}
+
+func realizedPnl(boughtAmount float64, boughtUsd float64, soldAmount float64, soldUsd float64) float64 {
+	if boughtAmount <= 0 || soldAmount <= 0 {
+		return 0
+	}
+	return (soldUsd/soldAmount - boughtUsd/boughtAmount) * min(boughtAmount, soldAmount)
+}
+
+func (s *MemoryStore) UserPortfolio(address string, addresses []string, limit uint64, offset uint64) (*UserPortfolio, error) {
+	swaps := s.selectSwaps(func(swap *models.SwapCH) bool { return slices.Contains(addresses, swap.Sender) })
+	trades := s.selectTrades(func(trade *models.TradeCH) bool { return slices.Contains(addresses, trade.Sender) })
+
+	capped := slices.DeleteFunc(slices.Clone(trades), func(trade *models.TradeCH) bool { return !tradeUnderCap(trade) })
+	names, byDex := groupBy(capped, func(trade *models.TradeCH) string { return trade.Dex })
+	var dexes []UserDexVolume
+	for _, name := range names {
+		trades := byDex[name]
+		volume := UserDexVolume{Dex: name, VolumeUsd: sum(trades, tradeUsd), Swaps: uint64(len(trades)), FirstSwap: trades[0].Time, LastSwap: trades[0].Time}
+		for _, trade := range trades {
+			if trade.Time.Before(volume.FirstSwap) {
+				volume.FirstSwap = trade.Time
+			}
+			if trade.Time.After(volume.LastSwap) {
+				volume.LastSwap = trade.Time
+			}
+		}
+		dexes = append(dexes, volume)
+	}
+	dexes = top(dexes, descending(func(volume UserDexVolume) float64 { return volume.VolumeUsd }), len(dexes))
+
+	sides := slices.DeleteFunc(tradeSides(trades), func(side jettonSide) bool { return side.usd >= MaxSwapUsd })
+	masters, byJetton := groupBy(sides, func(side jettonSide) string { return side.jetton })
+	var jettons []UserJetton
+	for _, master := range masters {
+		jetton := UserJetton{Jetton: master, JettonSymbol: byJetton[master][0].symbol, Swaps: uint64(len(byJetton[master]))}
+		for _, side := range byJetton[master] {
+			amount := toJettons(side.amount, side.decimals)
+			if side.side == "buy" {
+				jetton.BoughtAmount += amount
+				jetton.BoughtUsd += side.usd
+			} else {
+				jetton.SoldAmount += amount
+				jetton.SoldUsd += side.usd
+			}
+		}
+		jetton.RealizedPnlUsd = realizedPnl(jetton.BoughtAmount, jetton.BoughtUsd, jetton.SoldAmount, jetton.SoldUsd)
+		jettons = append(jettons, jetton)
+	}
+	jettons = top(jettons, descending(func(jetton UserJetton) float64 { return jetton.BoughtUsd + jetton.SoldUsd }), 20)
+
+	arbitrages := s.selectArbitrages(func(arbitrage *models.ArbitrageCH) bool {
+		return slices.Contains(addresses, arbitrage.Sender) && arbitrageProfitUsd(arbitrage) < MaxArbitrageUsd
+	})
+	referrals := s.selectSwaps(func(swap *models.SwapCH) bool {
+		return slices.Contains(addresses, swap.ReferralAddress) && usdReferral(swap) < MaxSwapUsd
+	})
+
+	history := top(swaps, newestSwapsFirst, len(swaps))
+	history = history[min(int(offset), len(history)):]
+	history = history[:min(int(limit), len(history))]
+
+	return newUserPortfolio(address, dexes, jettons, &UserArbitrages{
+		Number:    uint64(len(arbitrages)),
+		ProfitUsd: sum(arbitrages, arbitrageProfitUsd),
+		VolumeUsd: sum(arbitrages, arbitrageInUsd),
+	}, &UserReferrals{
+		Swaps:       uint64(len(referrals)),
+		EarningsUsd: sum(referrals, usdReferral),
+	}, enrichSwaps(history)), nil
+}
+
+func (s *MemoryStore) JettonDetails(window models.Window, dex models.Dex, master string) (*JettonDetails, error) {
+	sides := s.windowSides(window, dex, func(side jettonSide) bool { return side.jetton == master })
+	stats := &JettonStats{
+		VolumeUsd: sum(sides, sideUsd),
+		BuyUsd:    sum(sides, sideUsdOf("buy")),
+		SellUsd:   sum(sides, sideUsdOf("sell")),
+		Swaps:     uint64(len(sides)),
+		Traders:   uniq(sides, func(side jettonSide) []string { return []string{side.swap.Sender} }),
+	}
+	if len(sides) > 0 {
+		stats.JettonAddress, stats.JettonSymbol, stats.JettonName, stats.JettonDecimals = master, sides[0].symbol, sides[0].name, sides[0].decimals
+	}
+
+	inWindow := s.inWindow(window)
+	s.mutex.RLock()
+	var rates []*models.JettonRate
+	for _, rate := range s.rates {
+		if rate.Master == master && inWindow(rate.Time) {
+			rates = append(rates, rate)
+		}
+	}
+	s.mutex.RUnlock()
+	truncate, _ := bucketing(window)
+	periods, byPeriod := groupBy(rates, func(rate *models.JettonRate) string { return truncate(rate.Time).Format(time.RFC3339) })
+	var prices []JettonPriceEntry
+	for _, period := range periods {
+		rates := byPeriod[period]
+		prices = append(prices, JettonPriceEntry{
+			Period: truncate(rates[0].Time),
+			Rate:   sum(rates, func(rate *models.JettonRate) float64 { return rate.Rate }) / float64(len(rates)),
+		})
+	}
+	slices.SortFunc(prices, func(a, b JettonPriceEntry) int { return a.Period.Compare(b.Period) })
+
+	bucketPeriods, byBucket := buckets(window, sides, func(side jettonSide) time.Time { return side.swap.Time })
+	var volumes []JettonVolumeHistoryEntry
+	for _, period := range bucketPeriods {
+		volumes = append(volumes, JettonVolumeHistoryEntry{Period: period, VolumeUsd: sum(byBucket[period], sideUsd), Number: uint64(len(byBucket[period]))})
+	}
+
+	poolAddresses, byPool := groupBy(sides, func(side jettonSide) string { return side.swap.PoolAddress })
+	var pools []JettonPool
+	for _, pool := range poolAddresses {
+		pools = append(pools, JettonPool{PoolAddress: pool, Dex: byPool[pool][0].swap.Dex, VolumeUsd: sum(byPool[pool], sideUsd), Swaps: uint64(len(byPool[pool]))})
+	}
+
+	senders, bySender := groupBy(sides, func(side jettonSide) string { return side.swap.Sender })
+	var traders []JettonTrader
+	for _, sender := range senders {
+		sides := bySender[sender]
+		traders = append(traders, JettonTrader{
+			Sender:    sender,
+			VolumeUsd: sum(sides, sideUsd),
+			BuyUsd:    sum(sides, sideUsdOf("buy")),
+			SellUsd:   sum(sides, sideUsdOf("sell")),
+			Swaps:     uint64(len(sides)),
+		})
+	}
+
+	dexNames, byDex := groupBy(sides, func(side jettonSide) string { return side.swap.Dex })
+	var dexes []JettonDexVolume
+	for _, name := range dexNames {
+		dexes = append(dexes, JettonDexVolume{Dex: name, VolumeUsd: sum(byDex[name], sideUsd), Swaps: uint64(len(byDex[name]))})
+	}
+
+	return &JettonDetails{
+		Stats:         stats,
+		PriceHistory:  prices,
+		VolumeHistory: volumes,
+		Pools:         top(pools, descending(func(pool JettonPool) float64 { return pool.VolumeUsd }), 10),
+		Traders:       top(traders, descending(func(trader JettonTrader) float64 { return trader.VolumeUsd }), 10),
+		Dexes:         top(dexes, descending(func(dex JettonDexVolume) float64 { return dex.VolumeUsd }), len(dexes)),
+	}, nil
+}
+
+func (s *MemoryStore) PoolDetails(window models.Window, pool string, limit uint64) (*PoolDetails, error) {
+	swaps := s.selectSwaps(func(swap *models.SwapCH) bool { return swap.PoolAddress == pool })
+	if len(swaps) == 0 {
+		return nil, nil
+	}
+
+	first := swaps[0]
+	info := &PoolInfo{PoolAddress: pool, Dex: first.Dex, FirstSwap: first.Time, LastSwap: first.Time}
+	info.Jetton0, info.Jetton0Symbol, info.Jetton0Name, info.Jetton0Decimals = first.JettonIn, symbol(first.JettonInSymbol), first.JettonInName, first.JettonInDecimals
+	info.Jetton1, info.Jetton1Symbol, info.Jetton1Name, info.Jetton1Decimals = first.JettonOut, symbol(first.JettonOutSymbol), first.JettonOutName, first.JettonOutDecimals
+	if info.Jetton0 > info.Jetton1 {
+		info.Jetton0, info.Jetton1 = info.Jetton1, info.Jetton0
+		info.Jetton0Symbol, info.Jetton1Symbol = info.Jetton1Symbol, info.Jetton0Symbol
+		info.Jetton0Name, info.Jetton1Name = info.Jetton1Name, info.Jetton0Name
+		info.Jetton0Decimals, info.Jetton1Decimals = info.Jetton1Decimals, info.Jetton0Decimals
+	}
+	for _, swap := range swaps {
+		if swap.Time.Before(info.FirstSwap) {
+			info.FirstSwap = swap.Time
+		}
+		if swap.Time.After(info.LastSwap) {
+			info.LastSwap = swap.Time
+		}
+	}
+
+	inWindow := s.inWindow(window)
+	windowed := slices.DeleteFunc(slices.Clone(swaps), func(swap *models.SwapCH) bool { return !inWindow(swap.Time) || !swapUnderCap(swap) })
+	stats := &PoolStats{
+		VolumeUsd: sum(windowed, swapUsd),
+		Swaps:     uint64(len(windowed)),
+		Traders:   uniq(windowed, func(swap *models.SwapCH) []string { return []string{swap.Sender} }),
+	}
+	for _, swap := range windowed {
+		if swap.JettonIn < swap.JettonOut {
+			stats.Jetton0Sells++
+			stats.Jetton0SellUsd += usdIn(swap)
+		} else if swap.JettonIn > swap.JettonOut {
+			stats.Jetton1Sells++
+			stats.Jetton1SellUsd += usdIn(swap)
+		}
+	}
+
+	periods, grouped := buckets(window, windowed, swapTime)
+	var history []PoolVolumeHistoryEntry
+	for _, period := range periods {
+		history = append(history, PoolVolumeHistoryEntry{Period: period, VolumeUsd: sum(grouped[period], swapUsd), Number: uint64(len(grouped[period]))})
+	}
+
+	arbitrages := s.selectArbitrages(func(arbitrage *models.ArbitrageCH) bool {
+		return inWindow(arbitrage.Time) && slices.Contains(arbitrage.PoolsPath, pool) && arbitrageProfitUsd(arbitrage) < MaxArbitrageUsd
+	})
+
+	return &PoolDetails{
+		Info:          info,
+		Stats:         stats,
+		VolumeHistory: history,
+		Swaps:         enrichSwaps(top(swaps, newestSwapsFirst, int(limit))),
+		Arbitrages:    enrichArbitrages(top(arbitrages, newestArbitragesFirst, int(limit))),
+	}, nil
+}
+
+// Candles mirrors the candles_1m view, prices are amounts of jetton1 per one jetton0
+func (s *MemoryStore) Candles(pool string, jetton0 string, jetton1 string, interval string, from time.Time, to time.Time) ([]Candle, error) {
+	step, e := candleStep(pool, jetton0, jetton1, interval, from, to)
+	if e != nil {
+		return nil, e
+	}
+	swaps := s.selectSwaps(func(swap *models.SwapCH) bool {
+		return swap.JettonIn != "" && swap.JettonOut != "" && swap.JettonIn != swap.JettonOut &&
+			swap.AmountIn != nil && swap.AmountIn.Sign() > 0 && swap.AmountOut != nil && swap.AmountOut.Sign() > 0 &&
+			!swap.Time.Before(from) && swap.Time.Before(to) &&
+			((pool != "" && swap.PoolAddress == pool) || (pool == "" && min(swap.JettonIn, swap.JettonOut) == jetton0 && max(swap.JettonIn, swap.JettonOut) == jetton1))
+	})
+	slices.SortStableFunc(swaps, func(a, b *models.SwapCH) int { return cmp.Compare(a.Lt, b.Lt) })
+
+	keys, grouped := groupBy(swaps, func(swap *models.SwapCH) string {
+		return swap.Time.UTC().Truncate(step).Format(time.RFC3339) + min(swap.JettonIn, swap.JettonOut) + max(swap.JettonIn, swap.JettonOut)
+	})
+	var candles []Candle
+	for _, key := range keys {
+		var candle Candle
+		for i, swap := range grouped[key] {
+			amount0, amount1 := toJettons(swap.AmountIn, swap.JettonInDecimals), toJettons(swap.AmountOut, swap.JettonOutDecimals)
+			rate0, rate1 := swap.JettonInUsdRate, swap.JettonOutUsdRate
+			candle.Jetton0, candle.Jetton1 = swap.JettonIn, swap.JettonOut
+			if swap.JettonIn > swap.JettonOut {
+				amount0, amount1, rate0, rate1 = amount1, amount0, rate1, rate0
+				candle.Jetton0, candle.Jetton1 = swap.JettonOut, swap.JettonIn
+			}
+			price := amount1 / amount0
+			priceUsd, volumeUsd := rate0, amount0*rate0
+			if rate1 > 0 {
+				priceUsd, volumeUsd = price*rate1, amount1*rate1
+			}
+			if i == 0 {
+				candle.Time = swap.Time.UTC().Truncate(step)
+				candle.Open, candle.High, candle.Low = price, price, price
+				candle.OpenUsd, candle.HighUsd, candle.LowUsd = priceUsd, priceUsd, priceUsd
+			}
+			candle.High, candle.Low = max(candle.High, price), min(candle.Low, price)
+			candle.HighUsd, candle.LowUsd = max(candle.HighUsd, priceUsd), min(candle.LowUsd, priceUsd)
+			candle.Close, candle.CloseUsd = price, priceUsd
+			candle.Volume0 += amount0
+			candle.Volume1 += amount1
+			candle.VolumeUsd += volumeUsd
+			candle.Count++
+		}
+		candles = append(candles, candle)
+	}
+	return top(candles, func(a, b Candle) int { return a.Time.Compare(b.Time) }, MaxCandles), nil
+}
+
+func newestArbitragesFirst(a, b *models.ArbitrageCH) int {
+	if c := b.Time.Compare(a.Time); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(arbitrageKey(b), arbitrageKey(a)); c != 0 {
+		return c
+	}
+	return cmp.Compare(b.Sender, a.Sender)
+}
+
+func (s *MemoryStore) LatestArbitrages(filter ListingFilter, cursor *Cursor, limit uint64) ([]EnrichedArbitrageCH, error) {
+	names := filter.Dex.Names()
+	arbitrages := s.selectArbitrages(func(arbitrage *models.ArbitrageCH) bool {
+		return slices.ContainsFunc(arbitrage.Dexes, func(dex string) bool { return slices.Contains(names, dex) }) &&
+			arbitrageProfitUsd(arbitrage) < MaxArbitrageUsd &&
+			(len(filter.Senders) == 0 || slices.Contains(filter.Senders, arbitrage.Sender)) &&
+			(filter.Jetton == "" || slices.Contains(arbitrage.JettonsPath, filter.Jetton)) &&
+			(filter.Pool == "" || slices.Contains(arbitrage.PoolsPath, filter.Pool)) &&
+			inPage(filter, cursor, arbitrage.Time, arbitrageKey(arbitrage), arbitrage.Sender, arbitrageInUsd(arbitrage))
+	})
+	return enrichArbitrages(top(arbitrages, newestArbitragesFirst, int(limit))), nil
+}
+
+func (s *MemoryStore) ArbitragesSince(since time.Time) ([]EnrichedArbitrageCH, error) {
+	arbitrages := s.selectArbitrages(func(arbitrage *models.ArbitrageCH) bool {
+		return !arbitrage.CatchTime.Before(since) && arbitrageProfitUsd(arbitrage) < MaxArbitrageUsd
+	})
+	return enrichArbitrages(top(arbitrages, func(a, b *models.ArbitrageCH) int {
+		if c := a.CatchTime.Compare(b.CatchTime); c != 0 {
+			return c
+		}
+		return a.Time.Compare(b.Time)
+	}, FeedLimit)), nil
+}
+
+// windowArbitrages are profitable arbitrages within the window under the cap
+func (s *MemoryStore) windowArbitrages(window models.Window) []*models.ArbitrageCH {
+	inWindow := s.inWindow(window)
+	return s.selectArbitrages(func(arbitrage *models.ArbitrageCH) bool {
+		profit := arbitrageProfitUsd(arbitrage)
+		return inWindow(arbitrage.Time) && profit > 0 && profit < MaxArbitrageUsd
+	})
+}
+
+func (s *MemoryStore) TopArbitrages(window models.Window) ([]EnrichedArbitrageCH, error) {
+	return enrichArbitrages(top(s.windowArbitrages(window), descending(arbitrageProfitUsd), 15)), nil
+}
+
+func (s *MemoryStore) ArbitrageHistory(window models.Window) ([]ArbitrageHistoryEntry, error) {
+	periods, grouped := buckets(window, s.windowArbitrages(window), func(arbitrage *models.ArbitrageCH) time.Time { return arbitrage.Time })
+	var history []ArbitrageHistoryEntry
+	for _, period := range periods {
+		arbitrages := grouped[period]
+		history = append(history, ArbitrageHistoryEntry{
+			Period:    period,
+			UsdProfit: sum(arbitrages, arbitrageProfitUsd),
+			UsdVolume: sum(arbitrages, arbitrageInUsd),
+			Number:    uint64(len(arbitrages)),
+		})
+	}
+	return history, nil
+}
+
+func (s *MemoryStore) ArbitrageDistribution(window models.Window) (*ArbitrageDistribution, error) {
+	inWindow := s.inWindow(window)
+	var distribution ArbitrageDistribution
+	for _, arbitrage := range s.selectArbitrages(func(arbitrage *models.ArbitrageCH) bool { return inWindow(arbitrage.Time) }) {
+		switch usd := arbitrageProfitUsd(arbitrage); {
+		case usd < 0:
+		case usd <= 0.05:
+			distribution.Usd_5++
+		case usd <= 0.2:
+			distribution.Usd_5_20++
+		case usd < 0.5:
+			distribution.Usd_20_50++
+		case usd < 2:
+			distribution.Usd_50_200++
+		case usd < 5:
+			distribution.Usd_200_500++
+		case usd < 10:
+			distribution.Usd_500_1000++
+		case usd < 50:
+			distribution.Usd_1000_5000++
+		default:
+			distribution.Usd_5000++
+		}
+	}
+	return &distribution, nil
+}
+
+func (s *MemoryStore) TopArbitrageUsers(window models.Window) ([]TopArbitrageUser, error) {
+	inWindow := s.inWindow(window)
+	arbitrages := s.selectArbitrages(func(arbitrage *models.ArbitrageCH) bool {
+		return inWindow(arbitrage.Time) && arbitrageProfitUsd(arbitrage) < MaxArbitrageUsd
+	})
+	senders, grouped := groupBy(arbitrages, func(arbitrage *models.ArbitrageCH) string { return arbitrage.Sender })
+	var users []TopArbitrageUser
+	for _, sender := range senders {
+		arbitrages := grouped[sender]
+		users = append(users, TopArbitrageUser{
+			Sender:    sender,
+			ProfitUsd: sum(arbitrages, arbitrageProfitUsd),
+			Jettons:   uniq(arbitrages, func(arbitrage *models.ArbitrageCH) []string { return []string{arbitrage.JettonSymbol} }),
+			Number:    uint64(len(arbitrages)),
+		})
+	}
+	return top(users, descending(func(user TopArbitrageUser) float64 { return user.ProfitUsd }), 10), nil
+}
+
+func (s *MemoryStore) TopArbitrageJettons(window models.Window) ([]TopArbitrageJetton, error) {
+	symbols, grouped := groupBy(s.windowArbitrages(window), func(arbitrage *models.ArbitrageCH) string { return arbitrage.JettonSymbol })
+	var jettons []TopArbitrageJetton
+	for _, jettonSymbol := range symbols {
+		arbitrages := grouped[jettonSymbol]
+		if len(arbitrages) <= 1 {
+			continue
+		}
+		jettons = append(jettons, TopArbitrageJetton{
+			Jetton:         arbitrages[0].Jetton,
+			JettonSymbol:   jettonSymbol,
+			JettonName:     arbitrages[0].JettonName,
+			JettonDecimals: arbitrages[0].JettonDecimals,
+			ProfitUsd:      sum(arbitrages, arbitrageProfitUsd),
+			Number:         uint64(len(arbitrages)),
+		})
+	}
+	return top(jettons, descending(func(jetton TopArbitrageJetton) float64 { return jetton.ProfitUsd }), 5), nil
+}
+
+func (s *MemoryStore) windowMevEvents(window models.Window, dex models.Dex) []*models.MevEventCH {
+	inWindow := s.inWindow(window)
+	names := dex.Names()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var events []*models.MevEventCH
+	for _, event := range s.mevEvents {
+		if inWindow(event.Time) && slices.Contains(names, event.Dex) && event.ProfitUsd < MaxArbitrageUsd && event.VictimLossUsd < MaxArbitrageUsd {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func (s *MemoryStore) TopMevBots(window models.Window, dex models.Dex) ([]MevBot, error) {
+	attackers, grouped := groupBy(s.windowMevEvents(window, dex), func(event *models.MevEventCH) string { return event.Attacker })
+	var bots []MevBot
+	for _, attacker := range attackers {
+		events := grouped[attacker]
+		bots = append(bots, MevBot{
+			Attacker:  attacker,
+			ProfitUsd: sum(events, func(event *models.MevEventCH) float64 { return event.ProfitUsd }),
+			Attacks:   uniq(events, func(event *models.MevEventCH) []string { return []string{event.FrontrunTraceID + event.BackrunTraceID} }),
+			Victims:   uniq(events, func(event *models.MevEventCH) []string { return []string{event.Victim} }),
+			Pools:     uniq(events, func(event *models.MevEventCH) []string { return []string{event.PoolAddress} }),
+		})
+	}
+	return top(bots, descending(func(bot MevBot) float64 { return bot.ProfitUsd }), 15), nil
+}
+
+func (s *MemoryStore) TopMevVictims(window models.Window, dex models.Dex) ([]MevVictim, error) {
+	victims, grouped := groupBy(s.windowMevEvents(window, dex), func(event *models.MevEventCH) string { return event.Victim })
+	var result []MevVictim
+	for _, address := range victims {
+		events := grouped[address]
+		result = append(result, MevVictim{
+			Victim:      address,
+			LossUsd:     sum(events, func(event *models.MevEventCH) float64 { return event.VictimLossUsd }),
+			VolumeUsd:   sum(events, func(event *models.MevEventCH) float64 { return event.VictimVolumeUsd }),
+			Attacks:     uint64(len(events)),
+			AvgSlippage: sum(events, func(event *models.MevEventCH) float64 { return event.VictimSlippage }) / float64(len(events)),
+		})
+	}
+	return top(result, descending(func(victim MevVictim) float64 { return victim.LossUsd }), 15), nil
+}
+
+func (s *MemoryStore) TopPoolsTvl(models.Dex, uint64) ([]PoolTvl, error) {
+	return nil, nil
+}
+
+func (s *MemoryStore) PoolTvlHistory(models.Window, string) ([]PoolTvlHistoryEntry, error) {
+	return nil, nil
+}
+
+func (s *MemoryStore) TopLiquidityProviders(models.Window, models.Dex) ([]LiquidityProvider, error) {
+	return nil, nil
+}
+
+func (s *MemoryStore) PoolLiquidityFlow(models.Window, models.Dex) ([]PoolLiquidityFlow, error) {
+	return nil, nil
+}
+
+func (s *MemoryStore) LatestIngestionGaps(limit uint64) ([]models.IngestionGap, error) {
+	s.mutex.RLock()
+	gaps := append([]*models.IngestionGap(nil), s.gaps...)
+	s.mutex.RUnlock()
+	var latest []models.IngestionGap
+	for _, gap := range top(gaps, func(a, b *models.IngestionGap) int { return b.Time.Compare(a.Time) }, int(limit)) {
+		latest = append(latest, *gap)
+	}
+	return latest, nil
+}
@@ -0,0 +1,219 @@
+package persistence
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+	"tondexer/models"
+)
+
+var memoryNow = time.Date(2024, 11, 20, 12, 30, 0, 0, time.UTC)
+
+func ton(amount int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(amount), big.NewInt(1000000000))
+}
+
+// memorySwap sells TON for USDT at 5 usd per TON
+func memorySwap(sender string, minutesAgo int, lt uint64, tons int64, buy bool) *models.SwapCH {
+	swap := &models.SwapCH{
+		Dex:               models.StonfiV2,
+		Lt:                lt,
+		Time:              memoryNow.Add(-time.Duration(minutesAgo) * time.Minute),
+		JettonIn:          "ton",
+		AmountIn:          ton(tons),
+		JettonInSymbol:    "pTON",
+		JettonInUsdRate:   5,
+		JettonInDecimals:  9,
+		JettonOut:         "usdt",
+		AmountOut:         big.NewInt(tons * 5000000),
+		JettonOutSymbol:   "USDT",
+		JettonOutUsdRate:  1,
+		JettonOutDecimals: 6,
+		PoolAddress:       "pool",
+		Sender:            sender,
+		CatchTime:         memoryNow,
+	}
+	if buy {
+		swap.JettonIn, swap.JettonOut = swap.JettonOut, swap.JettonIn
+		swap.AmountIn, swap.AmountOut = swap.AmountOut, swap.AmountIn
+		swap.JettonInSymbol, swap.JettonOutSymbol = swap.JettonOutSymbol, swap.JettonInSymbol
+		swap.JettonInUsdRate, swap.JettonOutUsdRate = swap.JettonOutUsdRate, swap.JettonInUsdRate
+		swap.JettonInDecimals, swap.JettonOutDecimals = swap.JettonOutDecimals, swap.JettonInDecimals
+	}
+	return swap
+}
+
+// singleHopTrade is what pipeline.BuildTrades makes of a swap without other hops
+func singleHopTrade(swap *models.SwapCH) *models.TradeCH {
+	return &models.TradeCH{
+		Dex: swap.Dex, Sender: swap.Sender, Lt: swap.Lt, Time: swap.Time, CatchTime: swap.CatchTime,
+		JettonIn: swap.JettonIn, AmountIn: swap.AmountIn, JettonInSymbol: swap.JettonInSymbol, JettonInUsdRate: swap.JettonInUsdRate, JettonInDecimals: swap.JettonInDecimals,
+		JettonOut: swap.JettonOut, AmountOut: swap.AmountOut, JettonOutSymbol: swap.JettonOutSymbol, JettonOutUsdRate: swap.JettonOutUsdRate, JettonOutDecimals: swap.JettonOutDecimals,
+		PoolsPath: []string{swap.PoolAddress}, JettonsPath: []string{swap.JettonIn, swap.JettonOut},
+	}
+}
+
+func newTestMemoryStore() *MemoryStore {
+	store := NewMemoryStore()
+	store.Now = func() time.Time { return memoryNow }
+	swaps := []*models.SwapCH{
+		memorySwap("alice", 5, 3, 10, true),
+		memorySwap("alice", 65, 2, 4, false),
+		memorySwap("bob", 125, 1, 2, false),
+		memorySwap("bob", 3*24*60, 0, 100, false),
+	}
+	_ = store.SaveSwaps(swaps)
+	for _, swap := range swaps {
+		_ = store.SaveTrades([]*models.TradeCH{singleHopTrade(swap)})
+	}
+	return store
+}
+
+func TestMemoryStoreAggregatesWindow(t *testing.T) {
+	store := newTestMemoryStore()
+	day := models.Window{Period: models.Day}
+
+	summary, e := store.Summary(day, models.Dex("stonfi"))
+	assert.Nil(t, e)
+	assert.Equal(t, &SummaryStats{Volume: 80, Number: 3, UniqueTokens: 2, UniqueUsers: 2}, summary)
+
+	history, e := store.VolumeHistory(day, models.Dex("all"))
+	assert.Nil(t, e)
+	assert.Equal(t, 3, len(history))
+	assert.Equal(t, time.Date(2024, 11, 20, 10, 0, 0, 0, time.UTC), history[0].Period)
+	assert.Equal(t, big.NewInt(10), history[0].StonfiVolumeUsd)
+	assert.Equal(t, uint64(1), history[2].Number)
+
+	users, e := store.TopUsers(day, models.Dex("dedust"))
+	assert.Nil(t, e)
+	assert.Empty(t, users)
+}
+
+func TestMemoryStorePagesLatestSwaps(t *testing.T) {
+	store := newTestMemoryStore()
+	filter := ListingFilter{Dex: models.Dex("all")}
+
+	page, e := store.LatestSwaps(filter, nil, 3)
+	assert.Nil(t, e)
+	assert.Equal(t, []uint64{3, 2, 1}, []uint64{page[0].Lt, page[1].Lt, page[2].Lt})
+	assert.Equal(t, "TON", page[0].JettonOutSymbol)
+
+	page, e = store.LatestSwaps(filter, NextSwapsCursor(page, 3), 3)
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(page))
+	assert.Equal(t, uint64(0), page[0].Lt)
+
+	filter.Senders, filter.MinUsd = []string{"alice"}, 30
+	page, e = store.LatestSwaps(filter, nil, 3)
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(page))
+	assert.Equal(t, uint64(3), page[0].Lt)
+}
+
+func TestMemoryStorePagesSwapsSharingTimeAndLt(t *testing.T) {
+	store := NewMemoryStore()
+	first, second := memorySwap("alice", 5, 7, 10, true), memorySwap("bob", 5, 7, 10, true)
+	second.PoolAddress = "other"
+	_ = store.SaveSwaps([]*models.SwapCH{first, second})
+	filter := ListingFilter{Dex: models.Dex("all")}
+
+	page, e := store.LatestSwaps(filter, nil, 1)
+	assert.Nil(t, e)
+	assert.Equal(t, "pool", page[0].PoolAddress)
+
+	page, e = store.LatestSwaps(filter, NextSwapsCursor(page, 1), 1)
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(page))
+	assert.Equal(t, "other", page[0].PoolAddress)
+}
+
+func TestMemoryStoreUserPortfolio(t *testing.T) {
+	store := newTestMemoryStore()
+	// alice bought 10 TON at 5 usd and sold 4 of them at 5 usd
+	portfolio, e := store.UserPortfolio("alice", []string{"alice", "alice-raw"}, 1, 1)
+	assert.Nil(t, e)
+	assert.Equal(t, uint64(2), portfolio.Swaps)
+	assert.Equal(t, 70.0, portfolio.VolumeUsd)
+	assert.Equal(t, 2, len(portfolio.Jettons))
+	for _, jetton := range portfolio.Jettons {
+		if jetton.Jetton == "ton" {
+			assert.Equal(t, 10.0, jetton.BoughtAmount)
+			assert.Equal(t, 4.0, jetton.SoldAmount)
+		}
+	}
+	assert.InDelta(t, 0, portfolio.RealizedPnlUsd, 1e-9)
+	assert.Equal(t, 1, len(portfolio.History))
+	assert.Equal(t, uint64(2), portfolio.History[0].Lt)
+}
+
+func TestMemoryStoreArbitrages(t *testing.T) {
+	store := newTestMemoryStore()
+	arbitrage := func(sender string, in int64, out int64, traces ...string) *models.ArbitrageCH {
+		return &models.ArbitrageCH{
+			Sender: sender, Time: memoryNow.Add(-time.Minute), AmountIn: ton(in), AmountOut: ton(out),
+			Jetton: "ton", JettonSymbol: "pTON", JettonUsdRate: 5, JettonDecimals: 9,
+			PoolsPath: []string{"pool", "other"}, TraceIDs: traces, Dexes: []string{models.StonfiV2}, Senders: []string{sender, sender},
+			CatchTime: memoryNow,
+		}
+	}
+	_ = store.SaveArbitrages([]*models.ArbitrageCH{
+		arbitrage("alice", 10, 11, "a"),
+		arbitrage("alice", 10, 12, "b"),
+		{Sender: "mixed", Time: memoryNow, AmountIn: ton(1), AmountOut: ton(2), Senders: []string{"x", "y"}},
+	})
+
+	top, e := store.TopArbitrages(models.Window{Period: models.Day})
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(top))
+	assert.Equal(t, 10.0, top[0].AmountOutUSD-top[0].AmountInUSD)
+
+	// the feed polls by the write time, not by the trace time
+	caught, e := store.ArbitragesSince(memoryNow)
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(caught))
+
+	jettons, e := store.TopArbitrageJettons(models.Window{Period: models.Day})
+	assert.Nil(t, e)
+	assert.Equal(t, []TopArbitrageJetton{{Jetton: "ton", JettonSymbol: "pTON", JettonDecimals: 9, ProfitUsd: 15, Number: 2}}, jettons)
+
+	details, e := store.PoolDetails(models.Window{Period: models.Day}, "pool", 10)
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(details.Arbitrages))
+	assert.Equal(t, "ton", details.Info.Jetton0)
+	assert.Equal(t, uint64(2), details.Stats.Jetton0Sells)
+
+	details, e = store.PoolDetails(models.Window{Period: models.Day}, "missing", 10)
+	assert.Nil(t, e)
+	assert.Nil(t, details)
+}
+
+func TestMemoryStoreMevEvents(t *testing.T) {
+	store := newTestMemoryStore()
+	sandwich := func(attacker string, victim string, profitUsd float64, lossUsd float64) *models.MevEventCH {
+		return &models.MevEventCH{
+			Kind: models.MevSandwich, Time: memoryNow.Add(-time.Hour), Dex: models.DeDust, PoolAddress: "pool", Attacker: attacker, Victim: victim,
+			FrontrunTraceID: attacker + "-front", BackrunTraceID: attacker + "-back", ProfitUsd: profitUsd, VictimSlippage: 0.1, VictimLossUsd: lossUsd,
+		}
+	}
+	assert.Nil(t, store.SaveMevEvents([]*models.MevEventCH{
+		sandwich("bot", "alice", 5, 8),
+		sandwich("bot", "bob", 5, 6),
+		sandwich("other", "alice", 1, 2),
+		sandwich("broken", "carol", 2*MaxArbitrageUsd, 1),
+	}))
+	day := models.Window{Period: models.Day}
+
+	bots, e := store.TopMevBots(day, models.Dex("dedust"))
+	assert.Nil(t, e)
+	assert.Equal(t, []MevBot{{Attacker: "bot", ProfitUsd: 10, Attacks: 1, Victims: 2, Pools: 1}, {Attacker: "other", ProfitUsd: 1, Attacks: 1, Victims: 1, Pools: 1}}, bots)
+
+	victims, e := store.TopMevVictims(day, models.Dex("dedust"))
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(victims))
+	assert.Equal(t, MevVictim{Victim: "alice", LossUsd: 10, Attacks: 2, AvgSlippage: 0.1}, victims[0])
+
+	bots, e = store.TopMevBots(day, models.Dex("stonfi"))
+	assert.Nil(t, e)
+	assert.Empty(t, bots)
+}
@@ -0,0 +1,91 @@
+package persistence
+
+import (
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+func WriteMevEventsToClickhouse(config *core.DbConfig, events []*models.MevEventCH) error {
+	return WriteToClickhouse(config, events, "mev_events", AppendMevEvent)
+}
+
+func AppendMevEvent(batch driver.Batch, model *models.MevEventCH) error {
+	return batch.Append(
+		model.Kind,
+		model.Time,
+		model.Dex,
+		model.PoolAddress,
+		model.Attacker,
+		model.Victim,
+		model.FrontrunTraceID,
+		model.VictimTraceID,
+		model.BackrunTraceID,
+		model.FrontrunLt,
+		model.VictimLt,
+		model.BackrunLt,
+		model.JettonIn,
+		model.JettonOut,
+		model.VictimVolumeUsd,
+		model.ProfitUsd,
+		model.VictimSlippage,
+		model.VictimLossUsd,
+		model.CatchTime,
+	)
+}
+
+type MevBot struct {
+	Attacker  string  `json:"attacker" ch:"attacker"`
+	ProfitUsd float64 `json:"profit_usd" ch:"profit_usd"`
+	Attacks   uint64  `json:"attacks" ch:"attacks"`
+	Victims   uint64  `json:"victims" ch:"victims"`
+	Pools     uint64  `json:"pools" ch:"pools"`
+}
+
+type MevVictim struct {
+	Victim      string  `json:"victim" ch:"victim"`
+	LossUsd     float64 `json:"loss_usd" ch:"loss_usd"`
+	VolumeUsd   float64 `json:"volume_usd" ch:"volume_usd"`
+	Attacks     uint64  `json:"attacks" ch:"attacks"`
+	AvgSlippage float64 `json:"avg_slippage" ch:"avg_slippage"`
+}
+
+// Profit and loss above the arbitrage cap are pricing errors the same way they are for arbitrages
+
+func TopMevBotsSqlQuery(config *core.DbConfig, window models.Window, dex models.Dex) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    attacker,
+    sum(profit_usd) AS profit_usd,
+    uniq(frontrun_trace_id, backrun_trace_id) AS attacks,
+    uniq(victim) AS victims,
+    uniq(pool_address) AS pools`).
+		From("mev_events").
+		TimeWindow("time", window).
+		Dex("dex", dex).
+		UsdCap(MaxArbitrageUsd, "profit_usd", "victim_loss_usd").
+		Sql(`
+GROUP BY attacker
+ORDER BY profit_usd DESC`).
+		Limit(15).
+		Build()
+}
+
+func TopMevVictimsSqlQuery(config *core.DbConfig, window models.Window, dex models.Dex) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    victim,
+    sum(victim_loss_usd) AS loss_usd,
+    sum(victim_volume_usd) AS volume_usd,
+    count() AS attacks,
+    avg(victim_slippage) AS avg_slippage`).
+		From("mev_events").
+		TimeWindow("time", window).
+		Dex("dex", dex).
+		UsdCap(MaxArbitrageUsd, "profit_usd", "victim_loss_usd").
+		Sql(`
+GROUP BY victim
+ORDER BY loss_usd DESC`).
+		Limit(15).
+		Build()
+}
@@ -1,8 +1,9 @@
 package persistence
 
 import (
-	"fmt"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"math/big"
+	"time"
 	"tondexer/core"
 	"tondexer/models"
 )
@@ -25,9 +26,8 @@ type PoolVolume struct {
 	Dex               string   `json:"dex" ch:"pool_dex"`
 }
 
-func TopPoolsRequest(config *core.DbConfig, period models.Period, dex models.Dex) string {
-	periodParams := models.PeriodParamsMap[period]
-	return fmt.Sprint(`
+func TopPoolsRequest(config *core.DbConfig, window models.Window, dex models.Dex) Query {
+	return NewQuery(config).Sql(`
 SELECT
     pool_address,
     anyHeavy(jetton_in) AS jetton_in,
@@ -43,13 +43,270 @@ SELECT
     anyHeavy(`, Symbol("jetton_out_symbol"), `) AS jetton_out_symbol,
     anyHeavy(jetton_out_decimals) AS out_jetton_decimals,
     (amount_in_usd + amount_out_usd) / 2 AS amount_usd,
-	anyHeavy(dex) as pool_dex
-FROM `, config.DbName, `.swaps
-WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
-AND `, dex.WhereStatement("dex"), `
-AND `, UsdInField, ` < 1000000 AND `, UsdOutField, ` < 1000000
+	anyHeavy(dex) as pool_dex`).
+		From("swaps").
+		TimeWindow("time", window).
+		Dex("dex", dex).
+		UsdCap(MaxSwapUsd, UsdInField, UsdOutField).
+		Sql(`
 GROUP BY pool_address
-ORDER BY amount_usd DESC
-LIMIT 15
-`)
+ORDER BY amount_usd DESC`).
+		Limit(15).
+		Build()
+}
+
+type KnownPool struct {
+	PoolAddress string `ch:"pool_address"`
+	Dex         string `ch:"pool_dex"`
+}
+
+type PoolTvl struct {
+	Time          time.Time `json:"time" ch:"time"`
+	PoolAddress   string    `json:"pool_address" ch:"pool_address"`
+	Dex           string    `json:"dex" ch:"pool_dex"`
+	Jetton0       string    `json:"jetton0" ch:"jetton0"`
+	Jetton0Symbol string    `json:"jetton0_symbol" ch:"jetton0_symbol"`
+	Reserve0      float64   `json:"reserve0" ch:"reserve0"`
+	Jetton1       string    `json:"jetton1" ch:"jetton1"`
+	Jetton1Symbol string    `json:"jetton1_symbol" ch:"jetton1_symbol"`
+	Reserve1      float64   `json:"reserve1" ch:"reserve1"`
+	LpSupply      *big.Int  `json:"lp_supply" ch:"lp_supply"`
+	TvlUsd        float64   `json:"tvl_usd" ch:"tvl_usd"`
+}
+
+type PoolTvlHistoryEntry struct {
+	Time   time.Time `json:"time" ch:"period"`
+	TvlUsd float64   `json:"tvl_usd" ch:"tvl_usd"`
+}
+
+// KnownPoolsSqlQuery lists pools seen in swaps of the dexes since the time, pools without recent swaps keep their last snapshot
+func KnownPoolsSqlQuery(config *core.DbConfig, dexes []string, since time.Time) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    pool_address,
+    anyHeavy(dex) AS pool_dex`).
+		From("swaps").
+		Where("has(?, dex)", dexes).
+		Where("time >= ?", since).
+		Sql(`
+GROUP BY pool_address
+`).
+		Build()
+}
+
+func WritePoolSnapshotsToClickhouse(config *core.DbConfig, snapshots []*models.PoolSnapshotCH) error {
+	return WriteToClickhouse(config, snapshots, "pool_snapshots", func(batch driver.Batch, model *models.PoolSnapshotCH) error {
+		return batch.Append(
+			model.Time,
+			model.Dex,
+			model.PoolAddress,
+			model.Jetton0,
+			model.Reserve0,
+			model.Jetton0Symbol,
+			model.Jetton0Name,
+			model.Jetton0UsdRate,
+			model.Jetton0Decimals,
+			model.Jetton1,
+			model.Reserve1,
+			model.Jetton1Symbol,
+			model.Jetton1Name,
+			model.Jetton1UsdRate,
+			model.Jetton1Decimals,
+			model.LpSupply,
+			model.TvlUsd,
+		)
+	})
+}
+
+// TopPoolsTvlSqlQuery ranks pools by TVL of their latest snapshot
+func TopPoolsTvlSqlQuery(config *core.DbConfig, dex models.Dex, limit uint64) Query {
+	if limit == 0 || limit > 100 {
+		limit = 15
+	}
+	return NewQuery(config).Sql(`
+SELECT
+    max(time) AS time,
+    pool_address,
+    argMax(dex, time) AS pool_dex,
+    argMax(jetton0, time) AS jetton0,
+    argMax(`, Symbol("jetton0_symbol"), `, time) AS jetton0_symbol,
+    argMax(reserve0 / pow(10, jetton0_decimals), time) AS reserve0,
+    argMax(jetton1, time) AS jetton1,
+    argMax(`, Symbol("jetton1_symbol"), `, time) AS jetton1_symbol,
+    argMax(reserve1 / pow(10, jetton1_decimals), time) AS reserve1,
+    argMax(lp_supply, time) AS lp_supply,
+    argMax(tvl_usd, time) AS tvl_usd`).
+		From("pool_snapshots").
+		Where("time >= subtractDays(now(), 2)").
+		Dex("dex", dex).
+		Sql(`
+GROUP BY pool_address
+ORDER BY tvl_usd DESC`).
+		Limit(limit).
+		Build()
+}
+
+func PoolTvlHistorySqlQuery(config *core.DbConfig, window models.Window, pool string) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    `, window.ToStartOf(), `(time) AS period,
+    argMax(tvl_usd, time) AS tvl_usd`).
+		From("pool_snapshots").
+		TimeWindow("time", window).
+		Where("pool_address = ?", pool).
+		Sql(`
+GROUP BY period
+ORDER BY period ASC
+`).
+		Build()
+}
+
+// PoolInfo orders jettons of the pool by address, swaps go in both directions
+type PoolInfo struct {
+	PoolAddress     string    `json:"pool_address" ch:"pool_address"`
+	Dex             string    `json:"dex" ch:"pool_dex"`
+	Jetton0         string    `json:"jetton0" ch:"jetton0"`
+	Jetton0Symbol   string    `json:"jetton0_symbol" ch:"jetton0_symbol"`
+	Jetton0Name     string    `json:"jetton0_name" ch:"jetton0_name"`
+	Jetton0Decimals uint64    `json:"jetton0_decimals" ch:"jetton0_decimals"`
+	Jetton1         string    `json:"jetton1" ch:"jetton1"`
+	Jetton1Symbol   string    `json:"jetton1_symbol" ch:"jetton1_symbol"`
+	Jetton1Name     string    `json:"jetton1_name" ch:"jetton1_name"`
+	Jetton1Decimals uint64    `json:"jetton1_decimals" ch:"jetton1_decimals"`
+	FirstSwap       time.Time `json:"first_swap" ch:"first_swap"`
+	LastSwap        time.Time `json:"last_swap" ch:"last_swap"`
+}
+
+// PoolStats splits swaps by the sold jetton, selling jetton0 is buying jetton1
+type PoolStats struct {
+	VolumeUsd      float64 `json:"volume_usd" ch:"volume_usd"`
+	Swaps          uint64  `json:"swaps" ch:"swaps"`
+	Traders        uint64  `json:"traders" ch:"traders"`
+	Jetton0Sells   uint64  `json:"jetton0_sells" ch:"jetton0_sells"`
+	Jetton0SellUsd float64 `json:"jetton0_sell_usd" ch:"jetton0_sell_usd"`
+	Jetton1Sells   uint64  `json:"jetton1_sells" ch:"jetton1_sells"`
+	Jetton1SellUsd float64 `json:"jetton1_sell_usd" ch:"jetton1_sell_usd"`
+}
+
+type PoolVolumeHistoryEntry struct {
+	Period    time.Time `json:"period" ch:"period"`
+	VolumeUsd float64   `json:"volume_usd" ch:"volume_usd"`
+	Number    uint64    `json:"number" ch:"number"`
+}
+
+type PoolDetails struct {
+	Info          *PoolInfo                `json:"info"`
+	Stats         *PoolStats               `json:"stats"`
+	VolumeHistory []PoolVolumeHistoryEntry `json:"volume_history"`
+	Swaps         []EnrichedSwapCH         `json:"swaps"`
+	Arbitrages    []EnrichedArbitrageCH    `json:"arbitrages"`
+}
+
+func PoolInfoSqlQuery(config *core.DbConfig, pool string) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    pool_address,
+    anyHeavy(dex) AS pool_dex,
+    any(least(jetton_in, jetton_out)) AS jetton0,
+    anyHeavy(if(jetton_in < jetton_out, `, Symbol("jetton_in_symbol"), `, `, Symbol("jetton_out_symbol"), `)) AS jetton0_symbol,
+    anyHeavy(if(jetton_in < jetton_out, jetton_in_name, jetton_out_name)) AS jetton0_name,
+    anyHeavy(if(jetton_in < jetton_out, jetton_in_decimals, jetton_out_decimals)) AS jetton0_decimals,
+    any(greatest(jetton_in, jetton_out)) AS jetton1,
+    anyHeavy(if(jetton_in < jetton_out, `, Symbol("jetton_out_symbol"), `, `, Symbol("jetton_in_symbol"), `)) AS jetton1_symbol,
+    anyHeavy(if(jetton_in < jetton_out, jetton_out_name, jetton_in_name)) AS jetton1_name,
+    anyHeavy(if(jetton_in < jetton_out, jetton_out_decimals, jetton_in_decimals)) AS jetton1_decimals,
+    min(time) AS first_swap,
+    max(time) AS last_swap`).
+		From("swaps").
+		Where("pool_address = ?", pool).
+		Sql(`
+GROUP BY pool_address`).
+		Build()
+}
+
+func PoolStatsSqlQuery(config *core.DbConfig, window models.Window, pool string) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    sum((`, UsdInField, ` + `, UsdOutField, `) / 2) AS volume_usd,
+    count() AS swaps,
+    uniqExact(sender) AS traders,
+    countIf(jetton_in < jetton_out) AS jetton0_sells,
+    sumIf(`, UsdInField, `, jetton_in < jetton_out) AS jetton0_sell_usd,
+    countIf(jetton_in > jetton_out) AS jetton1_sells,
+    sumIf(`, UsdInField, `, jetton_in > jetton_out) AS jetton1_sell_usd`).
+		From("swaps").
+		TimeWindow("time", window).
+		Where("pool_address = ?", pool).
+		UsdCap(MaxSwapUsd, UsdInField, UsdOutField).
+		Build()
+}
+
+func PoolVolumeHistorySqlQuery(config *core.DbConfig, window models.Window, pool string) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    `, window.ToStartOf(), `(time) AS period,
+    sum((`, UsdInField, ` + `, UsdOutField, `) / 2) AS volume_usd,
+    count() AS number`).
+		From("swaps").
+		TimeWindow("time", window).
+		Where("pool_address = ?", pool).
+		UsdCap(MaxSwapUsd, UsdInField, UsdOutField).
+		Sql(`
+GROUP BY period`).
+		OrderByBuckets(window).
+		Build()
+}
+
+func PoolLatestSwapsSqlQuery(config *core.DbConfig, pool string, limit uint64) Query {
+	return NewQuery(config).Sql(enrichedSwapSelect).
+		From("swaps").
+		Where("pool_address = ?", pool).
+		Sql(`
+ORDER BY time DESC`).
+		Limit(limit).
+		Build()
+}
+
+func PoolArbitragesSqlQuery(config *core.DbConfig, window models.Window, pool string, limit uint64) Query {
+	return NewQuery(config).Sql(arbitrageSelectFields()).
+		From("arbitrages").
+		TimeWindow("time", window).
+		Where("has(pools_path, ?)", pool).
+		Where(singleSenderCondition).
+		UsdCap(MaxArbitrageUsd, "amount_out_usd - amount_in_usd").
+		Sql(`
+ORDER BY time DESC`).
+		Limit(limit).
+		Build()
+}
+
+// ReadPoolDetails returns nil when the pool has no swaps
+func ReadPoolDetails(config *core.DbConfig, window models.Window, pool string, limit uint64) (*PoolDetails, error) {
+	info, e := ReadArrayFromClickhouse[PoolInfo](config, PoolInfoSqlQuery(config, pool))
+	if e != nil || len(info) == 0 {
+		return nil, e
+	}
+	stats, e := ReadSingleRow[PoolStats](config, PoolStatsSqlQuery(config, window, pool))
+	if e != nil {
+		return nil, e
+	}
+	history, e := ReadArrayFromClickhouse[PoolVolumeHistoryEntry](config, PoolVolumeHistorySqlQuery(config, window, pool))
+	if e != nil {
+		return nil, e
+	}
+	swaps, e := ReadArrayFromClickhouse[EnrichedSwapCH](config, PoolLatestSwapsSqlQuery(config, pool, limit))
+	if e != nil {
+		return nil, e
+	}
+	arbitrages, e := ReadArrayFromClickhouse[EnrichedArbitrageCH](config, PoolArbitragesSqlQuery(config, window, pool, limit))
+	if e != nil {
+		return nil, e
+	}
+	return &PoolDetails{
+		Info:          &info[0],
+		Stats:         stats,
+		VolumeHistory: history,
+		Swaps:         swaps,
+		Arbitrages:    arbitrages,
+	}, nil
 }
@@ -0,0 +1,111 @@
+package persistence
+
+import (
+	"fmt"
+	"strings"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+// Sanity caps, rows above them are pricing errors rather than real trades
+const (
+	MaxSwapUsd      = 1000000
+	MaxArbitrageUsd = 10000
+	MaxLiquidityUsd = 10000000
+)
+
+// Query is sql with ? placeholders and the values the driver binds to them
+type Query struct {
+	Sql  string
+	Args []any
+}
+
+// QueryBuilder separates trusted sql written in this package from values which must be bound
+type QueryBuilder struct {
+	config     *core.DbConfig
+	sql        strings.Builder
+	args       []any
+	conditions int
+}
+
+func NewQuery(config *core.DbConfig) *QueryBuilder {
+	return &QueryBuilder{config: config}
+}
+
+// Sql appends trusted fragments like field expressions, values from requests must never be passed here
+func (q *QueryBuilder) Sql(parts ...any) *QueryBuilder {
+	q.sql.WriteString(fmt.Sprint(parts...))
+	return q
+}
+
+// Bind appends a fragment where every ? is replaced by the next argument
+func (q *QueryBuilder) Bind(sql string, args ...any) *QueryBuilder {
+	if strings.Count(sql, "?") != len(args) {
+		panic(fmt.Sprintf("%v placeholders for %v arguments in %v", strings.Count(sql, "?"), len(args), sql))
+	}
+	q.sql.WriteString(sql)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// Table appends the table name qualified by the configured database
+func (q *QueryBuilder) Table(table string) *QueryBuilder {
+	return q.Sql(q.config.DbName, ".", table)
+}
+
+func (q *QueryBuilder) From(table string) *QueryBuilder {
+	return q.Sql("\nFROM ").Table(table)
+}
+
+// Where joins conditions of a single WHERE clause with AND
+func (q *QueryBuilder) Where(condition string, args ...any) *QueryBuilder {
+	if q.conditions == 0 {
+		q.Sql("\nWHERE ")
+	} else {
+		q.Sql("\nAND ")
+	}
+	q.conditions++
+	return q.Bind(condition, args...)
+}
+
+// TimeWindow keeps rows since the start of the first bucket of the period or within the explicit range
+func (q *QueryBuilder) TimeWindow(column string, window models.Window) *QueryBuilder {
+	if window.Period != "" {
+		periodParams := models.PeriodParamsMap[window.Period]
+		return q.Where(fmt.Sprint(column, " >= ", periodParams.ToStartOf, "(subtractDays(now(), ?))"), periodParams.WindowInDays)
+	}
+	return q.Where(fmt.Sprint(column, " >= ? AND ", column, " < ?"), window.From, window.To)
+}
+
+// OrderByBuckets orders a history by the period column and fills empty buckets
+func (q *QueryBuilder) OrderByBuckets(window models.Window) *QueryBuilder {
+	q.Sql("\nORDER BY period ASC WITH FILL")
+	if window.Period == "" {
+		q.Bind(fmt.Sprint(" FROM ", window.ToStartOf(), "(?) TO ?"), window.From, window.To)
+	}
+	return q.Sql(" STEP ", window.Step())
+}
+
+func (q *QueryBuilder) Dex(column string, dex models.Dex) *QueryBuilder {
+	return q.Where(fmt.Sprint("has(?, ", column, ")"), dex.Names())
+}
+
+// UsdCap drops rows where any of the expressions reaches the cap
+func (q *QueryBuilder) UsdCap(cap float64, expressions ...string) *QueryBuilder {
+	for _, expression := range expressions {
+		q.Where(expression+" < ?", cap)
+	}
+	return q
+}
+
+func (q *QueryBuilder) Limit(limit uint64) *QueryBuilder {
+	return q.Bind("\nLIMIT ?", limit)
+}
+
+func (q *QueryBuilder) Offset(offset uint64) *QueryBuilder {
+	return q.Bind(" OFFSET ?", offset)
+}
+
+func (q *QueryBuilder) Build() Query {
+	return Query{Sql: q.sql.String(), Args: q.args}
+}
@@ -0,0 +1,134 @@
+package persistence
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+func TestQueryBuilderBindsFilters(t *testing.T) {
+	config := &core.DbConfig{DbName: "tondexer"}
+	query := NewQuery(config).Sql("SELECT count() AS count").
+		From("swaps").
+		TimeWindow("time", models.Window{Period: models.Week}).
+		Dex("dex", models.Dex("stonfi")).
+		Where("sender = ?", "EQ' OR 1=1 --").
+		UsdCap(MaxSwapUsd, UsdInField).
+		Limit(15).
+		Build()
+
+	assert.Equal(t, `SELECT count() AS count
+FROM tondexer.swaps
+WHERE time >= toStartOfDay(subtractDays(now(), ?))
+AND has(?, dex)
+AND sender = ?
+AND `+UsdInField+` < ?
+LIMIT ?`, query.Sql)
+	assert.Equal(t, []any{uint64(7), []string{models.StonfiV1, models.StonfiV2}, "EQ' OR 1=1 --", float64(MaxSwapUsd), uint64(15)}, query.Args)
+}
+
+func TestQueryBuilderRejectsUnboundPlaceholders(t *testing.T) {
+	assert.Panics(t, func() {
+		NewQuery(&core.DbConfig{}).Where("sender = ? AND pool_address = ?", "sender")
+	})
+}
+
+func TestEndpointQueriesBindAllPlaceholders(t *testing.T) {
+	config := &core.DbConfig{DbName: "tondexer"}
+	dex := models.Dex("all")
+	day := models.Window{Period: models.Day}
+	wallet := []string{"EQ", "UQ", "0:00"}
+	listing := ListingFilter{Senders: wallet, Jetton: "jetton", Pool: "pool", Dex: dex, MinUsd: 1, MaxUsd: 10, From: time.Unix(0, 0), To: time.Now()}
+	cursor := &Cursor{Time: time.Now(), Key: 42}
+	candles, e := CandlesSqlQuery(config, "", "a", "b", "1h", time.Now().Add(-24*time.Hour), time.Now())
+	assert.Nil(t, e)
+
+	queries := []Query{
+		SwapsSummarySql(config, day, dex),
+		VolumeHistorySqlQuery(config, day, dex),
+		TopSwapsSqlQuery(config, day, dex, 15),
+		LatestSwapsSqlQuery(config, ListingFilter{Dex: dex}, nil, 10),
+		LatestSwapsSqlQuery(config, listing, cursor, 10),
+		SwapsDistributionSqlQuery(config, day, dex),
+		TopPoolsRequest(config, day, dex),
+		TopJettonRequest(config, day, dex),
+		TopUsersRequest(config, day, dex),
+		TopReferrersRequest(config, day, dex),
+		TopUsersProfiters(config, day),
+		TopArbitragesSqlQuery(config, day),
+		LatestArbitragesSqlQuery(config, ListingFilter{Dex: dex}, nil, 10),
+		LatestArbitragesSqlQuery(config, listing, cursor, 10),
+		SwapsCaughtSinceSqlQuery(config, time.Now()),
+		ArbitragesSinceSqlQuery(config, time.Now()),
+		BackfillCheckpointsSqlQuery(config, "job"),
+		DeleteCandlesSqlQuery(config, CandleBuckets{Pools: []string{"pool"}, Minutes: []int64{60}}),
+		RebuildCandlesSqlQuery(config, CandleBuckets{Pools: []string{"pool"}, Minutes: []int64{60}}),
+		ArbitrageHistorySqlQuery(config, day),
+		ArbitrageDistributionSqlQuery(config, day),
+		TopArbitrageUsersSql(config, day),
+		TopArbitrageJettonsSql(config, day),
+		TopLiquidityProvidersSql(config, day, dex),
+		PoolLiquidityFlowSql(config, day, dex),
+		TopPoolsTvlSqlQuery(config, dex, 10),
+		PoolTvlHistorySqlQuery(config, day, "pool"),
+		LatestIngestionGapsSqlQuery(config, 10),
+		KnownPoolsSqlQuery(config, []string{models.StonfiV1, models.DeDust}, time.Now()),
+		candles,
+		UserSwapsSqlQuery(config, wallet, 50, 100),
+		UserDexVolumesSqlQuery(config, wallet),
+		UserJettonsSqlQuery(config, wallet),
+		UserArbitragesSqlQuery(config, wallet),
+		UserReferralsSqlQuery(config, wallet),
+		JettonStatsSqlQuery(config, day, dex, "master"),
+		JettonPriceHistorySqlQuery(config, day, "master"),
+		JettonVolumeHistorySqlQuery(config, day, dex, "master"),
+		JettonTopPoolsSqlQuery(config, day, dex, "master"),
+		JettonTopTradersSqlQuery(config, day, dex, "master"),
+		JettonDexSplitSqlQuery(config, day, dex, "master"),
+		PoolInfoSqlQuery(config, "pool"),
+		PoolStatsSqlQuery(config, day, "pool"),
+		PoolVolumeHistorySqlQuery(config, day, "pool"),
+		PoolLatestSwapsSqlQuery(config, "pool", 20),
+		PoolArbitragesSqlQuery(config, day, "pool", 20),
+		VolumeHistorySqlQuery(config, models.Window{From: time.Unix(0, 0), To: time.Now(), Interval: models.OneDay}, dex),
+		TraceSwapsSqlQuery(config, []string{"trace"}),
+		DeleteTraceSwapsSqlQuery(config, []string{"trace"}),
+		DeleteTraceTradesSqlQuery(config, []string{"trace"}),
+		TopMevBotsSqlQuery(config, day, dex),
+		TopMevVictimsSqlQuery(config, day, dex),
+	}
+	for _, query := range queries {
+		assert.Equal(t, strings.Count(query.Sql, "?"), len(query.Args), query.Sql)
+		assert.NotContains(t, query.Sql, "%!")
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := &Cursor{Time: time.Unix(1700000000, 0), Key: 51234567000001, Address: "EQB3ncyBUTjZUA5EnFKR5_EnOMI9V1tTEAAPaiU71gc4TiUt"}
+	parsed, e := ParseCursor(cursor.String())
+	assert.Nil(t, e)
+	assert.Equal(t, cursor, parsed)
+
+	parsed, e = ParseCursor("")
+	assert.Nil(t, e)
+	assert.Nil(t, parsed)
+
+	_, e = ParseCursor("not a cursor")
+	assert.NotNil(t, e)
+}
+
+func TestListingPaging(t *testing.T) {
+	assert.Equal(t, uint64(DefaultPageSize), PageSize(0))
+	assert.Equal(t, uint64(MaxPageSize), PageSize(100000))
+
+	swaps := []EnrichedSwapCH{{Time: time.Unix(10, 0), Lt: 2, PoolAddress: "b"}, {Time: time.Unix(5, 0), Lt: 1, PoolAddress: "a"}}
+	assert.Equal(t, &Cursor{Time: time.Unix(5, 0), Key: 1, Address: "a"}, NextSwapsCursor(swaps, 2))
+	assert.Nil(t, NextSwapsCursor(swaps, 3))
+
+	query := LatestSwapsSqlQuery(&core.DbConfig{DbName: "tondexer"}, ListingFilter{Dex: models.Dex("all")}, &Cursor{Time: time.Unix(5, 0), Key: 1, Address: "a"}, 2)
+	assert.Contains(t, query.Sql, "(time, lt, pool_address) < (?, ?, ?)")
+	assert.True(t, strings.HasSuffix(query.Sql, "ORDER BY time DESC, lt DESC, pool_address DESC\nLIMIT ?"))
+}
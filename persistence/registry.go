@@ -0,0 +1,32 @@
+package persistence
+
+import (
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+func WriteDexAccounts(config *core.DbConfig, accounts []*models.DexAccount) error {
+	return WriteToClickhouse(config, accounts, "dex_accounts", func(batch driver.Batch, model *models.DexAccount) error {
+		return batch.Append(
+			model.Dex,
+			model.Address,
+			model.Source,
+			model.Time,
+		)
+	})
+}
+
+func DexAccountsSqlQuery(config *core.DbConfig) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    argMin(dex, time) AS dex,
+    address,
+    argMin(source, time) AS source,
+    min(time) AS time`).
+		From("dex_accounts").
+		Sql(`
+GROUP BY address
+`).
+		Build()
+}
@@ -0,0 +1,77 @@
+package persistence
+
+import (
+	"tondexer/core"
+	"tondexer/models"
+)
+
+const swapColumns = `
+SELECT
+	dex,
+	hashes,
+	lt,
+	time,
+	jetton_in,
+	amount_in,
+	jetton_in_symbol,
+	jetton_in_name,
+	jetton_in_usd_rate,
+	jetton_in_decimals,
+	jetton_out,
+	amount_out,
+	jetton_out_symbol,
+	jetton_out_name,
+	jetton_out_usd_rate,
+	jetton_out_decimals,
+	min_amount_out,
+	pool_address,
+	sender,
+	referral_address,
+	referral_amount,
+	catch_time,
+	trace_id,
+	jetton_in_price_source,
+	jetton_out_price_source`
+
+// TraceSwapsSqlQuery selects stored rows of the traces as they were written
+func TraceSwapsSqlQuery(config *core.DbConfig, traceIDs []string) Query {
+	return NewQuery(config).Sql(swapColumns).
+		From("swaps").
+		Where("has(?, trace_id)", traceIDs).
+		Sql(`
+ORDER BY trace_id, lt`).
+		Build()
+}
+
+// DeleteTraceSwapsSqlQuery waits for the mutation, rows of the traces are written again right after it
+func DeleteTraceSwapsSqlQuery(config *core.DbConfig, traceIDs []string) Query {
+	return NewQuery(config).Sql("ALTER TABLE ").Table("swaps").Sql(" DELETE").
+		Where("has(?, trace_id)", traceIDs).
+		Sql(`
+SETTINGS mutations_sync = 1`).
+		Build()
+}
+
+func ReadTraceSwaps(config *core.DbConfig, traceIDs []string) ([]*models.SwapCH, error) {
+	swaps, e := ReadArrayFromClickhouse[models.SwapCH](config, TraceSwapsSqlQuery(config, traceIDs))
+	if e != nil {
+		return nil, e
+	}
+	result := make([]*models.SwapCH, len(swaps))
+	for i := range swaps {
+		result[i] = &swaps[i]
+	}
+	return result, nil
+}
+
+// ReplaceTraceSwaps deletes stored rows of the traces and writes the given ones instead,
+// it isn't atomic so the caller keeps the rows until it succeeds
+func ReplaceTraceSwaps(config *core.DbConfig, traceIDs []string, swaps []*models.SwapCH) error {
+	if e := ExecQuery(config, DeleteTraceSwapsSqlQuery(config, traceIDs)); e != nil {
+		return e
+	}
+	if len(swaps) == 0 {
+		return nil
+	}
+	return SaveSwapsToClickhouse(config, swaps)
+}
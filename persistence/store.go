@@ -0,0 +1,278 @@
+package persistence
+
+import (
+	"fmt"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"time"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+// Store is the storage of the listener and the web api.
+// ClickhouseStore is the production one, MemoryStore runs without a database in tests and on a laptop
+type Store interface {
+	SaveSwaps(swaps []*models.SwapCH) error
+	SaveArbitrages(arbitrages []*models.ArbitrageCH) error
+	SaveTrades(trades []*models.TradeCH) error
+	SaveMevEvents(events []*models.MevEventCH) error
+	SaveJettons(jettons []*models.ChainTokenInfo) error
+	SaveWalletMasters(wallets []*models.WalletJetton) error
+	SaveRates(rates []*models.JettonRate) error
+	SaveIngestionGaps(gaps []*models.IngestionGap) error
+	SaveDexAccounts(accounts []*models.DexAccount) error
+	Jettons() ([]models.ClickhouseJetton, error)
+	WalletMasters() ([]models.WalletJetton, error)
+	DexAccounts() ([]models.DexAccount, error)
+
+	Summary(window models.Window, dex models.Dex) (*SummaryStats, error)
+	VolumeHistory(window models.Window, dex models.Dex) ([]VolumeHistoryEntry, error)
+	SwapsDistribution(window models.Window, dex models.Dex) (*SwapDistribution, error)
+	TopSwaps(window models.Window, dex models.Dex, limit uint64) ([]EnrichedSwapCH, error)
+	LatestSwaps(filter ListingFilter, cursor *Cursor, limit uint64) ([]EnrichedSwapCH, error)
+	SwapsCaughtSince(since time.Time) ([]EnrichedSwapCH, error)
+	TopPools(window models.Window, dex models.Dex) ([]PoolVolume, error)
+	TopJettons(window models.Window, dex models.Dex) ([]JettonVolume, error)
+	TopUsers(window models.Window, dex models.Dex) ([]UserVolume, error)
+	TopReferrers(window models.Window, dex models.Dex) ([]UserVolume, error)
+	TopProfiters(window models.Window) ([]UserVolume, error)
+	UserPortfolio(address string, addresses []string, limit uint64, offset uint64) (*UserPortfolio, error)
+	JettonDetails(window models.Window, dex models.Dex, master string) (*JettonDetails, error)
+	// PoolDetails returns nil when the pool has no swaps
+	PoolDetails(window models.Window, pool string, limit uint64) (*PoolDetails, error)
+	Candles(pool string, jetton0 string, jetton1 string, interval string, from time.Time, to time.Time) ([]Candle, error)
+
+	LatestArbitrages(filter ListingFilter, cursor *Cursor, limit uint64) ([]EnrichedArbitrageCH, error)
+	ArbitragesSince(since time.Time) ([]EnrichedArbitrageCH, error)
+	TopArbitrages(window models.Window) ([]EnrichedArbitrageCH, error)
+	ArbitrageHistory(window models.Window) ([]ArbitrageHistoryEntry, error)
+	ArbitrageDistribution(window models.Window) (*ArbitrageDistribution, error)
+	TopArbitrageUsers(window models.Window) ([]TopArbitrageUser, error)
+	TopArbitrageJettons(window models.Window) ([]TopArbitrageJetton, error)
+
+	TopMevBots(window models.Window, dex models.Dex) ([]MevBot, error)
+	TopMevVictims(window models.Window, dex models.Dex) ([]MevVictim, error)
+
+	TopPoolsTvl(dex models.Dex, limit uint64) ([]PoolTvl, error)
+	PoolTvlHistory(window models.Window, pool string) ([]PoolTvlHistoryEntry, error)
+	TopLiquidityProviders(window models.Window, dex models.Dex) ([]LiquidityProvider, error)
+	PoolLiquidityFlow(window models.Window, dex models.Dex) ([]PoolLiquidityFlow, error)
+	LatestIngestionGaps(limit uint64) ([]models.IngestionGap, error)
+}
+
+var _ Store = (*ClickhouseStore)(nil)
+var _ Store = (*MemoryStore)(nil)
+
+// Kinds of the store config option of the listener and the web server
+const (
+	StoreClickhouse = "clickhouse"
+	StoreMemory     = "memory"
+)
+
+// OpenStore returns the store of the kind, checking the ClickHouse schema is up to the caller
+func OpenStore(kind string, config *core.DbConfig) (Store, error) {
+	switch kind {
+	case StoreClickhouse:
+		return NewClickhouseStore(config), nil
+	case StoreMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown store %v", kind)
+	}
+}
+
+type ClickhouseStore struct {
+	config *core.DbConfig
+}
+
+func NewClickhouseStore(config *core.DbConfig) *ClickhouseStore {
+	return &ClickhouseStore{config: config}
+}
+
+func (s *ClickhouseStore) SaveSwaps(swaps []*models.SwapCH) error {
+	return SaveSwapsToClickhouse(s.config, swaps)
+}
+
+func (s *ClickhouseStore) SaveArbitrages(arbitrages []*models.ArbitrageCH) error {
+	return WriteArbitragesToClickhouse(s.config, arbitrages)
+}
+
+func (s *ClickhouseStore) SaveTrades(trades []*models.TradeCH) error {
+	return WriteTradesToClickhouse(s.config, trades)
+}
+
+func (s *ClickhouseStore) SaveMevEvents(events []*models.MevEventCH) error {
+	return WriteMevEventsToClickhouse(s.config, events)
+}
+
+func (s *ClickhouseStore) SaveJettons(jettons []*models.ChainTokenInfo) error {
+	return WriteToClickhouse(s.config, jettons, "clickhouse_jetton", func(batch driver.Batch, model *models.ChainTokenInfo) error {
+		return batch.Append(
+			model.Name,
+			model.Symbol,
+			model.JettonAddress,
+			model.Decimals,
+		)
+	})
+}
+
+func (s *ClickhouseStore) SaveWalletMasters(wallets []*models.WalletJetton) error {
+	return WriteToClickhouse(s.config, wallets, "wallet_to_master", func(batch driver.Batch, model *models.WalletJetton) error {
+		return batch.Append(
+			model.Wallet,
+			model.Master,
+		)
+	})
+}
+
+func (s *ClickhouseStore) SaveRates(rates []*models.JettonRate) error {
+	return WriteToClickhouse(s.config, rates, "jetton_rates", func(batch driver.Batch, model *models.JettonRate) error {
+		return batch.Append(
+			model.Time,
+			model.Name,
+			model.Symbol,
+			model.Master,
+			model.Decimals,
+			model.Rate,
+		)
+	})
+}
+
+func (s *ClickhouseStore) SaveIngestionGaps(gaps []*models.IngestionGap) error {
+	return WriteIngestionGaps(s.config, gaps)
+}
+
+func (s *ClickhouseStore) SaveDexAccounts(accounts []*models.DexAccount) error {
+	return WriteDexAccounts(s.config, accounts)
+}
+
+func (s *ClickhouseStore) Jettons() ([]models.ClickhouseJetton, error) {
+	return ReadClickhouseJettons(s.config)
+}
+
+func (s *ClickhouseStore) WalletMasters() ([]models.WalletJetton, error) {
+	return ReadWalletMasters(s.config)
+}
+
+func (s *ClickhouseStore) DexAccounts() ([]models.DexAccount, error) {
+	return ReadArrayFromClickhouse[models.DexAccount](s.config, DexAccountsSqlQuery(s.config))
+}
+
+func (s *ClickhouseStore) Summary(window models.Window, dex models.Dex) (*SummaryStats, error) {
+	return ReadSingleRow[SummaryStats](s.config, SwapsSummarySql(s.config, window, dex))
+}
+
+func (s *ClickhouseStore) VolumeHistory(window models.Window, dex models.Dex) ([]VolumeHistoryEntry, error) {
+	return ReadArrayFromClickhouse[VolumeHistoryEntry](s.config, VolumeHistorySqlQuery(s.config, window, dex))
+}
+
+func (s *ClickhouseStore) SwapsDistribution(window models.Window, dex models.Dex) (*SwapDistribution, error) {
+	return ReadSingleRow[SwapDistribution](s.config, SwapsDistributionSqlQuery(s.config, window, dex))
+}
+
+func (s *ClickhouseStore) TopSwaps(window models.Window, dex models.Dex, limit uint64) ([]EnrichedSwapCH, error) {
+	return ReadArrayFromClickhouse[EnrichedSwapCH](s.config, TopSwapsSqlQuery(s.config, window, dex, limit))
+}
+
+func (s *ClickhouseStore) LatestSwaps(filter ListingFilter, cursor *Cursor, limit uint64) ([]EnrichedSwapCH, error) {
+	return ReadArrayFromClickhouse[EnrichedSwapCH](s.config, LatestSwapsSqlQuery(s.config, filter, cursor, limit))
+}
+
+func (s *ClickhouseStore) SwapsCaughtSince(since time.Time) ([]EnrichedSwapCH, error) {
+	return ReadArrayFromClickhouse[EnrichedSwapCH](s.config, SwapsCaughtSinceSqlQuery(s.config, since))
+}
+
+func (s *ClickhouseStore) TopPools(window models.Window, dex models.Dex) ([]PoolVolume, error) {
+	return ReadArrayFromClickhouse[PoolVolume](s.config, TopPoolsRequest(s.config, window, dex))
+}
+
+func (s *ClickhouseStore) TopJettons(window models.Window, dex models.Dex) ([]JettonVolume, error) {
+	return ReadArrayFromClickhouse[JettonVolume](s.config, TopJettonRequest(s.config, window, dex))
+}
+
+func (s *ClickhouseStore) TopUsers(window models.Window, dex models.Dex) ([]UserVolume, error) {
+	return ReadArrayFromClickhouse[UserVolume](s.config, TopUsersRequest(s.config, window, dex))
+}
+
+func (s *ClickhouseStore) TopReferrers(window models.Window, dex models.Dex) ([]UserVolume, error) {
+	return ReadArrayFromClickhouse[UserVolume](s.config, TopReferrersRequest(s.config, window, dex))
+}
+
+func (s *ClickhouseStore) TopProfiters(window models.Window) ([]UserVolume, error) {
+	return ReadArrayFromClickhouse[UserVolume](s.config, TopUsersProfiters(s.config, window))
+}
+
+func (s *ClickhouseStore) UserPortfolio(address string, addresses []string, limit uint64, offset uint64) (*UserPortfolio, error) {
+	return ReadUserPortfolio(s.config, address, addresses, limit, offset)
+}
+
+func (s *ClickhouseStore) JettonDetails(window models.Window, dex models.Dex, master string) (*JettonDetails, error) {
+	return ReadJettonDetails(s.config, window, dex, master)
+}
+
+func (s *ClickhouseStore) PoolDetails(window models.Window, pool string, limit uint64) (*PoolDetails, error) {
+	return ReadPoolDetails(s.config, window, pool, limit)
+}
+
+func (s *ClickhouseStore) Candles(pool string, jetton0 string, jetton1 string, interval string, from time.Time, to time.Time) ([]Candle, error) {
+	query, e := CandlesSqlQuery(s.config, pool, jetton0, jetton1, interval, from, to)
+	if e != nil {
+		return nil, e
+	}
+	return ReadArrayFromClickhouse[Candle](s.config, query)
+}
+
+func (s *ClickhouseStore) LatestArbitrages(filter ListingFilter, cursor *Cursor, limit uint64) ([]EnrichedArbitrageCH, error) {
+	return ReadArrayFromClickhouse[EnrichedArbitrageCH](s.config, LatestArbitragesSqlQuery(s.config, filter, cursor, limit))
+}
+
+func (s *ClickhouseStore) ArbitragesSince(since time.Time) ([]EnrichedArbitrageCH, error) {
+	return ReadArrayFromClickhouse[EnrichedArbitrageCH](s.config, ArbitragesSinceSqlQuery(s.config, since))
+}
+
+func (s *ClickhouseStore) TopArbitrages(window models.Window) ([]EnrichedArbitrageCH, error) {
+	return ReadArrayFromClickhouse[EnrichedArbitrageCH](s.config, TopArbitragesSqlQuery(s.config, window))
+}
+
+func (s *ClickhouseStore) ArbitrageHistory(window models.Window) ([]ArbitrageHistoryEntry, error) {
+	return ReadArrayFromClickhouse[ArbitrageHistoryEntry](s.config, ArbitrageHistorySqlQuery(s.config, window))
+}
+
+func (s *ClickhouseStore) ArbitrageDistribution(window models.Window) (*ArbitrageDistribution, error) {
+	return ReadSingleRow[ArbitrageDistribution](s.config, ArbitrageDistributionSqlQuery(s.config, window))
+}
+
+func (s *ClickhouseStore) TopArbitrageUsers(window models.Window) ([]TopArbitrageUser, error) {
+	return ReadArrayFromClickhouse[TopArbitrageUser](s.config, TopArbitrageUsersSql(s.config, window))
+}
+
+func (s *ClickhouseStore) TopArbitrageJettons(window models.Window) ([]TopArbitrageJetton, error) {
+	return ReadArrayFromClickhouse[TopArbitrageJetton](s.config, TopArbitrageJettonsSql(s.config, window))
+}
+
+func (s *ClickhouseStore) TopMevBots(window models.Window, dex models.Dex) ([]MevBot, error) {
+	return ReadArrayFromClickhouse[MevBot](s.config, TopMevBotsSqlQuery(s.config, window, dex))
+}
+
+func (s *ClickhouseStore) TopMevVictims(window models.Window, dex models.Dex) ([]MevVictim, error) {
+	return ReadArrayFromClickhouse[MevVictim](s.config, TopMevVictimsSqlQuery(s.config, window, dex))
+}
+
+func (s *ClickhouseStore) TopPoolsTvl(dex models.Dex, limit uint64) ([]PoolTvl, error) {
+	return ReadArrayFromClickhouse[PoolTvl](s.config, TopPoolsTvlSqlQuery(s.config, dex, limit))
+}
+
+func (s *ClickhouseStore) PoolTvlHistory(window models.Window, pool string) ([]PoolTvlHistoryEntry, error) {
+	return ReadArrayFromClickhouse[PoolTvlHistoryEntry](s.config, PoolTvlHistorySqlQuery(s.config, window, pool))
+}
+
+func (s *ClickhouseStore) TopLiquidityProviders(window models.Window, dex models.Dex) ([]LiquidityProvider, error) {
+	return ReadArrayFromClickhouse[LiquidityProvider](s.config, TopLiquidityProvidersSql(s.config, window, dex))
+}
+
+func (s *ClickhouseStore) PoolLiquidityFlow(window models.Window, dex models.Dex) ([]PoolLiquidityFlow, error) {
+	return ReadArrayFromClickhouse[PoolLiquidityFlow](s.config, PoolLiquidityFlowSql(s.config, window, dex))
+}
+
+func (s *ClickhouseStore) LatestIngestionGaps(limit uint64) ([]models.IngestionGap, error) {
+	return ReadArrayFromClickhouse[models.IngestionGap](s.config, LatestIngestionGapsSqlQuery(s.config, limit))
+}
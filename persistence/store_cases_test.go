@@ -0,0 +1,479 @@
+package persistence_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+	"tondexer/models"
+	"tondexer/persistence"
+	"tondexer/pipeline"
+)
+
+// storeCases run against every Store implementation, so the memory one can't drift from ClickHouse
+var storeCases = []struct {
+	name string
+	run  func(t *testing.T, store persistence.Store, now time.Time)
+}{
+	{"summary counts trades", testSummaryCountsTrades},
+	{"latest swaps page through rows sharing time and lt", testLatestSwapsPaging},
+	{"feed polls swaps and arbitrages by catch time", testFeedPollsByCatchTime},
+	{"volume history and distribution count trades and swaps", testVolumeHistoryAndDistribution},
+	{"top swaps, pools and jettons rank by usd", testTopSwapsPoolsAndJettons},
+	{"latest swaps are filtered", testLatestSwapsFilters},
+	{"top users, referrers and profiters", testTopUsers},
+	{"user portfolio counts trades and lists every hop", testUserPortfolio},
+	{"jetton details", testJettonDetails},
+	{"pool details", testPoolDetails},
+	{"candles by pool and by jettons", testCandles},
+	{"latest arbitrages page by cursor", testLatestArbitragesPaging},
+	{"arbitrage stats", testArbitrageStats},
+	{"mev bots and victims", testMevBotsAndVictims},
+	{"liquidity and tvl are empty without events", testLiquidityWithoutEvents},
+	{"ingestion gaps are listed newest first", testLatestIngestionGaps},
+	{"dex accounts keep the first record of an address", testDexAccounts},
+}
+
+func runStoreCases(t *testing.T, newStore func(t *testing.T) persistence.Store) {
+	for _, c := range storeCases {
+		t.Run(c.name, func(t *testing.T) {
+			// ClickHouse keeps seconds
+			c.run(t, newStore(t), time.Now().UTC().Truncate(time.Second))
+		})
+	}
+}
+
+func TestMemoryStoreCases(t *testing.T) {
+	runStoreCases(t, func(t *testing.T) persistence.Store {
+		return persistence.NewMemoryStore()
+	})
+}
+
+type leg struct {
+	jetton   string
+	amount   int64
+	rate     float64
+	decimals uint64
+}
+
+var (
+	tonLeg  = func(amount int64) leg { return leg{"ton", amount * 1000000000, 5, 9} }
+	usdtLeg = func(amount int64) leg { return leg{"usdt", amount * 1000000, 1, 6} }
+	notLeg  = func(amount int64) leg { return leg{"not", amount * 1000000000, 0.1, 9} }
+)
+
+func storeSwap(trace string, lt uint64, pool string, sender string, at time.Time, in leg, out leg) *models.SwapCH {
+	return &models.SwapCH{
+		Dex: models.StonfiV2, Hashes: []string{trace + pool}, Lt: lt, Time: at, CatchTime: at,
+		JettonIn: in.jetton, AmountIn: big.NewInt(in.amount), JettonInSymbol: in.jetton, JettonInUsdRate: in.rate, JettonInDecimals: in.decimals,
+		JettonOut: out.jetton, AmountOut: big.NewInt(out.amount), JettonOutSymbol: out.jetton, JettonOutUsdRate: out.rate, JettonOutDecimals: out.decimals,
+		MinAmountOut: big.NewInt(0), ReferralAmount: big.NewInt(0), PoolAddress: pool, Sender: sender, TraceID: trace,
+	}
+}
+
+// storeArbitrage is a ton round trip through two pools, amounts are in nanotons
+func storeArbitrage(sender string, trace string, at time.Time, in int64, out int64) *models.ArbitrageCH {
+	return &models.ArbitrageCH{
+		Sender: sender, Time: at, CatchTime: at,
+		AmountIn: big.NewInt(in), AmountOut: big.NewInt(out), Jetton: "ton", JettonSymbol: "ton", JettonUsdRate: 5, JettonDecimals: 9,
+		AmountsPath: []*big.Int{big.NewInt(in), big.NewInt(in / 200), big.NewInt(out)}, JettonsPath: []string{"ton", "usdt", "ton"},
+		JettonNames: []string{"", "", ""}, JettonSymbols: []string{"ton", "usdt", "ton"}, JettonUsdRates: []float64{5, 1, 5}, JettonsDecimals: []uint64{9, 6, 9},
+		PoolsPath: []string{"ton-usdt", "other"}, TraceIDs: []string{trace, trace + "-back"}, Dexes: []string{models.StonfiV2, models.StonfiV2}, Senders: []string{sender, sender},
+	}
+}
+
+func saveSwaps(t *testing.T, store persistence.Store, swaps ...*models.SwapCH) {
+	assert.Nil(t, store.SaveSwaps(swaps))
+	assert.Nil(t, store.SaveTrades(pipeline.BuildTrades(swaps)))
+}
+
+func testSummaryCountsTrades(t *testing.T, store persistence.Store, now time.Time) {
+	saveSwaps(t, store,
+		storeSwap("route", 1, "ton-usdt", "alice", now.Add(-time.Hour), tonLeg(2), usdtLeg(10)),
+		storeSwap("route", 2, "usdt-not", "alice", now.Add(-time.Hour), usdtLeg(10), notLeg(100)),
+		storeSwap("single", 3, "ton-usdt", "bob", now.Add(-time.Hour), tonLeg(4), usdtLeg(20)),
+	)
+
+	summary, e := store.Summary(models.Window{Period: models.Day}, models.Dex("all"))
+	assert.Nil(t, e)
+	assert.Equal(t, &persistence.SummaryStats{Volume: 30, Number: 2, UniqueTokens: 3, UniqueUsers: 2}, summary)
+}
+
+func testLatestSwapsPaging(t *testing.T, store persistence.Store, now time.Time) {
+	saveSwaps(t, store,
+		storeSwap("a", 7, "first", "alice", now.Add(-time.Minute), tonLeg(1), usdtLeg(5)),
+		storeSwap("b", 7, "second", "bob", now.Add(-time.Minute), tonLeg(1), usdtLeg(5)),
+		storeSwap("c", 3, "first", "alice", now.Add(-time.Hour), tonLeg(1), usdtLeg(5)),
+	)
+	filter := persistence.ListingFilter{Dex: models.Dex("all")}
+
+	var pools []string
+	var cursor *persistence.Cursor
+	for range 4 {
+		page, e := store.LatestSwaps(filter, cursor, 1)
+		assert.Nil(t, e)
+		if len(page) == 0 {
+			break
+		}
+		pools = append(pools, page[0].PoolAddress)
+		cursor = persistence.NextSwapsCursor(page, 1)
+	}
+	assert.Equal(t, []string{"second", "first", "first"}, pools)
+}
+
+func testFeedPollsByCatchTime(t *testing.T, store persistence.Store, now time.Time) {
+	// the late swap happened an hour ago but was written only now
+	late := storeSwap("late", 1, "pool", "alice", now.Add(-time.Hour), tonLeg(1), usdtLeg(5))
+	late.CatchTime = now
+	saveSwaps(t, store, late, storeSwap("old", 2, "pool", "bob", now.Add(-time.Hour), tonLeg(1), usdtLeg(5)))
+	arbitrage := storeArbitrage("alice", "late", now.Add(-time.Hour), 1000000000, 1100000000)
+	arbitrage.CatchTime = now
+	assert.Nil(t, store.SaveArbitrages([]*models.ArbitrageCH{arbitrage}))
+
+	swaps, e := store.SwapsCaughtSince(now.Add(-time.Minute))
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(swaps))
+	assert.Equal(t, uint64(1), swaps[0].Lt)
+
+	arbitrages, e := store.ArbitragesSince(now.Add(-time.Minute))
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(arbitrages))
+}
+
+func testLatestIngestionGaps(t *testing.T, store persistence.Store, now time.Time) {
+	assert.Nil(t, store.SaveIngestionGaps([]*models.IngestionGap{
+		{Account: "old", FromLt: 1, ToLt: 5, Reason: "timeout", Time: now.Add(-time.Hour)},
+		{Account: "new", FromLt: 7, ToLt: 9, Reason: "timeout", Time: now},
+	}))
+
+	gaps, e := store.LatestIngestionGaps(1)
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(gaps))
+	assert.Equal(t, "new", gaps[0].Account)
+}
+
+func testDexAccounts(t *testing.T, store persistence.Store, now time.Time) {
+	assert.Nil(t, store.SaveDexAccounts([]*models.DexAccount{
+		{Dex: models.DeDust, Address: "vault", Source: models.DexAccountSourceDiscovered, Time: now.Add(-time.Hour)},
+	}))
+	assert.Nil(t, store.SaveDexAccounts([]*models.DexAccount{
+		{Dex: models.StonfiV2, Address: "vault", Source: models.DexAccountSourceDiscovered, Time: now},
+	}))
+
+	accounts, e := store.DexAccounts()
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(accounts))
+	assert.Equal(t, models.DeDust, accounts[0].Dex)
+}
+
+// seedHour is the start of the previous hour, rows after it fall into one bucket of the day window
+func seedHour(now time.Time) time.Time {
+	return now.Truncate(time.Hour).Add(-time.Hour)
+}
+
+// seedSwaps stores a two hop route of alice worth 10 usd and a single swap of bob worth 21 usd which pays carol a referral
+func seedSwaps(t *testing.T, store persistence.Store, at time.Time) {
+	single := storeSwap("single", 3, "ton-usdt", "bob", at.Add(time.Minute), tonLeg(4), usdtLeg(22))
+	single.ReferralAddress, single.ReferralAmount = "carol", big.NewInt(100000)
+	saveSwaps(t, store,
+		storeSwap("route", 1, "ton-usdt", "alice", at, tonLeg(2), usdtLeg(10)),
+		storeSwap("route", 2, "usdt-not", "alice", at, usdtLeg(10), notLeg(100)),
+		single,
+	)
+}
+
+// seedArbitrages stores an arbitrage of alice with 1 usd profit and a later one of bob with 0.1 usd profit
+func seedArbitrages(t *testing.T, store persistence.Store, at time.Time) {
+	assert.Nil(t, store.SaveArbitrages([]*models.ArbitrageCH{
+		storeArbitrage("alice", "first", at, 1000000000, 1200000000),
+		storeArbitrage("bob", "second", at.Add(time.Minute), 1000000000, 1020000000),
+	}))
+}
+
+var day = models.Window{Period: models.Day}
+
+func testVolumeHistoryAndDistribution(t *testing.T, store persistence.Store, now time.Time) {
+	at := seedHour(now)
+	seedSwaps(t, store, at)
+
+	history, e := store.VolumeHistory(day, models.Dex("all"))
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(history))
+	assert.True(t, history[0].Period.Equal(at))
+	assert.Equal(t, uint64(2), history[0].Number)
+	assert.Equal(t, int64(31), history[0].StonfiVolumeUsd.Int64())
+	assert.Equal(t, int64(0), history[0].DedustVolumeUsd.Int64())
+
+	distribution, e := store.SwapsDistribution(day, models.Dex("all"))
+	assert.Nil(t, e)
+	assert.Equal(t, &persistence.SwapDistribution{Usd_5_15: 2, Usd_15_50: 1}, distribution)
+}
+
+func testTopSwapsPoolsAndJettons(t *testing.T, store persistence.Store, now time.Time) {
+	seedSwaps(t, store, seedHour(now))
+
+	swaps, e := store.TopSwaps(day, models.Dex("all"), 2)
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(swaps))
+	assert.Equal(t, uint64(3), swaps[0].Lt)
+
+	pools, e := store.TopPools(day, models.Dex("all"))
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(pools))
+	assert.Equal(t, "ton-usdt", pools[0].PoolAddress)
+	assert.InDelta(t, 31, pools[0].AmountUsd, 1e-6)
+	assert.Equal(t, "usdt-not", pools[1].PoolAddress)
+
+	jettons, e := store.TopJettons(day, models.Dex("all"))
+	assert.Nil(t, e)
+	var masters []string
+	for _, jetton := range jettons {
+		masters = append(masters, jetton.JettonAddress)
+	}
+	assert.Equal(t, []string{"usdt", "ton", "not"}, masters)
+	assert.InDelta(t, 42, jettons[0].JettonUsd, 1e-6)
+}
+
+func testLatestSwapsFilters(t *testing.T, store persistence.Store, now time.Time) {
+	seedSwaps(t, store, seedHour(now))
+
+	count := func(filter persistence.ListingFilter) int {
+		swaps, e := store.LatestSwaps(filter, nil, 10)
+		assert.Nil(t, e)
+		return len(swaps)
+	}
+	assert.Equal(t, 3, count(persistence.ListingFilter{Dex: models.Dex("all")}))
+	assert.Equal(t, 0, count(persistence.ListingFilter{Dex: models.Dex("dedust")}))
+	assert.Equal(t, 2, count(persistence.ListingFilter{Dex: models.Dex("all"), Senders: []string{"alice"}}))
+	assert.Equal(t, 1, count(persistence.ListingFilter{Dex: models.Dex("all"), Jetton: "not"}))
+	assert.Equal(t, 2, count(persistence.ListingFilter{Dex: models.Dex("all"), Pool: "ton-usdt"}))
+	assert.Equal(t, 1, count(persistence.ListingFilter{Dex: models.Dex("all"), MinUsd: 15}))
+}
+
+func testTopUsers(t *testing.T, store persistence.Store, now time.Time) {
+	seedSwaps(t, store, seedHour(now))
+
+	users, e := store.TopUsers(day, models.Dex("all"))
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, persistence.UserVolume{UserAddress: "bob", AmountUsd: 21, Tokens: 2, Count: 1}, users[0])
+	assert.Equal(t, persistence.UserVolume{UserAddress: "alice", AmountUsd: 10, Tokens: 3, Count: 1}, users[1])
+
+	referrers, e := store.TopReferrers(day, models.Dex("all"))
+	assert.Nil(t, e)
+	assert.Equal(t, "carol", referrers[0].UserAddress)
+	assert.InDelta(t, 0.1, referrers[0].AmountUsd, 1e-6)
+	assert.Equal(t, uint64(1), referrers[0].Count)
+
+	profiters, e := store.TopProfiters(day)
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(profiters))
+	assert.Equal(t, "bob", profiters[0].UserAddress)
+	assert.InDelta(t, 2, profiters[0].AmountUsd, 1e-6)
+}
+
+func testUserPortfolio(t *testing.T, store persistence.Store, now time.Time) {
+	at := seedHour(now)
+	seedSwaps(t, store, at)
+	seedArbitrages(t, store, at)
+
+	portfolio, e := store.UserPortfolio("alice", []string{"alice"}, 10, 0)
+	assert.Nil(t, e)
+	assert.Equal(t, uint64(1), portfolio.Swaps)
+	assert.InDelta(t, 10, portfolio.VolumeUsd, 1e-6)
+	assert.Equal(t, 2, len(portfolio.Jettons))
+	assert.Equal(t, uint64(1), portfolio.Arbitrages.Number)
+	assert.InDelta(t, 1, portfolio.Arbitrages.ProfitUsd, 1e-6)
+	assert.Equal(t, uint64(0), portfolio.Referrals.Swaps)
+	var lts []uint64
+	for _, swap := range portfolio.History {
+		lts = append(lts, swap.Lt)
+	}
+	assert.Equal(t, []uint64{2, 1}, lts)
+
+	referrer, e := store.UserPortfolio("carol", []string{"carol"}, 10, 0)
+	assert.Nil(t, e)
+	assert.Equal(t, uint64(1), referrer.Referrals.Swaps)
+	assert.Equal(t, 0, len(referrer.History))
+}
+
+func testJettonDetails(t *testing.T, store persistence.Store, now time.Time) {
+	at := seedHour(now)
+	seedSwaps(t, store, at)
+	assert.Nil(t, store.SaveRates([]*models.JettonRate{{Time: at, Name: "ton", Symbol: "ton", Master: "ton", Decimals: 9, Rate: 5}}))
+
+	details, e := store.JettonDetails(day, models.Dex("all"), "ton")
+	assert.Nil(t, e)
+	assert.Equal(t, "ton", details.Stats.JettonAddress)
+	assert.InDelta(t, 30, details.Stats.VolumeUsd, 1e-6)
+	assert.InDelta(t, 30, details.Stats.SellUsd, 1e-6)
+	assert.InDelta(t, 0, details.Stats.BuyUsd, 1e-6)
+	assert.Equal(t, uint64(2), details.Stats.Swaps)
+	assert.Equal(t, uint64(2), details.Stats.Traders)
+	assert.Equal(t, 1, len(details.PriceHistory))
+	assert.InDelta(t, 5, details.PriceHistory[0].Rate, 1e-6)
+	assert.Equal(t, 1, len(details.VolumeHistory))
+	assert.Equal(t, 1, len(details.Pools))
+	assert.Equal(t, "ton-usdt", details.Pools[0].PoolAddress)
+	assert.Equal(t, 2, len(details.Traders))
+	assert.Equal(t, "bob", details.Traders[0].Sender)
+}
+
+func testPoolDetails(t *testing.T, store persistence.Store, now time.Time) {
+	at := seedHour(now)
+	seedSwaps(t, store, at)
+	seedArbitrages(t, store, at)
+
+	details, e := store.PoolDetails(day, "ton-usdt", 10)
+	assert.Nil(t, e)
+	assert.Equal(t, "ton", details.Info.Jetton0)
+	assert.Equal(t, "usdt", details.Info.Jetton1)
+	assert.Equal(t, uint64(2), details.Stats.Swaps)
+	assert.Equal(t, uint64(2), details.Stats.Traders)
+	assert.Equal(t, uint64(2), details.Stats.Jetton0Sells)
+	assert.InDelta(t, 30, details.Stats.Jetton0SellUsd, 1e-6)
+	assert.Equal(t, 2, len(details.Swaps))
+	assert.Equal(t, uint64(3), details.Swaps[0].Lt)
+	assert.Equal(t, 2, len(details.Arbitrages))
+
+	missing, e := store.PoolDetails(day, "missing", 10)
+	assert.Nil(t, e)
+	assert.Nil(t, missing)
+}
+
+func testCandles(t *testing.T, store persistence.Store, now time.Time) {
+	at := seedHour(now)
+	first := storeSwap("first", 1, "ton-usdt", "alice", at, tonLeg(2), usdtLeg(10))
+	second := storeSwap("second", 2, "ton-usdt", "bob", at.Add(time.Minute), usdtLeg(22), tonLeg(4))
+	// the candles view counts swaps caught after the migration
+	first.CatchTime, second.CatchTime = now.Add(time.Minute), now.Add(time.Minute)
+	saveSwaps(t, store, first, second)
+
+	candles, e := store.Candles("ton-usdt", "", "", "1h", at, at.Add(time.Hour))
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(candles))
+	candle := candles[0]
+	assert.True(t, candle.Time.Equal(at))
+	assert.Equal(t, "ton", candle.Jetton0)
+	assert.Equal(t, "usdt", candle.Jetton1)
+	assert.InDelta(t, 5, candle.Open, 1e-6)
+	assert.InDelta(t, 5.5, candle.Close, 1e-6)
+	assert.InDelta(t, 5.5, candle.High, 1e-6)
+	assert.InDelta(t, 5, candle.Low, 1e-6)
+	assert.InDelta(t, 6, candle.Volume0, 1e-6)
+	assert.InDelta(t, 32, candle.Volume1, 1e-6)
+
+	candles, e = store.Candles("", "ton", "usdt", "1m", at, at.Add(time.Hour))
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(candles))
+
+	_, e = store.Candles("", "ton", "", "1m", at, at.Add(time.Hour))
+	assert.NotNil(t, e)
+}
+
+func testLatestArbitragesPaging(t *testing.T, store persistence.Store, now time.Time) {
+	seedArbitrages(t, store, seedHour(now))
+	filter := persistence.ListingFilter{Dex: models.Dex("all")}
+
+	var senders []string
+	var cursor *persistence.Cursor
+	for range 3 {
+		page, e := store.LatestArbitrages(filter, cursor, 1)
+		assert.Nil(t, e)
+		if len(page) == 0 {
+			break
+		}
+		senders = append(senders, page[0].Sender)
+		cursor = persistence.NextArbitragesCursor(page, 1)
+	}
+	assert.Equal(t, []string{"bob", "alice"}, senders)
+
+	filtered, e := store.LatestArbitrages(persistence.ListingFilter{Dex: models.Dex("all"), Senders: []string{"alice"}}, nil, 10)
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(filtered))
+}
+
+func testArbitrageStats(t *testing.T, store persistence.Store, now time.Time) {
+	seedArbitrages(t, store, seedHour(now))
+
+	arbitrages, e := store.TopArbitrages(day)
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(arbitrages))
+	assert.Equal(t, "alice", arbitrages[0].Sender)
+
+	history, e := store.ArbitrageHistory(day)
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, uint64(2), history[0].Number)
+	assert.InDelta(t, 1.1, history[0].UsdProfit, 1e-6)
+
+	distribution, e := store.ArbitrageDistribution(day)
+	assert.Nil(t, e)
+	assert.Equal(t, &persistence.ArbitrageDistribution{Usd_5_20: 1, Usd_50_200: 1}, distribution)
+
+	users, e := store.TopArbitrageUsers(day)
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, "alice", users[0].Sender)
+	assert.Equal(t, uint64(1), users[0].Number)
+
+	jettons, e := store.TopArbitrageJettons(day)
+	assert.Nil(t, e)
+	assert.Equal(t, 1, len(jettons))
+	assert.Equal(t, "ton", jettons[0].Jetton)
+	assert.Equal(t, uint64(2), jettons[0].Number)
+	assert.InDelta(t, 1.1, jettons[0].ProfitUsd, 1e-6)
+}
+
+func testMevBotsAndVictims(t *testing.T, store persistence.Store, now time.Time) {
+	at := seedHour(now)
+	sandwich := func(attacker string, victim string, trace string, profit float64, loss float64, slippage float64) *models.MevEventCH {
+		return &models.MevEventCH{
+			Kind: models.MevSandwich, Time: at, CatchTime: at, Dex: models.StonfiV2, PoolAddress: "ton-usdt", Attacker: attacker, Victim: victim,
+			FrontrunTraceID: trace + "-front", VictimTraceID: trace, BackrunTraceID: trace + "-back", JettonIn: "ton", JettonOut: "usdt",
+			VictimVolumeUsd: 10, ProfitUsd: profit, VictimSlippage: slippage, VictimLossUsd: loss,
+		}
+	}
+	assert.Nil(t, store.SaveMevEvents([]*models.MevEventCH{
+		sandwich("mallory", "alice", "a", 3, 2, 0.02),
+		sandwich("mallory", "bob", "b", 1, 0.5, 0.01),
+		sandwich("trent", "bob", "c", 0.5, 0.2, 0.03),
+	}))
+
+	bots, e := store.TopMevBots(day, models.Dex("all"))
+	assert.Nil(t, e)
+	assert.Equal(t, []persistence.MevBot{
+		{Attacker: "mallory", ProfitUsd: 4, Attacks: 2, Victims: 2, Pools: 1},
+		{Attacker: "trent", ProfitUsd: 0.5, Attacks: 1, Victims: 1, Pools: 1},
+	}, bots)
+
+	victims, e := store.TopMevVictims(day, models.Dex("all"))
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(victims))
+	assert.Equal(t, "alice", victims[0].Victim)
+	assert.Equal(t, "bob", victims[1].Victim)
+	assert.Equal(t, uint64(2), victims[1].Attacks)
+	assert.InDelta(t, 0.7, victims[1].LossUsd, 1e-6)
+	assert.InDelta(t, 0.02, victims[1].AvgSlippage, 1e-6)
+
+	none, e := store.TopMevBots(day, models.Dex("dedust"))
+	assert.Nil(t, e)
+	assert.Empty(t, none)
+}
+
+func testLiquidityWithoutEvents(t *testing.T, store persistence.Store, now time.Time) {
+	seedSwaps(t, store, seedHour(now))
+
+	tvl, e := store.TopPoolsTvl(models.Dex("all"), 10)
+	assert.Nil(t, e)
+	assert.Empty(t, tvl)
+	history, e := store.PoolTvlHistory(day, "ton-usdt")
+	assert.Nil(t, e)
+	assert.Empty(t, history)
+	providers, e := store.TopLiquidityProviders(day, models.Dex("all"))
+	assert.Nil(t, e)
+	assert.Empty(t, providers)
+	flow, e := store.PoolLiquidityFlow(day, models.Dex("all"))
+	assert.Nil(t, e)
+	assert.Empty(t, flow)
+}
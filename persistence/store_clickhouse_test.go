@@ -0,0 +1,53 @@
+//go:build clickhouse
+
+// The cases run against a ClickHouse server, every case in a database of its own:
+// DB_HOST=localhost DB_PORT=9000 go test -tags clickhouse ./persistence
+
+package persistence_test
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+	"tondexer/core"
+	"tondexer/migrations"
+	"tondexer/persistence"
+)
+
+func testDbConfig() *core.DbConfig {
+	config := &core.DbConfig{DbHost: "localhost", DbPort: 9000, DbUser: "default", DbName: "default"}
+	if host := os.Getenv("DB_HOST"); host != "" {
+		config.DbHost = host
+	}
+	if port, e := strconv.ParseUint(os.Getenv("DB_PORT"), 10, 32); e == nil {
+		config.DbPort = uint(port)
+	}
+	if user := os.Getenv("DB_USER"); user != "" {
+		config.DbUser = user
+	}
+	config.DbPassword = os.Getenv("DB_PASSWORD")
+	return config
+}
+
+func TestClickhouseStoreCases(t *testing.T) {
+	server := testDbConfig()
+	if e := persistence.ExecClickhouse(server, "SELECT 1"); e != nil {
+		t.Skipf("ClickHouse is not available: %v", e)
+	}
+	runStoreCases(t, func(t *testing.T) persistence.Store {
+		config := *server
+		config.DbName = fmt.Sprint("tondexer_test_", time.Now().UnixNano())
+		if e := persistence.ExecClickhouse(server, "CREATE DATABASE "+config.DbName); e != nil {
+			t.Fatal(e)
+		}
+		t.Cleanup(func() {
+			_ = persistence.ExecClickhouse(server, "DROP DATABASE IF EXISTS "+config.DbName)
+		})
+		if _, e := migrations.Up(&config); e != nil {
+			t.Fatal(e)
+		}
+		return persistence.NewClickhouseStore(&config)
+	})
+}
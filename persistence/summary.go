@@ -1,24 +1,21 @@
 package persistence
 
 import (
-	"fmt"
 	"tondexer/core"
 	"tondexer/models"
 )
 
-func SwapsSummarySql(config *core.DbConfig, period models.Period, dex models.Dex) string {
-	periodParams := models.PeriodParamsMap[period]
-	return fmt.Sprintf(`
+// SwapsSummarySql counts trades, a multi-hop route is one swap of the user
+func SwapsSummarySql(config *core.DbConfig, window models.Window, dex models.Dex) Query {
+	return NewQuery(config).Sql(`
 SELECT
-    toUInt64((sum(%v) + sum(%v)) / 2) AS volume,
+    toUInt64((sum(`, UsdInField, `) + sum(`, UsdOutField, `)) / 2) AS volume,
     count() AS number,
-    length(groupUniqArrayArray([jetton_in, jetton_out])) AS unique_tokens,
-    uniq(sender) AS unique_users
-FROM %v.swaps
-WHERE time >= %v(subtractDays(now(), %v))
-AND %v
-AND %v < 1000000 AND %v < 1000000`, UsdInField, UsdOutField,
-		config.DbName, periodParams.ToStartOf, periodParams.WindowInDays,
-		dex.WhereStatement("dex"),
-		UsdInField, UsdOutField)
+    length(groupUniqArrayArray(jettons_path)) AS unique_tokens,
+    uniq(sender) AS unique_users`).
+		From("trades").
+		TimeWindow("time", window).
+		Dex("dex", dex).
+		UsdCap(MaxSwapUsd, UsdInField, UsdOutField).
+		Build()
 }
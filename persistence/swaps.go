@@ -23,6 +23,7 @@ func Symbol(field string) string {
 var enrichedSwapSelect = fmt.Sprint(`
 SELECT
 	time, 
+	lt,
 	dex,
 	hashes,
 	sender,
@@ -47,11 +48,13 @@ SELECT
 	referral_amount,
 	floor(`, UsdReferralField, `, 2) AS referral_usd,
 	trace_id,
-	pool_address
+	pool_address,
+	catch_time
 `)
 
 type EnrichedSwapCH struct {
 	Time              time.Time `ch:"time"`
+	Lt                uint64    `ch:"lt"`
 	Dex               string    `ch:"dex"`
 	Hashes            []string  `ch:"hashes"`
 	Sender            string    `ch:"sender"`
@@ -77,28 +80,37 @@ type EnrichedSwapCH struct {
 	ReferralUsd       float64   `ch:"referral_usd"`
 	TraceID           string    `ch:"trace_id"`
 	PoolAddress       string    `ch:"pool_address"`
+	CatchTime         time.Time `ch:"catch_time"`
 }
 
-func LatestSwapsSqlQuery(config *core.DbConfig, limit uint64, dex models.Dex) string {
-	return fmt.Sprint(
-		enrichedSwapSelect, `
-FROM `, config.DbName, `.swaps
-WHERE `, dex.WhereStatement("dex"), `
-AND out_usd < 1000000 AND in_usd < 1000000
-ORDER BY time DESC
-LIMIT `, limit)
+func LatestSwapsSqlQuery(config *core.DbConfig, filter ListingFilter, cursor *Cursor, limit uint64) Query {
+	q := NewQuery(config).Sql(enrichedSwapSelect).
+		From("swaps").
+		Dex("dex", filter.Dex).
+		UsdCap(MaxSwapUsd, "out_usd", "in_usd")
+	if len(filter.Senders) > 0 {
+		q.Where("has(?, sender)", filter.Senders)
+	}
+	if filter.Jetton != "" {
+		q.Where("(jetton_in = ? OR jetton_out = ?)", filter.Jetton, filter.Jetton)
+	}
+	if filter.Pool != "" {
+		q.Where("pool_address = ?", filter.Pool)
+	}
+	return q.page(filter, cursor, "lt", "pool_address", "(in_usd + out_usd) / 2", limit).Build()
 }
 
-func TopSwapsSqlQuery(config *core.DbConfig, period models.Period, dex models.Dex) string {
-	periodParams := models.PeriodParamsMap[period]
-	return fmt.Sprint(enrichedSwapSelect, `
-FROM `, config.DbName, `.swaps
-WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
-AND `, dex.WhereStatement("dex"), `
-AND in_usd != 0 AND out_usd != 0 AND out_usd < 1000000 AND in_usd < 1000000
-ORDER BY (in_usd + out_usd) DESC
-LIMIT 15
-`)
+func TopSwapsSqlQuery(config *core.DbConfig, window models.Window, dex models.Dex, limit uint64) Query {
+	return NewQuery(config).Sql(enrichedSwapSelect).
+		From("swaps").
+		TimeWindow("time", window).
+		Dex("dex", dex).
+		Where("in_usd != 0 AND out_usd != 0").
+		UsdCap(MaxSwapUsd, "out_usd", "in_usd").
+		Sql(`
+ORDER BY (in_usd + out_usd) DESC`).
+		Limit(limit).
+		Build()
 }
 
 type SwapDistribution struct {
@@ -112,9 +124,8 @@ type SwapDistribution struct {
 	Usd_2000     uint64 `ch:"usd_2000" json:"usd_2000"`
 }
 
-func SwapsDistributionSqlQuery(config *core.DbConfig, period models.Period, dex models.Dex) string {
-	periodParams := models.PeriodParamsMap[period]
-	return fmt.Sprint(`
+func SwapsDistributionSqlQuery(config *core.DbConfig, window models.Window, dex models.Dex) Query {
+	return NewQuery(config).Sql(`
 SELECT
     countIf(usd <= 1) AS usd_1,
     countIf((usd > 1) AND (usd <= 5)) AS usd_1_5,
@@ -127,10 +138,28 @@ SELECT
 FROM
 (
     SELECT
-        (`, UsdInField, ` + `, UsdOutField, `) / 2 AS usd
-	FROM `, config.DbName, `.swaps
-	WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
-	AND `, dex.WhereStatement("dex"), ` AND usd < 1000000
-)
-`)
+        (`, UsdInField, ` + `, UsdOutField, `) / 2 AS usd`).
+		From("swaps").
+		TimeWindow("time", window).
+		Dex("dex", dex).
+		UsdCap(MaxSwapUsd, "usd").
+		Sql(`
+)`).
+		Build()
+}
+
+func RecentSwapAmountsSqlQuery(config *core.DbConfig, window time.Duration) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    time,
+    jetton_in,
+    amount_in,
+    jetton_in_decimals,
+    jetton_out,
+    amount_out,
+    jetton_out_decimals`).
+		From("swaps").
+		Where("time >= subtractSeconds(now(), ?)", uint64(window.Seconds())).
+		Where("jetton_in != '' AND jetton_out != ''").
+		Build()
 }
@@ -0,0 +1,58 @@
+package persistence
+
+import (
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+func WriteTradesToClickhouse(config *core.DbConfig, trades []*models.TradeCH) error {
+	return WriteToClickhouse(config, trades, "trades", AppendTrade)
+}
+
+func AppendTrade(batch driver.Batch, model *models.TradeCH) error {
+	return batch.Append(
+		model.Dex,
+		model.TraceID,
+		model.Sender,
+		model.Lt,
+		model.Time,
+		model.Hashes,
+		model.JettonIn,
+		model.AmountIn,
+		model.JettonInSymbol,
+		model.JettonInName,
+		model.JettonInUsdRate,
+		model.JettonInDecimals,
+		model.JettonInPriceSource,
+		model.JettonOut,
+		model.AmountOut,
+		model.JettonOutSymbol,
+		model.JettonOutName,
+		model.JettonOutUsdRate,
+		model.JettonOutDecimals,
+		model.JettonOutPriceSource,
+		model.PoolsPath,
+		model.JettonsPath,
+		model.CatchTime,
+	)
+}
+
+func DeleteTraceTradesSqlQuery(config *core.DbConfig, traceIDs []string) Query {
+	return NewQuery(config).Sql("ALTER TABLE ").Table("trades").Sql(" DELETE").
+		Where("has(?, trace_id)", traceIDs).
+		Sql(`
+SETTINGS mutations_sync = 1`).
+		Build()
+}
+
+// ReplaceTraceTrades deletes stored trades of the traces and writes the ones built from the rewritten swaps
+func ReplaceTraceTrades(config *core.DbConfig, traceIDs []string, trades []*models.TradeCH) error {
+	if e := ExecQuery(config, DeleteTraceTradesSqlQuery(config, traceIDs)); e != nil {
+		return e
+	}
+	if len(trades) == 0 {
+		return nil
+	}
+	return WriteTradesToClickhouse(config, trades)
+}
@@ -2,6 +2,7 @@ package persistence
 
 import (
 	"fmt"
+	"time"
 	"tondexer/core"
 	"tondexer/models"
 )
@@ -13,53 +14,236 @@ type UserVolume struct {
 	Count       uint64  `json:"count" ch:"count"`
 }
 
-func TopReferrersRequest(config *core.DbConfig, period models.Period, dex models.Dex) string {
-	periodParams := models.PeriodParamsMap[period]
-	return fmt.Sprint(`
+func TopReferrersRequest(config *core.DbConfig, window models.Window, dex models.Dex) Query {
+	return NewQuery(config).Sql(`
 SELECT
     referral_address AS sender,
     sum(`, UsdReferralField, `) AS amount_usd,
     uniq(jetton_out) AS tokens,
-    count() AS count
-FROM `, config.DbName, `.swaps
-WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
+    count() AS count`).
+		From("swaps").
+		TimeWindow("time", window).
+		Sql(`
 GROUP BY sender
-ORDER BY amount_usd DESC
-LIMIT 15
-`)
+ORDER BY amount_usd DESC`).
+		Limit(15).
+		Build()
 }
 
-func TopUsersRequest(config *core.DbConfig, period models.Period, dex models.Dex) string {
-	periodParams := models.PeriodParamsMap[period]
-	return fmt.Sprint(`
+func TopUsersRequest(config *core.DbConfig, window models.Window, dex models.Dex) Query {
+	return NewQuery(config).Sql(`
 SELECT
     sender,
     sum((`, UsdInField, ` + `, UsdOutField, `) / 2) AS amount_usd,
-    uniqArray([jetton_in, jetton_out]) AS tokens,
-    count() AS count
-FROM `, config.DbName, `.swaps
-WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
-AND `, dex.WhereStatement("dex"), `
-AND `, UsdInField, ` < 1000000 AND `, UsdOutField, ` < 1000000
+    uniqArray(jettons_path) AS tokens,
+    count() AS count`).
+		From("trades").
+		TimeWindow("time", window).
+		Dex("dex", dex).
+		UsdCap(MaxSwapUsd, UsdInField, UsdOutField).
+		Sql(`
 GROUP BY sender
-ORDER BY amount_usd DESC
-LIMIT 15
-`)
+ORDER BY amount_usd DESC`).
+		Limit(15).
+		Build()
 }
 
-func TopUsersProfiters(config *core.DbConfig, period models.Period) string {
-	periodParams := models.PeriodParamsMap[period]
-	return fmt.Sprint(`
+func TopUsersProfiters(config *core.DbConfig, window models.Window) Query {
+	return NewQuery(config).Sql(`
 SELECT
     sender,
     sum(`, UsdOutField, ` - `, UsdInField, `) AS amount_usd,
-    uniqArray([jetton_in, jetton_out]) AS tokens,
-    count() AS count
-FROM `, config.DbName, `.swaps
-WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
-AND jetton_in_usd_rate != 0 AND jetton_out_usd_rate != 0
+    uniqArray(jettons_path) AS tokens,
+    count() AS count`).
+		From("trades").
+		TimeWindow("time", window).
+		Where("jetton_in_usd_rate != 0 AND jetton_out_usd_rate != 0").
+		Sql(`
 GROUP BY sender
-ORDER BY amount_usd DESC
-LIMIT 15
-`)
+ORDER BY amount_usd DESC`).
+		Limit(15).
+		Build()
+}
+
+type UserDexVolume struct {
+	Dex       string    `json:"dex" ch:"dex"`
+	VolumeUsd float64   `json:"volume_usd" ch:"volume_usd"`
+	Swaps     uint64    `json:"swaps" ch:"swaps"`
+	FirstSwap time.Time `json:"first_swap" ch:"first_swap"`
+	LastSwap  time.Time `json:"last_swap" ch:"last_swap"`
+}
+
+// UserJetton realized pnl is the difference of average sell and buy prices for the amount both bought and sold
+type UserJetton struct {
+	Jetton         string  `json:"jetton" ch:"jetton"`
+	JettonSymbol   string  `json:"jetton_symbol" ch:"jetton_symbol"`
+	BoughtAmount   float64 `json:"bought_amount" ch:"bought_amount"`
+	BoughtUsd      float64 `json:"bought_usd" ch:"bought_usd"`
+	SoldAmount     float64 `json:"sold_amount" ch:"sold_amount"`
+	SoldUsd        float64 `json:"sold_usd" ch:"sold_usd"`
+	Swaps          uint64  `json:"swaps" ch:"swaps"`
+	RealizedPnlUsd float64 `json:"realized_pnl_usd" ch:"realized_pnl_usd"`
+}
+
+type UserArbitrages struct {
+	Number    uint64  `json:"number" ch:"number"`
+	ProfitUsd float64 `json:"profit_usd" ch:"profit_usd"`
+	VolumeUsd float64 `json:"volume_usd" ch:"volume_usd"`
+}
+
+type UserReferrals struct {
+	Swaps       uint64  `json:"swaps" ch:"swaps"`
+	EarningsUsd float64 `json:"earnings_usd" ch:"earnings_usd"`
+}
+
+type UserPortfolio struct {
+	Address        string           `json:"address"`
+	VolumeUsd      float64          `json:"volume_usd"`
+	Swaps          uint64           `json:"swaps"`
+	RealizedPnlUsd float64          `json:"realized_pnl_usd"`
+	Dexes          []UserDexVolume  `json:"dexes"`
+	Jettons        []UserJetton     `json:"jettons"`
+	Arbitrages     *UserArbitrages  `json:"arbitrages"`
+	Referrals      *UserReferrals   `json:"referrals"`
+	History        []EnrichedSwapCH `json:"history"`
+}
+
+// User queries take every form of the address because dexes report senders differently.
+// Volumes and jettons are counted over trades, the history lists every hop
+
+func UserSwapsSqlQuery(config *core.DbConfig, addresses []string, limit uint64, offset uint64) Query {
+	return NewQuery(config).Sql(enrichedSwapSelect).
+		From("swaps").
+		Where("has(?, sender)", addresses).
+		Sql(`
+ORDER BY time DESC, lt DESC`).
+		Limit(limit).
+		Offset(offset).
+		Build()
+}
+
+func UserDexVolumesSqlQuery(config *core.DbConfig, addresses []string) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    dex,
+    sum((`, UsdInField, ` + `, UsdOutField, `) / 2) AS volume_usd,
+    count() AS swaps,
+    min(time) AS first_swap,
+    max(time) AS last_swap`).
+		From("trades").
+		Where("has(?, sender)", addresses).
+		UsdCap(MaxSwapUsd, UsdInField, UsdOutField).
+		Sql(`
+GROUP BY dex
+ORDER BY volume_usd DESC`).
+		Build()
+}
+
+func UserJettonsSqlQuery(config *core.DbConfig, addresses []string) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    jetton,
+    anyHeavy(symbol) AS jetton_symbol,
+    sumIf(amount, side = 'buy') AS bought_amount,
+    sumIf(usd, side = 'buy') AS bought_usd,
+    sumIf(amount, side = 'sell') AS sold_amount,
+    sumIf(usd, side = 'sell') AS sold_usd,
+    count() AS swaps,
+    if(bought_amount > 0 AND sold_amount > 0, (sold_usd / sold_amount - bought_usd / bought_amount) * least(bought_amount, sold_amount), 0) AS realized_pnl_usd
+FROM
+(
+    SELECT
+        jetton_out AS jetton,
+        `, Symbol("jetton_out_symbol"), ` AS symbol,
+        amount_out / pow(10, jetton_out_decimals) AS amount,
+        `, UsdOutField, ` AS usd,
+        'buy' AS side
+    FROM `).Table("trades").
+		Bind(fmt.Sprint(`
+    WHERE has(?, sender) AND usd < ?
+    UNION ALL
+    SELECT
+        jetton_in AS jetton,
+        `, Symbol("jetton_in_symbol"), ` AS symbol,
+        amount_in / pow(10, jetton_in_decimals) AS amount,
+        `, UsdInField, ` AS usd,
+        'sell' AS side
+    FROM `), addresses, float64(MaxSwapUsd)).Table("trades").
+		Bind(`
+    WHERE has(?, sender) AND usd < ?
+)
+GROUP BY jetton
+ORDER BY bought_usd + sold_usd DESC`, addresses, float64(MaxSwapUsd)).
+		Limit(20).
+		Build()
+}
+
+func UserArbitragesSqlQuery(config *core.DbConfig, addresses []string) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    count() AS number,
+    sum(`, UsdField("out"), ` - `, UsdField("in"), `) AS profit_usd,
+    sum(`, UsdField("in"), `) AS volume_usd`).
+		From("arbitrages").
+		Where("has(?, sender)", addresses).
+		Where(singleSenderCondition).
+		UsdCap(MaxArbitrageUsd, fmt.Sprint(UsdField("out"), " - ", UsdField("in"))).
+		Build()
+}
+
+func UserReferralsSqlQuery(config *core.DbConfig, addresses []string) Query {
+	return NewQuery(config).Sql(`
+SELECT
+    count() AS swaps,
+    sum(`, UsdReferralField, `) AS earnings_usd`).
+		From("swaps").
+		Where("has(?, referral_address)", addresses).
+		UsdCap(MaxSwapUsd, UsdReferralField).
+		Build()
+}
+
+// ReadUserPortfolio aggregates swaps, arbitrages and referrals of the wallet given in all of its address forms
+func ReadUserPortfolio(config *core.DbConfig, address string, addresses []string, limit uint64, offset uint64) (*UserPortfolio, error) {
+	dexes, e := ReadArrayFromClickhouse[UserDexVolume](config, UserDexVolumesSqlQuery(config, addresses))
+	if e != nil {
+		return nil, e
+	}
+	jettons, e := ReadArrayFromClickhouse[UserJetton](config, UserJettonsSqlQuery(config, addresses))
+	if e != nil {
+		return nil, e
+	}
+	arbitrages, e := ReadSingleRow[UserArbitrages](config, UserArbitragesSqlQuery(config, addresses))
+	if e != nil {
+		return nil, e
+	}
+	referrals, e := ReadSingleRow[UserReferrals](config, UserReferralsSqlQuery(config, addresses))
+	if e != nil {
+		return nil, e
+	}
+	history, e := ReadArrayFromClickhouse[EnrichedSwapCH](config, UserSwapsSqlQuery(config, addresses, limit, offset))
+	if e != nil {
+		return nil, e
+	}
+
+	return newUserPortfolio(address, dexes, jettons, arbitrages, referrals, history), nil
+}
+
+// newUserPortfolio sums the totals over dexes and jettons
+func newUserPortfolio(address string, dexes []UserDexVolume, jettons []UserJetton, arbitrages *UserArbitrages, referrals *UserReferrals, history []EnrichedSwapCH) *UserPortfolio {
+	portfolio := &UserPortfolio{
+		Address:    address,
+		Dexes:      dexes,
+		Jettons:    jettons,
+		Arbitrages: arbitrages,
+		Referrals:  referrals,
+		History:    history,
+	}
+	for _, dex := range dexes {
+		portfolio.VolumeUsd += dex.VolumeUsd
+		portfolio.Swaps += dex.Swaps
+	}
+	for _, jetton := range jettons {
+		portfolio.RealizedPnlUsd += jetton.RealizedPnlUsd
+	}
+	return portfolio
 }
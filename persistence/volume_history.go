@@ -1,7 +1,6 @@
 package persistence
 
 import (
-	"fmt"
 	"math/big"
 	"time"
 	"tondexer/core"
@@ -12,20 +11,25 @@ type VolumeHistoryEntry struct {
 	Period          time.Time `json:"period" ch:"period"`
 	StonfiVolumeUsd *big.Int  `json:"stonfi_volume" ch:"stonfi_volume_usd"`
 	DedustVolumeUsd *big.Int  `json:"dedust_volume" ch:"dedust_volume_usd"`
+	ToncoVolumeUsd  *big.Int  `json:"tonco_volume" ch:"tonco_volume_usd"`
 	Number          uint64    `json:"number" ch:"number"`
 }
 
-func VolumeHistorySqlQuery(config *core.DbConfig, period models.Period, dex models.Dex) string {
-	periodParams := models.PeriodParamsMap[period]
-	return fmt.Sprint(`
+// VolumeHistorySqlQuery counts trades like the summary, so a multi-hop route adds its volume once
+func VolumeHistorySqlQuery(config *core.DbConfig, window models.Window, dex models.Dex) Query {
+	return NewQuery(config).Sql(`
 SELECT `,
-		periodParams.ToStartOf, `(time) AS period,
+		window.ToStartOf(), `(time) AS period,
     toUInt256((sumIf(`, UsdInField, `, dex = 'StonfiV1' OR dex = 'StonfiV2') + sumIf(`, UsdOutField, `, dex = 'StonfiV1' OR dex = 'StonfiV2')) / 2) AS stonfi_volume_usd,
     toUInt256((sumIf(`, UsdInField, `, dex = 'DeDust') + sumIf(`, UsdOutField, `, dex = 'DeDust')) / 2) AS dedust_volume_usd,
-    count() AS number
-FROM `, config.DbName, `.swaps
-WHERE time >= `, periodParams.ToStartOf, `(subtractDays(now(), `, periodParams.WindowInDays, `))
-AND `, dex.WhereStatement("dex"), ` AND `, UsdInField, ` < 1000000 and `, UsdOutField, ` < 1000000
-GROUP BY period
-ORDER BY period ASC WITH FILL STEP `, periodParams.ToInterval, `(1)`)
+    toUInt256((sumIf(`, UsdInField, `, dex = 'TONCO') + sumIf(`, UsdOutField, `, dex = 'TONCO')) / 2) AS tonco_volume_usd,
+    count() AS number`).
+		From("trades").
+		TimeWindow("time", window).
+		Dex("dex", dex).
+		UsdCap(MaxSwapUsd, UsdInField, UsdOutField).
+		Sql(`
+GROUP BY period`).
+		OrderByBuckets(window).
+		Build()
 }
@@ -0,0 +1,306 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"log"
+	"sync"
+	"time"
+	"tondexer/core"
+	"tondexer/spool"
+)
+
+var (
+	sharedConnections      = map[core.DbConfig]driver.Conn{}
+	sharedConnectionsMutex sync.Mutex
+)
+
+// sharedConnection returns the connection pool of the database, it is opened once and never closed
+func sharedConnection(config *core.DbConfig) (driver.Conn, error) {
+	sharedConnectionsMutex.Lock()
+	defer sharedConnectionsMutex.Unlock()
+	if conn, exists := sharedConnections[*config]; exists {
+		return conn, nil
+	}
+	conn, e := connection(config)
+	if e != nil {
+		return nil, e
+	}
+	sharedConnections[*config] = conn
+	return conn, nil
+}
+
+func insertBatch[T any](conn driver.Conn, config *core.DbConfig, entities []*T, table string, batchFunc func(driver.Batch, *T) error) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	batch, e := conn.PrepareBatch(context.Background(), fmt.Sprintf("INSERT INTO %v.%v", config.DbName, table))
+	if e != nil {
+		return e
+	}
+	for _, model := range entities {
+		if e := batchFunc(batch, model); e != nil {
+			_ = batch.Abort()
+			return e
+		}
+	}
+	start := time.Now()
+	e = batch.Send()
+	observeWrite(table, len(entities), start, e)
+	return e
+}
+
+type WriterOptions struct {
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	RetryBackoff  time.Duration
+	// QueueSize is how many full batches wait for insertion while one is being retried before Write blocks
+	QueueSize int
+	// DeadLetter keeps batches which failed all retries, they are stored with the table name as kind
+	DeadLetter *spool.Spool
+}
+
+var DefaultWriterOptions = WriterOptions{
+	BatchSize:     1000,
+	FlushInterval: 5 * time.Second,
+	MaxRetries:    5,
+	RetryBackoff:  time.Second,
+	QueueSize:     16,
+}
+
+// Writer buffers rows of a table and inserts them by size or time through the shared connection pool
+type Writer[T any] struct {
+	table   string
+	insert  func([]*T) error
+	options WriterOptions
+
+	rows    chan writeRequest[T]
+	batches chan writerBatch[T]
+	closed  chan struct{}
+	// stopping guards rows against writes racing with Close
+	stopping sync.RWMutex
+	stopped  bool
+	// abandoned makes the writer store batches as dead letters instead of inserting them
+	abandoned   chan struct{}
+	abandonOnce sync.Once
+}
+
+func NewWriter[T any](config *core.DbConfig, table string, batchFunc func(driver.Batch, *T) error, options WriterOptions) *Writer[T] {
+	return newWriter(table, func(entities []*T) error {
+		conn, e := sharedConnection(config)
+		if e != nil {
+			return e
+		}
+		return insertBatch(conn, config, entities, table, batchFunc)
+	}, options)
+}
+
+// NewBatchWriter buffers rows for an insert function such as a method of a Store, the name is the dead letter kind
+func NewBatchWriter[T any](name string, insert func([]*T) error, options WriterOptions) *Writer[T] {
+	return newWriter(name, insert, options)
+}
+
+func newWriter[T any](table string, insert func([]*T) error, options WriterOptions) *Writer[T] {
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultWriterOptions.BatchSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultWriterOptions.FlushInterval
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultWriterOptions.QueueSize
+	}
+	writer := &Writer[T]{
+		table:   table,
+		insert:  insert,
+		options: options,
+		rows:    make(chan writeRequest[T]),
+		batches: make(chan writerBatch[T], options.QueueSize),
+		closed:  make(chan struct{}),
+
+		abandoned: make(chan struct{}),
+	}
+	go writer.run()
+	go writer.insertBatches()
+	return writer
+}
+
+// writeRequest carries queued rows and the callback waiting for them
+type writeRequest[T any] struct {
+	entities []*T
+	done     func()
+}
+
+// pendingCallback runs once the first end rows of the buffer are flushed
+type pendingCallback struct {
+	end  int
+	done func()
+}
+
+// writerBatch is a batch handed over for insertion with the callbacks waiting for it
+type writerBatch[T any] struct {
+	entities []*T
+	done     []func()
+}
+
+// Write queues rows, it blocks only while the queue of full batches is full
+func (w *Writer[T]) Write(entities ...*T) {
+	if len(entities) > 0 {
+		w.send(writeRequest[T]{entities: entities})
+	}
+}
+
+// WriteThen queues rows like Write and calls done once they and everything queued before them are inserted or stored as
+// dead letters. done is called from the writer goroutine even when there are no rows
+func (w *Writer[T]) WriteThen(done func(), entities ...*T) {
+	w.send(writeRequest[T]{entities: entities, done: done})
+}
+
+// send queues a request unless the writer is closed, late rows of background jobs are dropped then
+func (w *Writer[T]) send(request writeRequest[T]) {
+	w.stopping.RLock()
+	defer w.stopping.RUnlock()
+	if w.stopped {
+		log.Printf("Warning: %v entities for closed %v writer are dropped \n", len(request.entities), w.table)
+		return
+	}
+	w.rows <- request
+}
+
+// Close inserts everything queued and waits for it, rows written afterwards are dropped
+func (w *Writer[T]) Close() {
+	w.stop()
+	<-w.closed
+}
+
+func (w *Writer[T]) stop() {
+	w.stopping.Lock()
+	defer w.stopping.Unlock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.rows)
+	}
+}
+
+// Abandon closes the writer and stores the batch being retried and everything buffered or queued as dead letters
+// instead of inserting them. It waits for them up to the timeout and reports whether they are all stored, an insert
+// which is in flight isn't interrupted
+func (w *Writer[T]) Abandon(timeout time.Duration) bool {
+	w.abandonOnce.Do(func() { close(w.abandoned) })
+	w.stop()
+	select {
+	case <-w.closed:
+		return true
+	case <-time.After(timeout):
+		log.Printf("Error: %v writer didn't store its rows within %v \n", w.table, timeout)
+		return false
+	}
+}
+
+// ReplayDeadLetters inserts batches left in the dead letter storage by previous runs
+func (w *Writer[T]) ReplayDeadLetters() error {
+	if w.options.DeadLetter == nil {
+		return nil
+	}
+	return spool.Replay(w.options.DeadLetter, w.table, w.insert)
+}
+
+// run collects rows into batches, inserting is left to insertBatches so retries don't hold up writing
+func (w *Writer[T]) run() {
+	defer close(w.batches)
+	ticker := time.NewTicker(w.options.FlushInterval)
+	defer ticker.Stop()
+
+	var buffer []*T
+	var callbacks []pendingCallback
+	// send hands over the first n rows of the buffer with everyone waiting only for them
+	send := func(n int) {
+		batch := writerBatch[T]{entities: buffer[:n]}
+		waiting := callbacks[:0]
+		for _, callback := range callbacks {
+			if callback.end <= n {
+				batch.done = append(batch.done, callback.done)
+			} else {
+				waiting = append(waiting, pendingCallback{end: callback.end - n, done: callback.done})
+			}
+		}
+		callbacks = waiting
+		buffer = buffer[n:]
+		if len(batch.entities) > 0 || len(batch.done) > 0 {
+			w.batches <- batch
+		}
+	}
+	for {
+		select {
+		case request, open := <-w.rows:
+			if !open {
+				send(len(buffer))
+				return
+			}
+			buffer = append(buffer, request.entities...)
+			if request.done != nil {
+				callbacks = append(callbacks, pendingCallback{end: len(buffer), done: request.done})
+			}
+			for len(buffer) >= w.options.BatchSize {
+				send(w.options.BatchSize)
+			}
+			if len(buffer) == 0 {
+				send(0)
+			}
+		case <-ticker.C:
+			send(len(buffer))
+		}
+	}
+}
+
+func (w *Writer[T]) insertBatches() {
+	defer close(w.closed)
+	for batch := range w.batches {
+		w.flush(batch.entities)
+		for _, done := range batch.done {
+			done()
+		}
+	}
+}
+
+func (w *Writer[T]) flush(entities []*T) {
+	if len(entities) == 0 {
+		return
+	}
+	backoff := w.options.RetryBackoff
+	for attempt := 0; !w.isAbandoned(); attempt++ {
+		e := w.insert(entities)
+		if e == nil {
+			log.Printf("Batch of %v entities has been written to %v\n", len(entities), w.table)
+			return
+		}
+		if attempt >= w.options.MaxRetries {
+			log.Printf("Unable to write %v entities to %v after %v attempts: %v \n", len(entities), w.table, attempt+1, e)
+			break
+		}
+		log.Printf("Warning: Unable to write to %v, retrying in %v: %v \n", w.table, backoff, e)
+		select {
+		case <-time.After(backoff):
+		case <-w.abandoned:
+		}
+		backoff *= 2
+	}
+	if w.options.DeadLetter == nil {
+		log.Printf("Error: %v entities for %v are dropped \n", len(entities), w.table)
+		return
+	}
+	if e := spool.Write(w.options.DeadLetter, w.table, entities); e != nil {
+		log.Printf("Error: Unable to store dead letters for %v, %v entities are dropped: %v \n", w.table, len(entities), e)
+	}
+}
+
+func (w *Writer[T]) isAbandoned() bool {
+	select {
+	case <-w.abandoned:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,117 @@
+package persistence
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"tondexer/spool"
+)
+
+type row struct {
+	Value int
+}
+
+func TestWriterBatchesBySize(t *testing.T) {
+	var batches [][]*row
+	writer := newWriter("rows", func(entities []*row) error {
+		batches = append(batches, entities)
+		return nil
+	}, WriterOptions{BatchSize: 2, FlushInterval: time.Hour})
+
+	writer.Write(&row{1}, &row{2}, &row{3})
+	writer.Write(&row{4})
+	writer.Close()
+	writer.Write(&row{5})
+	writer.Close()
+
+	assert.Equal(t, [][]*row{{{1}, {2}}, {{3}, {4}}}, batches)
+}
+
+func TestWriterRetriesAndStoresDeadLetters(t *testing.T) {
+	deadLetter, e := spool.New(t.TempDir())
+	assert.Nil(t, e)
+
+	attempts := 0
+	failing := newWriter("rows", func(entities []*row) error {
+		attempts++
+		return errors.New("clickhouse is down")
+	}, WriterOptions{BatchSize: 10, FlushInterval: time.Hour, MaxRetries: 2, RetryBackoff: time.Millisecond, DeadLetter: deadLetter})
+	failing.Write(&row{1}, &row{2})
+	failing.Close()
+	assert.Equal(t, 3, attempts)
+
+	var replayed []*row
+	recovered := newWriter("rows", func(entities []*row) error {
+		replayed = append(replayed, entities...)
+		return nil
+	}, WriterOptions{DeadLetter: deadLetter})
+	assert.Nil(t, recovered.ReplayDeadLetters())
+	recovered.Close()
+	assert.Equal(t, []*row{{1}, {2}}, replayed)
+
+	replayed = nil
+	assert.Nil(t, recovered.ReplayDeadLetters())
+	assert.Empty(t, replayed)
+}
+
+func TestWriterCallsBackAfterRowsAreWritten(t *testing.T) {
+	var written []*row
+	var confirmed []int
+	writer := newWriter("rows", func(entities []*row) error {
+		written = append(written, entities...)
+		return nil
+	}, WriterOptions{BatchSize: 2, FlushInterval: time.Hour})
+
+	writer.WriteThen(func() { confirmed = append(confirmed, len(written)) }, &row{1})
+	writer.WriteThen(func() { confirmed = append(confirmed, len(written)) }, &row{2}, &row{3})
+	writer.WriteThen(func() { confirmed = append(confirmed, len(written)) })
+	writer.Close()
+
+	assert.Equal(t, []int{2, 3, 3}, confirmed)
+
+	idle := newWriter("rows", func(entities []*row) error { return nil }, WriterOptions{FlushInterval: time.Hour})
+	called := make(chan struct{})
+	idle.WriteThen(func() { close(called) })
+	<-called
+	idle.Close()
+}
+
+func TestWriterKeepsAcceptingRowsWhileInserting(t *testing.T) {
+	release := make(chan struct{})
+	var written []*row
+	writer := newWriter("rows", func(entities []*row) error {
+		<-release
+		written = append(written, entities...)
+		return nil
+	}, WriterOptions{BatchSize: 1, FlushInterval: time.Hour, QueueSize: 4})
+
+	writer.Write(&row{1})
+	writer.Write(&row{2})
+	writer.Write(&row{3})
+	close(release)
+	writer.Close()
+
+	assert.Equal(t, []*row{{1}, {2}, {3}}, written)
+}
+
+func TestWriterAbandonStoresEverythingAsDeadLetters(t *testing.T) {
+	deadLetter, e := spool.New(t.TempDir())
+	assert.Nil(t, e)
+
+	confirmed := make(chan struct{}, 1)
+	writer := newWriter("rows", func(entities []*row) error {
+		return errors.New("clickhouse is down")
+	}, WriterOptions{BatchSize: 1, FlushInterval: time.Hour, MaxRetries: 100, RetryBackoff: time.Hour, DeadLetter: deadLetter})
+	writer.Write(&row{1})
+	writer.WriteThen(func() { confirmed <- struct{}{} }, &row{2})
+	assert.True(t, writer.Abandon(time.Second))
+	<-confirmed
+
+	var spooled []*row
+	assert.Nil(t, spool.Replay(deadLetter, "rows", func(entities []*row) error {
+		spooled = append(spooled, entities...)
+		return nil
+	}))
+	assert.ElementsMatch(t, []*row{{1}, {2}}, spooled)
+}
@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"path/filepath"
+	"testing"
+	"tondexer/fixtures"
+	"tondexer/fixtures/fixturestest"
+	"tondexer/jettons"
+	"tondexer/models"
+)
+
+// goldenCaches resolve every wallet to a jetton of the same address, so rows depend only on the trace
+var goldenCaches = &jettons.TokenCaches{
+	WalletToMaster: func(wallet string) *models.ChainTokenInfo {
+		return &models.ChainTokenInfo{Name: "Jetton", Symbol: "JET", JettonAddress: wallet, Decimals: 9}
+	},
+	Master: func(master string) *models.ChainTokenInfo {
+		return &models.ChainTokenInfo{Name: "Jetton", Symbol: "JET", JettonAddress: master, Decimals: 9}
+	},
+	UsdRate: func(master string) *float64 {
+		rate := 1.0
+		return &rate
+	},
+}
+
+// TestGoldenSwapRows converts swaps of traces recorded by the dex packages into clickhouse rows
+func TestGoldenSwapRows(t *testing.T) {
+	for _, dex := range []string{"stonfi", "stonfiv2", "dedust"} {
+		dir := filepath.Join("..", dex, fixtures.TracesDir)
+		for _, id := range fixturestest.Traces(t, dir) {
+			t.Run(dex+"/"+id, func(t *testing.T) {
+				trace, e := fixtures.ReadTrace(dir, id)
+				if e != nil {
+					t.Fatal(e)
+				}
+				fixturestest.Golden(t, dex+"/"+id, ExtractSwapsFromTrace(trace, goldenCaches))
+			})
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"github.com/tonkeeper/tonapi-go"
+	"tondexer/common"
+	"tondexer/core"
+	"tondexer/dedust"
+	"tondexer/jettons"
+	"tondexer/models"
+	"tondexer/stonfi"
+	"tondexer/stonfiv2"
+)
+
+// ExtractLiquidityEventsFromTrace runs every DEX liquidity extractor over the trace and converts the results into clickhouse models.
+func ExtractLiquidityEventsFromTrace(trace *tonapi.Trace, caches *jettons.TokenCaches) []*models.LiquidityEventCH {
+	var infos []*models.LiquidityInfo
+	infos = append(infos, stonfi.ExtractStonfiLiquidityFromRootTrace(trace)...)
+	infos = append(infos, stonfiv2.ExtractStonfiV2LiquidityFromRootTrace(trace)...)
+	infos = append(infos, dedust.ExtractDedustLiquidityFromRootTrace(trace)...)
+
+	modelsCh := common.Map(infos, func(info *models.LiquidityInfo) *models.LiquidityEventCH {
+		return models.ToChLiquidityEvent(info, caches.WalletToMaster, caches.Master, caches.UsdRate)
+	})
+	return common.Filter(modelsCh, func(ch *models.LiquidityEventCH) bool {
+		return ch != nil
+	})
+}
+
+// FilterNewLiquidityEvents drops events with any hash already present in the set and marks hashes of the remaining ones as seen.
+func FilterNewLiquidityEvents(events []*models.LiquidityEventCH, seenHashes *core.EvictableSet[string]) []*models.LiquidityEventCH {
+	newModels := common.Filter(events, func(ch *models.LiquidityEventCH) bool {
+		for _, hash := range ch.Hashes {
+			if seenHashes.Exists(hash) {
+				return false
+			}
+		}
+		return true
+	})
+	for _, event := range newModels {
+		for _, hash := range event.Hashes {
+			seenHashes.Add(hash)
+		}
+	}
+	return newModels
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"github.com/tonkeeper/tonapi-go"
+	"tondexer/common"
+	"tondexer/core"
+	"tondexer/dedust"
+	"tondexer/jettons"
+	"tondexer/models"
+	"tondexer/stonfi"
+	"tondexer/stonfiv2"
+	"tondexer/tonco"
+)
+
+func swapInfoWithDex(infos []*models.SwapInfo, dex string) []core.Pair[*models.SwapInfo, string] {
+	return common.Map(infos, func(swapInfo *models.SwapInfo) core.Pair[*models.SwapInfo, string] {
+		return core.Pair[*models.SwapInfo, string]{
+			First:  swapInfo,
+			Second: dex,
+		}
+	})
+}
+
+// ExtractSwapsFromTrace runs every DEX extractor over the trace and converts the results into clickhouse models.
+func ExtractSwapsFromTrace(trace *tonapi.Trace, caches *jettons.TokenCaches) []*models.SwapCH {
+	var modelsCh []*models.SwapCH
+
+	stonfiV1Swaps := swapInfoWithDex(stonfi.ExtractStonfiSwapsFromRootTrace(trace), models.StonfiV1)
+	stonfiV2Swaps := swapInfoWithDex(stonfiv2.ExtractStonfiV2SwapsFromRootTrace(trace), models.StonfiV2)
+	toncoSwaps := swapInfoWithDex(tonco.ExtractToncoSwapsFromRootTrace(trace), models.TONCO)
+
+	dedustSwaps := dedust.ExtractDedustSwapsFromRootTrace(trace)
+
+	modelsCh = append(modelsCh, common.Map(stonfiV1Swaps, func(pair core.Pair[*models.SwapInfo, string]) *models.SwapCH {
+		return models.ToChSwap(pair.First, pair.Second, caches.WalletToMaster, caches.UsdRate)
+	})...)
+
+	modelsCh = append(modelsCh, common.Map(stonfiV2Swaps, func(pair core.Pair[*models.SwapInfo, string]) *models.SwapCH {
+		return models.ToChSwap(pair.First, pair.Second, caches.WalletToMaster, caches.UsdRate)
+	})...)
+
+	modelsCh = append(modelsCh, common.Map(toncoSwaps, func(pair core.Pair[*models.SwapInfo, string]) *models.SwapCH {
+		return models.ToChSwap(pair.First, pair.Second, caches.WalletToMaster, caches.UsdRate)
+	})...)
+
+	for _, dedustSwap := range dedustSwaps {
+		modelsCh = append(modelsCh, models.DedustSwapInfoToChSwap(dedustSwap, caches.WalletToMaster, caches.Master, caches.UsdRate)...)
+	}
+
+	return common.Filter(modelsCh, func(ch *models.SwapCH) bool {
+		return ch != nil
+	})
+}
+
+// FilterNewSwaps drops swaps with any hash already present in the set and marks hashes of the remaining ones as seen.
+func FilterNewSwaps(swaps []*models.SwapCH, seenHashes *core.EvictableSet[string]) []*models.SwapCH {
+	newModels := common.Filter(swaps, func(ch *models.SwapCH) bool {
+		contains := false
+		for _, hash := range ch.Hashes {
+			if seenHashes.Exists(hash) {
+				contains = true
+			}
+		}
+		return !contains
+	})
+	for _, swap := range newModels {
+		for _, hash := range swap.Hashes {
+			seenHashes.Add(hash)
+		}
+	}
+	return newModels
+}
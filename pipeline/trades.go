@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"tondexer/models"
+)
+
+// tradeKey groups hops of one route, swaps without a trace id are trades on their own
+func tradeKey(swap *models.SwapCH) string {
+	if swap.TraceID == "" {
+		return fmt.Sprint(swap.Dex, ":", swap.PoolAddress, ":", swap.Lt, ":", strings.Join(swap.Hashes, ","))
+	}
+	return fmt.Sprint(swap.Dex, ":", swap.TraceID)
+}
+
+// chained tells whether every hop takes the jetton the previous one paid out
+func chained(hops []*models.SwapCH) bool {
+	for i := 1; i < len(hops); i++ {
+		if hops[i-1].JettonOut != hops[i].JettonIn {
+			return false
+		}
+	}
+	return true
+}
+
+func newTrade(hops []*models.SwapCH) *models.TradeCH {
+	first, last := hops[0], hops[len(hops)-1]
+	trade := &models.TradeCH{
+		Dex:                  first.Dex,
+		TraceID:              first.TraceID,
+		Sender:               first.Sender,
+		Lt:                   first.Lt,
+		Time:                 first.Time,
+		JettonIn:             first.JettonIn,
+		AmountIn:             first.AmountIn,
+		JettonInSymbol:       first.JettonInSymbol,
+		JettonInName:         first.JettonInName,
+		JettonInUsdRate:      first.JettonInUsdRate,
+		JettonInDecimals:     first.JettonInDecimals,
+		JettonInPriceSource:  first.JettonInPriceSource,
+		JettonOut:            last.JettonOut,
+		AmountOut:            last.AmountOut,
+		JettonOutSymbol:      last.JettonOutSymbol,
+		JettonOutName:        last.JettonOutName,
+		JettonOutUsdRate:     last.JettonOutUsdRate,
+		JettonOutDecimals:    last.JettonOutDecimals,
+		JettonOutPriceSource: last.JettonOutPriceSource,
+		JettonsPath:          []string{first.JettonIn},
+		CatchTime:            first.CatchTime,
+	}
+	for _, hop := range hops {
+		trade.Hashes = append(trade.Hashes, hop.Hashes...)
+		trade.PoolsPath = append(trade.PoolsPath, hop.PoolAddress)
+		trade.JettonsPath = append(trade.JettonsPath, hop.JettonOut)
+	}
+	return trade
+}
+
+// BuildTrades links hops of a trace on a dex into one trade when, ordered by lt, each hop takes the jetton the previous
+// one paid out. Otherwise the hops are parallel swaps and every one of them is a trade.
+// Migration 0008_trades fills the table from stored swaps by the same rule
+func BuildTrades(swaps []*models.SwapCH) []*models.TradeCH {
+	var keys []string
+	routes := map[string][]*models.SwapCH{}
+	for _, swap := range swaps {
+		key := tradeKey(swap)
+		if _, exists := routes[key]; !exists {
+			keys = append(keys, key)
+		}
+		routes[key] = append(routes[key], swap)
+	}
+
+	var trades []*models.TradeCH
+	for _, key := range keys {
+		hops := slices.SortedStableFunc(slices.Values(routes[key]), func(a, b *models.SwapCH) int { return cmp.Compare(a.Lt, b.Lt) })
+		if chained(hops) {
+			trades = append(trades, newTrade(hops))
+			continue
+		}
+		for _, hop := range hops {
+			trades = append(trades, newTrade([]*models.SwapCH{hop}))
+		}
+	}
+	return trades
+}
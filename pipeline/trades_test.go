@@ -0,0 +1,43 @@
+package pipeline
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"tondexer/models"
+)
+
+func hop(dex string, trace string, lt uint64, jettonIn string, amountIn int64, jettonOut string, amountOut int64) *models.SwapCH {
+	return &models.SwapCH{
+		Dex: dex, TraceID: trace, Lt: lt, Hashes: []string{trace + jettonIn}, PoolAddress: jettonIn + "-" + jettonOut, Sender: "user",
+		JettonIn: jettonIn, AmountIn: big.NewInt(amountIn), JettonInUsdRate: 5,
+		JettonOut: jettonOut, AmountOut: big.NewInt(amountOut), JettonOutUsdRate: 1,
+	}
+}
+
+func TestBuildTrades(t *testing.T) {
+	trades := BuildTrades([]*models.SwapCH{
+		hop(models.StonfiV2, "route", 20, "usdt", 50, "not", 900),
+		hop(models.StonfiV2, "route", 10, "ton", 10, "usdt", 50),
+		hop(models.DeDust, "route", 30, "not", 900, "ton", 9),
+		hop(models.StonfiV1, "parallel", 10, "ton", 10, "usdt", 50),
+		hop(models.StonfiV1, "parallel", 11, "ton", 20, "usdt", 100),
+	})
+
+	assert.Equal(t, 4, len(trades))
+	route := trades[0]
+	assert.Equal(t, "ton", route.JettonIn)
+	assert.Equal(t, big.NewInt(10), route.AmountIn)
+	assert.Equal(t, 5.0, route.JettonInUsdRate)
+	assert.Equal(t, "not", route.JettonOut)
+	assert.Equal(t, big.NewInt(900), route.AmountOut)
+	assert.Equal(t, uint64(10), route.Lt)
+	assert.Equal(t, []string{"ton-usdt", "usdt-not"}, route.PoolsPath)
+	assert.Equal(t, []string{"ton", "usdt", "not"}, route.JettonsPath)
+	assert.Equal(t, []string{"routeton", "routeusdt"}, route.Hashes)
+
+	assert.Equal(t, models.DeDust, trades[1].Dex)
+	assert.Equal(t, []string{"not", "ton"}, trades[1].JettonsPath)
+	assert.Equal(t, big.NewInt(10), trades[2].AmountIn)
+	assert.Equal(t, big.NewInt(20), trades[3].AmountIn)
+}
@@ -0,0 +1,268 @@
+package pools
+
+import (
+	"context"
+	"fmt"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/ton"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+	"tondexer/core"
+	"tondexer/dedust"
+	"tondexer/jettons"
+	"tondexer/models"
+	"tondexer/persistence"
+)
+
+const getMethodRetries = 4
+
+// snapshotDexes are the dexes whose pool state poolState decodes, TONCO pools have concentrated liquidity and are skipped
+var snapshotDexes = []string{models.StonfiV1, models.StonfiV2, models.DeDust}
+
+type SnapshotCollector struct {
+	DbConfig    *core.DbConfig
+	TonApi      *jettons.TonApi
+	TokenCaches *jettons.TokenCaches
+	// ActiveWithin limits snapshots to pools with swaps in the period
+	ActiveWithin time.Duration
+	// Workers is the number of pools whose state is fetched at once
+	Workers int
+}
+
+func addressAt(res *ton.ExecutionResult, index uint) (*address.Address, error) {
+	slice, e := res.Slice(index)
+	if e != nil {
+		return nil, e
+	}
+	return slice.LoadAddr()
+}
+
+func (c *SnapshotCollector) lpSupply(block *ton.BlockIDExt, pool *address.Address) (*big.Int, error) {
+	res, e := c.TonApi.RunGetMethodRetries(context.Background(), block, pool, "get_jetton_data", getMethodRetries)
+	if e != nil {
+		return nil, e
+	}
+	return res.Int(0)
+}
+
+// decodeStonfiV1PoolData reads get_pool_data of a Ston.fi v1 pool: reserve0, reserve1, token0 wallet, token1 wallet, ...
+func decodeStonfiV1PoolData(pool *address.Address, res *ton.ExecutionResult) (*models.PoolState, error) {
+	reserve0, e := res.Int(0)
+	if e != nil {
+		return nil, e
+	}
+	reserve1, e := res.Int(1)
+	if e != nil {
+		return nil, e
+	}
+	wallet0, e := addressAt(res, 2)
+	if e != nil {
+		return nil, e
+	}
+	wallet1, e := addressAt(res, 3)
+	if e != nil {
+		return nil, e
+	}
+	return &models.PoolState{
+		Dex:         models.StonfiV1,
+		PoolAddress: pool,
+		Asset0:      &models.LiquidityAsset{Wallet: wallet0, Amount: reserve0},
+		Asset1:      &models.LiquidityAsset{Wallet: wallet1, Amount: reserve1},
+	}, nil
+}
+
+// decodeStonfiV2PoolData reads get_pool_data of a Ston.fi v2 pool: is_locked, router, total_supply, reserve0, reserve1,
+// token0 wallet, token1 wallet, ...
+func decodeStonfiV2PoolData(pool *address.Address, res *ton.ExecutionResult) (*models.PoolState, error) {
+	lpSupply, e := res.Int(2)
+	if e != nil {
+		return nil, e
+	}
+	reserve0, e := res.Int(3)
+	if e != nil {
+		return nil, e
+	}
+	reserve1, e := res.Int(4)
+	if e != nil {
+		return nil, e
+	}
+	wallet0, e := addressAt(res, 5)
+	if e != nil {
+		return nil, e
+	}
+	wallet1, e := addressAt(res, 6)
+	if e != nil {
+		return nil, e
+	}
+	return &models.PoolState{
+		Dex:         models.StonfiV2,
+		PoolAddress: pool,
+		Asset0:      &models.LiquidityAsset{Wallet: wallet0, Amount: reserve0},
+		Asset1:      &models.LiquidityAsset{Wallet: wallet1, Amount: reserve1},
+		LpSupply:    lpSupply,
+	}, nil
+}
+
+// decodeDedustPoolData reads get_reserves and get_assets of a DeDust pool, assets are native or jetton masters
+func decodeDedustPoolData(pool *address.Address, reserves *ton.ExecutionResult, assets *ton.ExecutionResult) (*models.PoolState, error) {
+	reserve0, e := reserves.Int(0)
+	if e != nil {
+		return nil, e
+	}
+	reserve1, e := reserves.Int(1)
+	if e != nil {
+		return nil, e
+	}
+	assetSlice0, e := assets.Slice(0)
+	if e != nil {
+		return nil, e
+	}
+	master0, e := dedust.LoadAsset(assetSlice0)
+	if e != nil {
+		return nil, e
+	}
+	assetSlice1, e := assets.Slice(1)
+	if e != nil {
+		return nil, e
+	}
+	master1, e := dedust.LoadAsset(assetSlice1)
+	if e != nil {
+		return nil, e
+	}
+	return &models.PoolState{
+		Dex:         models.DeDust,
+		PoolAddress: pool,
+		Asset0:      &models.LiquidityAsset{Master: master0, Amount: reserve0},
+		Asset1:      &models.LiquidityAsset{Master: master1, Amount: reserve1},
+	}, nil
+}
+
+func (c *SnapshotCollector) stonfiV1State(block *ton.BlockIDExt, pool *address.Address) (*models.PoolState, error) {
+	res, e := c.TonApi.RunGetMethodRetries(context.Background(), block, pool, "get_pool_data", getMethodRetries)
+	if e != nil {
+		return nil, e
+	}
+	state, e := decodeStonfiV1PoolData(pool, res)
+	if e != nil {
+		return nil, e
+	}
+	if state.LpSupply, e = c.lpSupply(block, pool); e != nil {
+		return nil, e
+	}
+	return state, nil
+}
+
+func (c *SnapshotCollector) stonfiV2State(block *ton.BlockIDExt, pool *address.Address) (*models.PoolState, error) {
+	res, e := c.TonApi.RunGetMethodRetries(context.Background(), block, pool, "get_pool_data", getMethodRetries)
+	if e != nil {
+		return nil, e
+	}
+	return decodeStonfiV2PoolData(pool, res)
+}
+
+func (c *SnapshotCollector) dedustState(block *ton.BlockIDExt, pool *address.Address) (*models.PoolState, error) {
+	reserves, e := c.TonApi.RunGetMethodRetries(context.Background(), block, pool, "get_reserves", getMethodRetries)
+	if e != nil {
+		return nil, e
+	}
+	assets, e := c.TonApi.RunGetMethodRetries(context.Background(), block, pool, "get_assets", getMethodRetries)
+	if e != nil {
+		return nil, e
+	}
+	state, e := decodeDedustPoolData(pool, reserves, assets)
+	if e != nil {
+		return nil, e
+	}
+	if state.LpSupply, e = c.lpSupply(block, pool); e != nil {
+		return nil, e
+	}
+	return state, nil
+}
+
+func (c *SnapshotCollector) poolState(block *ton.BlockIDExt, pool *address.Address, dex string) (*models.PoolState, error) {
+	switch dex {
+	case models.StonfiV1:
+		return c.stonfiV1State(block, pool)
+	case models.StonfiV2:
+		return c.stonfiV2State(block, pool)
+	case models.DeDust:
+		return c.dedustState(block, pool)
+	default:
+		return nil, fmt.Errorf("unsupported dex %v", dex)
+	}
+}
+
+// inParallel calls fetch for every pool on at most workers goroutines and returns the non-nil results in no particular order
+func inParallel[T any](knownPools []persistence.KnownPool, workers int, fetch func(persistence.KnownPool) *T) []*T {
+	jobs := make(chan persistence.KnownPool)
+	var mutex sync.Mutex
+	var results []*T
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for knownPool := range jobs {
+				if result := fetch(knownPool); result != nil {
+					mutex.Lock()
+					results = append(results, result)
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	for _, knownPool := range knownPools {
+		jobs <- knownPool
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// Collect reads reserves of every pool of snapshotDexes with recent swaps and saves them as snapshots
+func (c *SnapshotCollector) Collect() error {
+	snapshotTime := time.Now()
+	query := persistence.KnownPoolsSqlQuery(c.DbConfig, snapshotDexes, snapshotTime.Add(-c.ActiveWithin))
+	knownPools, e := persistence.ReadArrayFromClickhouse[persistence.KnownPool](c.DbConfig, query)
+	if e != nil {
+		return e
+	}
+	block, e := (*c.TonApi.Api).CurrentMasterchainInfo(context.Background())
+	if e != nil {
+		return e
+	}
+
+	snapshots := inParallel(knownPools, c.Workers, func(knownPool persistence.KnownPool) *models.PoolSnapshotCH {
+		pool, e := address.ParseAddr(knownPool.PoolAddress)
+		if e != nil {
+			log.Printf("Invalid pool address %v: %v \n", knownPool.PoolAddress, e)
+			return nil
+		}
+		state, e := c.poolState(block, pool, knownPool.Dex)
+		if e != nil {
+			log.Printf("Unable to get state of pool %v: %v \n", knownPool.PoolAddress, e)
+			return nil
+		}
+		state.Time = snapshotTime
+		return models.ToChPoolSnapshot(state, c.TokenCaches.WalletToMaster, c.TokenCaches.Master, c.TokenCaches.UsdRate)
+	})
+	log.Printf("Collected %v of %v pool snapshots \n", len(snapshots), len(knownPools))
+	return persistence.WritePoolSnapshotsToClickhouse(c.DbConfig, snapshots)
+}
+
+func (c *SnapshotCollector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if e := c.Collect(); e != nil {
+			log.Printf("Warning: Unable to collect pool snapshots %v\n", e)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
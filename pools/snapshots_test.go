@@ -0,0 +1,114 @@
+package pools
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tondexer/models"
+	"tondexer/persistence"
+)
+
+// the stacks below follow the get method layouts of the pool contracts
+var (
+	pool     = address.MustParseAddr("EQD8TJ8xEWB1SpnRE4d89YO3jl0W0EiBnNS4IBaHaUmdfizE")
+	router   = address.MustParseAddr("EQB3ncyBUTjZUA5EnFKR5_EnOMI9V1tTEAAPaiU71gc4TiUt")
+	wallet0  = address.MustParseAddr("EQARULUYsmJq1RiZ-YiH-IJLcAZUVkVff-KBPwEmmaQGH6aC")
+	wallet1  = address.MustParseAddr("EQCM3B12QK1e4yZSf8GtBRT0aLMNyEsBc_DhVfRRtOEffLez")
+	feeOwner = address.MustParseAddr("EQCtiv7PrMJImWiF2L5oJCgPnzp-VML2CAt5cbn1VsKAxLiE")
+)
+
+func addressSlice(addr *address.Address) *cell.Slice {
+	return cell.BeginCell().MustStoreAddr(addr).EndCell().BeginParse()
+}
+
+func TestDecodeStonfiV1PoolData(t *testing.T) {
+	res := ton.NewExecutionResult([]any{
+		big.NewInt(1_500_000_000_000), big.NewInt(7_300_000_000),
+		addressSlice(wallet0), addressSlice(wallet1),
+		big.NewInt(20), big.NewInt(10), big.NewInt(10),
+		addressSlice(feeOwner), big.NewInt(0), big.NewInt(0),
+	})
+
+	state, e := decodeStonfiV1PoolData(pool, res)
+	assert.Nil(t, e)
+	assert.Equal(t, models.StonfiV1, state.Dex)
+	assert.Equal(t, pool, state.PoolAddress)
+	assert.Equal(t, wallet0.String(), state.Asset0.Wallet.String())
+	assert.Equal(t, big.NewInt(1_500_000_000_000), state.Asset0.Amount)
+	assert.Equal(t, wallet1.String(), state.Asset1.Wallet.String())
+	assert.Equal(t, big.NewInt(7_300_000_000), state.Asset1.Amount)
+	assert.Nil(t, state.LpSupply)
+}
+
+func TestDecodeStonfiV2PoolData(t *testing.T) {
+	res := ton.NewExecutionResult([]any{
+		big.NewInt(0), addressSlice(router), big.NewInt(3_300_000_000),
+		big.NewInt(1_500_000_000_000), big.NewInt(7_300_000_000),
+		addressSlice(wallet0), addressSlice(wallet1),
+		big.NewInt(20), big.NewInt(10), addressSlice(feeOwner), big.NewInt(0), big.NewInt(0),
+	})
+
+	state, e := decodeStonfiV2PoolData(pool, res)
+	assert.Nil(t, e)
+	assert.Equal(t, models.StonfiV2, state.Dex)
+	assert.Equal(t, wallet0.String(), state.Asset0.Wallet.String())
+	assert.Equal(t, big.NewInt(1_500_000_000_000), state.Asset0.Amount)
+	assert.Equal(t, wallet1.String(), state.Asset1.Wallet.String())
+	assert.Equal(t, big.NewInt(7_300_000_000), state.Asset1.Amount)
+	assert.Equal(t, big.NewInt(3_300_000_000), state.LpSupply)
+
+	// a v1 stack starts with reserves instead of the lock flag and the router
+	_, e = decodeStonfiV2PoolData(pool, ton.NewExecutionResult([]any{big.NewInt(1), big.NewInt(2), addressSlice(wallet0), addressSlice(wallet1)}))
+	assert.NotNil(t, e)
+}
+
+func TestDecodeDedustPoolData(t *testing.T) {
+	master := address.MustParseAddr("EQCxE6mUtQJKFnGfaROTKOt1lZbDiiX1kCixRv7Nw2Id_sDs")
+	native := cell.BeginCell().MustStoreUInt(0, 4).EndCell().BeginParse()
+	jetton := cell.BeginCell().MustStoreUInt(1, 4).MustStoreInt(0, 8).MustStoreSlice(master.Data(), 256).EndCell().BeginParse()
+	reserves := ton.NewExecutionResult([]any{big.NewInt(2_000_000_000_000), big.NewInt(9_100_000_000)})
+	assets := ton.NewExecutionResult([]any{native, jetton})
+
+	state, e := decodeDedustPoolData(pool, reserves, assets)
+	assert.Nil(t, e)
+	assert.Equal(t, models.DeDust, state.Dex)
+	assert.Nil(t, state.Asset0.Master)
+	assert.Equal(t, big.NewInt(2_000_000_000_000), state.Asset0.Amount)
+	assert.Equal(t, master.String(), state.Asset1.Master.String())
+	assert.Equal(t, big.NewInt(9_100_000_000), state.Asset1.Amount)
+
+	_, e = decodeDedustPoolData(pool, reserves, ton.NewExecutionResult([]any{native, cell.BeginCell().MustStoreUInt(2, 4).EndCell().BeginParse()}))
+	assert.NotNil(t, e)
+}
+
+func TestInParallelBoundsWorkers(t *testing.T) {
+	var knownPools []persistence.KnownPool
+	for i := 0; i < 20; i++ {
+		knownPools = append(knownPools, persistence.KnownPool{PoolAddress: fmt.Sprint(i)})
+	}
+	var running, most atomic.Int32
+	results := inParallel(knownPools, 3, func(knownPool persistence.KnownPool) *string {
+		now := running.Add(1)
+		defer running.Add(-1)
+		for {
+			seen := most.Load()
+			if now <= seen || most.CompareAndSwap(seen, now) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		if knownPool.PoolAddress == "0" {
+			return nil
+		}
+		return &knownPool.PoolAddress
+	})
+
+	assert.Equal(t, 19, len(results))
+	assert.LessOrEqual(t, most.Load(), int32(3))
+}
@@ -0,0 +1,290 @@
+package pricing
+
+import (
+	"log"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+	"tondexer/common"
+	"tondexer/core"
+	"tondexer/jettons"
+	"tondexer/models"
+	"tondexer/persistence"
+)
+
+const UsdtMaster = "EQCxE6mUtQJKFnGfaROTKOt1lZbDiiX1kCixRv7Nw2Id_sDs"
+
+// Price of the jetton, zero Usd with empty Source means the price is unknown
+type Price struct {
+	Ton    float64
+	Usd    float64
+	Source string
+}
+
+type observation struct {
+	time      time.Time
+	jettonIn  string
+	amountIn  float64
+	jettonOut string
+	amountOut float64
+}
+
+type pairKey struct {
+	jetton0 string
+	jetton1 string
+}
+
+type pairVolume struct {
+	amount0 float64
+	amount1 float64
+	count   int
+}
+
+// Engine derives prices from volume weighted swap rates against TON and USDT pools,
+// it falls back to the tonapi rate when the jetton has no liquid route
+type Engine struct {
+	Window       time.Duration
+	MinSwaps     int
+	MinTonVolume float64
+	// MaxObservations bounds the swaps kept for a pair of jettons, the newest ones are kept
+	MaxObservations int
+	// Fallback may call tonapi, it is never called under the mutex
+	Fallback func(master string) *float64
+
+	mutex        sync.Mutex
+	observations []observation
+	latest       time.Time
+	prices       map[string]Price
+	dirty        bool
+}
+
+func NewEngine(window time.Duration, fallback func(master string) *float64) *Engine {
+	return &Engine{
+		Window:          window,
+		MinSwaps:        3,
+		MinTonVolume:    100,
+		MaxObservations: 1000,
+		Fallback:        fallback,
+		prices:          map[string]Price{},
+	}
+}
+
+func normalizedAmount(amount *big.Int, decimals uint64) float64 {
+	if amount == nil {
+		return 0
+	}
+	result, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), big.NewFloat(math.Pow10(int(decimals)))).Float64()
+	return result
+}
+
+func key(jettonA string, jettonB string) (pairKey, bool) {
+	if jettonA < jettonB {
+		return pairKey{jettonA, jettonB}, false
+	}
+	return pairKey{jettonB, jettonA}, true
+}
+
+// Observe adds swaps to the price window
+func (e *Engine) Observe(swaps []*models.SwapCH) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, swap := range swaps {
+		if swap.JettonIn == "" || swap.JettonOut == "" {
+			continue
+		}
+		jettonIn, jettonOut := jettons.Contract(swap.JettonIn), jettons.Contract(swap.JettonOut)
+		if jettonIn == jettonOut {
+			continue
+		}
+		amountIn := normalizedAmount(swap.AmountIn, swap.JettonInDecimals)
+		amountOut := normalizedAmount(swap.AmountOut, swap.JettonOutDecimals)
+		if amountIn <= 0 || amountOut <= 0 {
+			continue
+		}
+		e.observations = append(e.observations, observation{
+			time:      swap.Time,
+			jettonIn:  jettonIn,
+			amountIn:  amountIn,
+			jettonOut: jettonOut,
+			amountOut: amountOut,
+		})
+		if swap.Time.After(e.latest) {
+			e.latest = swap.Time
+		}
+		e.dirty = true
+	}
+	e.evict()
+}
+
+// evict drops observations out of the window and the oldest ones above the limit of their pair
+func (e *Engine) evict() {
+	from := e.latest.Add(-e.Window)
+	counts := map[pairKey]int{}
+	keep := make([]bool, len(e.observations))
+	for i := len(e.observations) - 1; i >= 0; i-- {
+		o := e.observations[i]
+		k, _ := key(o.jettonIn, o.jettonOut)
+		if !o.time.Before(from) && counts[k] < e.MaxObservations {
+			counts[k]++
+			keep[i] = true
+		}
+	}
+	var kept []observation
+	for i, o := range e.observations {
+		if keep[i] {
+			kept = append(kept, o)
+		}
+	}
+	e.observations = kept
+}
+
+// recalculate expects the TON fallback rate to be read before the mutex was taken
+func (e *Engine) recalculate(tonFallback *float64) {
+	e.evict()
+
+	volumes := map[pairKey]*pairVolume{}
+	for _, o := range e.observations {
+		k, reversed := key(o.jettonIn, o.jettonOut)
+		volume, exists := volumes[k]
+		if !exists {
+			volume = &pairVolume{}
+			volumes[k] = volume
+		}
+		if reversed {
+			volume.amount0 += o.amountOut
+			volume.amount1 += o.amountIn
+		} else {
+			volume.amount0 += o.amountIn
+			volume.amount1 += o.amountOut
+		}
+		volume.count++
+	}
+
+	// amounts of the jetton and the anchor
+	anchorVolume := func(jetton string, anchor string) (float64, float64, bool) {
+		k, reversed := key(jetton, anchor)
+		volume, exists := volumes[k]
+		if !exists || volume.count < e.MinSwaps {
+			return 0, 0, false
+		}
+		if reversed {
+			return volume.amount1, volume.amount0, true
+		}
+		return volume.amount0, volume.amount1, true
+	}
+
+	prices := map[string]Price{}
+
+	tonUsd, usdt, fromSwaps := anchorVolume(models.TonMaster, UsdtMaster)
+	if fromSwaps && tonUsd >= e.MinTonVolume {
+		prices[models.TonMaster] = Price{Ton: 1, Usd: usdt / tonUsd, Source: models.PriceSourceSwaps}
+	} else if tonFallback != nil && *tonFallback > 0 {
+		prices[models.TonMaster] = Price{Ton: 1, Usd: *tonFallback, Source: models.PriceSourceTonapi}
+	} else {
+		// no way to convert TON prices into USD, until new swaps arrive
+		e.prices = prices
+		e.dirty = false
+		return
+	}
+	tonPrice := prices[models.TonMaster].Usd
+
+	jettonSet := map[string]bool{}
+	for k := range volumes {
+		jettonSet[k.jetton0] = true
+		jettonSet[k.jetton1] = true
+	}
+	for jetton := range jettonSet {
+		if jetton == models.TonMaster {
+			continue
+		}
+		// each liquid anchor contributes proportionally to its volume in TON
+		var weightedTonPrice, tonVolume float64
+		if jettonAmount, tonAmount, liquid := anchorVolume(jetton, models.TonMaster); liquid && tonAmount >= e.MinTonVolume {
+			weightedTonPrice += tonAmount / jettonAmount * tonAmount
+			tonVolume += tonAmount
+		}
+		if jettonAmount, usdtAmount, liquid := anchorVolume(jetton, UsdtMaster); liquid && usdtAmount/tonPrice >= e.MinTonVolume {
+			usdtInTon := usdtAmount / tonPrice
+			weightedTonPrice += usdtInTon / jettonAmount * usdtInTon
+			tonVolume += usdtInTon
+		}
+		if tonVolume > 0 {
+			priceInTon := weightedTonPrice / tonVolume
+			prices[jetton] = Price{Ton: priceInTon, Usd: priceInTon * tonPrice, Source: models.PriceSourceSwaps}
+		}
+	}
+	e.prices = prices
+	e.dirty = false
+}
+
+// Price returns the swap derived price of the jetton or the fallback rate
+func (e *Engine) Price(master string) Price {
+	master = jettons.Contract(master)
+
+	e.mutex.Lock()
+	dirty := e.dirty
+	e.mutex.Unlock()
+	var tonFallback *float64
+	if dirty {
+		tonFallback = e.Fallback(models.TonMaster)
+	}
+
+	e.mutex.Lock()
+	// swaps observed after the first check are priced by the next call, the fallback wasn't read for them
+	if dirty && e.dirty {
+		e.recalculate(tonFallback)
+	}
+	price, exists := e.prices[master]
+	tonPrice := e.prices[models.TonMaster]
+	e.mutex.Unlock()
+
+	if exists {
+		return price
+	}
+	if rate := e.Fallback(master); rate != nil {
+		result := Price{Usd: *rate, Source: models.PriceSourceTonapi}
+		if tonPrice.Usd > 0 {
+			result.Ton = *rate / tonPrice.Usd
+		}
+		return result
+	}
+	return Price{}
+}
+
+// UsdRate has the same signature as the rate cache, so it can replace it
+func (e *Engine) UsdRate(master string) *float64 {
+	price := e.Price(master)
+	if price.Source == "" {
+		return nil
+	}
+	return &price.Usd
+}
+
+// ApplyPrices sets usd rates and price sources of the swaps
+func (e *Engine) ApplyPrices(swaps []*models.SwapCH) {
+	for _, swap := range swaps {
+		if swap.JettonIn != "" {
+			price := e.Price(swap.JettonIn)
+			swap.JettonInUsdRate, swap.JettonInPriceSource = price.Usd, price.Source
+		}
+		if swap.JettonOut != "" {
+			price := e.Price(swap.JettonOut)
+			swap.JettonOutUsdRate, swap.JettonOutPriceSource = price.Usd, price.Source
+		}
+	}
+}
+
+// Warmup observes swaps stored within the window
+func (e *Engine) Warmup(config *core.DbConfig) error {
+	swaps, err := persistence.ReadArrayFromClickhouse[models.SwapCH](config, persistence.RecentSwapAmountsSqlQuery(config, e.Window))
+	if err != nil {
+		return err
+	}
+	e.Observe(common.Map(swaps, func(swap models.SwapCH) *models.SwapCH {
+		return &swap
+	}))
+	log.Printf("Price engine is warmed up with %v swaps \n", len(swaps))
+	return nil
+}
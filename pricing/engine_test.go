@@ -0,0 +1,102 @@
+package pricing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+	"tondexer/models"
+)
+
+const testJetton = "EQA2kCVNwVsil2EM2mB0SkXytxCqQjS4mttjDpnXmwG9T6bO"
+const illiquidJetton = "EQBlqsm144Dq6SjbPI4jjZvA1hqTIP3CvHovbIfW_t-SCALE"
+
+func swap(jettonIn string, amountIn int64, jettonOut string, amountOut int64) *models.SwapCH {
+	return &models.SwapCH{
+		Time:              time.Unix(1700000000, 0),
+		JettonIn:          jettonIn,
+		AmountIn:          new(big.Int).Mul(big.NewInt(amountIn), big.NewInt(1_000_000_000)),
+		JettonInDecimals:  9,
+		JettonOut:         jettonOut,
+		AmountOut:         new(big.Int).Mul(big.NewInt(amountOut), big.NewInt(1_000_000_000)),
+		JettonOutDecimals: 9,
+	}
+}
+
+func TestEngineDerivesPricesFromSwaps(t *testing.T) {
+	fallbackRate := 0.5
+	engine := NewEngine(time.Hour, func(master string) *float64 {
+		if master == illiquidJetton {
+			return &fallbackRate
+		}
+		return nil
+	})
+
+	var swaps []*models.SwapCH
+	for i := 0; i < 3; i++ {
+		swaps = append(swaps, swap(models.TonMaster, 100, UsdtMaster, 500))
+		swaps = append(swaps, swap(testJetton, 1000, models.TonMaster, 200))
+	}
+	swaps = append(swaps, swap(illiquidJetton, 10, models.TonMaster, 1))
+	engine.Observe(swaps)
+
+	tonPrice := engine.Price(models.TonMaster)
+	assert.Equal(t, models.PriceSourceSwaps, tonPrice.Source)
+	assert.InDelta(t, 5.0, tonPrice.Usd, 0.0001)
+
+	jettonPrice := engine.Price(testJetton)
+	assert.Equal(t, models.PriceSourceSwaps, jettonPrice.Source)
+	assert.InDelta(t, 0.2, jettonPrice.Ton, 0.0001)
+	assert.InDelta(t, 1.0, jettonPrice.Usd, 0.0001)
+
+	illiquidPrice := engine.Price(illiquidJetton)
+	assert.Equal(t, models.PriceSourceTonapi, illiquidPrice.Source)
+	assert.InDelta(t, 0.5, illiquidPrice.Usd, 0.0001)
+
+	unknown := swap(testJetton, 1, "EQD0vdSA_NedR9uvbgN9EikRX-suesDxGeFg69XQMavfLqIw", 1)
+	engine.ApplyPrices([]*models.SwapCH{unknown})
+	assert.Equal(t, models.PriceSourceSwaps, unknown.JettonInPriceSource)
+	assert.InDelta(t, 1.0, unknown.JettonInUsdRate, 0.0001)
+	assert.Equal(t, "", unknown.JettonOutPriceSource)
+	assert.Equal(t, 0.0, unknown.JettonOutUsdRate)
+}
+
+func TestEngineDoesNotRecalculateWithoutTonPrice(t *testing.T) {
+	tonLookups := 0
+	engine := NewEngine(time.Hour, func(master string) *float64 {
+		if master == models.TonMaster {
+			tonLookups++
+		}
+		return nil
+	})
+	engine.Observe([]*models.SwapCH{swap(testJetton, 1000, models.TonMaster, 200)})
+
+	assert.Equal(t, "", engine.Price(testJetton).Source)
+	assert.Equal(t, "", engine.Price(testJetton).Source)
+	assert.Equal(t, 1, tonLookups)
+}
+
+func TestEngineReadsFallbackOutsideOfTheLock(t *testing.T) {
+	var engine *Engine
+	engine = NewEngine(time.Hour, func(master string) *float64 {
+		// it would deadlock if the fallback was called under the mutex
+		engine.Observe(nil)
+		rate := 5.0
+		return &rate
+	})
+	engine.Observe([]*models.SwapCH{swap(testJetton, 1000, models.TonMaster, 200)})
+
+	assert.Equal(t, models.PriceSourceTonapi, engine.Price(models.TonMaster).Source)
+}
+
+func TestEngineKeepsNewestObservationsOfAPair(t *testing.T) {
+	engine := NewEngine(time.Hour, func(string) *float64 { return nil })
+	engine.MaxObservations = 2
+	for i := 0; i < 5; i++ {
+		engine.Observe([]*models.SwapCH{swap(testJetton, int64(1000+i), models.TonMaster, 200)})
+	}
+	engine.Observe([]*models.SwapCH{swap(models.TonMaster, 100, UsdtMaster, 500)})
+
+	assert.Equal(t, 3, len(engine.observations))
+	assert.Equal(t, 1003.0, engine.observations[0].amountIn)
+}
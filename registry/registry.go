@@ -0,0 +1,181 @@
+package registry
+
+import (
+	"github.com/tonkeeper/tonapi-go"
+	"github.com/xssnick/tonutils-go/address"
+	"log"
+	"sync"
+	"time"
+	"tondexer/common"
+	"tondexer/dedust"
+	"tondexer/models"
+	"tondexer/persistence"
+	"tondexer/stonfi"
+	"tondexer/stonfiv2"
+	"tondexer/tonco"
+)
+
+// Registry keeps accounts the listener is subscribed to, it only grows since subscriptions can't be cancelled
+type Registry struct {
+	mutex       sync.Mutex
+	accounts    map[string]*models.DexAccount
+	subscribers []chan []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{accounts: map[string]*models.DexAccount{}}
+}
+
+func dexAccounts(dex string, source string, addresses []string) []*models.DexAccount {
+	return common.Map(addresses, func(account string) *models.DexAccount {
+		return &models.DexAccount{Dex: dex, Address: account, Source: source, Time: time.Now()}
+	})
+}
+
+// DefaultAccounts are the routers and vaults known at build time
+func DefaultAccounts() []*models.DexAccount {
+	var accounts []*models.DexAccount
+	accounts = append(accounts, dexAccounts(models.StonfiV1, models.DexAccountSourceDefault, []string{stonfi.StonfiRouter})...)
+	accounts = append(accounts, dexAccounts(models.StonfiV2, models.DexAccountSourceDefault, stonfiv2.Routers)...)
+	accounts = append(accounts, dexAccounts(models.DeDust, models.DexAccountSourceDefault, dedust.VaultAddresses)...)
+	accounts = append(accounts, dexAccounts(models.TONCO, models.DexAccountSourceDefault, tonco.Routers)...)
+	return accounts
+}
+
+// ConfiguredAccounts converts per dex address lists from the config
+func ConfiguredAccounts(addressesByDex map[string][]string) []*models.DexAccount {
+	var accounts []*models.DexAccount
+	for dex, addresses := range addressesByDex {
+		addresses = common.Filter(addresses, func(s string) bool { return s != "" })
+		accounts = append(accounts, dexAccounts(dex, models.DexAccountSourceConfig, addresses)...)
+	}
+	return accounts
+}
+
+func normalize(account string) (string, bool) {
+	if addr, e := address.ParseAddr(account); e == nil {
+		return addr.Bounce(true).Testnet(false).String(), true
+	}
+	if addr, e := address.ParseRawAddr(account); e == nil {
+		return addr.String(), true
+	}
+	return "", false
+}
+
+// Add registers unknown accounts, notifies subscribers and returns the added ones
+func (r *Registry) Add(accounts ...*models.DexAccount) []*models.DexAccount {
+	r.mutex.Lock()
+	var added []*models.DexAccount
+	for _, account := range accounts {
+		normalized, valid := normalize(account.Address)
+		if !valid {
+			log.Printf("Warning: invalid %v account %v \n", account.Dex, account.Address)
+			continue
+		}
+		if _, exists := r.accounts[normalized]; exists {
+			continue
+		}
+		account.Address = normalized
+		r.accounts[normalized] = account
+		added = append(added, account)
+	}
+	subscribers := r.subscribers
+	r.mutex.Unlock()
+
+	if len(added) > 0 {
+		addresses := common.Map(added, func(account *models.DexAccount) string { return account.Address })
+		for _, subscriber := range subscribers {
+			go func() { subscriber <- addresses }()
+		}
+	}
+	return added
+}
+
+func (r *Registry) Accounts() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var accounts []string
+	for account := range r.accounts {
+		accounts = append(accounts, account)
+	}
+	return accounts
+}
+
+func (r *Registry) Contains(account string) bool {
+	normalized, valid := normalize(account)
+	if !valid {
+		return false
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	_, exists := r.accounts[normalized]
+	return exists
+}
+
+// Subscribe returns a channel receiving accounts added after the call
+func (r *Registry) Subscribe() <-chan []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	channel := make(chan []string)
+	r.subscribers = append(r.subscribers, channel)
+	return channel
+}
+
+// Reload adds accounts stored in the store
+func (r *Registry) Reload(store persistence.Store) error {
+	accounts, e := store.DexAccounts()
+	if e != nil {
+		return e
+	}
+	added := r.Add(common.Map(accounts, func(account models.DexAccount) *models.DexAccount { return &account })...)
+	if len(added) > 0 {
+		log.Printf("Registry reloaded %v new accounts \n", len(added))
+	}
+	return nil
+}
+
+// Discover registers DeDust vaults and Ston.fi v2 routers seen in the trace and saves them to the store
+func (r *Registry) Discover(store persistence.Store, trace *tonapi.Trace) []*models.DexAccount {
+	var candidates []*models.DexAccount
+	var traverse func(trace *tonapi.Trace)
+	traverse = func(trace *tonapi.Trace) {
+		var dex string
+		if common.Contains(trace.Interfaces, "dedust_vault") {
+			dex = models.DeDust
+		} else if common.Contains(trace.Interfaces, "stonfi_router_v2") {
+			dex = models.StonfiV2
+		}
+		if dex != "" && !r.Contains(trace.Transaction.Account.Address) {
+			candidates = append(candidates, &models.DexAccount{
+				Dex:     dex,
+				Address: trace.Transaction.Account.Address,
+				Source:  models.DexAccountSourceDiscovered,
+				Time:    time.Now(),
+			})
+		}
+		for i := range trace.Children {
+			traverse(&trace.Children[i])
+		}
+	}
+	traverse(trace)
+
+	added := r.Add(candidates...)
+	if len(added) > 0 {
+		log.Printf("Discovered %v new dex accounts \n", len(added))
+		if e := store.SaveDexAccounts(added); e != nil {
+			log.Printf("Warning: Unable to save discovered accounts %v\n", e)
+		}
+	}
+	return added
+}
+
+// LoadRegistry combines default, configured and stored accounts
+func LoadRegistry(store persistence.Store, configured []*models.DexAccount) (*Registry, error) {
+	registry := NewRegistry()
+	registry.Add(DefaultAccounts()...)
+	registry.Add(configured...)
+	if e := registry.Reload(store); e != nil {
+		return nil, e
+	}
+	return registry, nil
+}
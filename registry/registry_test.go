@@ -0,0 +1,26 @@
+package registry
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"tondexer/models"
+)
+
+func TestRegistryAddsOnlyUnknownAccounts(t *testing.T) {
+	registry := NewRegistry()
+	added := registry.Add(DefaultAccounts()...)
+	assert.Equal(t, len(added), len(registry.Accounts()))
+
+	subscription := registry.Subscribe()
+	invalid := "0:da e153a74d894bbc32748198cd626e4f5df4a69ad2fa56ce80fc2644b5708d20"
+	assert.Empty(t, registry.Add(&models.DexAccount{Dex: models.DeDust, Address: invalid}))
+
+	// raw form of a default vault is already known
+	known := "0:dae153a74d894bbc32748198cd626e4f5df4a69ad2fa56ce80fc2644b5708d20"
+	assert.Empty(t, registry.Add(&models.DexAccount{Dex: models.DeDust, Address: known}))
+
+	fresh := &models.DexAccount{Dex: models.DeDust, Address: "EQA-X_yo3fzzbDbJ_0bzFWKqtRuZFIRa1sJsveZJ1YpViO3r"}
+	assert.Equal(t, []*models.DexAccount{fresh}, registry.Add(fresh))
+	assert.Equal(t, []string{fresh.Address}, <-subscription)
+	assert.True(t, registry.Contains("EQA-X_yo3fzzbDbJ_0bzFWKqtRuZFIRa1sJsveZJ1YpViO3r"))
+}
@@ -0,0 +1,170 @@
+package replay
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"time"
+	"tondexer/models"
+)
+
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// Change of a swap row, Stored is nil for added rows and Replayed for removed ones
+type Change struct {
+	Kind     ChangeKind
+	Stored   *models.SwapCH
+	Replayed *models.SwapCH
+	Fields   []string
+}
+
+func (change *Change) swap() *models.SwapCH {
+	if change.Replayed != nil {
+		return change.Replayed
+	}
+	return change.Stored
+}
+
+func (change *Change) String() string {
+	swap := change.swap()
+	description := fmt.Sprintf("%v %v swap of trace %v in pool %v at lt %v", change.Kind, swap.Dex, swap.TraceID, swap.PoolAddress, swap.Lt)
+	for _, field := range change.Fields {
+		description += fmt.Sprintf("\n\t%v: %v -> %v", field, fieldValue(change.Stored, field), fieldValue(change.Replayed, field))
+	}
+	return description
+}
+
+// swapKey identifies a row across runs, the pool transaction doesn't change when a parser is fixed
+func swapKey(swap *models.SwapCH) string {
+	return fmt.Sprint(swap.TraceID, ":", swap.Dex, ":", swap.PoolAddress, ":", swap.Lt)
+}
+
+// extractedFields are compared, names and usd rates come from caches and pricing at the time of writing, not from the trace
+var extractedFields = []string{"hashes", "time", "jetton_in", "amount_in", "jetton_out", "amount_out", "min_amount_out", "sender", "referral_address", "referral_amount"}
+
+func fieldValue(swap *models.SwapCH, field string) any {
+	if swap == nil {
+		return nil
+	}
+	switch field {
+	case "hashes":
+		hashes := slices.Clone(swap.Hashes)
+		sort.Strings(hashes)
+		return fmt.Sprint(hashes)
+	case "time":
+		return swap.Time.Unix()
+	case "jetton_in":
+		return swap.JettonIn
+	case "amount_in":
+		return amount(swap.AmountIn)
+	case "jetton_out":
+		return swap.JettonOut
+	case "amount_out":
+		return amount(swap.AmountOut)
+	case "min_amount_out":
+		return amount(swap.MinAmountOut)
+	case "sender":
+		return swap.Sender
+	case "referral_address":
+		return swap.ReferralAddress
+	case "referral_amount":
+		return amount(swap.ReferralAmount)
+	}
+	return nil
+}
+
+// amount renders nil as zero, clickhouse doesn't store nulls
+func amount(value *big.Int) string {
+	if value == nil {
+		return "0"
+	}
+	return value.String()
+}
+
+// DiffSwaps compares rows extracted again from the traces with the stored rows of the same traces
+func DiffSwaps(stored []*models.SwapCH, replayed []*models.SwapCH) []*Change {
+	storedByKey := map[string]*models.SwapCH{}
+	for _, swap := range stored {
+		storedByKey[swapKey(swap)] = swap
+	}
+
+	var changes []*Change
+	matched := map[string]bool{}
+	for _, swap := range replayed {
+		key := swapKey(swap)
+		storedSwap, exists := storedByKey[key]
+		if !exists {
+			changes = append(changes, &Change{Kind: Added, Replayed: swap, Fields: extractedFields})
+			continue
+		}
+		matched[key] = true
+		var fields []string
+		for _, field := range extractedFields {
+			if fieldValue(storedSwap, field) != fieldValue(swap, field) {
+				fields = append(fields, field)
+			}
+		}
+		if len(fields) > 0 {
+			changes = append(changes, &Change{Kind: Changed, Stored: storedSwap, Replayed: swap, Fields: fields})
+		}
+	}
+	for _, swap := range stored {
+		if !matched[swapKey(swap)] {
+			changes = append(changes, &Change{Kind: Removed, Stored: swap})
+		}
+	}
+	return changes
+}
+
+// KeepStoredPricing copies what was known when the rows were written into the rows extracted again,
+// rates of a jetton are kept unless the fix changed the jetton itself. Added rows have nothing stored and keep the rates
+// of the replay time. They get the catch time of their trace, or the trace time if nothing of it was stored, so the live
+// feed doesn't publish them as new swaps
+func KeepStoredPricing(stored []*models.SwapCH, replayed []*models.SwapCH) {
+	storedByKey := map[string]*models.SwapCH{}
+	traceCatchTime := map[string]time.Time{}
+	for _, swap := range stored {
+		storedByKey[swapKey(swap)] = swap
+		if caught, exists := traceCatchTime[swap.TraceID]; !exists || swap.CatchTime.Before(caught) {
+			traceCatchTime[swap.TraceID] = swap.CatchTime
+		}
+	}
+	for _, swap := range replayed {
+		storedSwap, exists := storedByKey[swapKey(swap)]
+		if !exists {
+			if caught, exists := traceCatchTime[swap.TraceID]; exists {
+				swap.CatchTime = caught
+			} else {
+				swap.CatchTime = swap.Time
+			}
+			continue
+		}
+		swap.CatchTime = storedSwap.CatchTime
+		if swap.JettonIn == storedSwap.JettonIn {
+			swap.JettonInUsdRate, swap.JettonInPriceSource = storedSwap.JettonInUsdRate, storedSwap.JettonInPriceSource
+		}
+		if swap.JettonOut == storedSwap.JettonOut {
+			swap.JettonOutUsdRate, swap.JettonOutPriceSource = storedSwap.JettonOutUsdRate, storedSwap.JettonOutPriceSource
+		}
+	}
+}
+
+// Summary counts changes by dex and kind
+type Summary map[string]map[ChangeKind]int
+
+func (summary Summary) Add(changes []*Change) {
+	for _, change := range changes {
+		dex := change.swap().Dex
+		if summary[dex] == nil {
+			summary[dex] = map[ChangeKind]int{}
+		}
+		summary[dex][change.Kind]++
+	}
+}
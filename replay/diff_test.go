@@ -0,0 +1,63 @@
+package replay
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+	"tondexer/models"
+)
+
+func swap(lt uint64, amountOut int64) *models.SwapCH {
+	return &models.SwapCH{
+		Dex: models.DeDust, TraceID: "trace", PoolAddress: "pool", Lt: lt, Time: time.Unix(100, 0),
+		Hashes: []string{"b", "a"}, JettonIn: "ton", AmountIn: big.NewInt(10), JettonOut: "usdt", AmountOut: big.NewInt(amountOut),
+		JettonInUsdRate: 5, JettonOutUsdRate: 1, CatchTime: time.Unix(110, 0),
+	}
+}
+
+func TestDiffSwaps(t *testing.T) {
+	storedUnchanged, storedChanged, storedRemoved := swap(1, 50), swap(2, 50), swap(3, 50)
+	storedUnchanged.MinAmountOut = big.NewInt(0)
+	replayedUnchanged, replayedChanged, replayedAdded := swap(1, 50), swap(2, 49), swap(4, 50)
+	replayedUnchanged.Hashes = []string{"a", "b"}
+	replayedUnchanged.JettonInUsdRate = 6
+
+	changes := DiffSwaps(
+		[]*models.SwapCH{storedUnchanged, storedChanged, storedRemoved},
+		[]*models.SwapCH{replayedUnchanged, replayedChanged, replayedAdded})
+
+	assert.Equal(t, 3, len(changes))
+	assert.Equal(t, &Change{Kind: Changed, Stored: storedChanged, Replayed: replayedChanged, Fields: []string{"amount_out"}}, changes[0])
+	assert.Equal(t, Added, changes[1].Kind)
+	assert.Equal(t, replayedAdded, changes[1].Replayed)
+	assert.Equal(t, &Change{Kind: Removed, Stored: storedRemoved}, changes[2])
+	assert.Contains(t, changes[0].String(), "amount_out: 50 -> 49")
+
+	summary := Summary{}
+	summary.Add(changes)
+	assert.Equal(t, Summary{models.DeDust: {Added: 1, Removed: 1, Changed: 1}}, summary)
+}
+
+func TestKeepStoredPricing(t *testing.T) {
+	stored, replayed := swap(1, 50), swap(1, 49)
+	stored.JettonInPriceSource = models.PriceSourceSwaps
+	replayed.JettonInUsdRate, replayed.JettonOutUsdRate, replayed.CatchTime = 7, 2, time.Now()
+	replayed.JettonOut = "not"
+
+	KeepStoredPricing([]*models.SwapCH{stored}, []*models.SwapCH{replayed})
+	assert.Equal(t, 5.0, replayed.JettonInUsdRate)
+	assert.Equal(t, models.PriceSourceSwaps, replayed.JettonInPriceSource)
+	assert.Equal(t, 2.0, replayed.JettonOutUsdRate)
+	assert.Equal(t, stored.CatchTime, replayed.CatchTime)
+}
+
+func TestAddedRowsKeepTheCatchTimeOfTheirTrace(t *testing.T) {
+	stored, added, untraced := swap(1, 50), swap(2, 50), swap(3, 50)
+	added.CatchTime, untraced.CatchTime = time.Now(), time.Now()
+	untraced.TraceID = "new"
+
+	KeepStoredPricing([]*models.SwapCH{stored}, []*models.SwapCH{added, untraced})
+	assert.Equal(t, stored.CatchTime, added.CatchTime)
+	assert.Equal(t, untraced.Time, untraced.CatchTime)
+}
@@ -0,0 +1,72 @@
+package spool
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Spool is a directory of batches that failed to persist, one json file per batch
+type Spool struct {
+	Dir string
+}
+
+func New(dir string) (*Spool, error) {
+	if e := os.MkdirAll(dir, 0755); e != nil {
+		return nil, e
+	}
+	return &Spool{Dir: dir}, nil
+}
+
+// Write stores the batch atomically, kind is the prefix of the file name
+func Write[T any](spool *Spool, kind string, entities []*T) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	data, e := json.Marshal(entities)
+	if e != nil {
+		return e
+	}
+	name := fmt.Sprintf("%v-%v.json", kind, time.Now().UnixNano())
+	tmp := filepath.Join(spool.Dir, name+".tmp")
+	if e := os.WriteFile(tmp, data, 0644); e != nil {
+		return e
+	}
+	return os.Rename(tmp, filepath.Join(spool.Dir, name))
+}
+
+// Replay passes stored batches of the kind to the function oldest first and deletes the ones it accepted
+func Replay[T any](spool *Spool, kind string, persist func([]*T) error) error {
+	files, e := filepath.Glob(filepath.Join(spool.Dir, kind+"-*.json"))
+	if e != nil {
+		return e
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if strings.HasSuffix(file, ".tmp") {
+			continue
+		}
+		data, e := os.ReadFile(file)
+		if e != nil {
+			return e
+		}
+		var entities []*T
+		if e := json.Unmarshal(data, &entities); e != nil {
+			log.Printf("Warning: Skipping broken spool file %v: %v \n", file, e)
+			continue
+		}
+		if e := persist(entities); e != nil {
+			return e
+		}
+		if e := os.Remove(file); e != nil {
+			return e
+		}
+		log.Printf("Replayed %v entities from %v \n", len(entities), file)
+	}
+	return nil
+}
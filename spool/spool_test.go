@@ -0,0 +1,36 @@
+package spool
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+	"tondexer/models"
+)
+
+func TestSpoolReplaysFailedBatches(t *testing.T) {
+	spool, e := New(t.TempDir())
+	assert.Nil(t, e)
+
+	swap := &models.SwapCH{Dex: models.DeDust, Hashes: []string{"a"}, AmountIn: big.NewInt(42), Time: time.Unix(1700000000, 0).UTC()}
+	assert.Nil(t, Write(spool, "swaps", []*models.SwapCH{swap}))
+	assert.Nil(t, Write(spool, "arbitrages", []*models.ArbitrageCH{{Sender: "sender"}}))
+
+	e = Replay(spool, "swaps", func(swaps []*models.SwapCH) error {
+		return errors.New("clickhouse is down")
+	})
+	assert.NotNil(t, e)
+
+	var replayed []*models.SwapCH
+	e = Replay(spool, "swaps", func(swaps []*models.SwapCH) error {
+		replayed = append(replayed, swaps...)
+		return nil
+	})
+	assert.Nil(t, e)
+	assert.Equal(t, []*models.SwapCH{swap}, replayed)
+
+	files, _ := os.ReadDir(spool.Dir)
+	assert.Equal(t, 1, len(files))
+}
@@ -0,0 +1,29 @@
+package stonfi
+
+import (
+	"testing"
+	"tondexer/fixtures"
+	"tondexer/fixtures/fixturestest"
+)
+
+// goldenTraces are the traces the tests of the package read
+var goldenTraces = []string{
+	"179156c9a79fe218d5cccae289f84762f16d080198221d2103049566e176a17e",
+	"1eb52591ace42a6c364b436cfc08a018c6c45684daf888936340af6145c712cc",
+	"3648ab7b96037d9983ef957ba019d6fdd4d5ba64fa95f790d550d49b6b4e65c3",
+	"a69e0f2a244c7807ba6e8ecfe3da1ebac48b080782d828166fd0db5ee27d1238",
+	"c666610443281d4395e101fdc70f157ea9fd907da38c1350c3f57d28a492ac97",
+	"d2668f071f74f70493e18d3957f3d260dc7d6eeee68e1759a6f43af1aad11e85",
+	"d680dca3d9c2448ec69282a35e08a739b04f22d8c2e23f573b63beb0cd62f3a6",
+	"e4bf0ba74bc636ebda771731f3308849b2744b088298047af0d6f2603bdb23d8",
+	"f020893c2b8ac55b477211ad0be0eae87ef106092cfaa76b2e6448c140b612b5",
+}
+
+func TestGoldenSwaps(t *testing.T) {
+	for _, id := range fixturestest.Traces(t, fixtures.TracesDir, goldenTraces...) {
+		t.Run(id, func(t *testing.T) {
+			trace := fixturestest.Trace(t, id)
+			fixturestest.Golden(t, id, ExtractStonfiSwapsFromRootTrace(trace))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package stonfi
+
+import (
+	"errors"
+	"github.com/tonkeeper/tonapi-go"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"log"
+	"math/big"
+	"time"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+var errNotLiquidityMessage = errors.New("not a liquidity message")
+
+// ExtractStonfiLiquidityFromRootTrace finds provide_lp notifications and burn payouts of the router.
+// Every provide_lp notification deposits one token, LP tokens are minted after the second one.
+func ExtractStonfiLiquidityFromRootTrace(root *tonapi.Trace) []*models.LiquidityInfo {
+	var infos []*models.LiquidityInfo
+	var traverse func(trace *tonapi.Trace, parent *tonapi.Trace)
+	traverse = func(trace *tonapi.Trace, parent *tonapi.Trace) {
+		account := address.MustParseRawAddr(trace.Transaction.Account.Address)
+		parsedTransaction, e := ParseRawTransaction(trace.Transaction.Raw)
+		if e == nil &&
+			account.String() == StonfiRouter &&
+			parsedTransaction.IO.In != nil &&
+			parsedTransaction.IO.In.MsgType == tlb.MsgTypeInternal {
+			slice := parsedTransaction.IO.In.AsInternal().Body.BeginParse()
+			if msgCode, e := slice.LoadUInt(32); e == nil {
+				var info *models.LiquidityInfo
+				switch msgCode {
+				case TransferNotificationCode:
+					info, e = provideInfoFromNotification(trace, root)
+				case PaymentRequestCode:
+					info, e = withdrawInfoFromPayment(trace, parent, root)
+				default:
+					e = errNotLiquidityMessage
+				}
+				if e == nil {
+					infos = append(infos, info)
+				} else if !errors.Is(e, errNotLiquidityMessage) {
+					log.Printf("Warning: could not parse stonfi liquidity message %v: %v \n", trace.Transaction.Hash, e)
+				}
+			}
+		}
+		for i := range trace.Children {
+			traverse(&trace.Children[i], trace)
+		}
+	}
+	traverse(root, nil)
+	return infos
+}
+
+func provideInfoFromNotification(notification *tonapi.Trace, root *tonapi.Trace) (*models.LiquidityInfo, error) {
+	transaction, e := ParseRawTransaction(notification.Transaction.Raw)
+	if e != nil {
+		return nil, e
+	}
+	message := transaction.IO.In.AsInternal()
+	cll := message.Body.BeginParse()
+
+	if _, e := cll.LoadUInt(32); e != nil { // op code
+		return nil, e
+	}
+	if _, e := cll.LoadUInt(64); e != nil { // query id
+		return nil, e
+	}
+	amount, e := cll.LoadBigCoins()
+	if e != nil {
+		return nil, e
+	}
+	fromUser, e := cll.LoadAddr()
+	if e != nil {
+		return nil, e
+	}
+	ref, e := cll.LoadRef()
+	if e != nil {
+		return nil, errNotLiquidityMessage
+	}
+	if op, e := ref.LoadUInt(32); e != nil || op != ProvideLpOpCode {
+		return nil, errNotLiquidityMessage
+	}
+
+	pool := findPoolAddressForNotification(notification)
+
+	return &models.LiquidityInfo{
+		TraceID:     root.Transaction.Hash,
+		Dex:         models.StonfiV1,
+		Type:        models.LiquidityProvide,
+		Hashes:      []string{notification.Transaction.Hash},
+		Lt:          message.CreatedLT,
+		Time:        time.UnixMilli(notification.Transaction.Utime * 1000),
+		CatchTime:   time.Now(),
+		PoolAddress: pool,
+		Provider:    fromUser,
+		Assets: []*models.LiquidityAsset{{
+			Wallet: message.SrcAddr, // router jetton wallet
+			Amount: amount,
+		}},
+		LpAmount: core.MintedJettonAmount(notification, pool),
+	}, nil
+}
+
+func withdrawInfoFromPayment(payment *tonapi.Trace, pool *tonapi.Trace, root *tonapi.Trace) (*models.LiquidityInfo, error) {
+	request, e := payToFromTrace(payment)
+	if e != nil {
+		return nil, e
+	}
+	if request.ExitCode != BurnOkPaymentCode {
+		return nil, errNotLiquidityMessage
+	}
+
+	hashes := []string{payment.Transaction.Hash}
+	lpAmount := big.NewInt(0)
+	var poolAddress *address.Address
+	if pool != nil {
+		poolAddress = address.MustParseRawAddr(pool.Transaction.Account.Address)
+		if burnt, e := core.JettonBurnNotificationAmount(pool); e == nil {
+			lpAmount = burnt
+			hashes = append([]string{pool.Transaction.Hash}, hashes...)
+		}
+	}
+
+	return &models.LiquidityInfo{
+		TraceID:     root.Transaction.Hash,
+		Dex:         models.StonfiV1,
+		Type:        models.LiquidityWithdraw,
+		Hashes:      hashes,
+		Lt:          request.Lt,
+		Time:        request.TransactionTime,
+		CatchTime:   request.EventCatchTime,
+		PoolAddress: poolAddress,
+		Provider:    request.Owner,
+		Assets: []*models.LiquidityAsset{
+			{Wallet: request.Token0WalletAddress, Amount: request.Amount0Out},
+			{Wallet: request.Token1WalletAddress, Amount: request.Amount1Out},
+		},
+		LpAmount: lpAmount,
+	}, nil
+}
@@ -0,0 +1,163 @@
+package stonfi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tonkeeper/tonapi-go"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"testing"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+var (
+	router       = address.MustParseAddr(StonfiRouter)
+	pool         = address.MustParseAddr("EQD8TJ8xEWB1SpnRE4d89YO3jl0W0EiBnNS4IBaHaUmdfizE")
+	provider     = address.MustParseAddr("EQCM3B12QK1e4yZSf8GtBRT0aLMNyEsBc_DhVfRRtOEffLez")
+	routerWallet = address.MustParseAddr("EQARULUYsmJq1RiZ-YiH-IJLcAZUVkVff-KBPwEmmaQGH6aC")
+	otherWallet  = address.MustParseAddr("EQCtiv7PrMJImWiF2L5oJCgPnzp-VML2CAt5cbn1VsKAxLiE")
+	lpWallet     = address.MustParseAddr("EQDa4VOnTYlLvDJ0gZjNYm5PXfSmmtL6Vs6A_CZEtXCNICq_")
+)
+
+func rawAddress(addr *address.Address) string {
+	return fmt.Sprintf("%v:%x", addr.Workchain(), addr.Data())
+}
+
+// rawTransaction is the boc of a transaction of the router receiving the body from source, as tonapi returns it in raw
+func rawTransaction(t *testing.T, source *address.Address, lt uint64, body *cell.Cell) string {
+	transaction := tlb.Transaction{
+		AccountAddr: router.Data(),
+		PrevTxHash:  make([]byte, 32),
+		OrigStatus:  tlb.AccountStatusActive,
+		EndStatus:   tlb.AccountStatusActive,
+		TotalFees:   tlb.CurrencyCollection{Coins: tlb.ZeroCoins},
+		StateUpdate: tlb.HashUpdate{OldHash: make([]byte, 32), NewHash: make([]byte, 32)},
+		Description: tlb.TransactionDescription{Description: tlb.TransactionDescriptionOrdinary{
+			ComputePhase: tlb.ComputePhase{Phase: tlb.ComputePhaseSkipped{Reason: tlb.ComputeSkipReason{Type: tlb.ComputeSkipReasonNoState}}},
+		}},
+	}
+	transaction.IO.In = &tlb.Message{MsgType: tlb.MsgTypeInternal, Msg: &tlb.InternalMessage{
+		SrcAddr:   source,
+		DstAddr:   router,
+		Amount:    tlb.ZeroCoins,
+		CreatedLT: lt,
+		Body:      body,
+	}}
+	cl, e := tlb.ToCell(transaction)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return hex.EncodeToString(cl.ToBOC())
+}
+
+func jettonMessage(opCode uint64, amount int64) tonapi.OptString {
+	body := cell.BeginCell().MustStoreUInt(opCode, 32).MustStoreUInt(1, 64).MustStoreBigCoins(big.NewInt(amount)).EndCell()
+	return tonapi.NewOptString(hex.EncodeToString(body.ToBOC()))
+}
+
+func notification(t *testing.T, hash string, forwardOp uint64, amount int64, children ...tonapi.Trace) tonapi.Trace {
+	body := cell.BeginCell().
+		MustStoreUInt(TransferNotificationCode, 32).
+		MustStoreUInt(1, 64).
+		MustStoreBigCoins(big.NewInt(amount)).
+		MustStoreAddr(provider).
+		MustStoreRef(cell.BeginCell().MustStoreUInt(forwardOp, 32).MustStoreAddr(otherWallet).EndCell()).
+		EndCell()
+	return tonapi.Trace{
+		Transaction: tonapi.Transaction{
+			Hash:    hash,
+			Utime:   1700000000,
+			Account: tonapi.AccountAddress{Address: rawAddress(router)},
+			Raw:     rawTransaction(t, routerWallet, 10, body),
+		},
+		Children: children,
+	}
+}
+
+func TestExtractStonfiProvideFromTrace(t *testing.T) {
+	minted := tonapi.Trace{Transaction: tonapi.Transaction{
+		Account: tonapi.AccountAddress{Address: rawAddress(lpWallet)},
+		InMsg: tonapi.NewOptMessage(tonapi.Message{
+			OpCode:  tonapi.NewOptString(core.JettonInternalTransferOpCode),
+			Source:  tonapi.NewOptAccountAddress(tonapi.AccountAddress{Address: rawAddress(pool)}),
+			RawBody: jettonMessage(core.JettonInternalTransferCode, 300),
+		}),
+	}}
+	poolTrace := tonapi.Trace{
+		Transaction: tonapi.Transaction{Account: tonapi.AccountAddress{Address: rawAddress(pool)}},
+		Children:    []tonapi.Trace{minted},
+	}
+	root := tonapi.Trace{
+		Transaction: tonapi.Transaction{Hash: "root", Account: tonapi.AccountAddress{Address: rawAddress(provider)}},
+		Children: []tonapi.Trace{
+			notification(t, "provide", ProvideLpOpCode, 1000, poolTrace),
+			notification(t, "swap", SwapOpCode, 500),
+		},
+	}
+
+	infos := ExtractStonfiLiquidityFromRootTrace(&root)
+
+	assert.Equal(t, 1, len(infos))
+	info := infos[0]
+	assert.Equal(t, models.LiquidityProvide, info.Type)
+	assert.Equal(t, models.StonfiV1, info.Dex)
+	assert.Equal(t, "root", info.TraceID)
+	assert.Equal(t, []string{"provide"}, info.Hashes)
+	assert.Equal(t, uint64(10), info.Lt)
+	assert.True(t, pool.Equals(info.PoolAddress))
+	assert.True(t, provider.Equals(info.Provider))
+	assert.Equal(t, 1, len(info.Assets))
+	assert.True(t, routerWallet.Equals(info.Assets[0].Wallet))
+	assert.Equal(t, big.NewInt(1000), info.Assets[0].Amount)
+	assert.Equal(t, big.NewInt(300), info.LpAmount)
+}
+
+func TestExtractStonfiWithdrawalFromTrace(t *testing.T) {
+	payment := func(exitCode uint64) tonapi.Trace {
+		body := cell.BeginCell().
+			MustStoreUInt(PaymentRequestCode, 32).
+			MustStoreUInt(1, 64).
+			MustStoreAddr(provider).
+			MustStoreUInt(exitCode, 32).
+			MustStoreRef(cell.BeginCell().
+				MustStoreBigCoins(big.NewInt(700)).MustStoreAddr(routerWallet).
+				MustStoreBigCoins(big.NewInt(900)).MustStoreAddr(otherWallet).
+				EndCell()).
+			EndCell()
+		return tonapi.Trace{Transaction: tonapi.Transaction{
+			Hash:    fmt.Sprint("payment", exitCode),
+			Utime:   1700000000,
+			Account: tonapi.AccountAddress{Address: rawAddress(router)},
+			Raw:     rawTransaction(t, pool, 20, body),
+		}}
+	}
+	root := tonapi.Trace{
+		Transaction: tonapi.Transaction{
+			Hash:    "pool",
+			Account: tonapi.AccountAddress{Address: rawAddress(pool)},
+			InMsg:   tonapi.NewOptMessage(tonapi.Message{RawBody: jettonMessage(core.JettonBurnNotificationCode, 400)}),
+		},
+		Children: []tonapi.Trace{payment(BurnOkPaymentCode), payment(SwapOkPaymentCode)},
+	}
+
+	infos := ExtractStonfiLiquidityFromRootTrace(&root)
+
+	assert.Equal(t, 1, len(infos))
+	info := infos[0]
+	assert.Equal(t, models.LiquidityWithdraw, info.Type)
+	assert.Equal(t, models.StonfiV1, info.Dex)
+	assert.Equal(t, []string{"pool", fmt.Sprint("payment", BurnOkPaymentCode)}, info.Hashes)
+	assert.Equal(t, uint64(20), info.Lt)
+	assert.True(t, pool.Equals(info.PoolAddress))
+	assert.True(t, provider.Equals(info.Provider))
+	assert.Equal(t, 2, len(info.Assets))
+	assert.True(t, routerWallet.Equals(info.Assets[0].Wallet))
+	assert.Equal(t, big.NewInt(700), info.Assets[0].Amount)
+	assert.True(t, otherWallet.Equals(info.Assets[1].Wallet))
+	assert.Equal(t, big.NewInt(900), info.Assets[1].Amount)
+	assert.Equal(t, big.NewInt(400), info.LpAmount)
+}
@@ -15,13 +15,37 @@ import (
 const SwapOpCode = 630424929
 const SwapOkPaymentCode = 3326308581
 const SwapRefPaymentCode = 1158120768
+const BurnOkPaymentCode = 3718548330
 
 const TransferNotificationCode = 1935855772
 const PaymentRequestCode = 4181439551
+const ProvideLpOpCode = 4244235663
 
 const StonfiRouter = "EQB3ncyBUTjZUA5EnFKR5_EnOMI9V1tTEAAPaiU71gc4TiUt"
 
 func PaymentRequestFromTrace(trace *tonapi.Trace) (*models.PayoutRequest, error) {
+	request, e := payToFromTrace(trace)
+	if e != nil {
+		return nil, e
+	}
+	if request.ExitCode != SwapOkPaymentCode && request.ExitCode != SwapRefPaymentCode {
+		return nil, errors.New("invalid payment refOrOk request code")
+	}
+	return request, nil
+}
+
+func BurnPaymentFromTrace(trace *tonapi.Trace) (*models.PayoutRequest, error) {
+	request, e := payToFromTrace(trace)
+	if e != nil {
+		return nil, e
+	}
+	if request.ExitCode != BurnOkPaymentCode {
+		return nil, errors.New("invalid payment burn request code")
+	}
+	return request, nil
+}
+
+func payToFromTrace(trace *tonapi.Trace) (*models.PayoutRequest, error) {
 	transaction, e := ParseRawTransaction(trace.Transaction.Raw)
 	if e != nil {
 		return nil, e
@@ -37,9 +61,6 @@ func PaymentRequestFromTrace(trace *tonapi.Trace) (*models.PayoutRequest, error)
 	queryId := cll.MustLoadUInt(64)
 	owner := cll.MustLoadAddr()
 	exitCode := cll.MustLoadUInt(32)
-	if exitCode != SwapOkPaymentCode && exitCode != SwapRefPaymentCode {
-		return nil, errors.New("invalid payment refOrOk request code")
-	}
 
 	ref := cll.MustLoadRef()
 	amount0Out := ref.MustLoadCoins()
@@ -63,8 +84,14 @@ func PaymentRequestFromTrace(trace *tonapi.Trace) (*models.PayoutRequest, error)
 }
 
 func ParseRawTransaction(transactions string) (*tlb.Transaction, error) {
-	hx, _ := hex.DecodeString(transactions)
-	cl, _ := cell.FromBOC(hx)
+	hx, e := hex.DecodeString(transactions)
+	if e != nil {
+		return nil, e
+	}
+	cl, e := cell.FromBOC(hx)
+	if e != nil {
+		return nil, e
+	}
 
 	var tx tlb.Transaction
 	if err := tlb.LoadFromCell(&tx, cl.BeginParse()); err != nil {
@@ -1,21 +1,18 @@
 package stonfi
 
 import (
-	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/tonkeeper/tonapi-go"
 	"github.com/xssnick/tonutils-go/address"
 	"math/big"
 	"slices"
 	"testing"
+	"tondexer/fixtures/fixturestest"
 )
 
 func TestSwapTransferNotificationWithReferral(t *testing.T) {
-	client, _ := tonapi.New()
-
 	// Regular swap without ref
-	params := tonapi.GetTraceParams{TraceID: "e4bf0ba74bc636ebda771731f3308849b2744b088298047af0d6f2603bdb23d8"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "e4bf0ba74bc636ebda771731f3308849b2744b088298047af0d6f2603bdb23d8")
 	notification := findRouterTransferNotificationNodes(trace)[0]
 
 	message, _ := V1NotificationFromTrace(notification)
@@ -33,10 +30,7 @@ func TestSwapTransferNotificationWithReferral(t *testing.T) {
 }
 
 func TestParsePaymentRequestMessage(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "d680dca3d9c2448ec69282a35e08a739b04f22d8c2e23f573b63beb0cd62f3a6"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "d680dca3d9c2448ec69282a35e08a739b04f22d8c2e23f573b63beb0cd62f3a6")
 	notification := findRouterTransferNotificationNodes(trace)[0]
 
 	payments := findPaymentsForNotification(notification)
@@ -170,19 +170,3 @@ func findRouterTransferNotificationNodes(root *tonapi.Trace) []*tonapi.Trace {
 
 	return traces
 }
-
-func GetAllTransactionsFromTrace(trace *tonapi.Trace) []tonapi.Transaction {
-	var transactions []tonapi.Transaction
-	var traverse func(t *tonapi.Trace)
-
-	traverse = func(t *tonapi.Trace) {
-		transactions = append(transactions, t.Transaction)
-
-		for _, child := range t.Children {
-			traverse(&child)
-		}
-	}
-
-	traverse(trace)
-	return transactions
-}
@@ -1,31 +1,26 @@
 package stonfi
 
 import (
-	"context"
 	"github.com/stretchr/testify/assert"
-	"github.com/tonkeeper/tonapi-go"
 	"github.com/xssnick/tonutils-go/address"
 	"math/big"
 	"slices"
 	"testing"
 	"tondexer/common"
+	"tondexer/fixtures/fixturestest"
 	"tondexer/models"
 )
 
 func TestFindRouterNotificationNodes(t *testing.T) {
-	client, _ := tonapi.New()
-
 	// Regular swap without ref
-	params := tonapi.GetTraceParams{TraceID: "a69e0f2a244c7807ba6e8ecfe3da1ebac48b080782d828166fd0db5ee27d1238"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "a69e0f2a244c7807ba6e8ecfe3da1ebac48b080782d828166fd0db5ee27d1238")
 	notifications := findRouterTransferNotificationNodes(trace)
 
 	assert.Equal(t, 1, len(notifications))
 	assert.Equal(t, "0d42e08d83e30eaec5455425f032b79aa54f477af5310081f732be3112d5d70d", notifications[0].Transaction.Hash)
 
 	// 2 parallel swaps without refs
-	params = tonapi.GetTraceParams{TraceID: "1eb52591ace42a6c364b436cfc08a018c6c45684daf888936340af6145c712cc"}
-	trace, _ = client.GetTrace(context.Background(), params)
+	trace = fixturestest.Trace(t, "1eb52591ace42a6c364b436cfc08a018c6c45684daf888936340af6145c712cc")
 	notifications = findRouterTransferNotificationNodes(trace)
 
 	assert.Equal(t, 2, len(notifications))
@@ -36,11 +31,8 @@ func TestFindRouterNotificationNodes(t *testing.T) {
 }
 
 func TestFindPaymentForNotification(t *testing.T) {
-	client, _ := tonapi.New()
-
 	// Regular swap without ref
-	params := tonapi.GetTraceParams{TraceID: "a69e0f2a244c7807ba6e8ecfe3da1ebac48b080782d828166fd0db5ee27d1238"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "a69e0f2a244c7807ba6e8ecfe3da1ebac48b080782d828166fd0db5ee27d1238")
 	notification := findRouterTransferNotificationNodes(trace)[0]
 
 	payments := findPaymentsForNotification(notification)
@@ -49,8 +41,7 @@ func TestFindPaymentForNotification(t *testing.T) {
 	assert.Equal(t, "a70f3c5d8a09f2414f55af769c37cbfdd8304e68a22d63ab04df9af5d14bda4f", payments[0].Transaction.Hash)
 
 	// regular swap with ref
-	params = tonapi.GetTraceParams{TraceID: "c666610443281d4395e101fdc70f157ea9fd907da38c1350c3f57d28a492ac97"}
-	trace, _ = client.GetTrace(context.Background(), params)
+	trace = fixturestest.Trace(t, "c666610443281d4395e101fdc70f157ea9fd907da38c1350c3f57d28a492ac97")
 	notification = findRouterTransferNotificationNodes(trace)[0]
 
 	payments = findPaymentsForNotification(notification)
@@ -63,10 +54,7 @@ func TestFindPaymentForNotification(t *testing.T) {
 }
 
 func TestFindPoolAddressForNotification(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "c666610443281d4395e101fdc70f157ea9fd907da38c1350c3f57d28a492ac97"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "c666610443281d4395e101fdc70f157ea9fd907da38c1350c3f57d28a492ac97")
 	notification := findRouterTransferNotificationNodes(trace)[0]
 
 	addr := findPoolAddressForNotification(notification)
@@ -74,10 +62,7 @@ func TestFindPoolAddressForNotification(t *testing.T) {
 }
 
 func TestExtractSwapsFromRegularSwapWithoutRef(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "a69e0f2a244c7807ba6e8ecfe3da1ebac48b080782d828166fd0db5ee27d1238"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "a69e0f2a244c7807ba6e8ecfe3da1ebac48b080782d828166fd0db5ee27d1238")
 
 	swaps := ExtractStonfiSwapsFromRootTrace(trace)
 
@@ -92,10 +77,7 @@ func TestExtractSwapsFromRegularSwapWithoutRef(t *testing.T) {
 }
 
 func TestExtractSwapsFromRegularSwapWithRef(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "179156c9a79fe218d5cccae289f84762f16d080198221d2103049566e176a17e"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "179156c9a79fe218d5cccae289f84762f16d080198221d2103049566e176a17e")
 
 	swaps := ExtractStonfiSwapsFromRootTrace(trace)
 
@@ -109,10 +91,7 @@ func TestExtractSwapsFromRegularSwapWithRef(t *testing.T) {
 }
 
 func TestExtractSwapsFromParallelSwaps(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "3648ab7b96037d9983ef957ba019d6fdd4d5ba64fa95f790d550d49b6b4e65c3"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "3648ab7b96037d9983ef957ba019d6fdd4d5ba64fa95f790d550d49b6b4e65c3")
 
 	swaps := ExtractStonfiSwapsFromRootTrace(trace)
 
@@ -140,10 +119,7 @@ func TestExtractSwapsFromParallelSwaps(t *testing.T) {
 }
 
 func TestExtractSwapFromConsequentSwaps(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "d2668f071f74f70493e18d3957f3d260dc7d6eeee68e1759a6f43af1aad11e85"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "d2668f071f74f70493e18d3957f3d260dc7d6eeee68e1759a6f43af1aad11e85")
 
 	swaps := ExtractStonfiSwapsFromRootTrace(trace)
 
@@ -171,10 +147,7 @@ func TestExtractSwapFromConsequentSwaps(t *testing.T) {
 }
 
 func TestExtractSwapsFromRegularSwapWithRefSameAsSender(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "f020893c2b8ac55b477211ad0be0eae87ef106092cfaa76b2e6448c140b612b5"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "f020893c2b8ac55b477211ad0be0eae87ef106092cfaa76b2e6448c140b612b5")
 
 	swaps := ExtractStonfiSwapsFromRootTrace(trace)
 
@@ -190,10 +163,7 @@ func TestExtractSwapsFromRegularSwapWithRefSameAsSender(t *testing.T) {
 }
 
 func TestTraceIDOfSwapInfo(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "f020893c2b8ac55b477211ad0be0eae87ef106092cfaa76b2e6448c140b612b5"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "f020893c2b8ac55b477211ad0be0eae87ef106092cfaa76b2e6448c140b612b5")
 
 	swaps := ExtractStonfiSwapsFromRootTrace(trace)
 
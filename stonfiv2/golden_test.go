@@ -0,0 +1,25 @@
+package stonfiv2
+
+import (
+	"testing"
+	"tondexer/fixtures"
+	"tondexer/fixtures/fixturestest"
+)
+
+// goldenTraces are the traces the tests of the package read
+var goldenTraces = []string{
+	"05761a40ef710ce6ec6d6b7a22e717701e7cedfc21cd2f8f372a0f627a9981a8",
+	"093e92969e33af9d162c23724d4581a7c137ceecab5641bb9a1c4b191c34a95a",
+	"49c2837771b6b07b0984ac3d29d57aa92e8ddb85854765fe50b42239e28bfa34",
+	"b960db5ada0013fa0d70639e258863d48861e06648f5201b89d7cd34bc6c7442",
+	"fd88effd16246914a578fddf8484ca3f919d94e6534e43ec5abb0674a0ce0c54",
+}
+
+func TestGoldenSwaps(t *testing.T) {
+	for _, id := range fixturestest.Traces(t, fixtures.TracesDir, goldenTraces...) {
+		t.Run(id, func(t *testing.T) {
+			trace := fixturestest.Trace(t, id)
+			fixturestest.Golden(t, id, ExtractStonfiV2SwapsFromRootTrace(trace))
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package stonfiv2
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/tonkeeper/tonapi-go"
+	"github.com/xssnick/tonutils-go/address"
+	"log"
+	"math/big"
+	"time"
+	"tondexer/common"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+const provideLpOpCode = 0x37c096df
+const burnNotificationExtOpCode = 0x297437cf
+const burnOkExitCode = 0xdda48b6a
+
+var errNotLiquidityMessage = errors.New("not a liquidity message")
+
+// ExtractStonfiV2LiquidityFromRootTrace finds provide_lp notifications and burn payouts of v2 routers.
+// Every provide_lp notification deposits one token, LP tokens are minted after the second one.
+func ExtractStonfiV2LiquidityFromRootTrace(root *tonapi.Trace) []*models.LiquidityInfo {
+	var infos []*models.LiquidityInfo
+	var traverse func(trace *tonapi.Trace, parent *tonapi.Trace)
+	traverse = func(trace *tonapi.Trace, parent *tonapi.Trace) {
+		if trace.Transaction.InMsg.IsSet() && common.Contains(trace.Interfaces, stonfiRouterV2) {
+			var info *models.LiquidityInfo
+			var e error
+			if trace.Transaction.InMsg.Value.OpCode.Value == core.JettonNotifyOpCode {
+				info, e = provideInfoFromNotification(trace, root)
+			} else if trace.Transaction.InMsg.Value.DecodedOpName.Value == payoutOpCode {
+				info, e = withdrawInfoFromPayout(trace, parent, root)
+			} else {
+				e = errNotLiquidityMessage
+			}
+			if e == nil {
+				infos = append(infos, info)
+			} else if !errors.Is(e, errNotLiquidityMessage) {
+				log.Printf("error parsing liquidity message %v: %v \n", trace.Transaction.Hash, e)
+			}
+		}
+		for i := range trace.Children {
+			traverse(&trace.Children[i], trace)
+		}
+	}
+	traverse(root, nil)
+	return infos
+}
+
+func provideInfoFromNotification(notification *tonapi.Trace, root *tonapi.Trace) (*models.LiquidityInfo, error) {
+	var notificationInfo NotificationJsonBody
+	if err := json.Unmarshal(notification.Transaction.InMsg.Value.DecodedBody, &notificationInfo); err != nil {
+		return nil, err
+	}
+	if notificationInfo.ForwardPayload.Value.OpCode != provideLpOpCode {
+		return nil, errNotLiquidityMessage
+	}
+
+	amount, success := new(big.Int).SetString(notificationInfo.Amount, 10)
+	if !success {
+		return nil, errors.New("invalid amount " + notificationInfo.Amount)
+	}
+	sender, e := address.ParseRawAddr(notificationInfo.Sender)
+	if e != nil {
+		return nil, e
+	}
+
+	pool := findPoolAddressForNotification(notification)
+
+	return &models.LiquidityInfo{
+		TraceID:     root.Transaction.Hash,
+		Dex:         models.StonfiV2,
+		Type:        models.LiquidityProvide,
+		Hashes:      []string{notification.Transaction.Hash},
+		Lt:          uint64(notification.Transaction.Lt),
+		Time:        time.UnixMilli(notification.Transaction.Utime * 1000),
+		CatchTime:   time.Now(),
+		PoolAddress: pool,
+		Provider:    sender,
+		Assets: []*models.LiquidityAsset{{
+			Wallet: core.InMessageSource(notification), // router jetton wallet
+			Amount: amount,
+		}},
+		LpAmount: core.MintedJettonAmount(notification, pool),
+	}, nil
+}
+
+func withdrawInfoFromPayout(payoutTrace *tonapi.Trace, pool *tonapi.Trace, root *tonapi.Trace) (*models.LiquidityInfo, error) {
+	payout, e := parseTracePayout(payoutTrace)
+	if e != nil {
+		return nil, e
+	}
+	if payout.ExitCode != burnOkExitCode {
+		return nil, errNotLiquidityMessage
+	}
+
+	hashes := []string{payoutTrace.Transaction.Hash}
+	lpAmount := big.NewInt(0)
+	if pool != nil {
+		if burnt, e := burntLpAmount(pool); e == nil {
+			lpAmount = burnt
+			hashes = append([]string{pool.Transaction.Hash}, hashes...)
+		}
+	}
+
+	return &models.LiquidityInfo{
+		TraceID:     root.Transaction.Hash,
+		Dex:         models.StonfiV2,
+		Type:        models.LiquidityWithdraw,
+		Hashes:      hashes,
+		Lt:          payout.Lt,
+		Time:        payout.TransactionTime,
+		CatchTime:   payout.EventCatchTime,
+		PoolAddress: core.InMessageSource(payoutTrace),
+		Provider:    payout.Owner,
+		Assets: []*models.LiquidityAsset{
+			{Wallet: payout.Token0WalletAddress, Amount: payout.Amount0Out},
+			{Wallet: payout.Token1WalletAddress, Amount: payout.Amount1Out},
+		},
+		LpAmount: lpAmount,
+	}, nil
+}
+
+// burntLpAmount parses burn_notification_ext sent to the pool by LP wallet
+func burntLpAmount(pool *tonapi.Trace) (*big.Int, error) {
+	opCode, body, e := core.InMessageBody(pool)
+	if e != nil {
+		return nil, e
+	}
+	if opCode != burnNotificationExtOpCode && opCode != core.JettonBurnNotificationCode {
+		return nil, errNotLiquidityMessage
+	}
+	if _, e := body.LoadUInt(64); e != nil { // query id
+		return nil, e
+	}
+	return body.LoadBigCoins()
+}
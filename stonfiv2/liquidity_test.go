@@ -0,0 +1,138 @@
+package stonfiv2
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tonkeeper/tonapi-go"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"testing"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+var (
+	pool         = address.MustParseAddr("EQD8TJ8xEWB1SpnRE4d89YO3jl0W0EiBnNS4IBaHaUmdfizE")
+	provider     = address.MustParseAddr("EQCM3B12QK1e4yZSf8GtBRT0aLMNyEsBc_DhVfRRtOEffLez")
+	routerWallet = address.MustParseAddr("EQARULUYsmJq1RiZ-YiH-IJLcAZUVkVff-KBPwEmmaQGH6aC")
+	otherWallet  = address.MustParseAddr("EQCtiv7PrMJImWiF2L5oJCgPnzp-VML2CAt5cbn1VsKAxLiE")
+	lpWallet     = address.MustParseAddr("EQDa4VOnTYlLvDJ0gZjNYm5PXfSmmtL6Vs6A_CZEtXCNICq_")
+)
+
+func rawAddress(addr *address.Address) string {
+	return fmt.Sprintf("%v:%x", addr.Workchain(), addr.Data())
+}
+
+func accountAddress(addr *address.Address) tonapi.OptAccountAddress {
+	return tonapi.NewOptAccountAddress(tonapi.AccountAddress{Address: rawAddress(addr)})
+}
+
+func jettonMessage(opCode uint64, amount int64) tonapi.OptString {
+	body := cell.BeginCell().MustStoreUInt(opCode, 32).MustStoreUInt(1, 64).MustStoreBigCoins(big.NewInt(amount)).EndCell()
+	return tonapi.NewOptString(hex.EncodeToString(body.ToBOC()))
+}
+
+func notification(hash string, forwardOp int, amount int64, children ...tonapi.Trace) tonapi.Trace {
+	return tonapi.Trace{
+		Transaction: tonapi.Transaction{
+			Hash:  hash,
+			Lt:    10,
+			Utime: 1700000000,
+			InMsg: tonapi.NewOptMessage(tonapi.Message{
+				OpCode: tonapi.NewOptString(core.JettonNotifyOpCode),
+				Source: accountAddress(routerWallet),
+				DecodedBody: []byte(fmt.Sprintf(`{"query_id":1,"amount":"%v","sender":"%v","forward_payload":{"is_right":true,"value":{"sum_type":"DexPayload","op_code":%v}}}`,
+					amount, rawAddress(provider), forwardOp)),
+			}),
+		},
+		Interfaces: []string{stonfiRouterV2},
+		Children:   children,
+	}
+}
+
+func TestExtractStonfiV2ProvideFromTrace(t *testing.T) {
+	minted := tonapi.Trace{Transaction: tonapi.Transaction{
+		Account: tonapi.AccountAddress{Address: rawAddress(lpWallet)},
+		InMsg: tonapi.NewOptMessage(tonapi.Message{
+			OpCode:  tonapi.NewOptString(core.JettonInternalTransferOpCode),
+			Source:  accountAddress(pool),
+			RawBody: jettonMessage(core.JettonInternalTransferCode, 300),
+		}),
+	}}
+	poolTrace := tonapi.Trace{
+		Transaction: tonapi.Transaction{Account: tonapi.AccountAddress{Address: rawAddress(pool)}},
+		Interfaces:  []string{"stonfi_pool_v2"},
+		Children:    []tonapi.Trace{minted},
+	}
+	root := tonapi.Trace{
+		Transaction: tonapi.Transaction{Hash: "root"},
+		Children: []tonapi.Trace{
+			notification("provide", provideLpOpCode, 1000, poolTrace),
+			// a notification forwarding anything but provide_lp is not a deposit
+			notification("swap", 0, 500),
+		},
+	}
+
+	infos := ExtractStonfiV2LiquidityFromRootTrace(&root)
+
+	assert.Equal(t, 1, len(infos))
+	info := infos[0]
+	assert.Equal(t, models.LiquidityProvide, info.Type)
+	assert.Equal(t, models.StonfiV2, info.Dex)
+	assert.Equal(t, "root", info.TraceID)
+	assert.Equal(t, []string{"provide"}, info.Hashes)
+	assert.Equal(t, uint64(10), info.Lt)
+	assert.True(t, pool.Equals(info.PoolAddress))
+	assert.True(t, provider.Equals(info.Provider))
+	assert.Equal(t, 1, len(info.Assets))
+	assert.True(t, routerWallet.Equals(info.Assets[0].Wallet))
+	assert.Equal(t, big.NewInt(1000), info.Assets[0].Amount)
+	assert.Equal(t, big.NewInt(300), info.LpAmount)
+}
+
+func TestExtractStonfiV2WithdrawalFromTrace(t *testing.T) {
+	payout := func(exitCode uint64) tonapi.Trace {
+		return tonapi.Trace{
+			Transaction: tonapi.Transaction{
+				Hash:  fmt.Sprint("payout", exitCode),
+				Lt:    20,
+				Utime: 1700000000,
+				InMsg: tonapi.NewOptMessage(tonapi.Message{
+					DecodedOpName: tonapi.NewOptString(payoutOpCode),
+					Source:        accountAddress(pool),
+					DecodedBody: []byte(fmt.Sprintf(`{"query_id":1,"to_address":"%v","exit_code":%v,"additional_info":{"amount0_out":"700","token0_address":"%v","amount1_out":"900","token1_address":"%v"}}`,
+						rawAddress(provider), exitCode, rawAddress(routerWallet), rawAddress(otherWallet))),
+				}),
+			},
+			Interfaces: []string{stonfiRouterV2},
+		}
+	}
+	root := tonapi.Trace{
+		Transaction: tonapi.Transaction{
+			Hash:    "pool",
+			Account: tonapi.AccountAddress{Address: rawAddress(pool)},
+			InMsg:   tonapi.NewOptMessage(tonapi.Message{RawBody: jettonMessage(burnNotificationExtOpCode, 400)}),
+		},
+		Interfaces: []string{"stonfi_pool_v2"},
+		Children:   []tonapi.Trace{payout(burnOkExitCode), payout(0)},
+	}
+
+	infos := ExtractStonfiV2LiquidityFromRootTrace(&root)
+
+	assert.Equal(t, 1, len(infos))
+	info := infos[0]
+	assert.Equal(t, models.LiquidityWithdraw, info.Type)
+	assert.Equal(t, models.StonfiV2, info.Dex)
+	assert.Equal(t, []string{"pool", fmt.Sprint("payout", burnOkExitCode)}, info.Hashes)
+	assert.Equal(t, uint64(20), info.Lt)
+	assert.True(t, pool.Equals(info.PoolAddress))
+	assert.True(t, provider.Equals(info.Provider))
+	assert.Equal(t, 2, len(info.Assets))
+	assert.True(t, routerWallet.Equals(info.Assets[0].Wallet))
+	assert.Equal(t, big.NewInt(700), info.Assets[0].Amount)
+	assert.True(t, otherWallet.Equals(info.Assets[1].Wallet))
+	assert.Equal(t, big.NewInt(900), info.Assets[1].Amount)
+	assert.Equal(t, big.NewInt(400), info.LpAmount)
+}
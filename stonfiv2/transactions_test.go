@@ -1,19 +1,15 @@
 package stonfiv2
 
 import (
-	"context"
 	"github.com/stretchr/testify/assert"
-	"github.com/tonkeeper/tonapi-go"
 	"github.com/xssnick/tonutils-go/address"
 	"math/big"
 	"testing"
+	"tondexer/fixtures/fixturestest"
 )
 
 func Test_findRouterTransferNotificationNodes(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "49c2837771b6b07b0984ac3d29d57aa92e8ddb85854765fe50b42239e28bfa34"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "49c2837771b6b07b0984ac3d29d57aa92e8ddb85854765fe50b42239e28bfa34")
 
 	notificationTraces := findRouterTransferNotificationNodes(trace)
 
@@ -22,10 +18,7 @@ func Test_findRouterTransferNotificationNodes(t *testing.T) {
 }
 
 func Test_findPoolAddressForNotification(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "49c2837771b6b07b0984ac3d29d57aa92e8ddb85854765fe50b42239e28bfa34"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "49c2837771b6b07b0984ac3d29d57aa92e8ddb85854765fe50b42239e28bfa34")
 
 	notificationTrace := findRouterTransferNotificationNodes(trace)[0]
 
@@ -35,10 +28,7 @@ func Test_findPoolAddressForNotification(t *testing.T) {
 }
 
 func Test_findPayoutForNotification(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "49c2837771b6b07b0984ac3d29d57aa92e8ddb85854765fe50b42239e28bfa34"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "49c2837771b6b07b0984ac3d29d57aa92e8ddb85854765fe50b42239e28bfa34")
 
 	notificationTrace := findRouterTransferNotificationNodes(trace)[0]
 	payout := findPayoutForNotification(notificationTrace)
@@ -47,10 +37,7 @@ func Test_findPayoutForNotification(t *testing.T) {
 }
 
 func Test_findVaultPayoutForNotification(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "49c2837771b6b07b0984ac3d29d57aa92e8ddb85854765fe50b42239e28bfa34"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "49c2837771b6b07b0984ac3d29d57aa92e8ddb85854765fe50b42239e28bfa34")
 
 	notificationTrace := findRouterTransferNotificationNodes(trace)[0]
 	payout := findVaultPayoutForNotification(notificationTrace)
@@ -59,10 +46,7 @@ func Test_findVaultPayoutForNotification(t *testing.T) {
 }
 
 func Test_findNextSwapTraces(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "05761a40ef710ce6ec6d6b7a22e717701e7cedfc21cd2f8f372a0f627a9981a8"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "05761a40ef710ce6ec6d6b7a22e717701e7cedfc21cd2f8f372a0f627a9981a8")
 
 	swapTraces := findSwapTraces(trace)
 
@@ -76,10 +60,7 @@ func Test_findNextSwapTraces(t *testing.T) {
 }
 
 func Test_ExtractStonfiV2SwapsFromRootTrace(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "093e92969e33af9d162c23724d4581a7c137ceecab5641bb9a1c4b191c34a95a"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "093e92969e33af9d162c23724d4581a7c137ceecab5641bb9a1c4b191c34a95a")
 
 	swapInfos := ExtractStonfiV2SwapsFromRootTrace(trace)
 
@@ -122,10 +103,7 @@ func Test_ExtractStonfiV2SwapsFromRootTrace(t *testing.T) {
 }
 
 func Test_DoNotParseFailedTransaction(t *testing.T) {
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "b960db5ada0013fa0d70639e258863d48861e06648f5201b89d7cd34bc6c7442"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "b960db5ada0013fa0d70639e258863d48861e06648f5201b89d7cd34bc6c7442")
 
 	swapInfos := ExtractStonfiV2SwapsFromRootTrace(trace)
 
@@ -134,10 +112,7 @@ func Test_DoNotParseFailedTransaction(t *testing.T) {
 
 func Test_TransactionWithSmthFailed(t *testing.T) {
 	// in fact query id does not fit into int64 - only uint64
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "fd88effd16246914a578fddf8484ca3f919d94e6534e43ec5abb0674a0ce0c54"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "fd88effd16246914a578fddf8484ca3f919d94e6534e43ec5abb0674a0ce0c54")
 
 	swapInfos := ExtractStonfiV2SwapsFromRootTrace(trace)
 
@@ -146,10 +121,7 @@ func Test_TransactionWithSmthFailed(t *testing.T) {
 
 func TestTraceIDOfSwapInfo(t *testing.T) {
 	// in fact query id does not fit into int64 - only uint64
-	client, _ := tonapi.New()
-
-	params := tonapi.GetTraceParams{TraceID: "093e92969e33af9d162c23724d4581a7c137ceecab5641bb9a1c4b191c34a95a"}
-	trace, _ := client.GetTrace(context.Background(), params)
+	trace := fixturestest.Trace(t, "093e92969e33af9d162c23724d4581a7c137ceecab5641bb9a1c4b191c34a95a")
 
 	swapInfos := ExtractStonfiV2SwapsFromRootTrace(trace)
 
@@ -0,0 +1,17 @@
+package tonco
+
+import (
+	"testing"
+	"tondexer/fixtures"
+	"tondexer/fixtures/fixturestest"
+)
+
+// TestGoldenSwaps runs over every recorded TONCO trace, record them with go run ./cmd/fixtures -dir tonco record <trace hash>...
+func TestGoldenSwaps(t *testing.T) {
+	for _, id := range fixturestest.Traces(t, fixtures.TracesDir) {
+		t.Run(id, func(t *testing.T) {
+			trace := fixturestest.Trace(t, id)
+			fixturestest.Golden(t, id, ExtractToncoSwapsFromRootTrace(trace))
+		})
+	}
+}
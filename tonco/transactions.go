@@ -0,0 +1,198 @@
+package tonco
+
+import (
+	"errors"
+	"github.com/tonkeeper/tonapi-go"
+	"github.com/xssnick/tonutils-go/address"
+	"log"
+	"math/big"
+	"time"
+	"tondexer/core"
+	"tondexer/models"
+)
+
+const Router = "EQC_-t0nCnOFMdp7E7qPxAOCbCWGFz-e3pwxb6tTvFmshjt5"
+
+var Routers = []string{Router}
+
+const poolSwapCode = 0xa7fb58f8
+const routerPayToCode = 0xa1daa96d
+
+func isRouter(trace *tonapi.Trace) bool {
+	account, e := address.ParseRawAddr(trace.Transaction.Account.Address)
+	return e == nil && account.String() == Router
+}
+
+func inMessageCode(trace *tonapi.Trace) uint64 {
+	code, _, e := core.InMessageBody(trace)
+	if e != nil {
+		return 0
+	}
+	return code
+}
+
+// ExtractToncoSwapsFromRootTrace finds router notifications followed by the pool swap and the router payout
+func ExtractToncoSwapsFromRootTrace(root *tonapi.Trace) []*models.SwapInfo {
+	var swaps []*models.SwapInfo
+
+	var traverse func(trace *tonapi.Trace)
+	traverse = func(trace *tonapi.Trace) {
+		if isRouter(trace) && core.InMessageOpCode(trace) == core.JettonNotifyOpCode {
+			for i := range trace.Children {
+				poolTrace := &trace.Children[i]
+				if inMessageCode(poolTrace) != poolSwapCode {
+					continue
+				}
+				for j := range poolTrace.Children {
+					payToTrace := &poolTrace.Children[j]
+					if !isRouter(payToTrace) || inMessageCode(payToTrace) != routerPayToCode {
+						continue
+					}
+					swap, e := swapInfo(trace, poolTrace, payToTrace, root)
+					if e != nil {
+						log.Printf("Warning: could not parse tonco swap: %v . %v \n", trace.Transaction.Hash, e)
+						continue
+					}
+					if swap != nil {
+						swaps = append(swaps, swap)
+					}
+				}
+			}
+		}
+		for i := range trace.Children {
+			traverse(&trace.Children[i])
+		}
+	}
+	traverse(root)
+
+	return swaps
+}
+
+func notificationFromTrace(trace *tonapi.Trace) (*models.SwapTransferNotification, error) {
+	_, body, e := core.InMessageBody(trace)
+	if e != nil {
+		return nil, e
+	}
+	queryId, e := body.LoadUInt(64)
+	if e != nil {
+		return nil, e
+	}
+	amount, e := body.LoadBigCoins()
+	if e != nil {
+		return nil, e
+	}
+	sender, e := body.LoadAddr()
+	if e != nil {
+		return nil, e
+	}
+	return &models.SwapTransferNotification{
+		Hash:            trace.Transaction.Hash,
+		Lt:              uint64(trace.Transaction.Lt),
+		TransactionTime: time.UnixMilli(trace.Transaction.Utime * 1000),
+		EventCatchTime:  time.Now(),
+		QueryId:         queryId,
+		Amount:          amount,
+		Sender:          sender,
+		TokenWallet:     core.InMessageSource(trace),
+	}, nil
+}
+
+type payTo struct {
+	queryId  uint64
+	owner    *address.Address
+	exitCode uint64
+	amount0  *big.Int
+	wallet0  *address.Address
+	amount1  *big.Int
+	wallet1  *address.Address
+}
+
+func payToFromTrace(trace *tonapi.Trace) (*payTo, error) {
+	_, body, e := core.InMessageBody(trace)
+	if e != nil {
+		return nil, e
+	}
+	var result payTo
+	if result.queryId, e = body.LoadUInt(64); e != nil {
+		return nil, e
+	}
+	if result.owner, e = body.LoadAddr(); e != nil {
+		return nil, e
+	}
+	if _, e = body.LoadAddr(); e != nil { // second receiver
+		return nil, e
+	}
+	if result.exitCode, e = body.LoadUInt(32); e != nil {
+		return nil, e
+	}
+	if _, e = body.LoadUInt(64); e != nil { // seqno
+		return nil, e
+	}
+	coins, e := body.LoadRef()
+	if e != nil {
+		return nil, e
+	}
+	if result.amount0, e = coins.LoadBigCoins(); e != nil {
+		return nil, e
+	}
+	if result.wallet0, e = coins.LoadAddr(); e != nil {
+		return nil, e
+	}
+	if result.amount1, e = coins.LoadBigCoins(); e != nil {
+		return nil, e
+	}
+	if result.wallet1, e = coins.LoadAddr(); e != nil {
+		return nil, e
+	}
+	return &result, nil
+}
+
+func swapInfo(notificationTrace *tonapi.Trace, poolTrace *tonapi.Trace, payToTrace *tonapi.Trace, root *tonapi.Trace) (*models.SwapInfo, error) {
+	notification, e := notificationFromTrace(notificationTrace)
+	if e != nil {
+		return nil, e
+	}
+	payment, e := payToFromTrace(payToTrace)
+	if e != nil {
+		return nil, e
+	}
+
+	// the payout may refund the rest of the input, so the output is the wallet other than the incoming one
+	var outWallet *address.Address
+	var outAmount *big.Int
+	if notification.TokenWallet != nil && payment.wallet0.Equals(notification.TokenWallet) {
+		outWallet, outAmount = payment.wallet1, payment.amount1
+	} else {
+		outWallet, outAmount = payment.wallet0, payment.amount0
+	}
+	if outAmount.Sign() == 0 {
+		return nil, nil // swap wasn't executed and everything is refunded
+	}
+	if notification.TokenWallet == nil {
+		return nil, errors.New("notification without source")
+	}
+
+	pool, e := address.ParseRawAddr(poolTrace.Transaction.Account.Address)
+	if e != nil {
+		return nil, e
+	}
+
+	return &models.SwapInfo{
+		TraceID:      root.Transaction.Hash,
+		Notification: notification,
+		Payment: &models.PayoutRequest{
+			Hash:                payToTrace.Transaction.Hash,
+			Lt:                  uint64(payToTrace.Transaction.Lt),
+			TransactionTime:     time.UnixMilli(payToTrace.Transaction.Utime * 1000),
+			EventCatchTime:      time.Now(),
+			QueryId:             payment.queryId,
+			Owner:               payment.owner,
+			ExitCode:            payment.exitCode,
+			Amount0Out:          big.NewInt(0),
+			Token0WalletAddress: notification.TokenWallet,
+			Amount1Out:          outAmount,
+			Token1WalletAddress: outWallet,
+		},
+		PoolAddress: pool,
+	}, nil
+}
@@ -0,0 +1,97 @@
+package tonco
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tonkeeper/tonapi-go"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"math/big"
+	"testing"
+	"tondexer/core"
+)
+
+func rawAddress(addr *address.Address) string {
+	return fmt.Sprintf("%v:%x", addr.Workchain(), addr.Data())
+}
+
+func message(body *cell.Cell, opCode string, source *address.Address) tonapi.OptMessage {
+	msg := tonapi.Message{
+		OpCode:  tonapi.NewOptString(opCode),
+		RawBody: tonapi.NewOptString(hex.EncodeToString(body.ToBOC())),
+	}
+	if source != nil {
+		msg.Source = tonapi.NewOptAccountAddress(tonapi.AccountAddress{Address: rawAddress(source)})
+	}
+	return tonapi.NewOptMessage(msg)
+}
+
+func TestExtractToncoSwapFromTrace(t *testing.T) {
+	router := address.MustParseAddr(Router)
+	pool := address.MustParseAddr("EQD25vStEwc-h1QT1qlsYPQwqU5IiOhox5II0C_xsDNpMVo7")
+	sender := address.MustParseAddr("EQCM3B12QK1e4yZSf8GtBRT0aLMNyEsBc_DhVfRRtOEffLez")
+	walletIn := address.MustParseAddr("EQDa4VOnTYlLvDJ0gZjNYm5PXfSmmtL6Vs6A_CZEtXCNICq_")
+	walletOut := address.MustParseAddr("EQA-X_yo3fzzbDbJ_0bzFWKqtRuZFIRa1sJsveZJ1YpViO3r")
+
+	notificationBody := cell.BeginCell().
+		MustStoreUInt(0x7362d09c, 32).
+		MustStoreUInt(7, 64).
+		MustStoreBigCoins(big.NewInt(1000)).
+		MustStoreAddr(sender).
+		EndCell()
+	swapBody := cell.BeginCell().MustStoreUInt(poolSwapCode, 32).MustStoreUInt(7, 64).EndCell()
+	payToBody := cell.BeginCell().
+		MustStoreUInt(routerPayToCode, 32).
+		MustStoreUInt(7, 64).
+		MustStoreAddr(sender).
+		MustStoreAddr(sender).
+		MustStoreUInt(200, 32).
+		MustStoreUInt(1, 64).
+		MustStoreRef(cell.BeginCell().
+			MustStoreBigCoins(big.NewInt(10)).
+			MustStoreAddr(walletIn).
+			MustStoreBigCoins(big.NewInt(2500)).
+			MustStoreAddr(walletOut).
+			EndCell()).
+		EndCell()
+
+	root := tonapi.Trace{
+		Transaction: tonapi.Transaction{
+			Hash:    "notification",
+			Lt:      100,
+			Utime:   1700000000,
+			Account: tonapi.AccountAddress{Address: rawAddress(router)},
+			InMsg:   message(notificationBody, core.JettonNotifyOpCode, walletIn),
+		},
+		Children: []tonapi.Trace{{
+			Transaction: tonapi.Transaction{
+				Hash:    "swap",
+				Account: tonapi.AccountAddress{Address: rawAddress(pool)},
+				InMsg:   message(swapBody, "0xa7fb58f8", router),
+			},
+			Children: []tonapi.Trace{{
+				Transaction: tonapi.Transaction{
+					Hash:    "payto",
+					Lt:      102,
+					Account: tonapi.AccountAddress{Address: rawAddress(router)},
+					InMsg:   message(payToBody, "0xa1daa96d", pool),
+				},
+			}},
+		}},
+	}
+
+	swaps := ExtractToncoSwapsFromRootTrace(&root)
+
+	assert.Equal(t, 1, len(swaps))
+	swap := swaps[0]
+	assert.Equal(t, "notification", swap.TraceID)
+	assert.True(t, pool.Equals(swap.PoolAddress))
+	assert.Equal(t, big.NewInt(1000), swap.Notification.Amount)
+	assert.True(t, sender.Equals(swap.Notification.Sender))
+	assert.Equal(t, "payto", swap.Payment.Hash)
+	assert.True(t, walletIn.Equals(swap.Payment.Token0WalletAddress))
+	assert.Equal(t, big.NewInt(0), swap.Payment.Amount0Out)
+	assert.True(t, walletOut.Equals(swap.Payment.Token1WalletAddress))
+	assert.Equal(t, big.NewInt(2500), swap.Payment.Amount1Out)
+}
@@ -0,0 +1,146 @@
+package traces
+
+import (
+	"compress/gzip"
+	"errors"
+	"github.com/tonkeeper/tonapi-go"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const archiveSuffix = ".json.gz"
+
+// Archive keeps every fetched trace gzipped under <dir>/<first two chars of the id>/<id>.json.gz, the id is the hash of the root transaction
+type Archive struct {
+	Dir string
+}
+
+func NewArchive(dir string) (*Archive, error) {
+	if e := os.MkdirAll(dir, 0755); e != nil {
+		return nil, e
+	}
+	return &Archive{Dir: dir}, nil
+}
+
+func (archive *Archive) path(id string) string {
+	prefix := id
+	if len(prefix) > 2 {
+		prefix = prefix[:2]
+	}
+	return filepath.Join(archive.Dir, prefix, id+archiveSuffix)
+}
+
+// Write stores the trace atomically, archived traces are not rewritten
+func (archive *Archive) Write(trace *tonapi.Trace) error {
+	id := trace.Transaction.Hash
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return errors.New("invalid trace id " + id)
+	}
+	path := archive.path(id)
+	if _, e := os.Stat(path); e == nil {
+		return nil
+	}
+	data, e := trace.MarshalJSON()
+	if e != nil {
+		return e
+	}
+	if e := os.MkdirAll(filepath.Dir(path), 0755); e != nil {
+		return e
+	}
+	tmp, e := os.CreateTemp(filepath.Dir(path), id+".*.tmp")
+	if e != nil {
+		return e
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := gzip.NewWriter(tmp)
+	if _, e := writer.Write(data); e != nil {
+		tmp.Close()
+		return e
+	}
+	if e := writer.Close(); e != nil {
+		tmp.Close()
+		return e
+	}
+	if e := tmp.Close(); e != nil {
+		return e
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (archive *Archive) Read(id string) (*tonapi.Trace, error) {
+	return readArchiveFile(archive.path(id))
+}
+
+// Each passes archived traces to the function ordered by id and stops at the first error
+func (archive *Archive) Each(fn func(trace *tonapi.Trace) error) error {
+	var paths []string
+	e := filepath.WalkDir(archive.Dir, func(path string, entry fs.DirEntry, e error) error {
+		if e != nil {
+			return e
+		}
+		if !entry.IsDir() && strings.HasSuffix(path, archiveSuffix) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if e != nil {
+		return e
+	}
+	sort.Slice(paths, func(i, j int) bool { return filepath.Base(paths[i]) < filepath.Base(paths[j]) })
+	for _, path := range paths {
+		trace, e := readArchiveFile(path)
+		if e != nil {
+			log.Printf("Warning: Skipping broken archived trace %v: %v \n", path, e)
+			continue
+		}
+		if e := fn(trace); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func readArchiveFile(path string) (*tonapi.Trace, error) {
+	file, e := os.Open(path)
+	if e != nil {
+		return nil, e
+	}
+	defer file.Close()
+	reader, e := gzip.NewReader(file)
+	if e != nil {
+		return nil, e
+	}
+	defer reader.Close()
+	data, e := io.ReadAll(reader)
+	if e != nil {
+		return nil, e
+	}
+	var trace tonapi.Trace
+	if e := trace.UnmarshalJSON(data); e != nil {
+		return nil, e
+	}
+	return &trace, nil
+}
+
+// ArchivingTraceSource archives every trace fetched from the wrapped source
+type ArchivingTraceSource struct {
+	TraceSource
+	Archive *Archive
+}
+
+func (source *ArchivingTraceSource) TraceByHash(hash string) (*tonapi.Trace, error) {
+	trace, e := source.TraceSource.TraceByHash(hash)
+	if e != nil {
+		return nil, e
+	}
+	if e := source.Archive.Write(trace); e != nil {
+		log.Printf("Warning: Unable to archive trace %v: %v \n", trace.Transaction.Hash, e)
+	}
+	return trace, nil
+}
@@ -0,0 +1,36 @@
+package traces
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tonkeeper/tonapi-go"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "archive")
+	defer os.RemoveAll(dir)
+	archive, e := NewArchive(dir)
+	assert.Nil(t, e)
+
+	source := &ArchivingTraceSource{TraceSource: NewMemoryTraceSource(testTrace()), Archive: archive}
+	_, e = source.TraceByHash("router1")
+	assert.Nil(t, e)
+	assert.FileExists(t, filepath.Join(dir, "ro", "root.json.gz"))
+
+	trace, e := archive.Read("root")
+	assert.Nil(t, e)
+	assert.Equal(t, 2, len(trace.Children))
+
+	second := &tonapi.Trace{Transaction: tonapi.Transaction{Hash: "abc", Lt: 5}}
+	assert.Nil(t, archive.Write(second))
+	assert.NotNil(t, archive.Write(&tonapi.Trace{Transaction: tonapi.Transaction{Hash: "../x"}}))
+
+	var ids []string
+	assert.Nil(t, archive.Each(func(trace *tonapi.Trace) error {
+		ids = append(ids, trace.Transaction.Hash)
+		return nil
+	}))
+	assert.Equal(t, []string{"abc", "root"}, ids)
+}
@@ -0,0 +1,69 @@
+package traces
+
+import (
+	"github.com/tonkeeper/tonapi-go"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NewFileTraceSource loads every <trace id>.json file of the directory recorded by RecordingTraceSource or WriteTraceFile
+func NewFileTraceSource(dir string) (*MemoryTraceSource, error) {
+	entries, e := os.ReadDir(dir)
+	if e != nil {
+		return nil, e
+	}
+	source := NewMemoryTraceSource()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		trace, e := ReadTraceFile(filepath.Join(dir, entry.Name()))
+		if e != nil {
+			return nil, e
+		}
+		source.Add(trace)
+	}
+	return source, nil
+}
+
+func ReadTraceFile(path string) (*tonapi.Trace, error) {
+	data, e := os.ReadFile(path)
+	if e != nil {
+		return nil, e
+	}
+	var trace tonapi.Trace
+	if e := trace.UnmarshalJSON(data); e != nil {
+		return nil, e
+	}
+	return &trace, nil
+}
+
+func WriteTraceFile(dir string, trace *tonapi.Trace) error {
+	data, e := trace.MarshalJSON()
+	if e != nil {
+		return e
+	}
+	if e := os.MkdirAll(dir, 0755); e != nil {
+		return e
+	}
+	return os.WriteFile(filepath.Join(dir, trace.Transaction.Hash+".json"), data, 0644)
+}
+
+// RecordingTraceSource saves every trace fetched from the wrapped source into the directory
+type RecordingTraceSource struct {
+	TraceSource
+	Dir string
+}
+
+func (source *RecordingTraceSource) TraceByHash(hash string) (*tonapi.Trace, error) {
+	trace, e := source.TraceSource.TraceByHash(hash)
+	if e != nil {
+		return nil, e
+	}
+	if e := WriteTraceFile(source.Dir, trace); e != nil {
+		log.Printf("Warning: Unable to record trace %v: %v \n", trace.Transaction.Hash, e)
+	}
+	return trace, nil
+}
@@ -0,0 +1,96 @@
+package traces
+
+import (
+	"context"
+	"errors"
+	"github.com/tonkeeper/tonapi-go"
+	"github.com/xssnick/tonutils-go/address"
+	"sort"
+	"sync"
+	"tondexer/common"
+	"tondexer/core"
+)
+
+// MemoryTraceSource serves traces added to it, every transaction hash of a trace resolves to the whole trace
+type MemoryTraceSource struct {
+	mu     sync.RWMutex
+	traces map[string]*tonapi.Trace
+	byHash map[string]*tonapi.Trace
+}
+
+func NewMemoryTraceSource(traces ...*tonapi.Trace) *MemoryTraceSource {
+	source := &MemoryTraceSource{
+		traces: map[string]*tonapi.Trace{},
+		byHash: map[string]*tonapi.Trace{},
+	}
+	for _, trace := range traces {
+		source.Add(trace)
+	}
+	return source
+}
+
+func (source *MemoryTraceSource) Add(trace *tonapi.Trace) {
+	source.mu.Lock()
+	defer source.mu.Unlock()
+
+	source.traces[trace.Transaction.Hash] = trace
+	for _, transaction := range core.GetAllTransactionsFromTrace(trace) {
+		source.byHash[transaction.Hash] = trace
+	}
+}
+
+func (source *MemoryTraceSource) TraceByHash(hash string) (*tonapi.Trace, error) {
+	source.mu.RLock()
+	defer source.mu.RUnlock()
+
+	if trace, exists := source.byHash[hash]; exists {
+		return trace, nil
+	}
+	return nil, errors.New("no trace for transaction " + hash)
+}
+
+// SubscribeToAccounts sends stored transactions of the accounts ordered by lt
+func (source *MemoryTraceSource) SubscribeToAccounts(ctx context.Context, accounts []string, transactions chan TransactionEvent) {
+	parsedAccounts := common.FilterNonNill(common.Map(accounts, parseAnyAddress))
+
+	source.mu.RLock()
+	var matched []TransactionEvent
+	for _, trace := range source.traces {
+		for _, transaction := range core.GetAllTransactionsFromTrace(trace) {
+			account := parseAnyAddress(transaction.Account.Address)
+			if account == nil {
+				continue
+			}
+			for _, subscribed := range parsedAccounts {
+				if subscribed.Equals(account) {
+					matched = append(matched, TransactionEvent{
+						Account: account.String(),
+						Lt:      uint64(transaction.Lt),
+						Hash:    transaction.Hash,
+					})
+					break
+				}
+			}
+		}
+	}
+	source.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Lt < matched[j].Lt })
+	for _, transaction := range matched {
+		select {
+		case transactions <- transaction:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func parseAnyAddress(s string) *address.Address {
+	if addr, e := address.ParseAddr(s); e == nil {
+		return addr
+	}
+	if addr, e := address.ParseRawAddr(s); e == nil {
+		return addr
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package traces
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tonkeeper/tonapi-go"
+	"github.com/xssnick/tonutils-go/address"
+	"os"
+	"testing"
+)
+
+const router = "EQB3ncyBUTjZUA5EnFKR5_EnOMI9V1tTEAAPaiU71gc4TiUt"
+const wallet = "EQCM3B12QK1e4yZSf8GtBRT0aLMNyEsBc_DhVfRRtOEffLez"
+
+func rawAddress(friendly string) string {
+	addr := address.MustParseAddr(friendly)
+	return fmt.Sprintf("%v:%x", addr.Workchain(), addr.Data())
+}
+
+func testTrace() *tonapi.Trace {
+	return &tonapi.Trace{
+		Transaction: tonapi.Transaction{Hash: "root", Lt: 1, Account: tonapi.AccountAddress{Address: rawAddress(wallet)}},
+		Children: []tonapi.Trace{
+			{
+				Transaction: tonapi.Transaction{Hash: "router2", Lt: 3, Account: tonapi.AccountAddress{Address: rawAddress(router)}},
+			},
+			{
+				Transaction: tonapi.Transaction{Hash: "router1", Lt: 2, Account: tonapi.AccountAddress{Address: rawAddress(router)}},
+			},
+		},
+	}
+}
+
+func TestMemoryTraceByAnyHash(t *testing.T) {
+	source := NewMemoryTraceSource(testTrace())
+
+	trace, e := source.TraceByHash("router1")
+	assert.Nil(t, e)
+	assert.Equal(t, "root", trace.Transaction.Hash)
+
+	_, e = source.TraceByHash("unknown")
+	assert.NotNil(t, e)
+}
+
+func TestMemorySubscribeToAccounts(t *testing.T) {
+	source := NewMemoryTraceSource(testTrace())
+
+	events := make(chan TransactionEvent, 10)
+	source.SubscribeToAccounts(context.Background(), []string{router}, events)
+	close(events)
+
+	var received []TransactionEvent
+	for event := range events {
+		received = append(received, event)
+	}
+	assert.Equal(t, []TransactionEvent{
+		{Account: router, Lt: 2, Hash: "router1"},
+		{Account: router, Lt: 3, Hash: "router2"},
+	}, received)
+}
+
+func TestFileTraceSourceRoundTrip(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "traces")
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, WriteTraceFile(dir, testTrace()))
+
+	source, e := NewFileTraceSource(dir)
+	assert.Nil(t, e)
+
+	trace, e := source.TraceByHash("router2")
+	assert.Nil(t, e)
+	assert.Equal(t, "root", trace.Transaction.Hash)
+	assert.Equal(t, 2, len(trace.Children))
+}
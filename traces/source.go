@@ -0,0 +1,21 @@
+package traces
+
+import (
+	"context"
+	"github.com/tonkeeper/tonapi-go"
+)
+
+type TransactionEvent struct {
+	Account string // user friendly bounceable form
+	Lt      uint64
+	Hash    string
+}
+
+// TraceSource is where the pipeline takes transactions and their traces from
+type TraceSource interface {
+	// SubscribeToAccounts sends transactions of the accounts to the channel.
+	// Streaming sources block until the context is cancelled, recorded ones return after all transactions were sent.
+	SubscribeToAccounts(ctx context.Context, accounts []string, transactions chan TransactionEvent)
+	// TraceByHash returns the whole trace containing the transaction with the hash
+	TraceByHash(hash string) (*tonapi.Trace, error)
+}
@@ -0,0 +1,54 @@
+package traces
+
+import (
+	"context"
+	"github.com/tonkeeper/tonapi-go"
+	"log"
+	"time"
+	"tondexer/core"
+)
+
+type TonapiTraceSource struct {
+	StreamingApi *tonapi.StreamingAPI
+	ConsoleApi   *core.TonConsoleApi
+}
+
+func NewTonapiTraceSource(token string) *TonapiTraceSource {
+	client, _ := tonapi.New(tonapi.WithToken(token))
+	return &TonapiTraceSource{
+		StreamingApi: tonapi.NewStreamingAPI(tonapi.WithStreamingToken(token)),
+		ConsoleApi:   &core.TonConsoleApi{Client: client},
+	}
+}
+
+func (source *TonapiTraceSource) SubscribeToAccounts(ctx context.Context, accounts []string, transactions chan TransactionEvent) {
+	for ctx.Err() == nil {
+		e := source.StreamingApi.WebsocketHandleRequests(ctx, func(ws tonapi.Websocket) error {
+			ws.SetTransactionHandler(func(data tonapi.TransactionEventData) {
+				go func() {
+					select {
+					case transactions <- TransactionEvent{
+						Account: data.AccountID.ToHuman(true, false),
+						Lt:      data.Lt,
+						Hash:    data.TxHash,
+					}:
+					case <-ctx.Done():
+					}
+				}()
+			})
+			if err := ws.SubscribeToTransactions(accounts, nil); err != nil {
+				return err
+			}
+
+			return nil
+		})
+		if e != nil && ctx.Err() == nil {
+			log.Printf("Streaming failed! for accounts %v: %v \n", accounts, e)
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+func (source *TonapiTraceSource) TraceByHash(hash string) (*tonapi.Trace, error) {
+	return source.ConsoleApi.GetTraceByHash(hash)
+}
@@ -1,18 +1,36 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/xssnick/tonutils-go/address"
+	"io"
 	"log"
+	"net/http"
 	"os"
+	"time"
 	"tondexer/core"
+	"tondexer/feed"
+	"tondexer/migrations"
 	"tondexer/models"
 	"tondexer/persistence"
 )
 
+// WindowRequest is either a period back from now or an explicit range, from and to are unix seconds or RFC3339
+type WindowRequest struct {
+	Period   string `form:"period" binding:"required_without=From,omitempty,oneof=day week month"`
+	From     string `form:"from"`
+	To       string `form:"to"`
+	Interval string `form:"interval" binding:"omitempty,oneof=5m 1h 1d 1w"`
+}
+
 type DexPeriodRequest struct {
-	Period string `form:"period" binding:"required,oneof=day week month"`
-	Dex    string `form:"dex" binding:"omitempty,oneof=all stonfi dedust"`
+	WindowRequest
+	Dex string `form:"dex" binding:"omitempty,oneof=all stonfi dedust tonco"`
 }
 
 type Config struct {
@@ -21,6 +39,12 @@ type Config struct {
 	DbUser     string `yaml:"db_user" env:"DB_USER" env-default:"default"`
 	DbPassword string `yaml:"db_password" env:"DB_PASSWORD" env-default:""`
 	DbName     string `yaml:"db_name" env:"DB_NAME" env-default:"default"`
+	// Store is clickhouse or memory, the memory one serves an empty API without a database
+	Store string `yaml:"store" env:"STORE" env-default:"clickhouse"`
+
+	FeedPollInterval   time.Duration `yaml:"feed_poll_interval" env:"FEED_POLL_INTERVAL" env-default:"2s"`
+	FeedLookback       time.Duration `yaml:"feed_lookback" env:"FEED_LOOKBACK" env-default:"1m"`
+	FeedMaxSubscribers int           `yaml:"feed_max_subscribers" env:"FEED_MAX_SUBSCRIBERS" env-default:"1000"`
 }
 
 func main() {
@@ -37,73 +61,104 @@ func main() {
 		DbPassword: cfg.DbPassword,
 		DbName:     cfg.DbName,
 	}
+	store, e := persistence.OpenStore(cfg.Store, &dbConfig)
+	if e != nil {
+		panic(e)
+	}
+	if cfg.Store == persistence.StoreClickhouse {
+		if e := migrations.CheckSchema(&dbConfig); e != nil {
+			panic(e)
+		}
+	}
+
+	hub := feed.NewHub()
+	go feed.Poll(context.Background(), store, hub, feed.PollerOptions{
+		Interval: cfg.FeedPollInterval,
+		Lookback: cfg.FeedLookback,
+	})
+
+	newRouter(store, hub, cfg.FeedMaxSubscribers).Run(":8088")
+}
 
+func newRouter(store persistence.Store, hub *feed.Hub, feedMaxSubscribers int) *gin.Engine {
 	route := gin.Default()
 
-	route.GET("/api/summary", oneRowPeriodDexRequest[persistence.SummaryStats](&dbConfig, func(cfg *core.DbConfig, period models.Period, dex models.Dex) string {
-		return persistence.SwapsSummarySql(cfg, period, dex)
-	}))
-	route.GET("/api/swaps/latest", latestSwaps(&dbConfig))
-	route.GET("/api/volumeHistory", periodDexArrayRequest[persistence.VolumeHistoryEntry](&dbConfig, func(config *core.DbConfig, period models.Period, dex models.Dex) string {
-		return persistence.VolumeHistorySqlQuery(config, period, dex)
-	}))
-	route.GET("/api/swaps/top", periodDexArrayRequest[persistence.EnrichedSwapCH](&dbConfig, func(config *core.DbConfig, period models.Period, dex models.Dex) string {
-		return persistence.TopSwapsSqlQuery(config, period, dex)
-	}))
-	route.GET("/api/pools/top", periodDexArrayRequest[persistence.PoolVolume](&dbConfig, func(config *core.DbConfig, period models.Period, dex models.Dex) string {
-		return persistence.TopPoolsRequest(config, period, dex)
-	}))
-	route.GET("api/jettons/top", periodDexArrayRequest[persistence.JettonVolume](&dbConfig, func(config *core.DbConfig, period models.Period, dex models.Dex) string {
-		return persistence.TopJettonRequest(config, period, dex)
-	}))
-	route.GET("/api/users/top", periodDexArrayRequest[persistence.UserVolume](&dbConfig, func(config *core.DbConfig, period models.Period, dex models.Dex) string {
-		return persistence.TopUsersRequest(config, period, dex)
-	}))
-	route.GET("/api/referrers/top", periodDexArrayRequest[persistence.UserVolume](&dbConfig, func(config *core.DbConfig, period models.Period, dex models.Dex) string {
-		return persistence.TopReferrersRequest(config, period, dex)
-	}))
-	route.GET("/api/profiters/top", periodDexArrayRequest[persistence.UserVolume](&dbConfig, func(config *core.DbConfig, period models.Period, dex models.Dex) string {
+	route.GET("/api/summary", oneRowPeriodDexRequest(store.Summary))
+	route.GET("/api/swaps/latest", latestSwaps(store))
+	route.GET("/api/volumeHistory", periodDexArrayRequest(store.VolumeHistory))
+	route.GET("/api/swaps/top", topSwaps(store))
+	route.GET("/api/pools/top", periodDexArrayRequest(store.TopPools))
+	route.GET("api/jettons/top", periodDexArrayRequest(store.TopJettons))
+	route.GET("/api/jettons/:master", jettonDetails(store))
+	route.GET("/api/users/top", periodDexArrayRequest(store.TopUsers))
+	route.GET("/api/users/:address", userPortfolio(store))
+	route.GET("/api/referrers/top", periodDexArrayRequest(store.TopReferrers))
+	route.GET("/api/profiters/top", periodDexArrayRequest(func(window models.Window, _ models.Dex) ([]persistence.UserVolume, error) {
 		//Deprecated
-		return persistence.TopUsersProfiters(config, period)
-	}))
-	route.GET("/api/swaps/distribution", oneRowPeriodDexRequest[persistence.SwapDistribution](&dbConfig, func(cfg *core.DbConfig, period models.Period, dex models.Dex) string {
-		return persistence.SwapsDistributionSqlQuery(cfg, period, dex)
+		return store.TopProfiters(window)
 	}))
+	route.GET("/api/swaps/distribution", oneRowPeriodDexRequest(store.SwapsDistribution))
 
-	route.GET("/api/arbitrages/latest", latestArbitrages(&dbConfig))
-	route.GET("/api/arbitrages/top", periodDexArrayRequest[persistence.EnrichedArbitrageCH](&dbConfig, func(config *core.DbConfig, period models.Period, dex models.Dex) string {
-		return persistence.TopArbitragesSqlQuery(config, period)
-	}))
-	route.GET("/api/arbitrages/volumeHistory", periodDexArrayRequest[persistence.ArbitrageHistoryEntry](&dbConfig, func(config *core.DbConfig, period models.Period, _ models.Dex) string {
-		return persistence.ArbitrageHistorySqlQuery(config, period)
-	}))
-	route.GET("/api/arbitrages/distribution", oneRowPeriodDexRequest[persistence.ArbitrageDistribution](&dbConfig, func(cfg *core.DbConfig, period models.Period, _ models.Dex) string {
-		return persistence.ArbitrageDistributionSqlQuery(cfg, period)
-	}))
-	route.GET("/api/arbitrages/users/top", periodDexArrayRequest[persistence.TopArbitrageUser](&dbConfig, func(cfg *core.DbConfig, period models.Period, dex models.Dex) string {
-		return persistence.TopArbitrageUsersSql(cfg, period)
-	}))
-	route.GET("/api/arbitrages/jettons/top", periodDexArrayRequest[persistence.TopArbitrageJetton](&dbConfig, func(cfg *core.DbConfig, period models.Period, dex models.Dex) string {
-		return persistence.TopArbitrageJettonsSql(cfg, period)
-	}))
+	route.GET("/api/arbitrages/latest", latestArbitrages(store))
+	route.GET("/api/arbitrages/top", periodDexArrayRequest(withoutDex(store.TopArbitrages)))
+	route.GET("/api/arbitrages/volumeHistory", periodDexArrayRequest(withoutDex(store.ArbitrageHistory)))
+	route.GET("/api/arbitrages/distribution", oneRowPeriodDexRequest(withoutDex(store.ArbitrageDistribution)))
+	route.GET("/api/arbitrages/users/top", periodDexArrayRequest(withoutDex(store.TopArbitrageUsers)))
+	route.GET("/api/arbitrages/jettons/top", periodDexArrayRequest(withoutDex(store.TopArbitrageJettons)))
+
+	route.GET("/api/mev/bots/top", periodDexArrayRequest(store.TopMevBots))
+	route.GET("/api/mev/victims/top", periodDexArrayRequest(store.TopMevVictims))
+
+	route.GET("/api/pools/tvl", topPoolsTvl(store))
+	route.GET("/api/pools/tvl/history", poolTvlHistory(store))
+	route.GET("/api/pools/:address", poolDetails(store))
+
+	route.GET("/api/candles", candles(store))
+
+	route.GET("/api/liquidity/providers/top", periodDexArrayRequest(store.TopLiquidityProviders))
+	route.GET("/api/liquidity/pools/flow", periodDexArrayRequest(store.PoolLiquidityFlow))
 
-	route.Run(":8088")
+	route.GET("/api/ingestion/gaps", latestIngestionGaps(store))
+
+	route.GET("/api/feed/ws", feedWebSocket(hub, feedMaxSubscribers))
+	route.GET("/api/feed/sse", feedEvents(hub, feedMaxSubscribers))
+
+	return route
+}
+
+// withoutDex adapts reads of arbitrages, they span several dexes and are not filtered by one
+func withoutDex[T any](read func(window models.Window) (T, error)) func(window models.Window, dex models.Dex) (T, error) {
+	return func(window models.Window, _ models.Dex) (T, error) {
+		return read(window)
+	}
+}
+
+func windowFromRequest(request WindowRequest) (models.Window, error) {
+	from, e := core.ParseTime(request.From)
+	if e != nil {
+		return models.Window{}, errors.New("invalid from")
+	}
+	to, e := core.ParseTime(request.To)
+	if e != nil {
+		return models.Window{}, errors.New("invalid to")
+	}
+	return models.NewWindow(request.Period, from, to, request.Interval)
 }
 
-func periodAndDexFromRequest(request DexPeriodRequest) (models.Period, models.Dex, error) {
-	period, e := models.ParsePeriod(request.Period)
+func windowAndDexFromRequest(request DexPeriodRequest) (models.Window, models.Dex, error) {
+	window, e := windowFromRequest(request.WindowRequest)
 	if e != nil {
-		return "", "", e
+		return models.Window{}, "", e
 	}
 	dex, e := models.ParseDex(request.Dex)
 	if e != nil {
-		return "", "", e
+		return models.Window{}, "", e
 	}
 
-	return period, dex, nil
+	return window, dex, nil
 }
 
-func periodDexArrayRequest[T any](cfg *core.DbConfig, sqlFunc func(cfg *core.DbConfig, period models.Period, dex models.Dex) string) func(c *gin.Context) {
+func periodDexArrayRequest[T any](read func(window models.Window, dex models.Dex) ([]T, error)) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		var request DexPeriodRequest
 		if err := c.ShouldBindQuery(&request); err != nil {
@@ -111,13 +166,14 @@ func periodDexArrayRequest[T any](cfg *core.DbConfig, sqlFunc func(cfg *core.DbC
 			c.JSON(400, gin.H{"msg": err.Error()})
 			return
 		}
-		period, dex, e := periodAndDexFromRequest(request)
+		window, dex, e := windowAndDexFromRequest(request)
 		if e != nil {
 			log.Printf("Invalid request: %v - %v\n", request.Period, request.Dex)
 			c.JSON(400, gin.H{"msg": e.Error()})
+			return
 		}
 
-		entities, e := persistence.ReadArrayFromClickhouse[T](cfg, sqlFunc(cfg, period, dex))
+		entities, e := read(window, dex)
 		if e != nil {
 			log.Printf("Error queryin entities: %v\n", e)
 			c.JSON(500, gin.H{"msg": e.Error()})
@@ -127,25 +183,107 @@ func periodDexArrayRequest[T any](cfg *core.DbConfig, sqlFunc func(cfg *core.DbC
 	}
 }
 
-func latestSwaps(cfg *core.DbConfig) func(c *gin.Context) {
+// ListingRequest pages listings from the newest rows, the cursor comes from the X-Next-Cursor header of the previous page
+type ListingRequest struct {
+	Limit  uint64  `form:"limit"`
+	Cursor string  `form:"cursor"`
+	Sender string  `form:"sender"`
+	Jetton string  `form:"jetton"`
+	Pool   string  `form:"pool"`
+	Dex    string  `form:"dex" binding:"omitempty,oneof=all stonfi dedust tonco"`
+	MinUsd float64 `form:"min_usd" binding:"gte=0"`
+	MaxUsd float64 `form:"max_usd" binding:"gte=0"`
+	From   string  `form:"from"`
+	To     string  `form:"to"`
+}
+
+func listingFromRequest(request ListingRequest) (persistence.ListingFilter, *persistence.Cursor, error) {
+	var filter persistence.ListingFilter
+	var e error
+	if filter.Dex, e = models.ParseDex(request.Dex); e != nil {
+		return filter, nil, e
+	}
+	if request.Sender != "" {
+		if _, filter.Senders, e = addressForms(request.Sender); e != nil {
+			return filter, nil, errors.New("invalid sender")
+		}
+	}
+	if request.Jetton != "" {
+		if filter.Jetton, e = normalizeAddress(request.Jetton); e != nil {
+			return filter, nil, errors.New("invalid jetton")
+		}
+	}
+	if request.Pool != "" {
+		if filter.Pool, e = normalizeAddress(request.Pool); e != nil {
+			return filter, nil, errors.New("invalid pool")
+		}
+	}
+	if request.MaxUsd > 0 && request.MinUsd > request.MaxUsd {
+		return filter, nil, errors.New("min_usd is greater than max_usd")
+	}
+	filter.MinUsd, filter.MaxUsd = request.MinUsd, request.MaxUsd
+	if filter.From, e = core.ParseTime(request.From); e != nil {
+		return filter, nil, errors.New("invalid from")
+	}
+	if filter.To, e = core.ParseTime(request.To); e != nil {
+		return filter, nil, errors.New("invalid to")
+	}
+	cursor, e := persistence.ParseCursor(request.Cursor)
+	return filter, cursor, e
+}
+
+// setNextCursor exposes the header to the dashboard, which is served from another origin
+func setNextCursor(c *gin.Context, cursor *persistence.Cursor) {
+	c.Header("Access-Control-Expose-Headers", "X-Next-Cursor")
+	if cursor != nil {
+		c.Header("X-Next-Cursor", cursor.String())
+	}
+}
+
+func latestSwaps(store persistence.Store) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var request ListingRequest
+		if err := c.ShouldBindQuery(&request); err != nil {
+			c.JSON(400, gin.H{"msg": err.Error()})
+			return
+		}
+		filter, cursor, e := listingFromRequest(request)
+		if e != nil {
+			c.JSON(400, gin.H{"msg": e.Error()})
+			return
+		}
+		limit := persistence.PageSize(request.Limit)
+
+		swaps, e := store.LatestSwaps(filter, cursor, limit)
+		if e != nil {
+			c.JSON(500, gin.H{"msg": e.Error()})
+			return
+		}
+
+		setNextCursor(c, persistence.NextSwapsCursor(swaps, limit))
+		c.JSON(200, swaps)
+	}
+}
+
+func topSwaps(store persistence.Store) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		var request struct {
-			Limit uint64 `form:"limit"`
-			Dex   string `form:"dex" binding:"omitempty,oneof=all stonfi dedust"`
+			DexPeriodRequest
+			Limit uint64 `form:"limit,default=15"`
 		}
 		if err := c.ShouldBindQuery(&request); err != nil {
 			c.JSON(400, gin.H{"msg": err.Error()})
 			return
 		}
-		dex, e := models.ParseDex(request.Dex)
+		window, dex, e := windowAndDexFromRequest(request.DexPeriodRequest)
 		if e != nil {
-			log.Printf("Invalid dex: %v\n", request.Dex)
 			c.JSON(400, gin.H{"msg": e.Error()})
+			return
 		}
 
-		swaps, e := persistence.ReadArrayFromClickhouse[persistence.EnrichedSwapCH](cfg, persistence.LatestSwapsSqlQuery(cfg, request.Limit, dex))
+		swaps, e := store.TopSwaps(window, dex, persistence.PageSize(request.Limit))
 		if e != nil {
-			c.JSON(200, gin.H{"msg": e.Error()})
+			c.JSON(500, gin.H{"msg": e.Error()})
 			return
 		}
 
@@ -153,27 +291,433 @@ func latestSwaps(cfg *core.DbConfig) func(c *gin.Context) {
 	}
 }
 
-func latestArbitrages(cfg *core.DbConfig) func(c *gin.Context) {
+func latestArbitrages(store persistence.Store) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var request ListingRequest
+		if err := c.ShouldBindQuery(&request); err != nil {
+			c.JSON(400, gin.H{"msg": err.Error()})
+			return
+		}
+		filter, cursor, e := listingFromRequest(request)
+		if e != nil {
+			c.JSON(400, gin.H{"msg": e.Error()})
+			return
+		}
+		limit := persistence.PageSize(request.Limit)
+
+		arbitrages, e := store.LatestArbitrages(filter, cursor, limit)
+		if e != nil {
+			c.JSON(500, gin.H{"msg": e.Error()})
+			return
+		}
+
+		setNextCursor(c, persistence.NextArbitragesCursor(arbitrages, limit))
+		c.JSON(200, arbitrages)
+	}
+}
+
+// FeedRequest filters pushed events, all of them are sent without filters
+type FeedRequest struct {
+	Type   string  `form:"type" binding:"omitempty,oneof=swap arbitrage"`
+	Dex    string  `form:"dex" binding:"omitempty,oneof=all stonfi dedust tonco"`
+	Jetton string  `form:"jetton"`
+	Pool   string  `form:"pool"`
+	MinUsd float64 `form:"min_usd" binding:"gte=0"`
+}
+
+const feedPingInterval = 30 * time.Second
+
+// feedWriteTimeout drops a subscriber which doesn't read, otherwise it would block its events forever
+const feedWriteTimeout = 10 * time.Second
+
+var feedUpgrader = websocket.Upgrader{
+	// the dashboard is served from another origin
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// subscribeToFeed binds the filter and subscribes, it responds itself and returns nil on errors
+func subscribeToFeed(c *gin.Context, hub *feed.Hub, maxSubscribers int) *feed.Subscription {
+	var request FeedRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(400, gin.H{"msg": err.Error()})
+		return nil
+	}
+	filter := feed.Filter{Type: request.Type, Dex: models.Dex(request.Dex), MinUsd: request.MinUsd}
+	var e error
+	if request.Jetton != "" {
+		if filter.Jetton, e = normalizeAddress(request.Jetton); e != nil {
+			c.JSON(400, gin.H{"msg": "invalid jetton"})
+			return nil
+		}
+	}
+	if request.Pool != "" {
+		if filter.Pool, e = normalizeAddress(request.Pool); e != nil {
+			c.JSON(400, gin.H{"msg": "invalid pool"})
+			return nil
+		}
+	}
+	if hub.Subscribers() >= maxSubscribers {
+		c.JSON(503, gin.H{"msg": "too many subscribers"})
+		return nil
+	}
+	return hub.Subscribe(filter)
+}
+
+func feedEvents(hub *feed.Hub, maxSubscribers int) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		subscription := subscribeToFeed(c, hub, maxSubscribers)
+		if subscription == nil {
+			return
+		}
+		defer hub.Unsubscribe(subscription)
+
+		controller := http.NewResponseController(c.Writer)
+		ping := time.NewTicker(feedPingInterval)
+		defer ping.Stop()
+		c.Stream(func(w io.Writer) bool {
+			var name string
+			var message any
+			select {
+			case event := <-subscription.Events:
+				name, message = event.Type, event
+			case <-ping.C:
+				name, message = "ping", time.Now().Unix()
+			case <-c.Request.Context().Done():
+				return false
+			}
+			if e := controller.SetWriteDeadline(time.Now().Add(feedWriteTimeout)); e != nil {
+				log.Printf("Error setting feed write deadline: %v\n", e)
+				return false
+			}
+			c.SSEvent(name, message)
+			// a failed write aborts the context
+			return !c.IsAborted()
+		})
+	}
+}
+
+func feedWebSocket(hub *feed.Hub, maxSubscribers int) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		subscription := subscribeToFeed(c, hub, maxSubscribers)
+		if subscription == nil {
+			return
+		}
+		defer hub.Unsubscribe(subscription)
+
+		conn, e := feedUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if e != nil {
+			log.Printf("Error upgrading feed connection: %v\n", e)
+			return
+		}
+		defer conn.Close()
+
+		// clients only listen, reading detects when they disconnect
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, e := conn.ReadMessage(); e != nil {
+					return
+				}
+			}
+		}()
+
+		ping := time.NewTicker(feedPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case event := <-subscription.Events:
+				if e := conn.SetWriteDeadline(time.Now().Add(feedWriteTimeout)); e != nil {
+					return
+				}
+				if e := conn.WriteJSON(event); e != nil {
+					return
+				}
+			case <-ping.C:
+				if e := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(feedWriteTimeout)); e != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}
+}
+
+// normalizeAddress converts raw or user friendly address into the bounceable form stored in clickhouse
+func normalizeAddress(s string) (string, error) {
+	if addr, e := address.ParseAddr(s); e == nil {
+		return addr.Bounce(true).Testnet(false).String(), nil
+	}
+	addr, e := address.ParseRawAddr(s)
+	if e != nil {
+		return "", e
+	}
+	return addr.String(), nil
+}
+
+// addressForms returns the bounceable form along with every form a wallet may be stored in, dexes report senders differently
+func addressForms(s string) (string, []string, error) {
+	addr, e := address.ParseAddr(s)
+	if e != nil {
+		if addr, e = address.ParseRawAddr(s); e != nil {
+			return "", nil, e
+		}
+	}
+	addr = addr.Testnet(false)
+	bounceable := addr.Bounce(true).String()
+	return bounceable, []string{
+		bounceable,
+		addr.Bounce(false).String(),
+		fmt.Sprintf("%v:%x", addr.Workchain(), addr.Data()),
+	}, nil
+}
+
+func userPortfolio(store persistence.Store) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var request struct {
+			Limit  uint64 `form:"limit,default=50" binding:"max=200"`
+			Offset uint64 `form:"offset"`
+		}
+		if err := c.ShouldBindQuery(&request); err != nil {
+			c.JSON(400, gin.H{"msg": err.Error()})
+			return
+		}
+		wallet, forms, e := addressForms(c.Param("address"))
+		if e != nil {
+			c.JSON(400, gin.H{"msg": "invalid address"})
+			return
+		}
+
+		portfolio, e := store.UserPortfolio(wallet, forms, request.Limit, request.Offset)
+		if e != nil {
+			log.Printf("Error reading portfolio of %v: %v\n", wallet, e)
+			c.JSON(500, gin.H{"msg": e.Error()})
+			return
+		}
+
+		c.JSON(200, portfolio)
+	}
+}
+
+func jettonDetails(store persistence.Store) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var request DexPeriodRequest
+		if err := c.ShouldBindQuery(&request); err != nil {
+			c.JSON(400, gin.H{"msg": err.Error()})
+			return
+		}
+		window, dex, e := windowAndDexFromRequest(request)
+		if e != nil {
+			c.JSON(400, gin.H{"msg": e.Error()})
+			return
+		}
+		master, e := normalizeAddress(c.Param("master"))
+		if e != nil {
+			c.JSON(400, gin.H{"msg": "invalid jetton master address"})
+			return
+		}
+
+		details, e := store.JettonDetails(window, dex, master)
+		if e != nil {
+			log.Printf("Error reading jetton %v: %v\n", master, e)
+			c.JSON(500, gin.H{"msg": e.Error()})
+			return
+		}
+
+		c.JSON(200, details)
+	}
+}
+
+func topPoolsTvl(store persistence.Store) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		var request struct {
 			Limit uint64 `form:"limit"`
+			Dex   string `form:"dex" binding:"omitempty,oneof=all stonfi dedust tonco"`
 		}
 		if err := c.ShouldBindQuery(&request); err != nil {
 			c.JSON(400, gin.H{"msg": err.Error()})
 			return
 		}
+		dex, e := models.ParseDex(request.Dex)
+		if e != nil {
+			c.JSON(400, gin.H{"msg": e.Error()})
+			return
+		}
 
-		swaps, e := persistence.ReadArrayFromClickhouse[persistence.EnrichedArbitrageCH](cfg, persistence.LatestArbitragesSqlQuery(cfg, request.Limit))
+		pools, e := store.TopPoolsTvl(dex, request.Limit)
 		if e != nil {
-			c.JSON(200, gin.H{"msg": e.Error()})
+			c.JSON(500, gin.H{"msg": e.Error()})
 			return
 		}
 
-		c.JSON(200, swaps)
+		c.JSON(200, pools)
+	}
+}
+
+func poolTvlHistory(store persistence.Store) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var request struct {
+			WindowRequest
+			Pool string `form:"pool" binding:"required"`
+		}
+		if err := c.ShouldBindQuery(&request); err != nil {
+			c.JSON(400, gin.H{"msg": err.Error()})
+			return
+		}
+		window, e := windowFromRequest(request.WindowRequest)
+		if e != nil {
+			c.JSON(400, gin.H{"msg": e.Error()})
+			return
+		}
+		pool, e := normalizeAddress(request.Pool)
+		if e != nil {
+			c.JSON(400, gin.H{"msg": "invalid pool address"})
+			return
+		}
+
+		history, e := store.PoolTvlHistory(window, pool)
+		if e != nil {
+			c.JSON(500, gin.H{"msg": e.Error()})
+			return
+		}
+
+		c.JSON(200, history)
 	}
 }
 
-func oneRowPeriodDexRequest[T any](cfg *core.DbConfig, sqlFunc func(cfg *core.DbConfig, period models.Period, dex models.Dex) string) func(c *gin.Context) {
+func poolDetails(store persistence.Store) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var request struct {
+			WindowRequest
+			Limit uint64 `form:"limit,default=20" binding:"max=100"`
+		}
+		if err := c.ShouldBindQuery(&request); err != nil {
+			c.JSON(400, gin.H{"msg": err.Error()})
+			return
+		}
+		window, e := windowFromRequest(request.WindowRequest)
+		if e != nil {
+			c.JSON(400, gin.H{"msg": e.Error()})
+			return
+		}
+		pool, e := normalizeAddress(c.Param("address"))
+		if e != nil {
+			c.JSON(400, gin.H{"msg": "invalid pool address"})
+			return
+		}
+
+		details, e := store.PoolDetails(window, pool, request.Limit)
+		if e != nil {
+			log.Printf("Error reading pool %v: %v\n", pool, e)
+			c.JSON(500, gin.H{"msg": e.Error()})
+			return
+		}
+		if details == nil {
+			c.JSON(404, gin.H{"msg": "pool not found"})
+			return
+		}
+
+		c.JSON(200, details)
+	}
+}
+
+func candles(store persistence.Store) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var request struct {
+			Pool     string `form:"pool"`
+			Base     string `form:"base"`
+			Quote    string `form:"quote"`
+			Interval string `form:"interval" binding:"required,oneof=1m 5m 1h 1d"`
+			From     string `form:"from"`
+			To       string `form:"to"`
+		}
+		if err := c.ShouldBindQuery(&request); err != nil {
+			c.JSON(400, gin.H{"msg": err.Error()})
+			return
+		}
+
+		from, e := core.ParseTime(request.From)
+		if e != nil {
+			c.JSON(400, gin.H{"msg": "invalid from"})
+			return
+		}
+		to, e := core.ParseTime(request.To)
+		if e != nil {
+			c.JSON(400, gin.H{"msg": "invalid to"})
+			return
+		}
+		if to.IsZero() {
+			to = time.Now()
+		}
+		if from.IsZero() {
+			from = to.Add(-500 * persistence.CandleIntervals[request.Interval])
+		}
+		if e := persistence.ValidateCandleRange(request.Interval, from, to); e != nil {
+			c.JSON(400, gin.H{"msg": e.Error()})
+			return
+		}
+
+		var pool, jetton0, jetton1 string
+		inverted := false
+		if request.Pool != "" {
+			if pool, e = normalizeAddress(request.Pool); e != nil {
+				c.JSON(400, gin.H{"msg": "invalid pool address"})
+				return
+			}
+		} else {
+			base, e := normalizeAddress(request.Base)
+			if e != nil {
+				c.JSON(400, gin.H{"msg": "invalid base address"})
+				return
+			}
+			quote, e := normalizeAddress(request.Quote)
+			if e != nil {
+				c.JSON(400, gin.H{"msg": "invalid quote address"})
+				return
+			}
+			jetton0, jetton1 = base, quote
+			if base > quote {
+				jetton0, jetton1, inverted = quote, base, true
+			}
+		}
+
+		result, e := store.Candles(pool, jetton0, jetton1, request.Interval, from, to)
+		if e != nil {
+			c.JSON(500, gin.H{"msg": e.Error()})
+			return
+		}
+		if inverted {
+			for i := range result {
+				result[i].Invert()
+			}
+		}
+
+		c.JSON(200, result)
+	}
+}
+
+func latestIngestionGaps(store persistence.Store) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var request struct {
+			Limit uint64 `form:"limit,default=100" binding:"max=1000"`
+		}
+		if err := c.ShouldBindQuery(&request); err != nil {
+			c.JSON(400, gin.H{"msg": err.Error()})
+			return
+		}
+
+		gaps, e := store.LatestIngestionGaps(request.Limit)
+		if e != nil {
+			c.JSON(500, gin.H{"msg": e.Error()})
+			return
+		}
+
+		c.JSON(200, gaps)
+	}
+}
+
+func oneRowPeriodDexRequest[T any](read func(window models.Window, dex models.Dex) (*T, error)) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		var request DexPeriodRequest
 
@@ -181,13 +725,14 @@ func oneRowPeriodDexRequest[T any](cfg *core.DbConfig, sqlFunc func(cfg *core.Db
 			c.JSON(400, gin.H{"msg": err.Error()})
 			return
 		}
-		period, dex, e := periodAndDexFromRequest(request)
+		window, dex, e := windowAndDexFromRequest(request)
 		if e != nil {
 			log.Printf("Invalid request: %v - %v\n", request.Period, request.Dex)
 			c.JSON(400, gin.H{"msg": e.Error()})
+			return
 		}
 
-		result, e := persistence.ReadSingleRow[T](cfg, sqlFunc(cfg, period, dex))
+		result, e := read(window, dex)
 		if e != nil {
 			log.Printf("Error querying one row: %v\n", e)
 			c.JSON(500, gin.H{"msg": e.Error()})
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"tondexer/feed"
+	"tondexer/models"
+	"tondexer/persistence"
+	"tondexer/pipeline"
+)
+
+const (
+	testPool   = "EQABAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAJYa"
+	testWallet = "EQACAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAH3Q"
+)
+
+func get(router *gin.Engine, path string, result any) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if result != nil {
+		_ = json.Unmarshal(recorder.Body.Bytes(), result)
+	}
+	return recorder
+}
+
+func TestApiOnMemoryStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := persistence.NewMemoryStore()
+	var swaps []*models.SwapCH
+	for lt := uint64(1); lt <= 3; lt++ {
+		swaps = append(swaps, &models.SwapCH{
+			Dex: models.DeDust, Lt: lt, Time: time.Now().Add(-time.Duration(lt) * time.Minute), CatchTime: time.Now(),
+			JettonIn: "ton", AmountIn: big.NewInt(2000000000), JettonInSymbol: "pTON", JettonInUsdRate: 5, JettonInDecimals: 9,
+			JettonOut: "usdt", AmountOut: big.NewInt(10000000), JettonOutSymbol: "USDT", JettonOutUsdRate: 1, JettonOutDecimals: 6,
+			PoolAddress: testPool, Sender: testWallet,
+		})
+	}
+	assert.Nil(t, store.SaveSwaps(swaps))
+	assert.Nil(t, store.SaveTrades(pipeline.BuildTrades(swaps)))
+	router := newRouter(store, feed.NewHub(), 10)
+
+	var summary persistence.SummaryStats
+	assert.Equal(t, 200, get(router, "/api/summary?period=day&dex=dedust", &summary).Code)
+	assert.Equal(t, persistence.SummaryStats{Volume: 30, Number: 3, UniqueTokens: 2, UniqueUsers: 1}, summary)
+
+	var page []persistence.EnrichedSwapCH
+	response := get(router, "/api/swaps/latest?limit=2&sender="+testWallet, &page)
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, 2, len(page))
+	cursor := response.Header().Get("X-Next-Cursor")
+	assert.NotEmpty(t, cursor)
+	assert.Equal(t, "X-Next-Cursor", response.Header().Get("Access-Control-Expose-Headers"))
+	response = get(router, "/api/swaps/latest?limit=2&cursor="+cursor, &page)
+	assert.Equal(t, 1, len(page))
+	assert.Equal(t, uint64(3), page[0].Lt)
+	assert.Empty(t, response.Header().Get("X-Next-Cursor"))
+
+	var portfolio persistence.UserPortfolio
+	assert.Equal(t, 200, get(router, "/api/users/"+testWallet, &portfolio).Code)
+	assert.Equal(t, uint64(3), portfolio.Swaps)
+	assert.Equal(t, 3, len(portfolio.History))
+
+	assert.Equal(t, 200, get(router, "/api/pools/"+testPool+"?period=week", nil).Code)
+	assert.Equal(t, 404, get(router, "/api/pools/"+testWallet+"?period=week", nil).Code)
+	assert.Equal(t, 400, get(router, "/api/summary?period=year", nil).Code)
+	assert.Equal(t, 200, get(router, "/api/ingestion/gaps?limit=1000", nil).Code)
+	assert.Equal(t, 400, get(router, "/api/ingestion/gaps?limit=1001", nil).Code)
+}